@@ -25,18 +25,42 @@ func RandomAction(g core.Game, rng *rand.Rand) (core.Action, core.Game, bool) {
 // Returns the action, resulting game, and true; or (Action{}, game, false) when
 // the game is already over.
 func BasicAIAction(g core.Game, depth int) (core.Action, core.Game, bool) {
+	return BasicAIActionWithEvaluator(g, depth, DefaultEvaluator)
+}
+
+// BasicAIActionWithEvaluator is like BasicAIAction, but scores positions with the
+// given evaluator.
+func BasicAIActionWithEvaluator(g core.Game, depth int, e Evaluator) (core.Action, core.Game, bool) {
+	action, newGame, _, ok := basicAISearch(g, depth, e)
+	return action, newGame, ok
+}
+
+// MateScore is the score, in centipawns, of a position where the side to move
+// mates.
+const MateScore = 100000
+
+// BasicAISearch is like BasicAIAction, but it also returns the chosen action's
+// score in centipawns, from the point of view of the side to move. Mates score
+// ±MateScore.
+func BasicAISearch(g core.Game, depth int) (core.Action, core.Game, int, bool) {
+	return basicAISearch(g, depth, DefaultEvaluator)
+}
+
+func basicAISearch(g core.Game, depth int, e Evaluator) (core.Action, core.Game, int, bool) {
 	nonResign := nonResignActions(g)
 	if len(nonResign) == 0 {
-		return core.Action{}, g, false
+		return core.Action{}, g, 0, false
 	}
 
 	player := int(g.Turn())
 	bestScore := int64(math.MinInt64)
 	bestIdx := 0
 
+	p := core.NewPosition(g)
 	for i, action := range nonResign {
-		newGame := g.DoAction(action)
-		score := alphabeta(newGame, action, player, depth, math.MinInt64, math.MaxInt64)
+		p.MakeMove(action)
+		score := alphabeta(e, p, player, depth, math.MinInt64, math.MaxInt64)
+		p.UnmakeMove()
 		if score > bestScore || (score == bestScore && tieBreakPrefer(action, nonResign[bestIdx])) {
 			bestScore = score
 			bestIdx = i
@@ -44,35 +68,47 @@ func BasicAIAction(g core.Game, depth int) (core.Action, core.Game, bool) {
 	}
 
 	chosen := nonResign[bestIdx]
-	return chosen, g.DoAction(chosen), true
+	return chosen, g.DoAction(chosen), centipawns(bestScore), true
+}
+
+// centipawns converts an evaluate score to centipawns.
+func centipawns(score int64) int {
+	switch {
+	case score >= math.MaxInt64/4:
+		return MateScore
+	case score <= -math.MaxInt64/4:
+		return -MateScore
+	}
+	return int(score / centipawn)
 }
 
 func nonResignActions(g core.Game) []core.Action {
 	out := make([]core.Action, 0, len(g.Actions))
 	for _, a := range g.Actions {
-		if !a.IsResign && !a.IsDraw {
+		if a.IsMove() {
 			out = append(out, a)
 		}
 	}
 	return out
 }
 
-func alphabeta(g core.Game, lastAction core.Action, player int, depth int, alpha, beta int64) int64 {
-	if depth == 0 {
-		return evaluate(g, lastAction, player)
+func alphabeta(e Evaluator, p *core.Position, player int, depth int, alpha, beta int64) int64 {
+	if isEnd, _ := p.VariantEnd(); depth == 0 || isEnd {
+		return evaluatePosition(e, p, player)
 	}
 
-	nonResign := nonResignActions(g)
-	if len(nonResign) == 0 {
-		return evaluate(g, lastAction, player)
+	actions := p.LegalActions()
+	if len(actions) == 0 {
+		return evaluatePosition(e, p, player)
 	}
 
-	if int(g.Turn()) != player {
+	if int(p.Turn()) != player {
 		// Maximizing (our turn next is opponent's perspective → we maximize our score)
 		v := int64(math.MinInt64)
-		for _, action := range nonResign {
-			newGame := g.DoAction(action)
-			score := alphabeta(newGame, action, player, depth-1, alpha, beta)
+		for _, action := range actions {
+			p.MakeMove(action)
+			score := alphabeta(e, p, player, depth-1, alpha, beta)
+			p.UnmakeMove()
 			if score > v {
 				v = score
 			}
@@ -88,9 +124,10 @@ func alphabeta(g core.Game, lastAction core.Action, player int, depth int, alpha
 
 	// Minimizing (opponent's turn)
 	v := int64(math.MaxInt64)
-	for _, action := range nonResign {
-		newGame := g.DoAction(action)
-		score := alphabeta(newGame, action, player, depth-1, alpha, beta)
+	for _, action := range actions {
+		p.MakeMove(action)
+		score := alphabeta(e, p, player, depth-1, alpha, beta)
+		p.UnmakeMove()
 		if score < v {
 			v = score
 		}
@@ -125,55 +162,41 @@ func materialValue(pt core.PieceType) int {
 	return 0
 }
 
-func evaluate(g core.Game, lastAction core.Action, player int) int64 {
-	actionTurn := int(lastAction.FromPiece.Owner)
-	opponent := 1 - player
+// centipawn is a centipawn in evaluate's scale, which leaves room for mate
+// scores far above any evaluation.
+const centipawn = 10_000_000
 
-	// Checkmate is decisive
+// evaluate scores g from the point of view of player.
+func evaluate(e Evaluator, g core.Game, player int) int64 {
+	// Checkmate is decisive: the side to move is mated
 	if g.IsCheckmate {
-		return math.MaxInt64 / 2 * int64(sign(actionTurn, player))
+		return math.MaxInt64 / 2 * int64(-sign(int(g.Turn()), player))
 	}
 	if g.IsStalemate || g.IsDraw {
 		return 0
 	}
+	return int64(e.Evaluate(g)) * centipawn * int64(sign(int(g.Turn()), player))
+}
 
-	// Material
-	playerMaterial, opponentMaterial := 0, 0
-	for _, piece := range g.Pieces(core.Color(player)) {
-		playerMaterial += materialValue(piece.PieceType)
-	}
-	for _, piece := range g.Pieces(core.Color(opponent)) {
-		opponentMaterial += materialValue(piece.PieceType)
-	}
-	totalMaterial := int64(playerMaterial-opponentMaterial) * 1_000_000_000
-
-	// Check bonus
-	checkBonus := int64(0)
-	if g.IsCheck {
-		checkBonus = 100_000_000 * int64(sign(actionTurn, player))
-	}
-
-	// Center control: pieces in the center (files c-f, ranks 4-5)
-	centerPlayer, centerOpponent := 0, 0
-	for _, piece := range g.Pieces(core.Color(player)) {
-		if piece.XY.X >= 2 && piece.XY.X <= 5 && piece.XY.Y >= 3 && piece.XY.Y <= 4 {
-			centerPlayer++
+// evaluatePosition is evaluate for a Position, which doesn't know whether it's
+// over until asked.
+func evaluatePosition(e Evaluator, p *core.Position, player int) int64 {
+	if isEnd, winner := p.VariantEnd(); isEnd {
+		if winner == -1 {
+			return 0
 		}
+		return math.MaxInt64 / 2 * int64(sign(int(winner), player))
 	}
-	for _, piece := range g.Pieces(core.Color(opponent)) {
-		if piece.XY.X >= 2 && piece.XY.X <= 5 && piece.XY.Y >= 3 && piece.XY.Y <= 4 {
-			centerOpponent++
+	if !p.HasLegalActions() {
+		if p.IsCheck() {
+			return math.MaxInt64 / 2 * int64(-sign(int(p.Turn()), player))
 		}
+		return 0
 	}
-	centerBonus := int64(centerPlayer-centerOpponent) * 100
-
-	// Castling incentive
-	castleBonus := int64(0)
-	if lastAction.IsCastle {
-		castleBonus = 100 * int64(sign(actionTurn, player))
+	if p.IsDraw() {
+		return 0
 	}
-
-	return totalMaterial + checkBonus + centerBonus + castleBonus
+	return evaluate(e, p.Snapshot(), player)
 }
 
 // tieBreakPrefer returns true if a should be preferred over b when scores are equal.
@@ -0,0 +1,290 @@
+package ai
+
+import "github.com/marianogappa/cheesse/core"
+
+// Evaluator scores positions at the leaves of a search.
+type Evaluator interface {
+	// Evaluate returns the score of a game that isn't over, in centipawns, from the
+	// point of view of the side to move. Checkmates and draws are scored by the
+	// search itself.
+	//
+	// For speed, g is a core.Position's Snapshot: it has the board, but its derived
+	// fields, like Actions, aren't calculated (IsCheck is).
+	Evaluate(g core.Game) int
+}
+
+// EvaluatorFunc adapts a function to the Evaluator interface.
+type EvaluatorFunc func(g core.Game) int
+
+// Evaluate calls f(g).
+func (f EvaluatorFunc) Evaluate(g core.Game) int {
+	return f(g)
+}
+
+// DefaultEvaluator is the Evaluator used when none is given.
+var DefaultEvaluator Evaluator = TaperedEvaluator{}
+
+// TaperedEvaluator scores material, piece-square tables, pawn structure (passed,
+// doubled and isolated pawns), rooks on open files, the bishop pair, the king's
+// pawn shelter and mobility.
+//
+// Every term has a middlegame and an endgame value, and the score is their
+// average weighted by the game phase, which goes from middlegame to endgame as
+// pieces are traded. E.g. the king should hide behind its pawns in the
+// middlegame, but come to the center in the endgame.
+type TaperedEvaluator struct{}
+
+// Weights of the pieces left on the board in the game phase: all of them (24)
+// means middlegame, and none means endgame.
+const totalPhase = 24
+
+var phaseWeights = [7]int{core.PieceQueen: 4, core.PieceRook: 2, core.PieceBishop: 1, core.PieceKnight: 1}
+
+var (
+	materialMg = [7]int{core.PieceQueen: 900, core.PieceBishop: 330, core.PieceKnight: 320, core.PieceRook: 500, core.PiecePawn: 100}
+	materialEg = [7]int{core.PieceQueen: 930, core.PieceBishop: 310, core.PieceKnight: 290, core.PieceRook: 520, core.PiecePawn: 120}
+)
+
+// Mobility: the bonus per attacked square, over the given average number of
+// attacked squares.
+var (
+	mobilityMg      = [7]int{core.PieceQueen: 1, core.PieceBishop: 5, core.PieceKnight: 4, core.PieceRook: 2}
+	mobilityEg      = [7]int{core.PieceQueen: 2, core.PieceBishop: 5, core.PieceKnight: 4, core.PieceRook: 4}
+	mobilityAverage = [7]int{core.PieceQueen: 14, core.PieceBishop: 7, core.PieceKnight: 4, core.PieceRook: 7}
+)
+
+// Passed pawn bonuses by rank, from the pawn's side of the board.
+var (
+	passedPawnMg = [8]int{0, 5, 10, 15, 30, 50, 80, 0}
+	passedPawnEg = [8]int{0, 10, 15, 25, 45, 75, 120, 0}
+)
+
+const (
+	doubledPawnMg, doubledPawnEg       = -10, -20
+	isolatedPawnMg, isolatedPawnEg     = -10, -15
+	openFileRookMg, openFileRookEg     = 20, 10
+	semiOpenFileRookMg, semiOpenRookEg = 10, 5
+	bishopPairMg, bishopPairEg         = 30, 50
+	// Middlegame bonuses per pawn in front of the king, one and two ranks ahead.
+	kingShelterNear, kingShelterFar = 10, 5
+)
+
+// Piece-square tables, from white's point of view, with a8 first (i.e. as
+// the board is seen by white). Indexed by the squares of core's XY: y*8+x.
+var (
+	pawnTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	}
+	knightTable = [64]int{
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50,
+	}
+	bishopTable = [64]int{
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20,
+	}
+	rookTable = [64]int{
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, 10, 10, 10, 10, 5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		0, 0, 0, 5, 5, 0, 0, 0,
+	}
+	queenTable = [64]int{
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20,
+	}
+	kingMgTable = [64]int{
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		20, 30, 10, 0, 0, 10, 30, 20,
+	}
+	kingEgTable = [64]int{
+		-50, -40, -30, -20, -20, -30, -40, -50,
+		-30, -20, -10, 0, 0, -10, -20, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 30, 40, 40, 30, -10, -30,
+		-30, -10, 20, 30, 30, 20, -10, -30,
+		-30, -30, 0, 0, 0, 0, -30, -30,
+		-50, -30, -30, -30, -30, -30, -30, -50,
+	}
+
+	pieceSquareMg = [7]*[64]int{core.PieceQueen: &queenTable, core.PieceKing: &kingMgTable, core.PieceBishop: &bishopTable, core.PieceKnight: &knightTable, core.PieceRook: &rookTable, core.PiecePawn: &pawnTable}
+	pieceSquareEg = [7]*[64]int{core.PieceQueen: &queenTable, core.PieceKing: &kingEgTable, core.PieceBishop: &bishopTable, core.PieceKnight: &knightTable, core.PieceRook: &rookTable, core.PiecePawn: &pawnTable}
+)
+
+// Evaluate implements Evaluator.
+func (TaperedEvaluator) Evaluate(g core.Game) int {
+	var (
+		mg, eg  [2]int
+		phase   int
+		bishops [2]int
+		// pawnRanks[color][x] has the bit y set when there's a pawn on x, y.
+		pawnRanks [2][8]uint8
+		pieces    = append(g.Pieces(core.ColorBlack), g.Pieces(core.ColorWhite)...)
+	)
+	for _, p := range pieces {
+		phase += phaseWeights[p.PieceType]
+		switch p.PieceType {
+		case core.PiecePawn:
+			pawnRanks[p.Owner][p.XY.X] |= 1 << p.XY.Y
+		case core.PieceBishop:
+			bishops[p.Owner]++
+		}
+	}
+
+	for _, p := range pieces {
+		c, opponent := p.Owner, p.Owner.Opponent()
+		sq := relativeSquare(p)
+		mg[c] += materialMg[p.PieceType] + pieceSquareMg[p.PieceType][sq]
+		eg[c] += materialEg[p.PieceType] + pieceSquareEg[p.PieceType][sq]
+
+		switch p.PieceType {
+		case core.PiecePawn:
+			mgBonus, egBonus := pawnStructure(p, pawnRanks)
+			mg[c] += mgBonus
+			eg[c] += egBonus
+		case core.PieceRook:
+			switch {
+			case pawnRanks[c][p.XY.X] == 0 && pawnRanks[opponent][p.XY.X] == 0:
+				mg[c] += openFileRookMg
+				eg[c] += openFileRookEg
+			case pawnRanks[c][p.XY.X] == 0:
+				mg[c] += semiOpenFileRookMg
+				eg[c] += semiOpenRookEg
+			}
+		case core.PieceKing:
+			mg[c] += kingShelter(p, pawnRanks[c])
+		}
+		if mobilityAverage[p.PieceType] > 0 {
+			mobility := g.Mobility(p.XY) - mobilityAverage[p.PieceType]
+			mg[c] += mobility * mobilityMg[p.PieceType]
+			eg[c] += mobility * mobilityEg[p.PieceType]
+		}
+	}
+	for c := range bishops {
+		if bishops[c] >= 2 {
+			mg[c] += bishopPairMg
+			eg[c] += bishopPairEg
+		}
+	}
+
+	if phase > totalPhase {
+		phase = totalPhase
+	}
+	white, black := core.ColorWhite, core.ColorBlack
+	score := ((mg[white]-mg[black])*phase + (eg[white]-eg[black])*(totalPhase-phase)) / totalPhase
+	if g.Turn() == core.ColorBlack {
+		return -score
+	}
+	return score
+}
+
+// relativeSquare returns the piece's square index in the piece-square tables,
+// which are from white's point of view.
+func relativeSquare(p core.Piece) int {
+	if p.Owner == core.ColorBlack {
+		return (7-p.XY.Y)*8 + p.XY.X
+	}
+	return p.XY.Y*8 + p.XY.X
+}
+
+// pawnStructure returns the middlegame and endgame bonuses of a pawn for being
+// passed, doubled or isolated.
+func pawnStructure(p core.Piece, pawnRanks [2][8]uint8) (int, int) {
+	var (
+		mg, eg       int
+		c, opponent  = p.Owner, p.Owner.Opponent()
+		x, y         = p.XY.X, p.XY.Y
+		ahead        uint8
+		hasNeighbour bool
+		isPassed     = true
+		rank         = 7 - y
+	)
+	// Ranks ahead of the pawn: white pawns move towards y = 0
+	if c == core.ColorWhite {
+		ahead = 1<<y - 1
+	} else {
+		ahead = ^uint8(1<<(y+1) - 1)
+		rank = y
+	}
+	for file := x - 1; file <= x+1; file++ {
+		if file < 0 || file > 7 {
+			continue
+		}
+		if pawnRanks[opponent][file]&ahead != 0 {
+			isPassed = false
+		}
+		if file != x && pawnRanks[c][file] != 0 {
+			hasNeighbour = true
+		}
+	}
+	if isPassed && pawnRanks[c][x]&ahead == 0 {
+		mg += passedPawnMg[rank]
+		eg += passedPawnEg[rank]
+	}
+	if pawnRanks[c][x]&ahead != 0 {
+		// Only the pawns behind another one are doubled
+		mg += doubledPawnMg
+		eg += doubledPawnEg
+	}
+	if !hasNeighbour {
+		mg += isolatedPawnMg
+		eg += isolatedPawnEg
+	}
+	return mg, eg
+}
+
+// kingShelter returns the middlegame bonus of a king for the pawns in front of it.
+func kingShelter(king core.Piece, pawnRanks [8]uint8) int {
+	forward := -1
+	if king.Owner == core.ColorBlack {
+		forward = 1
+	}
+	bonus := 0
+	for file := king.XY.X - 1; file <= king.XY.X+1; file++ {
+		if file < 0 || file > 7 {
+			continue
+		}
+		if y := king.XY.Y + forward; y >= 0 && y <= 7 && pawnRanks[file]&(1<<y) != 0 {
+			bonus += kingShelterNear
+		} else if y := king.XY.Y + 2*forward; y >= 0 && y <= 7 && pawnRanks[file]&(1<<y) != 0 {
+			bonus += kingShelterFar
+		}
+	}
+	return bonus
+}
@@ -0,0 +1,133 @@
+package ai
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mirrorFEN flips the board vertically and swaps the colors, which must not
+// change the evaluation from the side to move's point of view.
+func mirrorFEN(fen string) string {
+	fields := strings.Fields(fen)
+	ranks := strings.Split(fields[0], "/")
+	for i, j := 0, len(ranks)-1; i < j; i, j = i+1, j-1 {
+		ranks[i], ranks[j] = ranks[j], ranks[i]
+	}
+	swapCase := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			case r >= 'A' && r <= 'Z':
+				return r - 'A' + 'a'
+			}
+			return r
+		}, s)
+	}
+	fields[0] = swapCase(strings.Join(ranks, "/"))
+	fields[1] = map[string]string{"w": "b", "b": "w"}[fields[1]]
+	fields[2] = swapCase(fields[2])
+	if fields[3] != "-" {
+		fields[3] = fields[3][:1] + map[byte]string{'3': "6", '6': "3"}[fields[3][1]]
+	}
+	return strings.Join(fields, " ")
+}
+
+func evaluateFEN(t *testing.T, fen string) int {
+	t.Helper()
+	g, err := core.NewGameFromFEN(fen)
+	require.NoError(t, err)
+	return TaperedEvaluator{}.Evaluate(g)
+}
+
+func TestTaperedEvaluator_Symmetry(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		"r1bqk2r/pppp1ppp/2n2n2/2b1p3/2B1P3/2NP1N2/PPP2PPP/R1BQK2R w KQkq - 1 5",
+		"r2q1rk1/pp2bppp/2n1pn2/3p4/3P4/2NBPN2/PP3PPP/R2Q1RK1 w - - 0 10",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		"8/5pk1/6p1/8/3P4/8/5PPP/6K1 b - - 0 40",
+	}
+	for _, fen := range fens {
+		t.Run(fen, func(t *testing.T) {
+			assert.Equal(t, evaluateFEN(t, fen), evaluateFEN(t, mirrorFEN(fen)))
+		})
+	}
+	assert.Equal(t, 0, evaluateFEN(t, fens[0]), "the initial position is balanced")
+}
+
+func TestTaperedEvaluator_Terms(t *testing.T) {
+	ts := []struct {
+		name          string
+		better, worse string
+	}{
+		{
+			name:   "passed pawns",
+			better: "4k3/8/8/3P4/8/8/8/4K3 w - - 0 1",
+			worse:  "4k3/3p4/8/3P4/8/8/8/4K3 w - - 0 1",
+		},
+		{
+			name:   "doubled pawns",
+			better: "4k3/pp6/8/8/8/8/PP6/4K3 w - - 0 1",
+			worse:  "4k3/pp6/8/8/8/P7/P7/4K3 w - - 0 1",
+		},
+		{
+			name:   "isolated pawns",
+			better: "4k3/ppp5/8/8/8/8/PP6/4K3 w - - 0 1",
+			worse:  "4k3/ppp5/8/8/8/8/P1P5/4K3 w - - 0 1",
+		},
+		{
+			name:   "rook on an open file",
+			better: "4k3/ppp5/8/8/8/8/PPP5/3RK3 w - - 0 1",
+			worse:  "4k3/ppp5/8/8/8/8/PPP5/2R1K3 w - - 0 1",
+		},
+		{
+			name:   "bishop pair",
+			better: "4k3/8/8/8/8/8/8/2B1KB2 w - - 0 1",
+			worse:  "4k3/8/8/8/8/8/8/2B1KN2 w - - 0 1",
+		},
+		{
+			name:   "king shelter in the middlegame",
+			better: "r2qkb1r/pppbnppp/2n5/8/8/2N5/PPPQBPPP/R1B2RK1 w kq - 0 1",
+			worse:  "r2qkb1r/pppbnppp/2n5/8/8/2N3PP/PPPQBP2/R1B2RK1 w kq - 0 1",
+		},
+		{
+			name:   "central king in the endgame",
+			better: "4k3/pp6/8/8/3K4/8/PP6/8 w - - 0 1",
+			worse:  "4k3/pp6/8/8/8/8/PP6/7K w - - 0 1",
+		},
+		{
+			name:   "mobility",
+			better: "4k3/8/8/8/3N4/8/8/4K3 w - - 0 1",
+			worse:  "4k3/8/8/8/8/8/8/N3K3 w - - 0 1",
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Greater(t, evaluateFEN(t, tc.better), evaluateFEN(t, tc.worse))
+		})
+	}
+}
+
+func TestBasicAIActionWithEvaluator(t *testing.T) {
+	g, err := core.NewGameFromFEN("4k3/p7/8/8/8/8/P7/4K3 w - - 0 1")
+	require.NoError(t, err)
+	// Only cares about the white king reaching f1
+	e := EvaluatorFunc(func(g core.Game) int {
+		score := 0
+		if g.King(core.ColorWhite).XY == (core.XY{X: 5, Y: 7}) {
+			score = 1000
+		}
+		if g.Turn() == core.ColorBlack {
+			return -score
+		}
+		return score
+	})
+	action, _, ok := BasicAIActionWithEvaluator(g, 0, e)
+	require.True(t, ok)
+	assert.Equal(t, core.XY{X: 5, Y: 7}, action.ToXY)
+}
@@ -0,0 +1,614 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/marianogappa/cheesse/core"
+)
+
+// maxSearchDepth is the deepest Search, in plies, when Limits has no Depth.
+const maxSearchDepth = 64
+
+// maxPly is the deepest a search goes, in plies, including check extensions and
+// the quiescence search.
+const maxPly = 2 * maxSearchDepth
+
+// mateValue is the evaluate-scale score of mating on the spot; mates further
+// away score one less per ply.
+const mateValue = math.MaxInt64 / 2
+
+// Limits bound a Search. Zero values mean no limit, but a Search without any
+// limit only ends when its context is done.
+type Limits struct {
+	// Depth is the maximum depth, in plies.
+	Depth int
+	// Time is the maximum duration of the search.
+	Time time.Duration
+	// Nodes is the maximum number of positions to visit.
+	Nodes int64
+	// Tablebase, if not nil, is trusted for the outcome of the positions it covers:
+	// at the root, only the actions that keep the best outcome are searched, and in
+	// the tree, positions right after a capture or a pawn move are probed.
+	Tablebase Tablebase
+}
+
+// SearchResult is the outcome of a Search's deepest completed iteration.
+type SearchResult struct {
+	// Action is the best action for the side to move, and Game the game after it.
+	Action core.Action
+	Game   core.Game
+	// PV is the principal variation: the expected line of play, starting with
+	// Action.
+	PV []core.Action
+	// Score is the PV's score in centipawns, from the point of view of the side to
+	// move. Mates score ±MateScore.
+	Score int
+	// Mate is the number of moves to mate when Score is a mate: positive when
+	// the side to move mates, negative when it gets mated.
+	Mate int
+	// Depth is the depth of the iteration, in plies.
+	Depth int
+	// Nodes is the number of positions visited by the whole search so far.
+	Nodes int64
+	// Time is the duration of the whole search so far.
+	Time time.Duration
+}
+
+// searcher holds the state of a Search.
+type searcher struct {
+	ctx     context.Context
+	tt      *TranspositionTable
+	eval    Evaluator
+	limits  Limits
+	nodes   int64
+	stopped bool
+	// mustComplete makes the search ignore ctx and limits, so that the first
+	// iteration always completes.
+	mustComplete bool
+	// killers are, by ply, the last two quiet actions that caused a beta cutoff.
+	// A quiet action that refutes a line is likely to refute its siblings too.
+	killers [maxPly][2]core.Action
+	// history scores quiet actions, by color and from/to squares, according to
+	// how often and how deep they caused a beta cutoff anywhere in the tree.
+	history [2][64][64]int
+}
+
+// Search finds the best action for the side to move by iterative deepening: it
+// runs an alpha-beta search one ply deeper each time, until ctx is done or a limit
+// is reached, and returns the result of the last completed iteration. A search
+// also ends early once it finds a forced mate, as deeper iterations can't improve
+// on it.
+//
+// The first iteration always completes, so a result is returned even when ctx is
+// already done. If progress isn't nil, it's called with the result of every
+// completed iteration.
+//
+// Returns false when the game is already over.
+func Search(ctx context.Context, g core.Game, limits Limits, progress func(SearchResult)) (SearchResult, bool) {
+	return SearchWithTable(ctx, NewTranspositionTable(DefaultTranspositionTableSizeMB), g, limits, progress)
+}
+
+// SearchWithTable is like Search, but uses the given transposition table, e.g. to
+// reuse what previous searches learned along a game.
+func SearchWithTable(ctx context.Context, tt *TranspositionTable, g core.Game, limits Limits, progress func(SearchResult)) (SearchResult, bool) {
+	var onIteration func([]SearchResult)
+	if progress != nil {
+		onIteration = func(results []SearchResult) { progress(results[0]) }
+	}
+	results, ok := search(ctx, tt, g, limits, 1, onIteration)
+	if !ok {
+		return SearchResult{Game: g}, false
+	}
+	return results[0], true
+}
+
+// Analyze is like Search, but finds the given number of best actions (multi-PV),
+// each with its own PV and score, best first. Fewer results are returned when
+// there are fewer actions.
+//
+// Each iteration searches the best action first, then the best of the remaining
+// ones, and so on, so analyzing n lines takes about n times as long as a Search.
+func Analyze(ctx context.Context, g core.Game, limits Limits, lines int) ([]SearchResult, bool) {
+	return search(ctx, NewTranspositionTable(DefaultTranspositionTableSizeMB), g, limits, lines, nil)
+}
+
+// search runs the iterative deepening of Search and Analyze for the given number
+// of lines, calling onIteration with the results of every completed iteration.
+func search(ctx context.Context, tt *TranspositionTable, g core.Game, limits Limits, lines int, onIteration func([]SearchResult)) ([]SearchResult, bool) {
+	nonResign := nonResignActions(g)
+	if len(nonResign) == 0 {
+		return nil, false
+	}
+	if lines < 1 {
+		lines = 1
+	}
+	if lines > len(nonResign) {
+		lines = len(nonResign)
+	}
+	if limits.Time > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.Time)
+		defer cancel()
+	}
+	maxDepth := limits.Depth
+	if maxDepth <= 0 {
+		maxDepth = maxSearchDepth
+	}
+
+	var (
+		s       = &searcher{ctx: ctx, tt: tt, eval: DefaultEvaluator, limits: limits}
+		p       = core.NewPosition(g)
+		start   = time.Now()
+		results []SearchResult
+	)
+	var tbRoot *tablebaseRoot
+	if limits.Tablebase != nil {
+		tbRoot, _ = newTablebaseRoot(g, limits.Tablebase, nonResign)
+	}
+	tt.newSearch()
+	for depth := 1; depth <= maxDepth; depth++ {
+		s.mustComplete = depth == 1
+		iteration := make([]SearchResult, 0, lines)
+		remaining := nonResign
+		for line := 0; line < lines; line++ {
+			var previousPV []core.Action
+			if line < len(results) {
+				previousPV = results[line].PV
+			}
+			candidates := remaining
+			if tbRoot != nil {
+				candidates = tbRoot.best(remaining)
+			}
+			score, pv := s.searchRoot(p, candidates, depth, previousPV)
+			if s.stopped {
+				break
+			}
+			pv = s.extendPV(g, pv, depth)
+			result := SearchResult{
+				Action: pv[0],
+				Game:   g.DoAction(pv[0]),
+				PV:     pv,
+				Score:  centipawns(score),
+				Mate:   mateMoves(score),
+				Depth:  depth,
+			}
+			if tbRoot != nil && result.Mate == 0 {
+				// The tablebase knows better than a search that didn't find a mate
+				result.Score = tbRoot.scores[pv[0]]
+			}
+			iteration = append(iteration, result)
+			remaining = withoutAction(remaining, pv[0])
+		}
+		if s.stopped {
+			break
+		}
+		for i := range iteration {
+			iteration[i].Nodes, iteration[i].Time = s.nodes, time.Since(start)
+		}
+		results = iteration
+		if onIteration != nil {
+			onIteration(results)
+		}
+		if allMates(results) {
+			break
+		}
+	}
+	return results, true
+}
+
+// withoutAction returns a copy of actions without the given one.
+func withoutAction(actions []core.Action, action core.Action) []core.Action {
+	out := make([]core.Action, 0, len(actions))
+	for _, a := range actions {
+		if a != action {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// allMates returns whether every result is a forced mate, which deeper
+// iterations can't improve on.
+func allMates(results []SearchResult) bool {
+	for _, result := range results {
+		if result.Mate == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// searchRoot runs one iteration of the search to the given depth. The previous
+// iteration's PV is searched first, as it's likely still best, which makes the
+// alpha-beta pruning more effective.
+func (s *searcher) searchRoot(p *core.Position, actions []core.Action, depth int, previousPV []core.Action) (int64, []core.Action) {
+	var first core.Action
+	if len(previousPV) > 0 {
+		first = previousPV[0]
+	}
+	ordered := s.orderActions(actions, 0, first)
+	var (
+		bestScore = int64(math.MinInt64)
+		bestPV    []core.Action
+		alpha     = int64(-math.MaxInt64)
+		beta      = int64(math.MaxInt64)
+	)
+	for i, action := range ordered {
+		var childPV []core.Action
+		if i == 0 && len(previousPV) > 1 && action == previousPV[0] {
+			childPV = previousPV[1:]
+		}
+		p.MakeMove(action)
+		score, pv := s.negamax(p, childDepth(action, depth, 0), 1, -beta, -alpha, childPV)
+		p.UnmakeMove()
+		score = -score
+		if s.stopped {
+			return bestScore, bestPV
+		}
+		if score > bestScore {
+			bestScore = score
+			bestPV = append([]core.Action{action}, pv...)
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+	return bestScore, bestPV
+}
+
+// childDepth returns the depth to search the position after the given action to,
+// at the given ply: one ply less, except after promotions to a queen. Like checks,
+// they're searched one ply deeper, so that promoting right away doesn't look worse
+// than a quiet move and then promoting at the horizon, which the opponent gets no
+// time to answer.
+func childDepth(action core.Action, depth, ply int) int {
+	if action.IsPromotion && action.PromotionPieceType == core.PieceQueen && ply < maxPly {
+		return depth
+	}
+	return depth - 1
+}
+
+// variantEndScore returns the score of p from the point of view of its side to
+// move, if the rules of its variant ended the game (see Position.VariantEnd).
+func variantEndScore(p *core.Position, ply int) (int64, bool) {
+	isEnd, winner := p.VariantEnd()
+	switch {
+	case !isEnd:
+		return 0, false
+	case winner == -1:
+		return 0, true
+	case winner == p.Turn():
+		return mateValue - int64(ply), true
+	}
+	return -(mateValue - int64(ply)), true
+}
+
+// negamax returns the score of p from the point of view of its side to move,
+// searched to the given depth, along with the line that leads to it.
+func (s *searcher) negamax(p *core.Position, depth, ply int, alpha, beta int64, previousPV []core.Action) (int64, []core.Action) {
+	s.nodes++
+	if s.shouldStop() {
+		return 0, nil
+	}
+	if score, isEnd := variantEndScore(p, ply); isEnd {
+		return score, nil
+	}
+
+	actions := p.LegalActions()
+	isCheck := p.IsCheck()
+	if len(actions) == 0 {
+		if isCheck {
+			return -(mateValue - int64(ply)), nil
+		}
+		return 0, nil
+	}
+	if p.IsDraw() {
+		return 0, nil
+	}
+	if s.limits.Tablebase != nil && p.HalfMoveClock() == 0 && p.PieceCount() <= maxTablebasePieces {
+		if wdl, err := s.limits.Tablebase.ProbeWDL(p.Game()); err == nil {
+			return tablebaseScore(wdl, ply), nil
+		}
+	}
+	if isCheck && ply < maxPly {
+		// Check extension: the replies to a check are few and forcing, so they're
+		// searched one ply deeper rather than cut short by the horizon.
+		depth++
+	}
+	if depth <= 0 || ply >= maxPly {
+		return s.quiesce(p, ply, alpha, beta)
+	}
+
+	key, originalAlpha := p.Hash(), alpha
+	first := core.Action{}
+	if len(previousPV) > 0 {
+		first = previousPV[0]
+	}
+	if entry, ok := s.tt.probe(key, ply); ok {
+		if first == (core.Action{}) {
+			first = entry.bestAction
+		}
+		if int(entry.depth) >= depth {
+			switch entry.bound {
+			case boundExact:
+				return entry.score, ttPV(entry)
+			case boundLower:
+				alpha = maxInt64(alpha, entry.score)
+			case boundUpper:
+				beta = minInt64(beta, entry.score)
+			}
+			if alpha >= beta {
+				return entry.score, ttPV(entry)
+			}
+		}
+	}
+
+	var (
+		bestScore = int64(math.MinInt64)
+		bestPV    []core.Action
+	)
+	for i, action := range s.orderActions(actions, ply, first) {
+		var childPV []core.Action
+		if i == 0 && len(previousPV) > 1 {
+			childPV = previousPV[1:]
+		}
+		p.MakeMove(action)
+		score, pv := s.negamax(p, childDepth(action, depth, ply), ply+1, -beta, -alpha, childPV)
+		p.UnmakeMove()
+		score = -score
+		if s.stopped {
+			return 0, nil
+		}
+		if score > bestScore {
+			bestScore = score
+			bestPV = append([]core.Action{action}, pv...)
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			s.recordCutoff(action, ply, depth)
+			break
+		}
+	}
+
+	b := boundExact
+	switch {
+	case bestScore <= originalAlpha:
+		b = boundUpper
+	case bestScore >= beta:
+		b = boundLower
+	}
+	s.tt.store(key, ply, depth, bestScore, b, bestPV[0])
+	return bestScore, bestPV
+}
+
+// quiesce returns the score of p from the point of view of its side to move once
+// the position is quiet, i.e. after resolving captures, so that the search doesn't
+// stop, say, right after a queen captures a defended pawn. The side to move may also
+// "stand pat" and keep the static evaluation, as it's not forced to capture, unless
+// it's in check. Promotions that aren't captures are left to the search, which
+// extends them (see childDepth).
+func (s *searcher) quiesce(p *core.Position, ply int, alpha, beta int64) (int64, []core.Action) {
+	s.nodes++
+	if s.shouldStop() {
+		return 0, nil
+	}
+	if score, isEnd := variantEndScore(p, ply); isEnd {
+		return score, nil
+	}
+
+	actions := p.LegalActions()
+	isCheck := p.IsCheck()
+	if len(actions) == 0 {
+		if isCheck {
+			return -(mateValue - int64(ply)), nil
+		}
+		return 0, nil
+	}
+	if p.IsDraw() {
+		return 0, nil
+	}
+
+	var (
+		bestScore = int64(math.MinInt64)
+		bestPV    []core.Action
+	)
+	if !isCheck || ply >= maxPly {
+		bestScore = evaluate(s.eval, p.Snapshot(), int(p.Turn()))
+		if bestScore >= beta || ply >= maxPly {
+			return bestScore, nil
+		}
+		alpha = maxInt64(alpha, bestScore)
+	}
+
+	for _, action := range s.orderActions(actions, ply, core.Action{}) {
+		if !isCheck && !isTactical(action) {
+			// Tactical actions are sorted first
+			break
+		}
+		if !isCheck && !action.IsCapture && !action.IsEnPassantCapture {
+			continue // A promotion
+		}
+		p.MakeMove(action)
+		score, pv := s.quiesce(p, ply+1, -beta, -alpha)
+		p.UnmakeMove()
+		score = -score
+		if s.stopped {
+			return 0, nil
+		}
+		if score > bestScore {
+			bestScore = score
+			bestPV = append([]core.Action{action}, pv...)
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return bestScore, bestPV
+}
+
+// recordCutoff updates the killers and the history with a beta cutoff.
+func (s *searcher) recordCutoff(action core.Action, ply, depth int) {
+	if isTactical(action) {
+		return
+	}
+	if s.killers[ply][0] != action {
+		s.killers[ply][1] = s.killers[ply][0]
+		s.killers[ply][0] = action
+	}
+	from, to := squareIndex(action.FromPiece.XY), squareIndex(action.ToXY)
+	s.history[action.FromPiece.Owner][from][to] += depth * depth
+}
+
+// ttPV returns the PV of a transposition table hit: only its best action is known.
+func ttPV(entry ttEntry) []core.Action {
+	if entry.bestAction == (core.Action{}) {
+		return nil
+	}
+	return []core.Action{entry.bestAction}
+}
+
+// extendPV completes a PV cut short by transposition table hits with the best
+// actions stored in the table, up to the given depth.
+func (s *searcher) extendPV(g core.Game, pv []core.Action, depth int) []core.Action {
+	for _, action := range pv {
+		g = g.DoAction(action)
+	}
+	for len(pv) < depth {
+		entry, ok := s.tt.probe(g.Hash(), len(pv))
+		if !ok || !isLegal(g, entry.bestAction) {
+			break
+		}
+		pv = append(pv, entry.bestAction)
+		g = g.DoAction(entry.bestAction)
+	}
+	return pv
+}
+
+// isLegal returns whether the action can be done in the game. A transposition
+// table's action may not be, if two positions share a hash.
+func isLegal(g core.Game, action core.Action) bool {
+	for _, a := range nonResignActions(g) {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldStop returns whether the search must stop, because its context is done
+// or it ran out of nodes.
+func (s *searcher) shouldStop() bool {
+	if !s.stopped && !s.mustComplete {
+		s.stopped = s.ctx.Err() != nil || (s.limits.Nodes > 0 && s.nodes >= s.limits.Nodes)
+	}
+	return s.stopped
+}
+
+// Action ordering scores: the given first action, then tactical actions by
+// MVV-LVA, then killers, then quiet actions by history.
+const (
+	orderFirst    = 1 << 30
+	orderTactical = 1 << 29
+	orderKiller   = 1 << 28
+)
+
+// orderActions sorts actions so that the likely best are searched first, which
+// makes alpha-beta prune more: the given first action (e.g. the previous
+// iteration's PV action, or the transposition table's), then captures and
+// promotions, most valuable victim first and least valuable attacker first
+// (MVV-LVA), then the ply's killers, and then quiet actions by history.
+func (s *searcher) orderActions(actions []core.Action, ply int, first core.Action) []core.Action {
+	type scoredAction struct {
+		action core.Action
+		score  int
+	}
+	scored := make([]scoredAction, len(actions))
+	for i, action := range actions {
+		scored[i] = scoredAction{action, s.orderScore(action, ply, first)}
+	}
+	sort.SliceStable(scored, func(i, j int) bool { return scored[i].score > scored[j].score })
+	ordered := make([]core.Action, len(actions))
+	for i := range scored {
+		ordered[i] = scored[i].action
+	}
+	return ordered
+}
+
+func (s *searcher) orderScore(action core.Action, ply int, first core.Action) int {
+	switch {
+	case action == first:
+		return orderFirst
+	case isTactical(action):
+		return orderTactical + mvvLva(action)
+	case action == s.killers[ply][0]:
+		return orderKiller + 1
+	case action == s.killers[ply][1]:
+		return orderKiller
+	}
+	from, to := squareIndex(action.FromPiece.XY), squareIndex(action.ToXY)
+	if h := s.history[action.FromPiece.Owner][from][to]; h < orderKiller {
+		return h
+	}
+	return orderKiller - 1
+}
+
+// isTactical returns whether an action changes the material: captures and
+// promotions.
+func isTactical(a core.Action) bool {
+	return a.IsCapture || a.IsEnPassantCapture || a.IsPromotion
+}
+
+// mvvLva scores a tactical action by its most valuable victim (and promotion),
+// and then by its least valuable attacker.
+func mvvLva(a core.Action) int {
+	victim := 0
+	if a.IsCapture || a.IsEnPassantCapture {
+		victim = materialValue(a.CapturedPiece.PieceType)
+	}
+	if a.IsPromotion {
+		victim += materialValue(a.PromotionPieceType) - 1
+	}
+	attacker := materialValue(a.FromPiece.PieceType)
+	if a.FromPiece.PieceType == core.PieceKing {
+		attacker = 10
+	}
+	return victim*16 - attacker
+}
+
+func squareIndex(xy core.XY) int {
+	return int(xy.Y)*8 + int(xy.X)
+}
+
+// mateMoves returns the number of moves to mate of a score, as in
+// SearchResult.Mate.
+func mateMoves(score int64) int {
+	switch {
+	case score >= math.MaxInt64/4:
+		return int(mateValue-score+1) / 2
+	case score <= -math.MaxInt64/4:
+		return -int(mateValue+score) / 2
+	}
+	return 0
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
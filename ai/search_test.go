@@ -0,0 +1,267 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/epd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearch_MateInOne(t *testing.T) {
+	g, err := core.NewGameFromFEN("r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 3")
+	require.NoError(t, err)
+	result, ok := Search(context.Background(), g, Limits{Depth: 4}, nil)
+	require.True(t, ok)
+	assert.True(t, result.Game.IsCheckmate)
+	assert.Equal(t, 1, result.Mate)
+	assert.Equal(t, MateScore, result.Score)
+	assert.Equal(t, 1, result.Depth, "stops deepening once mate is found")
+	assert.Equal(t, []core.Action{result.Action}, result.PV)
+}
+
+func TestSearch_MateInTwo(t *testing.T) {
+	// 1. Re8+ Rxe8 2. Rxe8#
+	g, err := core.NewGameFromFEN("3r2k1/5ppp/8/8/8/8/4RPPP/4R1K1 w - - 0 1")
+	require.NoError(t, err)
+	result, ok := Search(context.Background(), g, Limits{Depth: 4}, nil)
+	require.True(t, ok)
+	assert.Equal(t, 2, result.Mate)
+	assert.Equal(t, 1, result.Depth, "the check extension and the quiescence search see the mate")
+	require.Len(t, result.PV, 3)
+	game := g
+	for _, action := range result.PV {
+		game = game.DoAction(action)
+	}
+	assert.True(t, game.IsCheckmate, "the PV ends in mate")
+}
+
+func TestSearch_GettingMated(t *testing.T) {
+	// Any black move allows Qh5-f7#, except those that defend f7
+	g, err := core.NewGameFromFEN("6rk/6pp/8/8/8/8/6PP/R5K1 b - - 0 1")
+	require.NoError(t, err)
+	result, ok := Search(context.Background(), g, Limits{Depth: 2}, nil)
+	require.True(t, ok)
+	assert.Equal(t, 0, result.Mate, "h6 or g6 avoid the back rank mate")
+
+	g, err = core.NewGameFromFEN("7k/6pp/8/8/8/8/r5PP/R5K1 b - - 0 1")
+	require.NoError(t, err)
+	result, ok = Search(context.Background(), g, Limits{Depth: 1}, nil)
+	require.True(t, ok)
+	assert.Equal(t, 0, result.Mate)
+}
+
+func TestSearch_ProgressReportsEveryIteration(t *testing.T) {
+	g := core.NewDefaultGame()
+	var depths []int
+	result, ok := Search(context.Background(), g, Limits{Depth: 3}, func(r SearchResult) {
+		depths = append(depths, r.Depth)
+		assert.Len(t, r.PV, r.Depth)
+	})
+	require.True(t, ok)
+	assert.Equal(t, []int{1, 2, 3}, depths)
+	assert.Equal(t, 3, result.Depth)
+	assert.Greater(t, result.Nodes, int64(20))
+}
+
+func TestSearch_QuiescenceSeesRecaptures(t *testing.T) {
+	// Qxd5 wins a pawn, but loses the queen to exd5
+	g, err := core.NewGameFromFEN("4k3/8/4p3/3p4/8/8/8/3QK3 w - - 0 1")
+	require.NoError(t, err)
+	result, ok := Search(context.Background(), g, Limits{Depth: 1}, nil)
+	require.True(t, ok)
+	assert.False(t, result.Action.IsCapture)
+	// The endgame material, give or take the positional terms, which are worth less
+	// than half a pawn
+	assert.InDelta(t, materialEg[core.PieceQueen]-2*materialEg[core.PiecePawn], result.Score, 50, "queen against two pawns")
+
+	// Rxd8 Rxd8 Rxd8+ wins a rook, which is beyond a 2 ply horizon
+	g, err = core.NewGameFromFEN("3r1rk1/5pp1/7p/8/8/8/3R1PPP/3R2K1 w - - 0 1")
+	require.NoError(t, err)
+	result, ok = Search(context.Background(), g, Limits{Depth: 2}, nil)
+	require.True(t, ok)
+	assert.Equal(t, core.XY{X: 3, Y: 0}, result.Action.ToXY)
+	assert.InDelta(t, materialEg[core.PieceRook], result.Score, 50, "up a rook")
+}
+
+func TestSearch_PromotesRightAway(t *testing.T) {
+	// Nothing stops e8=Q, but promoting past the horizon, after a king move, would
+	// also look like winning a queen
+	g, err := core.NewGameFromFEN("8/4P3/8/8/8/k7/8/K7 w - - 0 1")
+	require.NoError(t, err)
+	for depth := 1; depth <= 4; depth++ {
+		result, ok := Search(context.Background(), g, Limits{Depth: depth}, nil)
+		require.True(t, ok)
+		assert.True(t, result.Action.IsPromotion, "depth %d", depth)
+		assert.Equal(t, core.PieceType(core.PieceQueen), result.Action.PromotionPieceType, "depth %d", depth)
+	}
+}
+
+// winAtChess are positions of the Win at Chess test suite that a shallow search
+// solves.
+const winAtChess = `2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";
+5rk1/1ppb3p/p1pb4/6q1/3P1p1r/2P1R2P/PP1BQ1P1/5RKN w - - bm Rg3; id "WAC.003";
+r1bq2rk/pp3pbp/2p1p1pQ/7P/3P4/2PB1N2/PP3PPR/2KR4 w - - bm Qxh7+; id "WAC.004";
+5k2/6pp/p1qN4/1p1p4/3P4/2PKP2Q/PP3r2/3R4 b - - bm Qc4+; id "WAC.005";
+`
+
+func TestSearch_WinAtChess(t *testing.T) {
+	r := epd.NewReader(strings.NewReader(winAtChess))
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		result, ok := Search(context.Background(), record.Game, Limits{Depth: 4}, nil)
+		require.True(t, ok)
+		assert.Contains(t, record.BestMoves, result.Action, record.ID)
+	}
+}
+
+func TestOrderActions(t *testing.T) {
+	// The e4 pawn can take a queen or a knight, and the d1 queen can take the
+	// queen too
+	g, err := core.NewGameFromFEN("4k3/8/8/3q1n2/4P3/8/8/3QK3 w - - 0 1")
+	require.NoError(t, err)
+	var (
+		s            = &searcher{}
+		actions      = nonResignActions(g)
+		pxq, qxq     core.Action
+		pxn, killer  core.Action
+		quiet, first core.Action
+	)
+	for _, a := range actions {
+		switch {
+		case a.IsCapture && a.FromPiece.PieceType == core.PiecePawn && a.CapturedPiece.PieceType == core.PieceQueen:
+			pxq = a
+		case a.IsCapture && a.FromPiece.PieceType == core.PieceQueen:
+			qxq = a
+		case a.IsCapture:
+			pxn = a
+		case a.FromPiece.PieceType == core.PieceKing && a.ToXY == core.XY{X: 5, Y: 7}:
+			killer = a
+		case a.FromPiece.PieceType == core.PieceQueen && a.ToXY == core.XY{X: 3, Y: 5}:
+			quiet = a
+		case a.FromPiece.PieceType == core.PieceQueen && a.ToXY == core.XY{X: 0, Y: 4}:
+			first = a
+		}
+	}
+	s.recordCutoff(killer, 3, 1)
+	s.recordCutoff(quiet, 0, 4)
+
+	ordered := s.orderActions(actions, 3, first)
+	assert.Equal(t, []core.Action{first, pxq, qxq, pxn, killer, quiet}, ordered[:6])
+}
+
+func TestSearch_Limits(t *testing.T) {
+	g := core.NewDefaultGame()
+
+	t.Run("nodes", func(t *testing.T) {
+		result, ok := Search(context.Background(), g, Limits{Nodes: 500}, nil)
+		require.True(t, ok)
+		assert.NotEqual(t, core.Action{}, result.Action)
+		assert.LessOrEqual(t, result.Nodes, int64(500))
+		assert.GreaterOrEqual(t, result.Depth, 1)
+	})
+
+	t.Run("time", func(t *testing.T) {
+		start := time.Now()
+		result, ok := Search(context.Background(), g, Limits{Time: 100 * time.Millisecond}, nil)
+		require.True(t, ok)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.NotEqual(t, core.Action{}, result.Action)
+	})
+
+	t.Run("cancelled context still completes the first iteration", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, ok := Search(ctx, g, Limits{}, nil)
+		require.True(t, ok)
+		assert.Equal(t, 1, result.Depth)
+		assert.NotEqual(t, core.Action{}, result.Action)
+	})
+
+	t.Run("cancellation mid-search", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+		result, ok := Search(ctx, g, Limits{}, nil)
+		require.True(t, ok)
+		assert.NotEqual(t, core.Action{}, result.Action)
+	})
+}
+
+func TestAnalyze(t *testing.T) {
+	t.Run("best lines first", func(t *testing.T) {
+		results, ok := Analyze(context.Background(), core.NewDefaultGame(), Limits{Depth: 3}, 3)
+		require.True(t, ok)
+		require.Len(t, results, 3)
+		seen := map[core.Action]bool{}
+		for i, result := range results {
+			assert.Equal(t, 3, result.Depth)
+			assert.Equal(t, result.Action, result.PV[0])
+			assert.False(t, seen[result.Action], "lines start with different actions")
+			seen[result.Action] = true
+			if i > 0 {
+				assert.LessOrEqual(t, result.Score, results[i-1].Score)
+			}
+		}
+	})
+
+	t.Run("mate and the next best line", func(t *testing.T) {
+		g, err := core.NewGameFromFEN("r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 3")
+		require.NoError(t, err)
+		results, ok := Analyze(context.Background(), g, Limits{Depth: 2}, 2)
+		require.True(t, ok)
+		require.Len(t, results, 2)
+		assert.Equal(t, 1, results[0].Mate)
+		assert.Equal(t, 0, results[1].Mate)
+		assert.Equal(t, 2, results[1].Depth)
+	})
+
+	t.Run("fewer actions than lines", func(t *testing.T) {
+		// The king can only go to g2 or h2
+		g, err := core.NewGameFromFEN("7k/8/8/8/8/8/8/r6K w - - 0 1")
+		require.NoError(t, err)
+		results, ok := Analyze(context.Background(), g, Limits{Depth: 2}, 5)
+		require.True(t, ok)
+		assert.Len(t, results, 2)
+	})
+
+	t.Run("game over", func(t *testing.T) {
+		g, err := core.NewGameFromFEN("r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4")
+		require.NoError(t, err)
+		_, ok := Analyze(context.Background(), g, Limits{Depth: 2}, 3)
+		assert.False(t, ok)
+	})
+}
+
+func TestSearch_GameOver(t *testing.T) {
+	g, err := core.NewGameFromFEN("r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4")
+	require.NoError(t, err)
+	_, ok := Search(context.Background(), g, Limits{Depth: 3}, nil)
+	assert.False(t, ok)
+}
+
+func TestSearch_VariantEnd(t *testing.T) {
+	// The king is a move away from the hill, which beats being a queen down
+	g, err := core.NewVariantGameFromFEN("k7/8/8/8/8/3K4/q7/8 w - - 0 1", core.VariantKingOfTheHill)
+	require.NoError(t, err)
+	result, ok := Search(context.Background(), g, Limits{Depth: 3}, nil)
+	require.True(t, ok)
+	assert.True(t, result.Game.IsVariantEnd)
+	assert.Equal(t, 1, result.Mate)
+	assert.Equal(t, MateScore, result.Score)
+
+	_, ok = Search(context.Background(), result.Game, Limits{Depth: 3}, nil)
+	assert.False(t, ok)
+}
@@ -0,0 +1,120 @@
+package ai
+
+import "github.com/marianogappa/cheesse/core"
+
+// Tablebase is an endgame tablebase, such as a *core.Tablebase of Syzygy tables,
+// which tells the outcome of positions with few pieces with perfect play.
+type Tablebase interface {
+	// ProbeWDL returns the outcome of the position for the side to move.
+	ProbeWDL(g core.Game) (core.WDL, error)
+	// ProbeDTZ returns the distance, in plies, to zeroing the 50-move counter with
+	// perfect play, as documented in core.Tablebase.ProbeDTZ.
+	ProbeDTZ(g core.Game) (int, error)
+}
+
+// TablebaseWinScore is the score, in centipawns, of a position that a tablebase
+// says the side to move wins. It's lower than MateScore, as the mate is yet to be
+// found, and higher than any evaluation.
+const TablebaseWinScore = 20000
+
+// tablebaseScore is the evaluate-scale score of a WDL found at the given ply; the
+// sooner a win, the better. Cursed wins and blessed losses are draws by the
+// 50-move rule.
+func tablebaseScore(wdl core.WDL, ply int) int64 {
+	switch wdl {
+	case core.WDLWin:
+		return int64(TablebaseWinScore-ply) * centipawn
+	case core.WDLLoss:
+		return -int64(TablebaseWinScore-ply) * centipawn
+	}
+	return 0
+}
+
+// maxTablebasePieces is the most pieces, kings included, of any Syzygy table, so
+// that positions with more pieces aren't converted to a Game only to be rejected.
+const maxTablebasePieces = 7
+
+// maxDTZ ranks the root actions that win (or lose) regardless of the 50-move rule.
+const maxDTZ = 1 << 18
+
+// tablebaseRoot ranks the root actions of a position a tablebase covers, so that
+// the search only considers the ones that keep the best outcome.
+type tablebaseRoot struct {
+	ranks  map[core.Action]int
+	scores map[core.Action]int
+}
+
+// newTablebaseRoot ranks the given root actions by the DTZ of the positions they
+// lead to, following Stockfish: all actions that win before the 50-move rule
+// draws rank equally, and so do all actions that lose; otherwise the shorter a win
+// and the longer a loss, the better. Returns false if the tablebase doesn't cover
+// the position.
+func newTablebaseRoot(g core.Game, tb Tablebase, actions []core.Action) (*tablebaseRoot, bool) {
+	if _, err := tb.ProbeWDL(g); err != nil {
+		return nil, false
+	}
+	root := &tablebaseRoot{ranks: map[core.Action]int{}, scores: map[core.Action]int{}}
+	for _, action := range actions {
+		newGame := g.DoAction(action)
+		var dtz int
+		switch {
+		case newGame.IsCheckmate:
+			dtz = 1
+		case newGame.HalfMoveClock == 0:
+			wdl, err := tb.ProbeWDL(newGame)
+			if err != nil {
+				return nil, false
+			}
+			dtz = (-wdl).DTZBeforeZeroing()
+		case newGame.IsDraw || newGame.CanClaimDraw:
+			dtz = 0
+		default:
+			childDTZ, err := tb.ProbeDTZ(newGame)
+			if err != nil {
+				return nil, false
+			}
+			// One ply more, from the root
+			switch dtz = -childDTZ; {
+			case dtz > 0:
+				dtz++
+			case dtz < 0:
+				dtz--
+			}
+		}
+
+		rank, clock := 0, g.HalfMoveClock
+		switch {
+		case dtz > 0 && dtz+clock <= 99:
+			rank = maxDTZ
+		case dtz > 0:
+			rank = maxDTZ - (dtz + clock)
+		case dtz < 0 && -dtz+clock <= 99:
+			rank = -maxDTZ
+		case dtz < 0:
+			rank = -maxDTZ + (-dtz + clock)
+		}
+		root.ranks[action] = rank
+		switch {
+		case rank == maxDTZ:
+			root.scores[action] = TablebaseWinScore
+		case rank == -maxDTZ:
+			root.scores[action] = -TablebaseWinScore
+		}
+	}
+	return root, true
+}
+
+// best returns the best ranked of the given actions.
+func (r *tablebaseRoot) best(actions []core.Action) []core.Action {
+	bestRank := -maxDTZ - 1
+	for _, action := range actions {
+		bestRank = max(bestRank, r.ranks[action])
+	}
+	var best []core.Action
+	for _, action := range actions {
+		if r.ranks[action] == bestRank {
+			best = append(best, action)
+		}
+	}
+	return best
+}
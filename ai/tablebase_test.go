@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubTablebase covers the positions with up to maxPieces pieces, which are
+// draws unless dtz has them, by board and side to move.
+type stubTablebase struct {
+	maxPieces int
+	dtz       map[string]int
+}
+
+func (tb stubTablebase) ProbeWDL(g core.Game) (core.WDL, error) {
+	dtz, err := tb.ProbeDTZ(g)
+	switch {
+	case dtz > 0:
+		return core.WDLWin, err
+	case dtz < 0:
+		return core.WDLLoss, err
+	}
+	return core.WDLDraw, err
+}
+
+func (tb stubTablebase) ProbeDTZ(g core.Game) (int, error) {
+	fields := strings.Fields(g.ToFEN())
+	pieces := 0
+	for _, r := range fields[0] {
+		if r > '9' && r != '/' {
+			pieces++
+		}
+	}
+	if pieces > tb.maxPieces {
+		return 0, core.ErrNotInTablebase
+	}
+	return tb.dtz[fields[0]+" "+fields[1]], nil
+}
+
+func TestSearch_TablebaseInTree(t *testing.T) {
+	// Rxd7 wins a knight, but the tablebase says Rxa4 wins the game
+	g, err := core.NewGameFromFEN("7k/3n4/8/8/p2R4/8/7P/4K3 w - - 0 1")
+	require.NoError(t, err)
+	tb := stubTablebase{maxPieces: 5, dtz: map[string]int{"7k/3n4/8/8/R7/8/7P/4K3 b": -20}}
+
+	result, ok := Search(context.Background(), g, Limits{Depth: 1}, nil)
+	require.True(t, ok)
+	assert.Equal(t, "d7", result.Action.ToXY.ToAlgebraic())
+
+	result, ok = Search(context.Background(), g, Limits{Depth: 1, Tablebase: tb}, nil)
+	require.True(t, ok)
+	assert.Equal(t, "a4", result.Action.ToXY.ToAlgebraic())
+	assert.Equal(t, TablebaseWinScore-1, result.Score)
+	assert.Equal(t, 0, result.Mate)
+}
+
+func TestSearch_TablebaseAtRoot(t *testing.T) {
+	// Only Rh2 wins, according to the tablebase
+	tb := stubTablebase{maxPieces: 3, dtz: map[string]int{"8/8/8/4k3/8/8/7R/4K3 b": -10}}
+
+	ts := []struct {
+		name          string
+		fen           string
+		expectedScore int
+	}{
+		{name: "win", fen: "8/8/8/4k3/8/8/R7/4K3 w - - 0 1", expectedScore: TablebaseWinScore},
+		{name: "drawn by the 50-move rule", fen: "8/8/8/4k3/8/8/R7/4K3 w - - 95 80", expectedScore: 0},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := core.NewGameFromFEN(tc.fen)
+			require.NoError(t, err)
+			result, ok := Search(context.Background(), g, Limits{Depth: 3, Tablebase: tb}, nil)
+			require.True(t, ok)
+			assert.EqualValues(t, core.PieceRook, result.Action.FromPiece.PieceType)
+			assert.Equal(t, "h2", result.Action.ToXY.ToAlgebraic())
+			assert.Equal(t, tc.expectedScore, result.Score)
+		})
+	}
+
+	t.Run("analysis only shows the best outcome", func(t *testing.T) {
+		g, err := core.NewGameFromFEN("8/8/8/4k3/8/8/R7/4K3 w - - 0 1")
+		require.NoError(t, err)
+		results, ok := Analyze(context.Background(), g, Limits{Depth: 2, Tablebase: tb}, 3)
+		require.True(t, ok)
+		require.Len(t, results, 3)
+		assert.Equal(t, TablebaseWinScore, results[0].Score)
+		assert.Equal(t, 0, results[1].Score)
+		assert.Equal(t, 0, results[2].Score)
+	})
+
+	t.Run("not covered", func(t *testing.T) {
+		g, err := core.NewGameFromFEN("8/8/8/4k3/8/8/R7/4K3 w - - 0 1")
+		require.NoError(t, err)
+		result, ok := Search(context.Background(), g, Limits{Depth: 1, Tablebase: stubTablebase{maxPieces: 2}}, nil)
+		require.True(t, ok)
+		assert.Greater(t, result.Score, 0)
+	})
+}
+
+func TestTablebaseRoot_CorruptTablebase(t *testing.T) {
+	g, err := core.NewGameFromFEN("8/8/8/4k3/8/8/R7/4K3 w - - 0 1")
+	require.NoError(t, err)
+	_, ok := newTablebaseRoot(g, failingTablebase{}, nonResignActions(g))
+	assert.False(t, ok)
+}
+
+type failingTablebase struct{}
+
+func (failingTablebase) ProbeWDL(core.Game) (core.WDL, error) { return core.WDLDraw, nil }
+func (failingTablebase) ProbeDTZ(core.Game) (int, error)      { return 0, errors.New("corrupt") }
@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"math"
+	"math/bits"
+	"unsafe"
+
+	"github.com/marianogappa/cheesse/core"
+)
+
+// DefaultTranspositionTableSizeMB is the size of the transposition table of a
+// Search that isn't given one.
+const DefaultTranspositionTableSizeMB = 16
+
+// bound is the kind of score stored in a transposition table entry. Alpha-beta
+// only knows the exact score of a position when it falls within the window.
+type bound uint8
+
+const (
+	boundNone  bound = iota
+	boundExact       // the score is exact
+	boundLower       // the score failed high: the real score is at least this
+	boundUpper       // the score failed low: the real score is at most this
+)
+
+// ttEntry is a transposition table entry: the result of searching a position.
+type ttEntry struct {
+	key        uint64
+	bestAction core.Action
+	score      int64
+	depth      int8
+	bound      bound
+	generation uint8
+}
+
+// TranspositionTable caches search results by position (i.e. core.Game.Hash), so
+// that positions reached through different move orders (transpositions) aren't
+// searched again, and so that the best action found for a position is tried
+// first when searching it again at a greater depth.
+//
+// It has a fixed number of entries. When two positions map to the same entry,
+// the deepest search is kept, unless it's from a previous Search.
+//
+// A TranspositionTable can be reused across searches (e.g. along a game), but
+// not concurrently.
+type TranspositionTable struct {
+	entries    []ttEntry
+	mask       uint64
+	generation uint8
+}
+
+// NewTranspositionTable creates a transposition table of at most the given size in
+// megabytes (and at least one entry).
+func NewTranspositionTable(sizeMB int) *TranspositionTable {
+	n := uint64(sizeMB) << 20 / uint64(unsafe.Sizeof(ttEntry{}))
+	if n == 0 {
+		n = 1
+	}
+	// A power of two, so that a key's index is a mask of its bits
+	n = 1 << (63 - bits.LeadingZeros64(n))
+	return &TranspositionTable{entries: make([]ttEntry, n), mask: n - 1}
+}
+
+// Clear empties the table, e.g. before a new game.
+func (tt *TranspositionTable) Clear() {
+	for i := range tt.entries {
+		tt.entries[i] = ttEntry{}
+	}
+	tt.generation = 0
+}
+
+// newSearch marks the start of a Search, whose entries replace previous ones.
+func (tt *TranspositionTable) newSearch() {
+	tt.generation++
+}
+
+// probe returns the entry of the position with the given key, if any. The score
+// is adjusted to the position's ply (see store).
+func (tt *TranspositionTable) probe(key uint64, ply int) (ttEntry, bool) {
+	entry := tt.entries[key&tt.mask]
+	if entry.bound == boundNone || entry.key != key {
+		return ttEntry{}, false
+	}
+	entry.score = scoreFromTT(entry.score, ply)
+	return entry, true
+}
+
+// store saves the result of searching the position with the given key at the
+// given ply.
+func (tt *TranspositionTable) store(key uint64, ply, depth int, score int64, b bound, bestAction core.Action) {
+	entry := &tt.entries[key&tt.mask]
+	if entry.bound != boundNone && entry.generation == tt.generation && entry.key != key && int(entry.depth) > depth {
+		return
+	}
+	if entry.key == key && bestAction == (core.Action{}) {
+		// Keep the best action of a previous search of the same position
+		bestAction = entry.bestAction
+	}
+	*entry = ttEntry{
+		key:        key,
+		bestAction: bestAction,
+		score:      scoreToTT(score, ply),
+		depth:      int8(depth),
+		bound:      b,
+		generation: tt.generation,
+	}
+}
+
+// Mate scores depend on the distance from the root (see negamax), but an entry
+// may be probed at a different ply, so they're stored as the distance from the
+// entry's own position instead.
+
+func scoreToTT(score int64, ply int) int64 {
+	switch {
+	case score >= math.MaxInt64/4:
+		return score + int64(ply)
+	case score <= -math.MaxInt64/4:
+		return score - int64(ply)
+	}
+	return score
+}
+
+func scoreFromTT(score int64, ply int) int64 {
+	switch {
+	case score >= math.MaxInt64/4:
+		return score - int64(ply)
+	case score <= -math.MaxInt64/4:
+		return score + int64(ply)
+	}
+	return score
+}
@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"context"
+	"testing"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranspositionTable(t *testing.T) {
+	action := core.Action{FromPiece: core.Piece{PieceType: core.PieceKnight, XY: core.XY{X: 6, Y: 7}}, ToXY: core.XY{X: 5, Y: 5}}
+
+	t.Run("store and probe", func(t *testing.T) {
+		tt := NewTranspositionTable(1)
+		tt.store(42, 3, 5, 1234, boundLower, action)
+		entry, ok := tt.probe(42, 3)
+		require.True(t, ok)
+		assert.Equal(t, int64(1234), entry.score)
+		assert.Equal(t, int8(5), entry.depth)
+		assert.Equal(t, boundLower, entry.bound)
+		assert.Equal(t, action, entry.bestAction)
+
+		_, ok = tt.probe(43, 3)
+		assert.False(t, ok)
+	})
+
+	t.Run("mate scores are adjusted to the ply", func(t *testing.T) {
+		tt := NewTranspositionTable(1)
+		// Mating in 2 plies from a position found at ply 3
+		tt.store(42, 3, 5, mateValue-5, boundExact, action)
+		entry, ok := tt.probe(42, 7)
+		require.True(t, ok)
+		assert.Equal(t, int64(mateValue-9), entry.score, "still mates 2 plies later")
+
+		tt.store(42, 3, 5, -(mateValue - 5), boundExact, action)
+		entry, ok = tt.probe(42, 1)
+		require.True(t, ok)
+		assert.Equal(t, -int64(mateValue-3), entry.score)
+	})
+
+	t.Run("deeper entries are kept within a search", func(t *testing.T) {
+		tt := NewTranspositionTable(1)
+		other := 42 + tt.mask + 1 // same index, different key
+		tt.newSearch()
+		tt.store(42, 0, 5, 1, boundExact, action)
+		tt.store(other, 0, 2, 2, boundExact, action)
+		_, ok := tt.probe(other, 0)
+		assert.False(t, ok)
+
+		tt.newSearch()
+		tt.store(other, 0, 2, 2, boundExact, action)
+		_, ok = tt.probe(other, 0)
+		assert.True(t, ok, "entries of previous searches are replaced")
+	})
+
+	t.Run("keeps the best action when storing none", func(t *testing.T) {
+		tt := NewTranspositionTable(1)
+		tt.store(42, 0, 2, 1, boundExact, action)
+		tt.store(42, 0, 3, 1, boundUpper, core.Action{})
+		entry, ok := tt.probe(42, 0)
+		require.True(t, ok)
+		assert.Equal(t, action, entry.bestAction)
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		tt := NewTranspositionTable(1)
+		tt.store(42, 0, 2, 1, boundExact, action)
+		tt.Clear()
+		_, ok := tt.probe(42, 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestSearchWithTable(t *testing.T) {
+	g := core.NewDefaultGame()
+	tt := NewTranspositionTable(DefaultTranspositionTableSizeMB)
+	first, ok := SearchWithTable(context.Background(), tt, g, Limits{Depth: 4}, nil)
+	require.True(t, ok)
+	second, ok := SearchWithTable(context.Background(), tt, g, Limits{Depth: 4}, nil)
+	require.True(t, ok)
+	assert.Less(t, second.Nodes, first.Nodes, "a reused table saves work")
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/epd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIMoveWithLimits(t *testing.T) {
+	t.Run("mate in two", func(t *testing.T) {
+		game := InputGame{FENString: "3r2k1/5ppp/8/8/8/8/4RPPP/4R1K1 w - - 0 1"}
+		_, outputAction, result, ok, err := New().AIMoveWithLimits(context.Background(), game, InputAILimits{MaxDepth: 5})
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "Re8+", outputAction.ActionString)
+		assert.Equal(t, []string{"Re8+", "Rxe8", "Rxe8#"}, result.PV)
+		assert.Equal(t, 2, result.Mate)
+		assert.Equal(t, 100000, result.Score)
+		assert.Equal(t, 1, result.Depth)
+	})
+
+	t.Run("node limit", func(t *testing.T) {
+		outputGame, outputAction, result, ok, err := New().AIMoveWithLimits(context.Background(), InputGame{}, InputAILimits{MaxNodes: 1000})
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.NotEmpty(t, outputAction.ActionString)
+		assert.Equal(t, "Black", outputGame.Board.Turn)
+		assert.NotEmpty(t, result.PV)
+		assert.LessOrEqual(t, result.Nodes, int64(1000))
+	})
+
+	t.Run("time limit", func(t *testing.T) {
+		_, _, result, ok, err := New().AIMoveWithLimits(context.Background(), InputGame{}, InputAILimits{MaxTimeMs: 50})
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, result.Depth, 1)
+	})
+
+	t.Run("game over", func(t *testing.T) {
+		game := InputGame{FENString: "r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4"}
+		_, _, _, ok, err := New().AIMoveWithLimits(context.Background(), game, InputAILimits{MaxDepth: 2})
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("limits are required", func(t *testing.T) {
+		_, _, _, _, err := New().AIMoveWithLimits(context.Background(), InputGame{}, InputAILimits{})
+		assert.ErrorIs(t, err, ErrMissingAILimits)
+	})
+
+	t.Run("negative limits", func(t *testing.T) {
+		_, _, _, _, err := New().AIMoveWithLimits(context.Background(), InputGame{}, InputAILimits{MaxDepth: 3, MaxNodes: -1})
+		assert.ErrorIs(t, err, ErrInvalidAILimits)
+	})
+
+	t.Run("limits above the caps", func(t *testing.T) {
+		ts := []struct {
+			limits InputAILimits
+			field  string
+		}{
+			{InputAILimits{MaxDepth: MaxAIDepth + 1}, "maxDepth"},
+			{InputAILimits{MaxTimeMs: 1e9}, "maxTimeMs"},
+			{InputAILimits{MaxDepth: 3, MaxNodes: MaxAINodes + 1}, "maxNodes"},
+		}
+		for _, tc := range ts {
+			_, _, _, _, err := New().AIMoveWithLimits(context.Background(), InputGame{}, tc.limits)
+			require.ErrorIs(t, err, ErrInvalidAILimits)
+			assert.Equal(t, tc.field, ErrorOf(err).Field)
+		}
+	})
+
+	t.Run("searches are limited in time by default", func(t *testing.T) {
+		assert.Equal(t, MaxAITimeMs*time.Millisecond, mapInputAILimitsToLimits(InputAILimits{MaxDepth: MaxAIDepth}).Time)
+		assert.Equal(t, 50*time.Millisecond, mapInputAILimitsToLimits(InputAILimits{MaxTimeMs: 50}).Time)
+	})
+}
+
+func TestAnalyze(t *testing.T) {
+	t.Run("three best lines", func(t *testing.T) {
+		game := InputGame{FENString: "r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 3"}
+		results, err := New().Analyze(context.Background(), game, InputAnalyzeOptions{InputAILimits: InputAILimits{MaxDepth: 2}, Lines: 3})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, []string{"Qxf7#"}, results[0].PV)
+		assert.Equal(t, 1, results[0].Mate)
+		assert.Equal(t, 100000, results[0].Score)
+		for _, result := range results[1:] {
+			assert.Equal(t, 0, result.Mate)
+			assert.Equal(t, 2, result.Depth)
+			assert.NotEqual(t, "Qxf7#", result.PV[0])
+			assert.Greater(t, result.Nodes, int64(0))
+		}
+	})
+
+	t.Run("defaults to one line", func(t *testing.T) {
+		results, err := New().Analyze(context.Background(), InputGame{}, InputAnalyzeOptions{InputAILimits: InputAILimits{MaxDepth: 1}})
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+	})
+
+	t.Run("game over", func(t *testing.T) {
+		game := InputGame{FENString: "r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4"}
+		results, err := New().Analyze(context.Background(), game, InputAnalyzeOptions{InputAILimits: InputAILimits{MaxDepth: 2}})
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("limits are required", func(t *testing.T) {
+		_, err := New().Analyze(context.Background(), InputGame{}, InputAnalyzeOptions{Lines: 3})
+		assert.ErrorIs(t, err, ErrMissingAILimits)
+	})
+
+	t.Run("negative lines", func(t *testing.T) {
+		_, err := New().Analyze(context.Background(), InputGame{}, InputAnalyzeOptions{InputAILimits: InputAILimits{MaxDepth: 1}, Lines: -1})
+		assert.ErrorIs(t, err, ErrInvalidAnalyzeLines)
+	})
+
+	t.Run("limits above the caps", func(t *testing.T) {
+		_, err := New().Analyze(context.Background(), InputGame{}, InputAnalyzeOptions{InputAILimits: InputAILimits{MaxDepth: 1}, Lines: MaxAnalyzeLines + 1})
+		require.ErrorIs(t, err, ErrInvalidAnalyzeLines)
+		assert.Equal(t, "lines", ErrorOf(err).Field)
+
+		_, err = New().Analyze(context.Background(), InputGame{}, InputAnalyzeOptions{InputAILimits: InputAILimits{MaxTimeMs: 1e9}, Lines: 3})
+		require.ErrorIs(t, err, ErrInvalidAILimits)
+		assert.Equal(t, "maxTimeMs", ErrorOf(err).Field)
+	})
+}
+
+// readTacticsEPD reads the records of testdata/tactics.epd, whose best moves (bm)
+// or moves to avoid (am) are for AIMove's medium mode.
+func readTacticsEPD(t *testing.T) []*epd.Record {
+	f, err := os.Open("testdata/tactics.epd")
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []*epd.Record
+	r := epd.NewReader(f)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+// sanStrings returns the SAN strings of the given actions on the given game.
+func sanStrings(g core.Game, actions []core.Action) []string {
+	var ss []string
+	for _, action := range mapEPDMoves(g, actions) {
+		ss = append(ss, action.ActionString)
+	}
+	return ss
+}
+
+func TestAIMoveTactics(t *testing.T) {
+	records := readTacticsEPD(t)
+	require.NotEmpty(t, records)
+	for _, record := range records {
+		t.Run(record.ID, func(t *testing.T) {
+			_, outputAction, ok, err := New().AIMove(InputGame{FENString: record.Game.ToFEN()}, "medium")
+			require.NoError(t, err)
+			require.True(t, ok)
+			if len(record.BestMoves) > 0 {
+				assert.Contains(t, sanStrings(record.Game, record.BestMoves), outputAction.ActionString)
+			}
+			assert.NotContains(t, sanStrings(record.Game, record.AvoidMoves), outputAction.ActionString)
+		})
+	}
+}
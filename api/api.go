@@ -1,37 +1,69 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"math/rand"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/marianogappa/cheesse/ai"
+	"github.com/marianogappa/cheesse/book"
 	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/epd"
 	"github.com/marianogappa/cheesse/parser"
 	"github.com/marianogappa/cheesse/parser/pgn"
 	"github.com/marianogappa/cheesse/printer"
 )
 
 // API represents the cheesse API. All cheesse API methods are exported methods of this struct.
-type API struct{}
+type API struct {
+	book      *book.Book
+	tablebase *core.Tablebase
+	sessions  SessionStore
+
+	sessionNotifier *sessionNotifier
+}
 
 // New constructs an API.
 func New() API { return API{} }
 
-var (
-	errInvalidInputGame                    = errors.New("invalid input game: please supply a valid fenString or a board")
-	errAlgebraicSquareInvalidOrOutOfBounds = errors.New("invalid algebraic square: empty or out of bounds")
-	errInvalidPieceTypeName                = errors.New("invalid piece type name: please use one of {Queen|King|Bishop|Knight|Rook|Pawn} or empty string")
-	errInvalidActionForGivenGame           = errors.New("the specified action is invalid for the specified game")
-	errInvalidPositionHistory              = errors.New("invalid position history: entries must be hex-encoded position hashes from a previous response")
-	errAmbiguousActionString               = errors.New("the specified action string is ambiguous: more than one action matches it; please disambiguate")
-)
+// WithBook returns a copy of the API that uses the given Polyglot opening book for
+// AIMove and BookMoves.
+func (a API) WithBook(b *book.Book) API {
+	a.book = b
+	return a
+}
+
+// WithTablebase returns a copy of the API that uses the given Syzygy endgame
+// tablebase for AIMove, AIMoveWithLimits and Analyze, and to annotate the games it
+// returns with their outcome (see OutputGame).
+func (a API) WithTablebase(tb *core.Tablebase) API {
+	a.tablebase = tb
+	return a
+}
 
 // DefaultGame returns the initial game of chess, with all pieces on their default positions
 // and before any action has taken place.
 func (a API) DefaultGame() OutputGame {
 	var defaultGame, _ = core.NewGameFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
-	return mapGameToOutputGame(defaultGame)
+	return a.outputGame(defaultGame)
+}
+
+// DefaultChess960Game returns the initial game of Chess960 (Fischer Random) for the given
+// start position id (its Scharnagl number, between 0 and 959), before any action has taken
+// place. Id 518 is the standard chess starting position.
+//
+// Chess960 games castle with the king landing on the g/c-file and the rook on the f/d-file,
+// as in standard chess. To castle via `fromSquare`/`toSquare`, either supply the king's
+// destination square or the castling rook's square ("king takes rook").
+func (a API) DefaultChess960Game(id int) (OutputGame, error) {
+	game, err := core.NewChess960Game(id)
+	if err != nil {
+		return OutputGame{}, ErrInvalidChess960ID.withCause(err)
+	}
+	return a.outputGame(game), nil
 }
 
 // ParseGame takes any valid input game and parses it, returning an OutputGame, which contains
@@ -46,7 +78,7 @@ func (a API) ParseGame(game InputGame) (OutputGame, error) {
 	if err != nil {
 		return OutputGame{}, err
 	}
-	return mapGameToOutputGame(parsedGame), nil
+	return a.outputGame(parsedGame), nil
 }
 
 // DoAction takes any valid input game and any valid input action, parses them and attempts
@@ -67,14 +99,17 @@ func (a API) DoAction(game InputGame, action InputAction) (OutputGame, OutputAct
 	if err != nil {
 		return OutputGame{}, OutputAction{}, err
 	}
-	newGame := parsedGame.DoAction(parsedAction)
+	if action.ElapsedMs < 0 {
+		return OutputGame{}, OutputAction{}, ErrInvalidElapsedTime.with("elapsedMs", "")
+	}
+	newGame := parsedGame.DoTimedAction(parsedAction, time.Duration(action.ElapsedMs)*time.Millisecond)
 	outputAction := mapInternalActionToAction(parsedAction)
 	actionString, err := printer.AlgebraicPrinter{}.PrintAction(core.GameStep{StepAction: parsedAction, StepGame: newGame, StepPreMoveGame: parsedGame}, printer.SANCharacteristics())
 	if err != nil {
 		return OutputGame{}, OutputAction{}, err
 	}
 	outputAction.ActionString = actionString
-	return mapGameToOutputGame(newGame), outputAction, nil
+	return a.outputGame(newGame), outputAction, nil
 }
 
 // ParseNotation takes any valid input game and a string representing a match in some
@@ -103,63 +138,66 @@ func (a API) ParseNotation(game InputGame, notationString string) (OutputGame, O
 	if err != nil {
 		return OutputGame{}, OutputParseResult{}, err
 	}
-	gameSteps, result := parseNotationAutoDetect(parsedGame, notationString)
-	result.Steps = mapGameStepsToOutputGameSteps(gameSteps)
-	return mapGameToOutputGame(parsedGame), result, nil
+	parsedNotation, result := parseNotationAutoDetect(parsedGame, notationString)
+	result.Steps = mapGameStepsToOutputGameSteps(parsedNotation.GameSteps)
+	result.MoveTree, _ = mapMoveTreeToOutputMoveTree(parsedNotation.MoveTree, func(gs core.GameStep) (string, error) { return gs.StepString, nil })
+	return a.outputGame(parsedGame), result, nil
 }
 
-// parseNotationAutoDetect tries all supported notation parsers and returns the game
-// steps of the attempt that parsed the furthest, along with a parse result describing
-// the winning attempt (with Steps unset; callers map the steps as needed).
-func parseNotationAutoDetect(parsedGame core.Game, notationString string) ([]core.GameStep, OutputParseResult) {
+// parseNotationAutoDetect tries all supported notation parsers and returns the parsed
+// game of the attempt that parsed the furthest, along with a parse result describing
+// the winning attempt (with Steps and MoveTree unset; callers map them as needed).
+func parseNotationAutoDetect(parsedGame core.Game, notationString string) (parser.ParsedGame, OutputParseResult) {
 	notationString = strings.TrimSpace(notationString)
 	type notationCandidate struct {
 		name  string
-		parse func() ([]core.GameStep, map[string]string, error)
+		parse func() (parser.ParsedGame, error)
 	}
-	noMetadata := func(parse func() ([]core.GameStep, error)) func() ([]core.GameStep, map[string]string, error) {
-		return func() ([]core.GameStep, map[string]string, error) {
+	// Notations without annotations or variations only yield the mainline; their
+	// move tree is the mainline with no annotations.
+	stepsOnly := func(parse func() ([]core.GameStep, error)) func() (parser.ParsedGame, error) {
+		return func() (parser.ParsedGame, error) {
 			gameSteps, err := parse()
-			return gameSteps, nil, err
+			return parser.ParsedGame{GameSteps: gameSteps, MoveTree: moveTreeFromGameSteps(gameSteps)}, err
 		}
 	}
 	candidates := []notationCandidate{
-		{"Algebraic Notation", noMetadata(func() ([]core.GameStep, error) {
+		{"Algebraic Notation", stepsOnly(func() ([]core.GameStep, error) {
 			return parser.NewNotationParserAlgebraic(parser.Characteristics{}).Parse(parsedGame, notationString)
 		})},
-		{"ICCF Notation", noMetadata(func() ([]core.GameStep, error) {
+		{"ICCF Notation", stepsOnly(func() ([]core.GameStep, error) {
 			return parser.NewNotationParserICCF(parser.Characteristics{}).Parse(parsedGame, notationString)
 		})},
-		{"Smith Notation", noMetadata(func() ([]core.GameStep, error) {
+		{"Smith Notation", stepsOnly(func() ([]core.GameStep, error) {
 			return parser.NewNotationParserSmith(parser.Characteristics{}).Parse(parsedGame, notationString)
 		})},
-		{"Coordinate Notation", noMetadata(func() ([]core.GameStep, error) {
+		{"Coordinate Notation", stepsOnly(func() ([]core.GameStep, error) {
 			return parser.NewNotationParserCoordinate(parser.Characteristics{}).Parse(parsedGame, notationString)
 		})},
-		{"Descriptive Notation", noMetadata(func() ([]core.GameStep, error) {
+		{"Descriptive Notation", stepsOnly(func() ([]core.GameStep, error) {
 			return parser.NewNotationParserDescriptive(parser.Characteristics{}).Parse(parsedGame, notationString)
 		})},
-		{"PGN", func() ([]core.GameStep, map[string]string, error) {
+		{"PGN", func() (parser.ParsedGame, error) {
 			parsed, err := parser.NewGenericNotationParser(pgn.NewVariantPGN()).Parse(parsedGame, notationString)
 			if parsed == nil {
-				return nil, nil, err
+				return parser.ParsedGame{}, err
 			}
-			return parsed.GameSteps, parsed.Metadata, err
+			return *parsed, err
 		}},
 	}
 
 	var (
-		bestSteps  []core.GameStep
+		bestParsed parser.ParsedGame
 		bestResult *OutputParseResult
 	)
 	for _, candidate := range candidates {
-		gameSteps, metadata, err := candidate.parse()
-		validSteps := len(gameSteps)
+		parsed, err := candidate.parse()
+		validSteps := len(parsed.GameSteps)
 		result := OutputParseResult{
 			NotationName:       candidate.name,
 			ParseWasSuccessful: err == nil,
 			ValidActionCount:   validSteps,
-			Metadata:           metadata,
+			Metadata:           parsed.Metadata,
 		}
 		if err != nil {
 			result.Error = err.Error()
@@ -167,15 +205,25 @@ func parseNotationAutoDetect(parsedGame core.Game, notationString string) ([]cor
 
 		// A fully-successful parse with at least one step wins immediately.
 		if result.ParseWasSuccessful && validSteps > 0 {
-			return gameSteps, result
+			return parsed, result
 		}
 		// Otherwise keep the attempt that parsed the furthest.
 		if bestResult == nil || validSteps > bestResult.ValidActionCount {
-			bestSteps, bestResult = gameSteps, &result
+			bestParsed, bestResult = parsed, &result
 		}
 	}
 
-	return bestSteps, *bestResult
+	return bestParsed, *bestResult
+}
+
+// moveTreeFromGameSteps returns a move tree with the given mainline steps and no
+// annotations or variations.
+func moveTreeFromGameSteps(gameSteps []core.GameStep) []core.MoveNode {
+	moveTree := make([]core.MoveNode, len(gameSteps))
+	for i, gameStep := range gameSteps {
+		moveTree[i] = core.MoveNode{GameStep: gameStep}
+	}
+	return moveTree
 }
 
 // ConvertNotation takes any valid input game and a string representing a match in
@@ -206,22 +254,28 @@ func (a API) ConvertNotation(game InputGame, notationString string, targetNotati
 		return OutputGame{}, OutputParseResult{}, err
 	}
 
-	gameSteps, result := parseNotationAutoDetect(parsedGame, notationString)
+	parsedNotation, result := parseNotationAutoDetect(parsedGame, notationString)
 
-	result.Steps = mapGameStepsToOutputGameSteps(gameSteps)
-	for i, gameStep := range gameSteps {
+	printAction := func(gameStep core.GameStep) (string, error) {
 		if gameStep.StepAction == (core.Action{}) {
 			// Result markers (e.g. "1-0" in PGN) have no action to re-render.
-			continue
+			return gameStep.StepString, nil
 		}
-		actionString, err := targetPrinter.PrintAction(gameStep, targetCharacteristics)
+		return targetPrinter.PrintAction(gameStep, targetCharacteristics)
+	}
+	result.Steps = mapGameStepsToOutputGameSteps(parsedNotation.GameSteps)
+	for i, gameStep := range parsedNotation.GameSteps {
+		actionString, err := printAction(gameStep)
 		if err != nil {
 			return OutputGame{}, OutputParseResult{}, err
 		}
 		result.Steps[i].ActionString = actionString
 	}
+	if result.MoveTree, err = mapMoveTreeToOutputMoveTree(parsedNotation.MoveTree, printAction); err != nil {
+		return OutputGame{}, OutputParseResult{}, err
+	}
 
-	return mapGameToOutputGame(parsedGame), result, nil
+	return a.outputGame(parsedGame), result, nil
 }
 
 // AIMove selects a move for the side to move in the given game.
@@ -229,8 +283,13 @@ func (a API) ConvertNotation(game InputGame, notationString string, targetNotati
 // `mode` must be one of: `{random|easy|medium|hard}` (case-insensitive).
 //   - `random`: uniformly random legal action (non-resign).
 //   - `easy`: minimax depth 0 (evaluates each move, no lookahead).
-//   - `medium`: minimax depth 1 (looks one full move ahead).
-//   - `hard`: minimax depth 2 (looks two full moves ahead).
+//   - `medium`: alpha-beta search 2 plies deep (looks one full move ahead), which
+//     resolves captures and checks past its horizon.
+//   - `hard`: the same search as `medium`, but 4 plies deep (looks two full moves
+//     ahead).
+//
+// If the API has an opening book (see WithBook), every mode but `random` plays a
+// book move while the position is in the book, picked at random by weight.
 //
 // Returns the resulting game AFTER the action is applied, the chosen action, and
 // whether a move was available (false = game is already over).
@@ -247,21 +306,35 @@ func (a API) AIMove(game InputGame, mode string) (OutputGame, OutputAction, bool
 		newGame core.Game
 		ok      bool
 	)
-	switch strings.ToLower(mode) {
-	case "random":
+	mode = strings.ToLower(mode)
+	switch mode {
+	case "random", "easy", "medium", "hard":
+	default:
+		return OutputGame{}, OutputAction{}, false, ErrUnknownAIMode.with("mode", mode)
+	}
+	bookMove, inBook := book.Move{}, false
+	if a.book != nil && mode != "random" {
+		bookMove, inBook = a.book.PickMove(parsedGame, rand.New(rand.NewSource(rand.Int63())))
+	}
+	switch {
+	case inBook:
+		action, newGame, ok = bookMove.Action, parsedGame.DoAction(bookMove.Action), true
+	case mode == "random":
 		action, newGame, ok = ai.RandomAction(parsedGame, rand.New(rand.NewSource(rand.Int63())))
-	case "easy":
+	case mode == "easy":
 		action, newGame, ok = ai.BasicAIAction(parsedGame, 0)
-	case "medium":
-		action, newGame, ok = ai.BasicAIAction(parsedGame, 1)
-	case "hard":
-		action, newGame, ok = ai.BasicAIAction(parsedGame, 2)
-	default:
-		return OutputGame{}, OutputAction{}, false, errUnknownAIMode
+	case mode == "medium":
+		var result ai.SearchResult
+		result, ok = ai.Search(context.Background(), parsedGame, a.withTablebase(ai.Limits{Depth: mediumModeDepth}), nil)
+		action, newGame = result.Action, result.Game
+	case mode == "hard":
+		var result ai.SearchResult
+		result, ok = ai.Search(context.Background(), parsedGame, a.withTablebase(ai.Limits{Depth: hardModeDepth}), nil)
+		action, newGame = result.Action, result.Game
 	}
 
 	if !ok {
-		return mapGameToOutputGame(parsedGame), OutputAction{}, false, nil
+		return a.outputGame(parsedGame), OutputAction{}, false, nil
 	}
 
 	outputAction := mapInternalActionToAction(action)
@@ -273,11 +346,240 @@ func (a API) AIMove(game InputGame, mode string) (OutputGame, OutputAction, bool
 		outputAction.ActionString = actionString
 	}
 
-	return mapGameToOutputGame(newGame), outputAction, true, nil
+	return a.outputGame(newGame), outputAction, true, nil
+}
+
+// Depths, in plies, of AIMove's modes.
+const (
+	mediumModeDepth = 2
+	hardModeDepth   = 4
+)
+
+// AIMoveWithLimits selects a move for the side to move in the given game, like AIMove,
+// but instead of a fixed mode it searches deeper and deeper (iterative deepening)
+// until one of the given limits is reached or ctx is done. Please refer to the docs
+// for the InputAILimits format.
+//
+// The chosen action is the best one of the deepest completed search, which also
+// provides the principal variation and the score. A depth 1 search always completes,
+// even if the limits are smaller.
+//
+// Returns the resulting game AFTER the action is applied, the chosen action, the
+// search result, and whether a move was available (false = game is already over).
+func (a API) AIMoveWithLimits(ctx context.Context, game InputGame, limits InputAILimits) (OutputGame, OutputAction, OutputSearchResult, bool, error) {
+	if err := validateAILimits(limits); err != nil {
+		return OutputGame{}, OutputAction{}, OutputSearchResult{}, false, err
+	}
+	parsedGame, err := a.parseGame(game)
+	if err != nil {
+		return OutputGame{}, OutputAction{}, OutputSearchResult{}, false, err
+	}
+
+	result, ok := ai.Search(ctx, parsedGame, a.withTablebase(mapInputAILimitsToLimits(limits)), nil)
+	if !ok {
+		return a.outputGame(parsedGame), OutputAction{}, OutputSearchResult{}, false, nil
+	}
+
+	outputAction := mapInternalActionToAction(result.Action)
+	outputSearchResult := mapSearchResultToOutputSearchResult(parsedGame, result)
+	outputAction.ActionString = outputSearchResult.PV[0]
+
+	return a.outputGame(result.Game), outputAction, outputSearchResult, true, nil
+}
+
+// Analyze finds the best moves for the side to move in the given game, for a coach
+// or an analysis board, by searching deeper and deeper like AIMoveWithLimits until
+// one of the given limits is reached or ctx is done. Please refer to the docs for
+// the InputAnalyzeOptions format.
+//
+// Returns one search result per requested line, best first, each starting with a
+// different move; fewer if there aren't enough legal moves, and none if the game is
+// already over. Their scores are from the point of view of the side to move.
+func (a API) Analyze(ctx context.Context, game InputGame, options InputAnalyzeOptions) ([]OutputSearchResult, error) {
+	if err := validateAnalyzeOptions(options); err != nil {
+		return nil, err
+	}
+	parsedGame, err := a.parseGame(game)
+	if err != nil {
+		return nil, err
+	}
+
+	results, _ := ai.Analyze(ctx, parsedGame, a.withTablebase(mapInputAILimitsToLimits(options.InputAILimits)), options.Lines)
+	outputSearchResults := make([]OutputSearchResult, len(results))
+	for i, result := range results {
+		outputSearchResults[i] = mapSearchResultToOutputSearchResult(parsedGame, result)
+	}
+	return outputSearchResults, nil
+}
+
+// BookMoves returns the moves of the API's opening book (see WithBook) for the given
+// game, heaviest first. It's empty when the position isn't in the book.
+//
+// An error is returned if the API has no opening book or the input game is invalid.
+func (a API) BookMoves(game InputGame) ([]OutputBookMove, error) {
+	if a.book == nil {
+		return nil, ErrNoBook
+	}
+	parsedGame, err := a.parseGame(game)
+	if err != nil {
+		return nil, err
+	}
+
+	moves := a.book.Moves(parsedGame)
+	outputBookMoves := make([]OutputBookMove, len(moves))
+	for i, move := range moves {
+		outputBookMoves[i] = OutputBookMove{Action: mapInternalActionToAction(move.Action), Weight: move.Weight}
+		outputBookMoves[i].Action.ActionString, _ = printer.AlgebraicPrinter{}.PrintAction(
+			core.GameStep{StepAction: move.Action, StepGame: parsedGame.DoAction(move.Action), StepPreMoveGame: parsedGame},
+			printer.SANCharacteristics(),
+		)
+	}
+	return outputBookMoves, nil
+}
+
+// ParseEPD parses a position in Extended Position Description, i.e. the first four
+// fields of a FEN string followed by operations (e.g. `bm Nf3; id "WAC.001";`), and
+// returns its game plus its typed opcodes, with the moves of `bm`, `am` and `pv`
+// resolved in Standard Algebraic Notation. The game's halfmove clock and full move
+// number are those of the `hmvc` and `fmvn` opcodes, or 0 and 1.
+//
+// An error is returned if the record is invalid, e.g. if a move isn't legal or its
+// position isn't (in which case the error's detail is the invalid field of the FEN
+// string).
+//
+// Please refer to OutputEPD's docs for format details.
+func (a API) ParseEPD(epdString string) (OutputGame, OutputEPD, error) {
+	record, err := epd.Parse(epdString)
+	if err != nil {
+		var fenErr *core.FENError
+		if errors.As(err, &fenErr) {
+			return OutputGame{}, OutputEPD{}, ErrInvalidEPD.withCause(err).with("epdString", fenErr.Field)
+		}
+		return OutputGame{}, OutputEPD{}, ErrInvalidEPD.withCause(err).with("epdString", "")
+	}
+
+	outputEPD := OutputEPD{
+		BestMoves:           mapEPDMoves(record.Game, record.BestMoves),
+		AvoidMoves:          mapEPDMoves(record.Game, record.AvoidMoves),
+		ID:                  record.ID,
+		Comment:             record.Comment,
+		AnalysisDepth:       record.AnalysisDepth,
+		CentipawnEvaluation: record.CentipawnEvaluation,
+		PV:                  mapLineToSAN(record.Game, record.PredictedVariation),
+		Operations:          make([]OutputEPDOperation, len(record.Operations)),
+	}
+	for i, op := range record.Operations {
+		outputEPD.Operations[i] = OutputEPDOperation{Opcode: op.Opcode, Operands: op.Operands}
+	}
+	return a.outputGame(record.Game), outputEPD, nil
+}
+
+// mapEPDMoves maps the alternative moves of an EPD operation (e.g. `bm`) on the given
+// game to output actions, with their action strings in SAN.
+func mapEPDMoves(g core.Game, actions []core.Action) []OutputAction {
+	outputActions := make([]OutputAction, len(actions))
+	for i, action := range actions {
+		outputActions[i] = mapInternalActionToAction(action)
+		outputActions[i].ActionString, _ = printer.AlgebraicPrinter{}.PrintAction(
+			core.GameStep{StepAction: action, StepGame: g.DoAction(action), StepPreMoveGame: g},
+			printer.SANCharacteristics(),
+		)
+	}
+	return outputActions
+}
+
+// Caps of InputAILimits and InputAnalyzeOptions, so that a request can't keep the
+// API searching for too long. A search without maxTimeMs is limited to MaxAITimeMs
+// too, whatever its number of lines.
+const (
+	MaxAIDepth      = 32
+	MaxAITimeMs     = 60000
+	MaxAINodes      = 100000000
+	MaxAnalyzeLines = 10
+)
+
+func validateAILimits(limits InputAILimits) error {
+	switch {
+	case limits.MaxDepth < 0 || limits.MaxDepth > MaxAIDepth:
+		return ErrInvalidAILimits.with("maxDepth", strconv.Itoa(limits.MaxDepth))
+	case limits.MaxTimeMs < 0 || limits.MaxTimeMs > MaxAITimeMs:
+		return ErrInvalidAILimits.with("maxTimeMs", strconv.Itoa(limits.MaxTimeMs))
+	case limits.MaxNodes < 0 || limits.MaxNodes > MaxAINodes:
+		return ErrInvalidAILimits.with("maxNodes", strconv.FormatInt(limits.MaxNodes, 10))
+	}
+	if limits == (InputAILimits{}) {
+		return ErrMissingAILimits
+	}
+	return nil
+}
+
+func validateAnalyzeOptions(options InputAnalyzeOptions) error {
+	if options.Lines < 0 || options.Lines > MaxAnalyzeLines {
+		return ErrInvalidAnalyzeLines.with("lines", strconv.Itoa(options.Lines))
+	}
+	return validateAILimits(options.InputAILimits)
+}
+
+func mapInputAILimitsToLimits(limits InputAILimits) ai.Limits {
+	if limits.MaxTimeMs == 0 {
+		limits.MaxTimeMs = MaxAITimeMs
+	}
+	return ai.Limits{
+		Depth: limits.MaxDepth,
+		Time:  time.Duration(limits.MaxTimeMs) * time.Millisecond,
+		Nodes: limits.MaxNodes,
+	}
 }
 
-var errUnknownAIMode = errors.New("unknown AI mode: please use one of {random|easy|medium|hard}")
-var errUnknownTargetNotation = errors.New("unknown target notation: please use one of {Algebraic|Figurine|Descriptive|Coordinate|ICCF|Smith}")
+// withTablebase returns the limits with the API's tablebase, if any.
+func (a API) withTablebase(limits ai.Limits) ai.Limits {
+	if a.tablebase != nil {
+		limits.Tablebase = a.tablebase
+	}
+	return limits
+}
+
+// outputGame maps the game to an OutputGame, annotated with its outcome if the
+// API's tablebase covers it.
+func (a API) outputGame(g core.Game) OutputGame {
+	outputGame := mapGameToOutputGame(g)
+	if a.tablebase == nil {
+		return outputGame
+	}
+	if result, err := g.ProbeTablebase(a.tablebase); err == nil {
+		outputGame.IsTablebaseResult = true
+		outputGame.TablebaseWDL = result.WDL.String()
+		outputGame.TablebaseDTZ = result.DTZ
+	}
+	return outputGame
+}
+
+// mapSearchResultToOutputSearchResult maps a search result of the given game,
+// printing its PV in SAN.
+func mapSearchResultToOutputSearchResult(g core.Game, result ai.SearchResult) OutputSearchResult {
+	return OutputSearchResult{
+		Depth:  result.Depth,
+		Score:  result.Score,
+		Mate:   result.Mate,
+		PV:     mapLineToSAN(g, result.PV),
+		Nodes:  result.Nodes,
+		TimeMs: result.Time.Milliseconds(),
+	}
+}
+
+// mapLineToSAN returns the action strings in SAN of a line of play from the given game.
+func mapLineToSAN(g core.Game, line []core.Action) []string {
+	sans := make([]string, len(line))
+	for i, action := range line {
+		newGame := g.DoAction(action)
+		sans[i], _ = printer.AlgebraicPrinter{}.PrintAction(
+			core.GameStep{StepAction: action, StepGame: newGame, StepPreMoveGame: g},
+			printer.SANCharacteristics(),
+		)
+		g = newGame
+	}
+	return sans
+}
 
 func notationPrinter(targetNotation string) (printer.NotationPrinter, printer.GameCharacteristics, error) {
 	switch strings.ToLower(targetNotation) {
@@ -294,7 +596,7 @@ func notationPrinter(targetNotation string) (printer.NotationPrinter, printer.Ga
 	case "smith":
 		return printer.SmithPrinter{}, printer.GameCharacteristics{}, nil
 	case "pgn":
-		return printer.PGNPrinter{}, printer.SANCharacteristics(), nil
+		return printer.PGNPrinter{}, printer.PGNCharacteristics(), nil
 	}
-	return nil, printer.GameCharacteristics{}, errUnknownTargetNotation
+	return nil, printer.GameCharacteristics{}, ErrUnknownNotation.with("targetNotation", targetNotation)
 }
@@ -21,35 +21,135 @@ import (
 // `positionHistory` is optional: pass the `positionHistory` of a previous OutputGame
 // to enable threefold/fivefold repetition detection across stateless API calls (the
 // entries are opaque position hashes). Without it, repetitions cannot be detected.
+//
+// `isChess960` makes `fenString` be read as a Chess960 game, where `KQkq` refer to the
+// outermost rooks on either side of the king. It's not required for Shredder-FEN
+// castling fields (e.g. `HAha`), which always imply Chess960.
+//
+// `drawOfferedBy` is optional: one of `{Black|White}` if that player's draw offer
+// is pending (pass the `drawOfferedBy` of a previous OutputGame), or empty.
+//
+// `clock` is optional: supply it to play a timed game (see Clock), and then pass
+// the `clock` of each OutputGame to the next call.
+//
+// `variant` is optional: one of
+// `{standard|threeCheck|kingOfTheHill|racingKings|crazyhouse|bughouse|atomic|antichess|horde}` to play by
+// that variant's rules, or empty for standard chess. An empty game of a variant is
+// its initial game, and the FEN strings of Three-check games may end with the
+// checks each player gave, e.g. `+2+1` (White's, then Black's). Those of Crazyhouse
+// and Bughouse games have the pockets after the piece placement, e.g. `[QNp]`
+// (White's in upper case), and a `~` after each promoted piece. A Bughouse game is
+// one of the two boards, whose captures don't go to its own pockets: the caller
+// moves them to the other board's FEN string. Atomic FEN strings may lack a king
+// that was blown up, and Antichess ones may have any number of kings. In Horde,
+// White has up to 36 pieces and no king, and pawns on the first rank.
+//
+// `kingsOptional` is optional: true lets the `fenString` or `board` lack either
+// king, e.g. for teaching positions with only pawns. A player without a king can't
+// be in check, and loses when they have no pieces left.
 type InputGame struct {
 	FENString       string   `json:"fenString"`
 	Board           Board    `json:"board"`
 	PositionHistory []string `json:"positionHistory"`
+	IsChess960      bool     `json:"isChess960"`
+	DrawOfferedBy   string   `json:"drawOfferedBy"`
+	Clock           *Clock   `json:"clock"`
+	Variant         string   `json:"variant"`
+	KingsOptional   bool     `json:"kingsOptional"`
+}
+
+// Clock is the input and output interface of the clocks of a timed game.
+//
+// - `timeControl` is the time control in the format of PGN's TimeControl tag:
+// periods separated by `:`, each of them a number of seconds for the rest of the
+// game (e.g. `300`), optionally preceded by a number of moves (e.g. `40/7200`) and
+// followed by an increment (e.g. `180+2`). A period may have a simple delay (e.g.
+// `300d5`) or a Bronstein delay (e.g. `300b5`) instead of an increment.
+//
+// - `whiteTimeMs` and `blackTimeMs` are each player's remaining time, in
+// milliseconds. To start a timed game, leave them and the move counts at 0, and
+// both players get the time control's initial time.
+//
+// - `whiteMoves` and `blackMoves` are the number of moves each player made on the
+// clock, which tell the period of the time control they're in.
+type Clock struct {
+	TimeControl string `json:"timeControl"`
+	WhiteTimeMs int64  `json:"whiteTimeMs"`
+	BlackTimeMs int64  `json:"blackTimeMs"`
+	WhiteMoves  int    `json:"whiteMoves"`
+	BlackMoves  int    `json:"blackMoves"`
 }
 
 // InputAction is the input interface to supply a chess action.
 //
-// - `fromSquare` and `toSquare` are required (unless `isResign`, `isDraw` or
-// `actionString` is set), and must be board cells described in Algebraic Notation
+// - `fromSquare` and `toSquare` are required (unless `isResign`,
+// `isDrawOffer`, `isDrawAccept`, `isDrawDecline` or `actionString` is set, or
+// `isDrawClaim` is set without a move), and must be board cells described in Algebraic Notation
 // (e.g. `e2`). Note that `a1` is where the White Queen's Rook starts.
 //
 // - `promotionPieceType` is only required if the action is a promotion.
 //
 // - `promotionPieceType` must be one of: `{Queen|King|Bishop|Knight|Rook|Pawn}`.
 //
-// - `isResign` resigns the game for the player to move; `isDraw` proposes a draw,
-// which the engine assumes accepted. When either is set, the other fields are ignored.
+// - `isResign` resigns the game for the player to move, and the other fields are
+// ignored. `isDraw` is never valid: draws are offered and accepted, or claimed.
+//
+// - `isDrawOffer` offers a draw; the player who offers still moves next, and the
+// offer stands until the opponent answers it with `isDrawAccept` (which ends the
+// game in a draw) or `isDrawDecline`, or moves. These are only valid when the
+// game's `actions` include them, and the other fields are ignored.
+//
+// - `isDrawClaim` claims a draw by the 50-move rule or threefold repetition, which
+// is only valid when the game's `canClaimDraw` is true. It may also be set
+// together with a move (via squares or `actionString`) that leads to such a
+// position: the move is made, and the game is drawn if the claim is correct.
 //
 // - `actionString` supplies the action as a single move in any supported notation
 // (e.g. `Nf3`, `♘f3`, `g1f3`, `N-KB3`, `7163`); the notation is auto-detected. When
-// set, `fromSquare`/`toSquare`/`promotionPieceType` are ignored.
+// set, `fromSquare`/`toSquare`/`promotionPieceType` are ignored. Drops can only be
+// supplied this way, e.g. `N@f3`.
+//
+// - `elapsedMs` is the time the move took, in milliseconds, which is deducted from
+// the clock of the player to move in timed games (see InputGame's `clock`). If
+// it's more than their remaining time, their flag falls instead of the move
+// being made. It's ignored in untimed games and for actions that aren't moves.
 type InputAction struct {
 	FromSquare         string `json:"fromSquare"`
 	ToSquare           string `json:"toSquare"`
 	PromotionPieceType string `json:"promotionPieceType"`
 	IsResign           bool   `json:"isResign"`
 	IsDraw             bool   `json:"isDraw"`
+	IsDrawOffer        bool   `json:"isDrawOffer"`
+	IsDrawAccept       bool   `json:"isDrawAccept"`
+	IsDrawDecline      bool   `json:"isDrawDecline"`
+	IsDrawClaim        bool   `json:"isDrawClaim"`
 	ActionString       string `json:"actionString"`
+	ElapsedMs          int64  `json:"elapsedMs"`
+}
+
+// InputAILimits is the input interface to bound an AI search. At least one limit is
+// required, and zero means no limit, but a search never takes longer than 60000ms.
+//
+// - `maxDepth` is the maximum depth of the search, in plies (half moves), up to 32.
+//
+// - `maxTimeMs` is the maximum duration of the search, in milliseconds, up to 60000.
+//
+// - `maxNodes` is the maximum number of positions the search visits, up to
+// 100000000.
+type InputAILimits struct {
+	MaxDepth  int   `json:"maxDepth"`
+	MaxTimeMs int   `json:"maxTimeMs"`
+	MaxNodes  int64 `json:"maxNodes"`
+}
+
+// InputAnalyzeOptions is the input interface to configure a position analysis. It
+// has the same limits as InputAILimits, and at least one of them is required.
+//
+// - `lines` is the number of best moves to analyze, each with its own principal
+// variation (multi-PV), up to 10. Defaults to 1.
+type InputAnalyzeOptions struct {
+	InputAILimits
+	Lines int `json:"lines"`
 }
 
 // Board is one of the input interfaces to supply a chess game.
@@ -81,7 +181,10 @@ type Board struct {
 // OutputGame is the output interface that describes a chess game.
 // All API calls that return a chess game represent it with an OutputGame.
 //
-// - `fenString` represents the chess game as a FEN Notation string.
+// - `fenString` represents the chess game as a FEN Notation string. Chess960 games
+// use X-FEN castling fields.
+//
+// - `isChess960` is true for Chess960 (Fischer Random) games.
 //
 // - `actions` is the exhaustive list of actions that can follow from this game.
 //
@@ -94,7 +197,8 @@ type Board struct {
 // of `{Queen|King|Bishop|Knight|Rook|Pawn}`.
 //
 // - `blackKing` and `whiteKing` are the cells where the Kings are located. The
-// cells are represented in Algebraic Notation (e.g `e2`).
+// cells are represented in Algebraic Notation (e.g `e2`). It's an empty string
+// if there's no King, e.g. once blown up in Atomic.
 //
 // - `gameOverWinner` is one of `{Black|White|Unknown}`, and represents the winner
 // of the game, when `isGameOver` is true. `Unknown` otherwise.
@@ -104,6 +208,32 @@ type Board struct {
 // represented in Algebraic Notation (e.g `e2`). To find out which piece is in a
 // cell, inspect `blackPieces` and `whitePieces`.
 //
+// - `isDrawOffered` is true when `drawOfferedBy`, one of `{Black|White}`, offered
+// a draw that the opponent hasn't answered yet. `drawOfferedBy` is an empty
+// string otherwise.
+//
+// - `clock` is the game's clocks if the game is timed, or null. `isTimeout` is
+// true when the player to move ran out of time: they lose, unless their opponent
+// can't checkmate them, in which case the game is drawn.
+//
+// - `variant` is the variant whose rules the game is played by (see InputGame).
+// `whiteChecks` and `blackChecks` are the number of checks each player gave, in
+// Three-check games (3 wins). `whitePocket` and `blackPocket` are the number of
+// pieces of each type, one of `{Queen|Bishop|Knight|Rook|Pawn}`, that each player
+// may drop in Crazyhouse and Bughouse games. `isVariantEnd` is true when the variant's own rules
+// ended the game, e.g. by a king reaching the center in King of the Hill, by a
+// king reaching the 8th rank in Racing Kings, by blowing up a king in Atomic, by
+// losing all pieces in Antichess, or by White losing all pieces in Horde.
+// `kingsOptional` is true when the game may lack either king (see InputGame), and
+// then a player without pieces left loses too.
+//
+// - `isTablebaseResult` is true when cheesse has an endgame tablebase that covers
+// the position, which then tells its outcome with perfect play: `tablebaseWDL` is
+// one of `{Win|CursedWin|Draw|BlessedLoss|Loss}` for the player whose turn it is
+// to move (cursed wins and blessed losses are draws by the 50-move rule), and
+// `tablebaseDTZ` is the number of plies to the next capture or pawn move with
+// perfect play (negative when losing), or 0 if unknown.
+//
 // Because OutputGame is a superset of InputGame, you may supply an OutputGame to
 // any API call that expects an InputGame.
 type OutputGame struct {
@@ -132,10 +262,25 @@ type OutputGame struct {
 	IsStalemate             bool              `json:"isStalemate"`
 	IsDraw                  bool              `json:"isDraw"`
 	CanClaimDraw            bool              `json:"canClaimDraw"`
+	IsDrawOffered           bool              `json:"isDrawOffered"`
+	DrawOfferedBy           string            `json:"drawOfferedBy"`
 	IsGameOver              bool              `json:"isGameOver"`
 	GameOverWinner          string            `json:"gameOverWinner"`
 	InCheckBy               []string          `json:"inCheckBy"`
 	PositionHistory         []string          `json:"positionHistory"`
+	IsChess960              bool              `json:"isChess960"`
+	Clock                   *Clock            `json:"clock"`
+	IsTimeout               bool              `json:"isTimeout"`
+	Variant                 string            `json:"variant"`
+	WhiteChecks             int               `json:"whiteChecks"`
+	BlackChecks             int               `json:"blackChecks"`
+	WhitePocket             map[string]int    `json:"whitePocket"`
+	BlackPocket             map[string]int    `json:"blackPocket"`
+	IsVariantEnd            bool              `json:"isVariantEnd"`
+	KingsOptional           bool              `json:"kingsOptional"`
+	IsTablebaseResult       bool              `json:"isTablebaseResult"`
+	TablebaseWDL            string            `json:"tablebaseWDL"`
+	TablebaseDTZ            int               `json:"tablebaseDTZ"`
 }
 
 // OutputAction is the output interface that describes a chess action.
@@ -158,8 +303,12 @@ type OutputGame struct {
 // and represents the piece that was captured, if the action is a capture.
 // If the action is not a capture, it's an empty string.
 //
+// - `isDrop` is true for drops in Crazyhouse and Bughouse games, of a piece of
+// `fromPieceType` from the player's pocket onto `toSquare` (which is also
+// `fromPieceSquare`).
+//
 // - `actionString` is the action rendered in Standard Algebraic Notation
-// (e.g. `Nf3`). It is only populated by API calls that apply the action on
+// (e.g. `Nf3`, or `N@f3` for a drop). It is only populated by API calls that apply the action on
 // a game (e.g. `DoAction`); otherwise it's an empty string.
 type OutputAction struct {
 	FromPieceOwner     string `json:"fromPieceOwner"`
@@ -169,6 +318,10 @@ type OutputAction struct {
 	IsCapture          bool   `json:"isCapture"`
 	IsResign           bool   `json:"isResign"`
 	IsDraw             bool   `json:"isDraw"`
+	IsDrawOffer        bool   `json:"isDrawOffer"`
+	IsDrawAccept       bool   `json:"isDrawAccept"`
+	IsDrawDecline      bool   `json:"isDrawDecline"`
+	IsDrawClaim        bool   `json:"isDrawClaim"`
 	IsPromotion        bool   `json:"isPromotion"`
 	IsEnPassantCapture bool   `json:"isEnPassantCapture"`
 	IsCastle           bool   `json:"isCastle"`
@@ -176,6 +329,7 @@ type OutputAction struct {
 	IsQueensideCastle  bool   `json:"isQueensideCastle"`
 	PromotionPieceType string `json:"promotionPieceType"`
 	CapturedPieceType  string `json:"capturedPieceType"`
+	IsDrop             bool   `json:"isDrop"`
 	ActionString       string `json:"actionString"`
 }
 
@@ -193,12 +347,17 @@ type OutputAction struct {
 // - `steps` contains one OutputGameStep per valid action, even if the parse
 // failed midway: clients can render the valid prefix and flag the invalid tail.
 //
+// - `moveTree` contains the same valid actions as `steps` (the mainline), plus any
+// comments, annotations and variations the notation supports (i.e. PGN). Please
+// refer to OutputMoveNode's docs for format details.
+//
 // - `error` describes why the parse stopped, when `parseWasSuccessful` is false.
 type OutputParseResult struct {
 	NotationName       string            `json:"notationName"`
 	ParseWasSuccessful bool              `json:"parseWasSuccessful"`
 	ValidActionCount   int               `json:"validActionCount"`
 	Steps              []OutputGameStep  `json:"steps"`
+	MoveTree           []OutputMoveNode  `json:"moveTree"`
 	Metadata           map[string]string `json:"metadata,omitempty"` // e.g. PGN tag pairs
 	Error              string            `json:"error,omitempty"`
 }
@@ -226,10 +385,159 @@ type OutputGameStep struct {
 	ActionString string       `json:"actionString"`
 }
 
+// OutputMoveNode is the output interface that describes a node in the move tree
+// of a parsed or converted match: an OutputGameStep plus its annotations and the
+// variations that branch off it.
+//
+// - `comment` is the comment following the action, and `preComment` the comment
+// preceding it (e.g. at the start of a variation), without delimiters.
+//
+// - `nags` are the action's Numeric Annotation Glyphs (e.g. `1` for `$1` or `!`).
+//
+// - `commands` are the commands embedded in the comment following the action (e.g.
+// `[%clk 0:03:00]` is `{"name": "clk", "value": "0:03:00"}`), in order. They're not
+// part of `comment`.
+//
+// - `variations` are alternative lines to this action: each one starts from the
+// game BEFORE this action, and its nodes may in turn have variations.
+type OutputMoveNode struct {
+	OutputGameStep
+	Comment    string             `json:"comment,omitempty"`
+	PreComment string             `json:"preComment,omitempty"`
+	NAGs       []int              `json:"nags,omitempty"`
+	Commands   []OutputCommand    `json:"commands,omitempty"`
+	Variations [][]OutputMoveNode `json:"variations,omitempty"`
+}
+
+// OutputCommand is a command embedded in a comment, e.g. PGN's `[%eval 0.17]`.
+type OutputCommand struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// OutputSearchResult is the output interface that describes the outcome of an AI
+// search, i.e. of its deepest completed iteration.
+//
+// - `depth` is the depth of the search, in plies (half moves).
+//
+// - `score` is the evaluation of the position in centipawns, from the point of view
+// of the player who moves. Mates score ±100000.
+//
+// - `mate` is the number of moves to mate, if the search found one: positive when the
+// player who moves mates, negative when it gets mated. Zero otherwise.
+//
+// - `pv` is the principal variation, i.e. the line of play the search expects,
+// starting with the chosen action, in Standard Algebraic Notation.
+//
+// - `nodes` is the number of positions visited, and `timeMs` the search's duration
+// in milliseconds.
+type OutputSearchResult struct {
+	Depth  int      `json:"depth"`
+	Score  int      `json:"score"`
+	Mate   int      `json:"mate"`
+	PV     []string `json:"pv"`
+	Nodes  int64    `json:"nodes"`
+	TimeMs int64    `json:"timeMs"`
+}
+
+// OutputBookMove is the output interface that describes a move of the opening book.
+//
+// - `action` is the move, with its `actionString` in Standard Algebraic Notation.
+//
+// - `weight` is how often the book plays the move, relative to the position's other
+// book moves.
+type OutputBookMove struct {
+	Action OutputAction `json:"action"`
+	Weight int          `json:"weight"`
+}
+
+// OutputEPD is the output interface that describes the operations of a position in
+// Extended Position Description (see ParseEPD).
+//
+// - `bestMoves` and `avoidMoves` are the moves of the `bm` and `am` opcodes, with
+// their `actionString` in Standard Algebraic Notation.
+//
+// - `id` and `comment` are the operands of the `id` and `c0` opcodes, or empty.
+//
+// - `analysisDepth` and `centipawnEvaluation` are the operands of the `acd` and
+// `ce` opcodes, or null.
+//
+// - `pv` is the predicted variation of the `pv` opcode, in Standard Algebraic
+// Notation.
+//
+// - `operations` are the record's other operations, in order, with their operands
+// unquoted.
+type OutputEPD struct {
+	BestMoves           []OutputAction       `json:"bestMoves"`
+	AvoidMoves          []OutputAction       `json:"avoidMoves"`
+	ID                  string               `json:"id"`
+	Comment             string               `json:"comment"`
+	AnalysisDepth       *int                 `json:"analysisDepth"`
+	CentipawnEvaluation *int                 `json:"centipawnEvaluation"`
+	PV                  []string             `json:"pv"`
+	Operations          []OutputEPDOperation `json:"operations"`
+}
+
+// OutputEPDOperation is an operation of an EPD record, e.g. `{"opcode": "c1",
+// "operands": ["a comment"]}`.
+type OutputEPDOperation struct {
+	Opcode   string   `json:"opcode"`
+	Operands []string `json:"operands"`
+}
+
+// OutputSession is the output interface that describes a game session (see
+// CreateSession).
+//
+// - `id` identifies the session in further API calls.
+//
+// - `game` is the session's current game.
+//
+// - `actions` are the actions done in the session, in order, each with its
+// `actionString` in Standard Algebraic Notation. Pass their count as
+// DoSessionAction's `actionCount` to only act on the game you saw.
+//
+// - `metadata` are the game's PGN tags, e.g. those of the PGN it was created from.
+type OutputSession struct {
+	ID       string            `json:"id"`
+	Game     OutputGame        `json:"game"`
+	Actions  []OutputAction    `json:"actions"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// OutputSessionEvent is the output interface that describes an event of a game
+// session (see SessionEvents): an action done on it, and the state of the game
+// after it.
+//
+// - `id` is the event's number: the session's first action is event 1, and so
+// on. Resume a stream of events from the id of the last event received.
+//
+// - `action` is the action, with its `actionString` in Standard Algebraic
+// Notation. If the player's flag fell before it (see `isTimeout`), it wasn't made.
+//
+// - The other fields are those of the OutputGame after the action.
+type OutputSessionEvent struct {
+	ID             int          `json:"id"`
+	Action         OutputAction `json:"action"`
+	FENString      string       `json:"fenString"`
+	Clock          *Clock       `json:"clock"`
+	IsCheck        bool         `json:"isCheck"`
+	IsCheckmate    bool         `json:"isCheckmate"`
+	IsStalemate    bool         `json:"isStalemate"`
+	IsDraw         bool         `json:"isDraw"`
+	CanClaimDraw   bool         `json:"canClaimDraw"`
+	IsDrawOffered  bool         `json:"isDrawOffered"`
+	DrawOfferedBy  string       `json:"drawOfferedBy"`
+	IsTimeout      bool         `json:"isTimeout"`
+	IsVariantEnd   bool         `json:"isVariantEnd"`
+	IsGameOver     bool         `json:"isGameOver"`
+	GameOverWinner string       `json:"gameOverWinner"`
+}
+
 func mapGameToOutputGame(g core.Game) OutputGame {
 	var o OutputGame
 
 	o.FENString = g.ToFEN()
+	o.IsChess960 = g.IsChess960
 	o.Board = mapInternalBoardToBoard(g.ToBoard())
 	o.Actions = make([]OutputAction, len(g.Actions))
 	o.CanWhiteCastle = g.CanWhiteCastle
@@ -247,8 +555,8 @@ func mapGameToOutputGame(g core.Game) OutputGame {
 	whitePieces := g.Pieces(core.ColorWhite)
 	o.BlackPieces = make(map[string]string, len(blackPieces))
 	o.WhitePieces = make(map[string]string, len(whitePieces))
-	o.BlackKing = g.King(core.ColorBlack).XY.ToAlgebraic()
-	o.WhiteKing = g.King(core.ColorWhite).XY.ToAlgebraic()
+	o.BlackKing = mapKingToOutputKing(g.King(core.ColorBlack))
+	o.WhiteKing = mapKingToOutputKing(g.King(core.ColorWhite))
 	o.IsCheck = g.IsCheck
 	o.IsDoubleCheck = g.IsDoubleCheck
 	o.IsDiscoverCheck = g.IsDiscoverCheck
@@ -256,7 +564,20 @@ func mapGameToOutputGame(g core.Game) OutputGame {
 	o.IsStalemate = g.IsStalemate
 	o.IsDraw = g.IsDraw
 	o.CanClaimDraw = g.CanClaimDraw
+	o.IsDrawOffered = g.IsDrawOffered
+	if g.IsDrawOffered {
+		o.DrawOfferedBy = g.DrawOfferedBy.String()
+	}
 	o.IsGameOver = g.IsGameOver
+	o.IsTimeout = g.IsTimeout
+	o.Variant = g.Variant().Name()
+	o.WhiteChecks = g.ChecksGiven(core.ColorWhite)
+	o.BlackChecks = g.ChecksGiven(core.ColorBlack)
+	o.WhitePocket = mapPocketToOutputPocket(g, core.ColorWhite)
+	o.BlackPocket = mapPocketToOutputPocket(g, core.ColorBlack)
+	o.IsVariantEnd = g.IsVariantEnd
+	o.KingsOptional = g.Validation().KingsOptional
+	o.Clock = mapClockToOutputClock(g.Clock)
 	o.GameOverWinner = g.GameOverWinner.String()
 	o.InCheckBy = make([]string, len(g.InCheckBy))
 
@@ -289,6 +610,20 @@ func mapGameToOutputGame(g core.Game) OutputGame {
 	return o
 }
 
+// mapClockToOutputClock maps the clock to a Clock, or to nil if it's untimed.
+func mapClockToOutputClock(c core.Clock) *Clock {
+	if !c.IsTimed() {
+		return nil
+	}
+	return &Clock{
+		TimeControl: c.TimeControl.String(),
+		WhiteTimeMs: c.Remaining[core.ColorWhite].Milliseconds(),
+		BlackTimeMs: c.Remaining[core.ColorBlack].Milliseconds(),
+		WhiteMoves:  c.Moves[core.ColorWhite],
+		BlackMoves:  c.Moves[core.ColorBlack],
+	}
+}
+
 func mapInternalBoardToBoard(b core.Board) Board {
 	return Board{
 		Board:                   b.Board,
@@ -326,6 +661,10 @@ func mapInternalActionToAction(a core.Action) OutputAction {
 		IsCapture:          a.IsCapture,
 		IsResign:           a.IsResign,
 		IsDraw:             a.IsDraw,
+		IsDrawOffer:        a.IsDrawOffer,
+		IsDrawAccept:       a.IsDrawAccept,
+		IsDrawDecline:      a.IsDrawDecline,
+		IsDrawClaim:        a.IsDrawClaim,
 		IsPromotion:        a.IsPromotion,
 		IsEnPassantCapture: a.IsEnPassantCapture,
 		IsCastle:           a.IsCastle,
@@ -333,7 +672,77 @@ func mapInternalActionToAction(a core.Action) OutputAction {
 		IsQueensideCastle:  a.IsQueensideCastle,
 		PromotionPieceType: a.PromotionPieceType.String(),
 		CapturedPieceType:  a.CapturedPiece.PieceType.String(),
+		IsDrop:             a.IsDrop,
+	}
+}
+
+// mapPocketToOutputPocket maps the pocket of the given color to the number of
+// pieces of each type in it, without the types it has none of.
+func mapPocketToOutputPocket(g core.Game, c core.Color) map[string]int {
+	pocket := map[string]int{}
+	for _, t := range []core.PieceType{core.PieceQueen, core.PieceBishop, core.PieceKnight, core.PieceRook, core.PiecePawn} {
+		if n := g.PocketCount(c, t); n > 0 {
+			pocket[t.String()] = n
+		}
+	}
+	return pocket
+}
+
+// mapKingToOutputKing maps a king to its cell, or to an empty string if there's
+// none (see core.Game.King).
+func mapKingToOutputKing(king core.Piece) string {
+	if king.PieceType == core.PieceNone {
+		return ""
+	}
+	return king.XY.ToAlgebraic()
+}
+
+// mapGameStepToOutputSessionEvent maps the step of a session's action to its event
+// with the given id.
+func mapGameStepToOutputSessionEvent(id int, gs core.GameStep) OutputSessionEvent {
+	g := gs.StepGame
+	event := OutputSessionEvent{
+		ID:             id,
+		Action:         mapInternalActionToAction(gs.StepAction),
+		FENString:      g.ToFEN(),
+		Clock:          mapClockToOutputClock(g.Clock),
+		IsCheck:        g.IsCheck,
+		IsCheckmate:    g.IsCheckmate,
+		IsStalemate:    g.IsStalemate,
+		IsDraw:         g.IsDraw,
+		CanClaimDraw:   g.CanClaimDraw,
+		IsDrawOffered:  g.IsDrawOffered,
+		IsTimeout:      g.IsTimeout,
+		IsVariantEnd:   g.IsVariantEnd,
+		IsGameOver:     g.IsGameOver,
+		GameOverWinner: g.GameOverWinner.String(),
+	}
+	event.Action.ActionString = gs.StepString
+	if g.IsDrawOffered {
+		event.DrawOfferedBy = g.DrawOfferedBy.String()
+	}
+	return event
+}
+
+// mapInternalActionToInputAction maps the action to an InputAction with its squares
+// and promotion piece type, or the flag of an action that isn't a move.
+func mapInternalActionToInputAction(a core.Action) InputAction {
+	ia := InputAction{
+		IsResign:      a.IsResign,
+		IsDraw:        a.IsDraw,
+		IsDrawOffer:   a.IsDrawOffer,
+		IsDrawAccept:  a.IsDrawAccept,
+		IsDrawDecline: a.IsDrawDecline,
+		IsDrawClaim:   a.IsDrawClaim,
 	}
+	if a.IsMove() {
+		ia.FromSquare = a.FromPiece.XY.ToAlgebraic()
+		ia.ToSquare = a.ToXY.ToAlgebraic()
+		if a.IsPromotion {
+			ia.PromotionPieceType = a.PromotionPieceType.String()
+		}
+	}
+	return ia
 }
 
 func mapGameStepsToOutputGameSteps(gss []core.GameStep) []OutputGameStep {
@@ -347,3 +756,36 @@ func mapGameStepsToOutputGameSteps(gss []core.GameStep) []OutputGameStep {
 	}
 	return ogs
 }
+
+// mapMoveTreeToOutputMoveTree maps a move tree (recursively) to OutputMoveNodes,
+// using the given function to render each node's action string.
+func mapMoveTreeToOutputMoveTree(nodes []core.MoveNode, actionString func(core.GameStep) (string, error)) ([]OutputMoveNode, error) {
+	outputNodes := make([]OutputMoveNode, len(nodes))
+	for i, node := range nodes {
+		s, err := actionString(node.GameStep)
+		if err != nil {
+			return nil, err
+		}
+		outputNodes[i] = OutputMoveNode{
+			OutputGameStep: OutputGameStep{
+				Game:         mapGameToOutputGame(node.StepGame),
+				Action:       mapInternalActionToAction(node.StepAction),
+				ActionString: s,
+			},
+			Comment:    node.StepComment,
+			PreComment: node.PreComment,
+			NAGs:       node.NAGs,
+		}
+		for _, command := range node.Commands {
+			outputNodes[i].Commands = append(outputNodes[i].Commands, OutputCommand{Name: command.Name, Value: command.Value})
+		}
+		for _, variation := range node.Variations {
+			outputVariation, err := mapMoveTreeToOutputMoveTree(variation, actionString)
+			if err != nil {
+				return nil, err
+			}
+			outputNodes[i].Variations = append(outputNodes[i].Variations, outputVariation)
+		}
+	}
+	return outputNodes, nil
+}
@@ -87,6 +87,9 @@ func TestAPIDefaultGame(t *testing.T) {
 		IsGameOver:     false,
 		GameOverWinner: "Unknown",
 		InCheckBy:      []string{},
+		Variant:        "standard",
+		WhitePocket:    map[string]int{},
+		BlackPocket:    map[string]int{},
 	}
 	actual := New().DefaultGame()
 	actual.Actions = []OutputAction{} // Not testing every single action on this test
@@ -179,7 +182,7 @@ func TestParseGame(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			actualOutputGame, err := New().ParseGame(tc.inputGame)
-			require.Equal(t, tc.err, err)
+			require.ErrorIs(t, err, tc.err)
 			if err != nil {
 				return
 			}
@@ -198,40 +201,40 @@ func TestDoAction(t *testing.T) {
 		err          error
 	}{
 		{
-			name:        "errAlgebraicSquareInvalidOrOutOfBounds: empty FromSquare",
+			name:        "ErrInvalidSquare: empty FromSquare",
 			inputGame:   InputGame{FENString: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
 			inputAction: InputAction{FromSquare: "", ToSquare: "e4"},
-			err:         errAlgebraicSquareInvalidOrOutOfBounds,
+			err:         ErrInvalidSquare,
 		},
 		{
-			name:        "errAlgebraicSquareInvalidOrOutOfBounds: empty ToSquare",
+			name:        "ErrInvalidSquare: empty ToSquare",
 			inputGame:   InputGame{FENString: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
 			inputAction: InputAction{FromSquare: "e2"},
-			err:         errAlgebraicSquareInvalidOrOutOfBounds,
+			err:         ErrInvalidSquare,
 		},
 		{
-			name:        "errAlgebraicSquareInvalidOrOutOfBounds: FromSquare out of bounds file",
+			name:        "ErrInvalidSquare: FromSquare out of bounds file",
 			inputGame:   InputGame{FENString: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
 			inputAction: InputAction{FromSquare: "i2", ToSquare: "e4"},
-			err:         errAlgebraicSquareInvalidOrOutOfBounds,
+			err:         ErrInvalidSquare,
 		},
 		{
-			name:        "errAlgebraicSquareInvalidOrOutOfBounds: FromSquare out of bounds rank",
+			name:        "ErrInvalidSquare: FromSquare out of bounds rank",
 			inputGame:   InputGame{FENString: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
 			inputAction: InputAction{FromSquare: "e0", ToSquare: "e4"},
-			err:         errAlgebraicSquareInvalidOrOutOfBounds,
+			err:         ErrInvalidSquare,
 		},
 		{
-			name:        "errAlgebraicSquareInvalidOrOutOfBounds: ToSquare out of bounds file",
+			name:        "ErrInvalidSquare: ToSquare out of bounds file",
 			inputGame:   InputGame{FENString: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
 			inputAction: InputAction{FromSquare: "e2", ToSquare: "i4"},
-			err:         errAlgebraicSquareInvalidOrOutOfBounds,
+			err:         ErrInvalidSquare,
 		},
 		{
-			name:        "errAlgebraicSquareInvalidOrOutOfBounds: ToSquare out of bounds rank",
+			name:        "ErrInvalidSquare: ToSquare out of bounds rank",
 			inputGame:   InputGame{FENString: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
 			inputAction: InputAction{FromSquare: "e2", ToSquare: "e9"},
-			err:         errAlgebraicSquareInvalidOrOutOfBounds,
+			err:         ErrInvalidSquare,
 		},
 		{
 			name:        "does standard Alekhine on a DefaultGame",
@@ -449,7 +452,7 @@ func TestDoAction(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			actualOutputGame, actualOutputAction, err := New().DoAction(tc.inputGame, tc.inputAction)
-			require.Equal(t, tc.err, err)
+			require.ErrorIs(t, err, tc.err)
 			if err != nil {
 				return
 			}
@@ -460,13 +463,97 @@ func TestDoAction(t *testing.T) {
 }
 
 func TestDoActionDraw(t *testing.T) {
-	outputGame, outputAction, err := New().DoAction(InputGame{}, InputAction{IsDraw: true})
+	// Draws are offered and accepted, or claimed, rather than agreed by one player
+	_, _, err := New().DoAction(InputGame{}, InputAction{IsDraw: true})
+	assert.ErrorIs(t, err, ErrIllegalMove)
+}
+
+func TestDoActionDrawOffer(t *testing.T) {
+	api := New()
+	offered, outputAction, err := api.DoAction(InputGame{}, InputAction{IsDrawOffer: true})
 	require.NoError(t, err)
-	assert.True(t, outputAction.IsDraw)
-	assert.Equal(t, "White", outputAction.FromPieceOwner)
-	assert.True(t, outputGame.IsDraw)
-	assert.True(t, outputGame.IsGameOver)
-	assert.Equal(t, "Unknown", outputGame.GameOverWinner)
+	assert.True(t, outputAction.IsDrawOffer)
+	assert.Equal(t, "(=)", outputAction.ActionString)
+	assert.True(t, offered.IsDrawOffered)
+	assert.Equal(t, "White", offered.DrawOfferedBy)
+	assert.Equal(t, "White", offered.Board.Turn)
+
+	// The offer can't be accepted by the offerer, and stands after their move
+	_, _, err = api.DoAction(inputGameOf(offered), InputAction{IsDrawAccept: true})
+	assert.ErrorIs(t, err, ErrIllegalMove)
+	moved, _, err := api.DoAction(inputGameOf(offered), InputAction{FromSquare: "e2", ToSquare: "e4"})
+	require.NoError(t, err)
+	assert.Equal(t, "White", moved.DrawOfferedBy)
+
+	accepted, outputAction, err := api.DoAction(inputGameOf(moved), InputAction{IsDrawAccept: true})
+	require.NoError(t, err)
+	assert.True(t, outputAction.IsDrawAccept)
+	assert.Equal(t, "Black", outputAction.FromPieceOwner)
+	assert.True(t, accepted.IsDraw)
+	assert.True(t, accepted.IsGameOver)
+
+	declined, _, err := api.DoAction(inputGameOf(moved), InputAction{IsDrawDecline: true})
+	require.NoError(t, err)
+	assert.False(t, declined.IsDrawOffered)
+	assert.Equal(t, "", declined.DrawOfferedBy)
+	assert.Equal(t, "Black", declined.Board.Turn)
+
+	_, err = api.ParseGame(InputGame{DrawOfferedBy: "Nobody"})
+	assert.ErrorIs(t, err, ErrInvalidDrawOfferedBy)
+}
+
+func TestDoActionDrawClaim(t *testing.T) {
+	api := New()
+	_, _, err := api.DoAction(InputGame{}, InputAction{IsDrawClaim: true})
+	assert.ErrorIs(t, err, ErrIllegalMove, "nothing to claim")
+
+	claimed, outputAction, err := api.DoAction(InputGame{FENString: "8/8/4k3/8/8/4K3/8/6R1 w - - 100 80"}, InputAction{IsDrawClaim: true})
+	require.NoError(t, err)
+	assert.True(t, outputAction.IsDrawClaim)
+	assert.True(t, claimed.IsDraw)
+	assert.True(t, claimed.IsGameOver)
+
+	// Claiming with the move that reaches the 50-move rule
+	claimed, outputAction, err = api.DoAction(InputGame{FENString: "8/8/4k3/8/8/4K3/8/6R1 w - - 99 80"}, InputAction{ActionString: "Rg2", IsDrawClaim: true})
+	require.NoError(t, err)
+	assert.True(t, outputAction.IsDrawClaim)
+	assert.Equal(t, "Rg2", outputAction.ActionString)
+	assert.True(t, claimed.IsDraw)
+
+	// An incorrect claim only makes the move
+	moved, _, err := api.DoAction(InputGame{}, InputAction{FromSquare: "e2", ToSquare: "e4", IsDrawClaim: true})
+	require.NoError(t, err)
+	assert.False(t, moved.IsGameOver)
+	assert.Equal(t, "Black", moved.Board.Turn)
+}
+
+func TestDoActionClock(t *testing.T) {
+	api := New()
+	game := InputGame{Clock: &Clock{TimeControl: "180+2"}}
+	started, err := api.ParseGame(game)
+	require.NoError(t, err)
+	assert.Equal(t, &Clock{TimeControl: "180+2", WhiteTimeMs: 180000, BlackTimeMs: 180000}, started.Clock)
+
+	moved, _, err := api.DoAction(game, InputAction{ActionString: "e4", ElapsedMs: 4500})
+	require.NoError(t, err)
+	assert.Equal(t, &Clock{TimeControl: "180+2", WhiteTimeMs: 177500, BlackTimeMs: 180000, WhiteMoves: 1}, moved.Clock)
+	assert.False(t, moved.IsTimeout)
+
+	flagged, _, err := api.DoAction(inputGameOf(moved), InputAction{ActionString: "e5", ElapsedMs: 180000})
+	require.NoError(t, err)
+	assert.True(t, flagged.IsTimeout)
+	assert.True(t, flagged.IsGameOver)
+	assert.Equal(t, "White", flagged.GameOverWinner)
+	assert.Equal(t, int64(0), flagged.Clock.BlackTimeMs)
+
+	untimed, _, err := api.DoAction(InputGame{}, InputAction{ActionString: "e4", ElapsedMs: 4500})
+	require.NoError(t, err)
+	assert.Nil(t, untimed.Clock)
+
+	_, err = api.ParseGame(InputGame{Clock: &Clock{TimeControl: "*180"}})
+	assert.ErrorIs(t, err, ErrInvalidTimeControl)
+	_, _, err = api.DoAction(game, InputAction{ActionString: "e4", ElapsedMs: -1})
+	assert.ErrorIs(t, err, ErrInvalidElapsedTime)
 }
 
 func TestDoActionResign(t *testing.T) {
@@ -477,14 +564,16 @@ func TestDoActionResign(t *testing.T) {
 	assert.Equal(t, "Black", outputGame.GameOverWinner)
 }
 
-func TestDrawActionIsAvailable(t *testing.T) {
+func TestDrawActionIsNotAvailable(t *testing.T) {
 	outputGame, err := New().ParseGame(InputGame{})
 	require.NoError(t, err)
-	hasDraw := false
 	for _, a := range outputGame.Actions {
-		if a.IsDraw {
-			hasDraw = true
-		}
+		assert.False(t, a.IsDraw, "draw action shouldn't be among the available actions")
 	}
-	assert.True(t, hasDraw, "draw action should be among the available actions")
+}
+
+// inputGameOf returns the InputGame that continues the given game, as a client
+// that keeps no state would send it.
+func inputGameOf(g OutputGame) InputGame {
+	return InputGame{FENString: g.FENString, PositionHistory: g.PositionHistory, DrawOfferedBy: g.DrawOfferedBy, Clock: g.Clock}
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/marianogappa/cheesse/core"
 	"github.com/marianogappa/cheesse/parser"
@@ -10,43 +11,103 @@ import (
 )
 
 func (a API) parseGame(g InputGame) (core.Game, error) {
+	variant := core.VariantStandard
+	if g.Variant != "" {
+		var ok bool
+		if variant, ok = core.VariantByName(g.Variant); !ok {
+			return core.Game{}, ErrInvalidVariant.with("variant", g.Variant)
+		}
+	}
+	validation := variant.Validation()
+	validation.KingsOptional = validation.KingsOptional || g.KingsOptional
 	var (
 		parsedGame core.Game
 		err        error
 	)
 	switch {
+	case g.FENString != "" && g.IsChess960:
+		parsedGame, err = core.NewChess960GameFromFENWithValidation(g.FENString, variant, validation)
 	case g.FENString != "":
-		parsedGame, err = core.NewGameFromFEN(g.FENString)
+		parsedGame, err = core.NewGameFromFENWithValidation(g.FENString, variant, validation)
 	case len(g.Board.Board) > 0:
-		parsedGame, err = core.NewGameFromBoard(mapBoardToInternalBoard(g.Board))
+		parsedGame, err = core.NewGameFromBoardWithValidation(mapBoardToInternalBoard(g.Board), variant, validation)
 	default:
-		var defaultGame, _ = core.NewGameFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
-		parsedGame = defaultGame
+		parsedGame = core.NewVariantGame(variant)
 	}
 	if err != nil {
-		return core.Game{}, err
+		return core.Game{}, gameError(err)
+	}
+	if parsedGame.Variant() != variant {
+		parsedGame = parsedGame.WithVariant(variant)
 	}
 	if len(g.PositionHistory) > 0 {
 		history := make([]uint64, len(g.PositionHistory))
 		for i, s := range g.PositionHistory {
 			if history[i], err = strconv.ParseUint(s, 16, 64); err != nil {
-				return core.Game{}, errInvalidPositionHistory
+				return core.Game{}, ErrInvalidPositionHistory.with("positionHistory", s)
 			}
 		}
 		parsedGame = parsedGame.WithPositionHistory(history)
 	}
+	switch g.DrawOfferedBy {
+	case "":
+	case "White":
+		parsedGame = parsedGame.WithDrawOfferedBy(core.ColorWhite)
+	case "Black":
+		parsedGame = parsedGame.WithDrawOfferedBy(core.ColorBlack)
+	default:
+		return core.Game{}, ErrInvalidDrawOfferedBy.with("drawOfferedBy", g.DrawOfferedBy)
+	}
+	if g.Clock != nil {
+		clock, err := a.parseClock(*g.Clock)
+		if err != nil {
+			return core.Game{}, err
+		}
+		parsedGame = parsedGame.WithClock(clock)
+	}
 	return parsedGame, nil
 }
 
+func (a API) parseClock(c Clock) (core.Clock, error) {
+	tc, err := core.ParseTimeControl(c.TimeControl)
+	if err != nil {
+		return core.Clock{}, ErrInvalidTimeControl.with("clock.timeControl", c.TimeControl)
+	}
+	if c.WhiteTimeMs < 0 || c.BlackTimeMs < 0 || c.WhiteMoves < 0 || c.BlackMoves < 0 {
+		return core.Clock{}, ErrInvalidClock.with("clock", "")
+	}
+	clock := core.NewClock(tc)
+	if c.WhiteTimeMs == 0 && c.BlackTimeMs == 0 && c.WhiteMoves == 0 && c.BlackMoves == 0 {
+		return clock, nil // A new clock
+	}
+	clock.Remaining[core.ColorWhite] = time.Duration(c.WhiteTimeMs) * time.Millisecond
+	clock.Remaining[core.ColorBlack] = time.Duration(c.BlackTimeMs) * time.Millisecond
+	clock.Moves[core.ColorWhite], clock.Moves[core.ColorBlack] = c.WhiteMoves, c.BlackMoves
+	return clock, nil
+}
+
 func (a API) parseAction(ia InputAction, g core.Game) (core.Action, error) {
-	// Terminal actions don't carry squares; match them directly.
-	if ia.IsResign || ia.IsDraw {
+	// Actions that aren't moves don't carry squares; match them directly. A draw
+	// claim may come with the move that allows it, though.
+	isDrawClaimWithMove := ia.IsDrawClaim && (ia.ActionString != "" || ia.FromSquare != "" || ia.ToSquare != "")
+	if ia.IsResign || ia.IsDraw || ia.IsDrawOffer || ia.IsDrawAccept || ia.IsDrawDecline || (ia.IsDrawClaim && !isDrawClaimWithMove) {
 		for _, action := range g.Actions {
-			if action.IsResign == ia.IsResign && action.IsDraw == ia.IsDraw {
+			if !action.IsMove() && action.IsResign == ia.IsResign && action.IsDraw == ia.IsDraw &&
+				action.IsDrawOffer == ia.IsDrawOffer && action.IsDrawAccept == ia.IsDrawAccept &&
+				action.IsDrawDecline == ia.IsDrawDecline && action.IsDrawClaim == ia.IsDrawClaim {
 				return action, nil
 			}
 		}
-		return core.Action{}, errInvalidActionForGivenGame
+		return core.Action{}, ErrIllegalMove
+	}
+	if isDrawClaimWithMove {
+		ia.IsDrawClaim = false
+		action, err := a.parseAction(ia, g)
+		if err != nil {
+			return core.Action{}, err
+		}
+		action.IsDrawClaim = true
+		return action, nil
 	}
 
 	// An action supplied as a move string in any notation: auto-detect and parse
@@ -56,22 +117,22 @@ func (a API) parseAction(ia InputAction, g core.Game) (core.Action, error) {
 		// An ambiguous SAN string (e.g. "Nd2" with two knights reaching d2) must be
 		// rejected rather than silently resolved to an arbitrary action.
 		if matches, err := parser.NewGenericNotationParser(pgn.NewVariantPGN()).MatchHalfMove(ia.ActionString, g); err == nil && len(matches) > 1 {
-			return core.Action{}, errAmbiguousActionString
+			return core.Action{}, ErrAmbiguousAction.with("actionString", ia.ActionString)
 		}
 		for _, s := range []string{ia.ActionString, "1. " + ia.ActionString} {
-			gameSteps, result := parseNotationAutoDetect(g, s)
-			if result.ParseWasSuccessful && len(gameSteps) == 1 {
-				return gameSteps[0].StepAction, nil
+			parsedNotation, result := parseNotationAutoDetect(g, s)
+			if result.ParseWasSuccessful && len(parsedNotation.GameSteps) == 1 {
+				return parsedNotation.GameSteps[0].StepAction, nil
 			}
 		}
-		return core.Action{}, errInvalidActionForGivenGame
+		return core.Action{}, ErrIllegalMove
 	}
 
-	fromXY, err := a.algebraicToXY(strings.ToLower(ia.FromSquare))
+	fromXY, err := a.algebraicToXY("fromSquare", strings.ToLower(ia.FromSquare))
 	if err != nil {
 		return core.Action{}, err
 	}
-	toXY, err := a.algebraicToXY(strings.ToLower(ia.ToSquare))
+	toXY, err := a.algebraicToXY("toSquare", strings.ToLower(ia.ToSquare))
 	if err != nil {
 		return core.Action{}, err
 	}
@@ -85,8 +146,8 @@ func (a API) parseAction(ia InputAction, g core.Game) (core.Action, error) {
 	}
 
 	for _, action := range g.Actions {
-		if action.IsResign || action.IsDraw {
-			continue // Terminal actions carry no squares; matched above.
+		if !action.IsMove() || action.IsDrop {
+			continue // Actions that aren't moves carry no squares (matched above); drops are supplied as action strings.
 		}
 		if action.FromPiece.XY != fromXY || action.ToXY != toXY || (action.IsPromotion && action.PromotionPieceType != promotionPieceType) {
 			continue
@@ -94,12 +155,20 @@ func (a API) parseAction(ia InputAction, g core.Game) (core.Action, error) {
 		return action, nil
 	}
 
-	return core.Action{}, errInvalidActionForGivenGame
+	// Chess960 UIs conventionally castle by moving the king onto its own rook, which is
+	// the only unambiguous input when the king's destination is also a normal move.
+	for _, action := range g.Actions {
+		if action.IsCastle && action.FromPiece.XY == fromXY && g.CastlingRookXY(action.FromPiece.Owner, action.IsKingsideCastle) == toXY {
+			return action, nil
+		}
+	}
+
+	return core.Action{}, ErrIllegalMove
 }
 
-func (a API) algebraicToXY(sq string) (core.XY, error) {
+func (a API) algebraicToXY(field, sq string) (core.XY, error) {
 	if len(sq) != 2 || sq[0] < 'a' || sq[0] > 'h' || sq[1] < '1' || sq[1] > '8' {
-		return core.XY{}, errAlgebraicSquareInvalidOrOutOfBounds
+		return core.XY{}, ErrInvalidSquare.with(field, sq)
 	}
 	return core.XY{X: int(sq[0] - 'a'), Y: int('8' - sq[1])}, nil
 }
@@ -116,7 +185,7 @@ func (a API) stringToPieceType(s string) (core.PieceType, error) {
 	}
 	pt, ok := m[s]
 	if !ok {
-		return core.PieceNone, errInvalidPieceTypeName
+		return core.PieceNone, ErrInvalidPieceType.with("promotionPieceType", s)
 	}
 	return pt, nil
 }
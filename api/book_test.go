@@ -0,0 +1,82 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marianogappa/cheesse/book"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBook(t *testing.T) *book.Book {
+	t.Helper()
+	builder := book.NewBuilder()
+	const games = `[Result "1-0"]
+
+1. e4 e5 2. Nf3 Nc6 1-0
+
+[Result "1/2-1/2"]
+
+1. e4 c5 1/2-1/2
+
+[Result "1-0"]
+
+1. d4 d5 2. c4 1-0
+`
+	added, err := builder.AddPGN(strings.NewReader(games))
+	require.NoError(t, err)
+	require.Equal(t, 3, added)
+	return builder.Book()
+}
+
+func TestBookMoves(t *testing.T) {
+	a := New().WithBook(newTestBook(t))
+
+	moves, err := a.BookMoves(InputGame{})
+	require.NoError(t, err)
+	require.Len(t, moves, 2)
+	assert.Equal(t, "e4", moves[0].Action.ActionString)
+	assert.Equal(t, 3, moves[0].Weight)
+	assert.Equal(t, "d4", moves[1].Action.ActionString)
+	assert.Equal(t, 2, moves[1].Weight)
+
+	moves, err = a.BookMoves(InputGame{FENString: "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2"})
+	require.NoError(t, err)
+	require.Len(t, moves, 1)
+	assert.Equal(t, "Nf3", moves[0].Action.ActionString)
+
+	moves, err = a.BookMoves(InputGame{FENString: "4k3/8/8/8/8/8/8/4K2R w K - 0 1"})
+	require.NoError(t, err)
+	assert.Empty(t, moves)
+
+	_, err = New().BookMoves(InputGame{})
+	assert.ErrorIs(t, err, ErrNoBook)
+}
+
+func TestAIMove_Book(t *testing.T) {
+	a := New().WithBook(newTestBook(t))
+
+	// After 1. e4, the book's only move is 1... c5, as 1... e5 lost
+	game := InputGame{FENString: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1"}
+	for _, mode := range []string{"easy", "medium", "hard"} {
+		t.Run(mode, func(t *testing.T) {
+			_, action, ok, err := a.AIMove(game, mode)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, "c5", action.ActionString)
+		})
+	}
+
+	t.Run("out of book", func(t *testing.T) {
+		_, action, ok, err := a.AIMove(InputGame{FENString: "3r2k1/5ppp/8/8/8/8/4RPPP/4R1K1 w - - 0 1"}, "medium")
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, "Re8+", action.ActionString)
+	})
+
+	t.Run("unknown mode", func(t *testing.T) {
+		_, _, _, err := a.AIMove(InputGame{}, "grandmaster")
+		assert.ErrorIs(t, err, ErrUnknownAIMode)
+	})
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultChess960Game(t *testing.T) {
+	outputGame, err := New().DefaultChess960Game(0)
+	require.NoError(t, err)
+	assert.True(t, outputGame.IsChess960)
+	assert.Equal(t, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1", outputGame.FENString)
+
+	standardGame, err := New().DefaultChess960Game(518)
+	require.NoError(t, err)
+	assert.Equal(t, New().DefaultGame().FENString, standardGame.FENString)
+
+	_, err = New().DefaultChess960Game(960)
+	assert.Error(t, err)
+}
+
+func TestDoActionChess960Castle(t *testing.T) {
+	game := InputGame{FENString: "4k3/8/8/8/8/8/8/1R3KR1 w KQ - 0 1", IsChess960: true}
+
+	testCases := []struct {
+		name        string
+		action      InputAction
+		expectedFEN string
+	}{
+		{"king takes rook kingside", InputAction{FromSquare: "f1", ToSquare: "g1"}, "4k3/8/8/8/8/8/8/1R3RK1 b - - 1 1"},
+		{"king takes rook queenside", InputAction{FromSquare: "f1", ToSquare: "b1"}, "4k3/8/8/8/8/8/8/2KR2R1 b - - 1 1"},
+		{"king to destination queenside", InputAction{FromSquare: "f1", ToSquare: "c1"}, "4k3/8/8/8/8/8/8/2KR2R1 b - - 1 1"},
+		{"O-O", InputAction{ActionString: "O-O"}, "4k3/8/8/8/8/8/8/1R3RK1 b - - 1 1"},
+		{"O-O-O", InputAction{ActionString: "O-O-O"}, "4k3/8/8/8/8/8/8/2KR2R1 b - - 1 1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			outputGame, outputAction, err := New().DoAction(game, tc.action)
+			require.NoError(t, err)
+			assert.True(t, outputAction.IsCastle)
+			assert.True(t, outputGame.IsChess960)
+			assert.Equal(t, tc.expectedFEN, outputGame.FENString)
+			assert.Contains(t, outputAction.ActionString, "O-O")
+		})
+	}
+
+	t.Run("without isChess960 KQ means a1/h1 rooks", func(t *testing.T) {
+		_, _, err := New().DoAction(InputGame{FENString: game.FENString}, InputAction{ActionString: "O-O"})
+		assert.ErrorIs(t, err, ErrIllegalMove)
+	})
+}
+
+func TestConvertNotationChess960(t *testing.T) {
+	game := InputGame{FENString: "1r3kr1/pppppppp/8/8/8/8/PPPPPPPP/1R3KR1 w KQkq - 0 1", IsChess960: true}
+	_, result, err := New().ConvertNotation(game, "1. O-O O-O-O", "Algebraic")
+	require.NoError(t, err)
+	require.True(t, result.ParseWasSuccessful, "parse failed: %v", result.Error)
+	require.Len(t, result.Steps, 2)
+	assert.Equal(t, "O-O", result.Steps[0].ActionString)
+	assert.Equal(t, "O-O-O", result.Steps[1].ActionString)
+	assert.Equal(t, "2kr2r1/pppppppp/8/8/8/8/PPPPPPPP/1R3RK1 w - - 2 2", result.Steps[1].Game.FENString)
+}
+
+func TestParseGameChess960WithVariantAndValidation(t *testing.T) {
+	t.Run("a Chess960 game of another variant", func(t *testing.T) {
+		game := InputGame{FENString: "4k3/8/8/8/8/8/8/1R3KR1 w KQ - 0 1", IsChess960: true, Variant: "atomic"}
+		outputGame, outputAction, err := New().DoAction(game, InputAction{ActionString: "O-O"})
+		require.NoError(t, err)
+		assert.True(t, outputAction.IsCastle)
+		assert.True(t, outputGame.IsChess960)
+		assert.Equal(t, "atomic", outputGame.Variant)
+		assert.Equal(t, "4k3/8/8/8/8/8/8/1R3RK1 b - - 1 1", outputGame.FENString)
+	})
+
+	t.Run("kings are optional if requested", func(t *testing.T) {
+		game := InputGame{FENString: "8/8/8/8/8/3p4/4P3/1R3R2 w - - 0 1", IsChess960: true}
+		_, err := New().ParseGame(game)
+		assert.ErrorIs(t, err, ErrInvalidFEN)
+
+		game.KingsOptional = true
+		outputGame, err := New().ParseGame(game)
+		require.NoError(t, err)
+		assert.True(t, outputGame.IsChess960)
+		assert.True(t, outputGame.KingsOptional)
+	})
+}
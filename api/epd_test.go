@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEPD(t *testing.T) {
+	game, record, err := New().ParseEPD(`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001"; acd 12; ce 32000; pv Qg6 fxg6 Bxg6; c1 "a queen sacrifice";`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - 0 1", game.FENString)
+	require.Len(t, record.BestMoves, 1)
+	assert.Equal(t, "Qg6", record.BestMoves[0].ActionString)
+	assert.Equal(t, "g3", record.BestMoves[0].FromPieceSquare)
+	assert.Equal(t, "g6", record.BestMoves[0].ToSquare)
+	assert.Empty(t, record.AvoidMoves)
+	assert.Equal(t, "WAC.001", record.ID)
+	require.NotNil(t, record.AnalysisDepth)
+	assert.Equal(t, 12, *record.AnalysisDepth)
+	require.NotNil(t, record.CentipawnEvaluation)
+	assert.Equal(t, 32000, *record.CentipawnEvaluation)
+	assert.Equal(t, []string{"Qg6", "fxg6", "Bxg6"}, record.PV)
+	assert.Equal(t, []OutputEPDOperation{{Opcode: "c1", Operands: []string{"a queen sacrifice"}}}, record.Operations)
+}
+
+func TestParseEPD_Errors(t *testing.T) {
+	ts := []struct {
+		name      string
+		epdString string
+		detail    string
+	}{
+		{name: "too few fields", epdString: "8/8/8/8 w"},
+		{name: "an invalid position", epdString: "4k3/8/8/8/8/8/8/4K3 w - e4 bm Ke2;", detail: "enPassant"},
+		{name: "an illegal move", epdString: "4k3/8/8/8/8/8/8/4K3 w - - bm Ke3;"},
+		{name: "an unterminated string", epdString: `4k3/8/8/8/8/8/8/4K3 w - - id "WAC.001;`},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := New().ParseEPD(tc.epdString)
+			require.ErrorIs(t, err, ErrInvalidEPD)
+			apiErr := ErrorOf(err)
+			assert.Equal(t, "epdString", apiErr.Field)
+			assert.Equal(t, tc.detail, apiErr.Detail)
+		})
+	}
+}
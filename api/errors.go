@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/marianogappa/cheesse/core"
+)
+
+// ErrorCode is the stable, machine-readable code of an Error, e.g. `ILLEGAL_MOVE`.
+type ErrorCode string
+
+// Error is the error of a failed API call. All API methods fail with an *Error
+// (see ErrorOf), which is one of the Err values below with the details of the
+// failure, so errors.Is(err, ErrIllegalMove) tells the kind of failure.
+//
+// - `code` is the stable, machine-readable code of the kind of failure.
+//
+// - `message` describes the failure to humans, and may change.
+//
+// - `field` is the input that failed, by its JSON name (e.g. `fenString`,
+// `fromSquare` or `clock.timeControl`), if the failure is in one.
+//
+// - `detail` pinpoints the failure within the field: the FEN field of a FEN
+// string (one of `{piecePlacement|activeColor|castling|enPassant|halfMoveClock|fullMoveNumber}`),
+// or the invalid value (e.g. the square `e9`), if known.
+type Error struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Field   string    `json:"field,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+
+	cause error
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Is makes errors match by code, whatever their field or detail.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func (e *Error) Unwrap() error { return e.cause }
+
+// with returns a copy of the error about the given field and detail.
+func (e *Error) with(field, detail string) *Error {
+	c := *e
+	c.Field, c.Detail = field, detail
+	return &c
+}
+
+// withCause returns a copy of the error caused by the given error, whose message
+// is appended to its own.
+func (e *Error) withCause(cause error) *Error {
+	c := *e
+	c.Message = fmt.Sprintf("%s: %v", e.Message, cause)
+	c.cause = cause
+	return &c
+}
+
+// ErrorOf returns the *Error of the given error, which is ErrInternal for errors
+// that aren't an *Error (e.g. those of a SessionStore), or nil if err is nil.
+func ErrorOf(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return ErrInternal.withCause(err)
+}
+
+func newError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+var (
+	// Input games
+	ErrInvalidFEN             = newError("INVALID_FEN", "invalid FEN string")
+	ErrInvalidBoard           = newError("INVALID_BOARD", "invalid board")
+	ErrInvalidChess960ID      = newError("INVALID_CHESS960_ID", "invalid Chess960 start position id: it must be between 0 and 959")
+	ErrInvalidPositionHistory = newError("INVALID_POSITION_HISTORY", "invalid position history: entries must be hex-encoded position hashes from a previous response")
+	ErrInvalidDrawOfferedBy   = newError("INVALID_DRAW_OFFERED_BY", "invalid drawOfferedBy: please use one of {Black|White} or empty string")
+	ErrInvalidTimeControl     = newError("INVALID_TIME_CONTROL", "invalid clock time control: please use the format of PGN's TimeControl tag, e.g. 40/7200:1800+30")
+	ErrInvalidClock           = newError("INVALID_CLOCK", "invalid clock: times and move counts can't be negative")
+	ErrInvalidVariant         = newError("INVALID_VARIANT", "invalid variant: please use one of {standard|threeCheck|kingOfTheHill|racingKings|crazyhouse|bughouse|atomic|antichess|horde} or empty string")
+
+	// Input actions
+	ErrInvalidSquare      = newError("INVALID_SQUARE", "invalid algebraic square: empty or out of bounds")
+	ErrInvalidPieceType   = newError("INVALID_PIECE_TYPE", "invalid piece type name: please use one of {Queen|King|Bishop|Knight|Rook|Pawn} or empty string")
+	ErrIllegalMove        = newError("ILLEGAL_MOVE", "the specified action is invalid for the specified game")
+	ErrAmbiguousAction    = newError("AMBIGUOUS_ACTION", "the specified action string is ambiguous: more than one action matches it; please disambiguate")
+	ErrInvalidElapsedTime = newError("INVALID_ELAPSED_TIME", "invalid elapsed time: it can't be negative")
+
+	// Notations
+	ErrInvalidNotation = newError("INVALID_NOTATION", "invalid notation string")
+	ErrUnknownNotation = newError("UNKNOWN_NOTATION", "unknown target notation: please use one of {Algebraic|Figurine|Descriptive|Coordinate|ICCF|Smith|PGN}")
+	ErrInvalidEPD      = newError("INVALID_EPD", "invalid EPD record")
+
+	// AI
+	ErrUnknownAIMode       = newError("UNKNOWN_AI_MODE", "unknown AI mode: please use one of {random|easy|medium|hard}")
+	ErrMissingAILimits     = newError("MISSING_AI_LIMITS", "missing AI limits: please supply at least one of maxDepth, maxTimeMs or maxNodes")
+	ErrInvalidAILimits     = newError("INVALID_AI_LIMITS", "invalid AI limits: maxDepth, maxTimeMs and maxNodes can't be negative, nor larger than 32, 60000 and 100000000")
+	ErrInvalidAnalyzeLines = newError("INVALID_ANALYZE_LINES", "invalid analyze options: lines can't be negative, nor larger than 10")
+	ErrNoBook              = newError("NO_BOOK", "no opening book: please start cheesse with an opening book")
+
+	// Sessions; SessionStore implementations return (or wrap) ErrSessionNotFound too
+	ErrNoSessionStore     = newError("NO_SESSION_STORE", "sessions are not enabled: the API has no session store")
+	ErrSessionNotFound    = newError("SESSION_NOT_FOUND", "session not found")
+	ErrSessionConflict    = newError("SESSION_CONFLICT", "the session's actions changed since the supplied action count: please fetch the session and retry")
+	ErrInvalidLastEventID = newError("INVALID_LAST_EVENT_ID", "invalid last event id: it must be the id of one of the session's events, or 0")
+
+	// ErrInvalidRequest is for requests that can't be decoded, e.g. invalid JSON.
+	// The API doesn't return it itself, but its server, CLI and WASM binary do.
+	ErrInvalidRequest = newError("INVALID_REQUEST", "invalid request")
+	// ErrInternal is for unexpected failures (see ErrorOf).
+	ErrInternal = newError("INTERNAL", "internal error")
+)
+
+// gameError returns the error of an input game that core couldn't construct.
+func gameError(err error) *Error {
+	var (
+		fenErr   *core.FENError
+		boardErr *core.BoardError
+	)
+	switch {
+	case errors.As(err, &fenErr):
+		return ErrInvalidFEN.withCause(err).with("fenString", fenErr.Field)
+	case errors.As(err, &boardErr):
+		field := strings.ToLower(boardErr.Field[:1]) + boardErr.Field[1:]
+		return ErrInvalidBoard.withCause(err).with("board."+field, "")
+	}
+	return ErrorOf(err)
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	ts := []struct {
+		name   string
+		do     func() error
+		err    *Error
+		field  string
+		detail string
+	}{
+		{
+			name: "a FEN string's invalid field",
+			do: func() error {
+				_, err := New().ParseGame(InputGame{FENString: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e4 0 1"})
+				return err
+			},
+			err:    ErrInvalidFEN,
+			field:  "fenString",
+			detail: "enPassant",
+		},
+		{
+			name: "a board's invalid field",
+			do: func() error {
+				_, err := New().ParseGame(InputGame{Board: Board{Board: []string{"♔       ", "        ", "        ", "        ", "        ", "        ", "        ", "       ♚"}, Turn: "Red"}})
+				return err
+			},
+			err:   ErrInvalidBoard,
+			field: "board.turn",
+		},
+		{
+			name: "an out of bounds square",
+			do: func() error {
+				_, _, err := New().DoAction(InputGame{}, InputAction{FromSquare: "e2", ToSquare: "e9"})
+				return err
+			},
+			err:    ErrInvalidSquare,
+			field:  "toSquare",
+			detail: "e9",
+		},
+		{
+			name: "an illegal move",
+			do: func() error {
+				_, _, err := New().DoAction(InputGame{}, InputAction{FromSquare: "e2", ToSquare: "e5"})
+				return err
+			},
+			err: ErrIllegalMove,
+		},
+		{
+			name: "an ambiguous action string",
+			do: func() error {
+				_, _, err := New().DoAction(InputGame{FENString: "4k3/8/8/8/8/8/8/1N3N1K w - - 0 1"}, InputAction{ActionString: "Nd2"})
+				return err
+			},
+			err:    ErrAmbiguousAction,
+			field:  "actionString",
+			detail: "Nd2",
+		},
+		{
+			name: "an invalid time control",
+			do: func() error {
+				_, err := New().ParseGame(InputGame{Clock: &Clock{TimeControl: "5 minutes"}})
+				return err
+			},
+			err:    ErrInvalidTimeControl,
+			field:  "clock.timeControl",
+			detail: "5 minutes",
+		},
+		{
+			name: "an unknown target notation",
+			do: func() error {
+				_, _, err := New().ConvertNotation(InputGame{}, "1. e4", "Klingon")
+				return err
+			},
+			err:    ErrUnknownNotation,
+			field:  "targetNotation",
+			detail: "Klingon",
+		},
+		{
+			name: "an invalid Chess960 id",
+			do: func() error {
+				_, err := New().DefaultChess960Game(960)
+				return err
+			},
+			err: ErrInvalidChess960ID,
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.do()
+			require.ErrorIs(t, err, tc.err)
+			assert.NotErrorIs(t, err, ErrInternal)
+			apiErr := ErrorOf(err)
+			assert.Equal(t, tc.err.Code, apiErr.Code)
+			assert.Equal(t, tc.field, apiErr.Field)
+			assert.Equal(t, tc.detail, apiErr.Detail)
+		})
+	}
+}
+
+func TestErrorOf(t *testing.T) {
+	assert.Nil(t, ErrorOf(nil))
+
+	err := ErrorOf(errors.New("disk full"))
+	assert.ErrorIs(t, err, ErrInternal)
+	assert.Equal(t, "internal error: disk full", err.Error())
+
+	wrapped := ErrorOf(errors.Join(errors.New("store failed"), ErrSessionNotFound))
+	assert.Equal(t, ErrSessionNotFound.Code, wrapped.Code, "wrapped errors keep their code")
+}
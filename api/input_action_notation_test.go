@@ -56,15 +56,15 @@ func TestDoActionWithActionStringCastle(t *testing.T) {
 func TestDoActionWithActionStringErrors(t *testing.T) {
 	t.Run("illegal move", func(t *testing.T) {
 		_, _, err := New().DoAction(InputGame{}, InputAction{ActionString: "Qh5"})
-		assert.Equal(t, errInvalidActionForGivenGame, err)
+		assert.ErrorIs(t, err, ErrIllegalMove)
 	})
 	t.Run("garbage input", func(t *testing.T) {
 		_, _, err := New().DoAction(InputGame{}, InputAction{ActionString: "xyzzy"})
-		assert.Equal(t, errInvalidActionForGivenGame, err)
+		assert.ErrorIs(t, err, ErrIllegalMove)
 	})
 	t.Run("multiple moves are rejected", func(t *testing.T) {
 		_, _, err := New().DoAction(InputGame{}, InputAction{ActionString: "1. e4 e5"})
-		assert.Equal(t, errInvalidActionForGivenGame, err)
+		assert.ErrorIs(t, err, ErrIllegalMove)
 	})
 	t.Run("ambiguous move is rejected", func(t *testing.T) {
 		// Two knights can reach d2: Nbd2 or Nfd2 required.
@@ -34,6 +34,54 @@ func TestParseNotation_PGNWithVariationsAndNAGs(t *testing.T) {
 	assert.Equal(t, 5, result.ValidActionCount) // 4 moves + result marker
 }
 
+func TestParseNotation_PGNMoveTree(t *testing.T) {
+	pgn := `1. e4 $1 {King's pawn} e5 (1... c5 {Sicilian} 2. Nf3 (2. c3 $6)) 2. Nf3 *`
+	_, result, err := New().ParseNotation(InputGame{}, pgn)
+	require.NoError(t, err)
+	require.True(t, result.ParseWasSuccessful, "parse failed: %v", result.Error)
+	require.Len(t, result.MoveTree, len(result.Steps))
+	assert.Equal(t, []int{1}, result.MoveTree[0].NAGs)
+	assert.Equal(t, "King's pawn", result.MoveTree[0].Comment)
+
+	require.Len(t, result.MoveTree[1].Variations, 1)
+	sicilian := result.MoveTree[1].Variations[0]
+	require.Len(t, sicilian, 2)
+	assert.Equal(t, "c5", sicilian[0].ActionString)
+	assert.Equal(t, "c7", sicilian[0].Action.FromPieceSquare)
+	assert.Equal(t, "Sicilian", sicilian[0].Comment)
+	require.Len(t, sicilian[1].Variations, 1)
+	assert.Equal(t, "c3", sicilian[1].Variations[0][0].ActionString)
+	assert.Equal(t, []int{6}, sicilian[1].Variations[0][0].NAGs)
+}
+
+func TestParseNotation_PGNMoveTreeCommands(t *testing.T) {
+	_, result, err := New().ParseNotation(InputGame{}, "1. e4 { [%clk 0:03:00] Book } e5 *")
+	require.NoError(t, err)
+	require.True(t, result.ParseWasSuccessful, "parse failed: %v", result.Error)
+	assert.Equal(t, []OutputCommand{{Name: "clk", Value: "0:03:00"}}, result.MoveTree[0].Commands)
+	assert.Equal(t, "Book", result.MoveTree[0].Comment)
+}
+
+func TestParseNotation_MoveTreeWithoutVariations(t *testing.T) {
+	_, result, err := New().ParseNotation(InputGame{}, "1. e2-e4 e7-e5")
+	require.NoError(t, err)
+	require.True(t, result.ParseWasSuccessful, "parse failed: %v", result.Error)
+	require.Len(t, result.MoveTree, 2)
+	assert.Equal(t, result.Steps[1], result.MoveTree[1].OutputGameStep)
+	assert.Empty(t, result.MoveTree[1].Variations)
+}
+
+func TestConvertNotation_MoveTree(t *testing.T) {
+	_, result, err := New().ConvertNotation(InputGame{}, "1. e4 e5 (1... c5 2. Nf3) 2. Nf3 *", "Coordinate")
+	require.NoError(t, err)
+	require.True(t, result.ParseWasSuccessful, "parse failed: %v", result.Error)
+	require.Len(t, result.MoveTree, 4)
+	assert.Equal(t, "*", result.MoveTree[3].ActionString)
+	variation := result.MoveTree[1].Variations[0]
+	assert.Equal(t, "c7-c5", variation[0].ActionString)
+	assert.Equal(t, "g1-f3", variation[1].ActionString)
+}
+
 func TestConvertNotation_ToPGN(t *testing.T) {
 	_, result, err := New().ConvertNotation(InputGame{}, "1. e4 e5 2. Nf3 Nc6", "PGN")
 	require.NoError(t, err)
@@ -0,0 +1,433 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/printer"
+)
+
+// Session is a game session, as a SessionStore stores it: the game it started
+// from and the actions done since, which are replayed to get its current game.
+// It's meant to be easy to persist, e.g. as JSON.
+//
+// Each action is stored with its `fromSquare`, `toSquare` and
+// `promotionPieceType`, or the flag of an action that isn't a move, and with its
+// `elapsedMs`.
+type Session struct {
+	ID       string            `json:"id"`
+	Game     InputGame         `json:"game"`
+	Actions  []InputAction     `json:"actions"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+// SessionStore stores the game sessions of an API (see WithSessionStore).
+// MemorySessionStore keeps them in memory; implement it to persist them.
+type SessionStore interface {
+	// Create stores a new session and returns its id. The supplied session's
+	// ID is ignored.
+	Create(s Session) (string, error)
+	// Get returns the session with the given id, or ErrSessionNotFound.
+	Get(id string) (Session, error)
+	// Update replaces the session with the given id by the result of update,
+	// which is called with its current state, and returns the new state. If
+	// update returns an error, the session is left as is and the error is
+	// returned.
+	//
+	// Updates of the same session must not run concurrently, so that each one
+	// sees the result of the previous one.
+	Update(id string, update func(Session) (Session, error)) (Session, error)
+}
+
+// MemorySessionStore is a SessionStore that keeps sessions in memory, which
+// lasts as long as the process. It's safe for concurrent use.
+type MemorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]*memorySession
+}
+
+type memorySession struct {
+	mu      sync.Mutex
+	session Session
+}
+
+// NewMemorySessionStore constructs an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]*memorySession{}}
+}
+
+// Create implements SessionStore, with random ids.
+func (m *MemorySessionStore) Create(s Session) (string, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	s.ID = hex.EncodeToString(idBytes)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[s.ID] = &memorySession{session: copySession(s)}
+	return s.ID, nil
+}
+
+// Get implements SessionStore.
+func (m *MemorySessionStore) Get(id string) (Session, error) {
+	ms, err := m.get(id)
+	if err != nil {
+		return Session{}, err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return copySession(ms.session), nil
+}
+
+// Update implements SessionStore: updates of the same session wait for each
+// other, but not for those of other sessions.
+func (m *MemorySessionStore) Update(id string, update func(Session) (Session, error)) (Session, error) {
+	ms, err := m.get(id)
+	if err != nil {
+		return Session{}, err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	updated, err := update(copySession(ms.session))
+	if err != nil {
+		return Session{}, err
+	}
+	updated.ID = id
+	ms.session = copySession(updated)
+	return updated, nil
+}
+
+func (m *MemorySessionStore) get(id string) (*memorySession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ms, ok := m.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return ms, nil
+}
+
+// copySession copies the session's slices and maps, so that the store's copy
+// isn't shared with callers.
+func copySession(s Session) Session {
+	s.Game.PositionHistory = append([]string(nil), s.Game.PositionHistory...)
+	if s.Game.Clock != nil {
+		clock := *s.Game.Clock
+		s.Game.Clock = &clock
+	}
+	s.Actions = append([]InputAction(nil), s.Actions...)
+	if s.Metadata != nil {
+		metadata := make(map[string]string, len(s.Metadata))
+		for k, v := range s.Metadata {
+			metadata[k] = v
+		}
+		s.Metadata = metadata
+	}
+	return s
+}
+
+// sessionNotifier notifies the subscribers to a session's events of its new
+// actions (see SessionEvents).
+type sessionNotifier struct {
+	mu          sync.Mutex
+	subscribers map[string]map[chan struct{}]bool
+}
+
+// subscribe returns a channel that receives after each action on the session with
+// the given id. Notifications coalesce: one pending notification stands for any
+// number of actions. The returned function unsubscribes.
+func (n *sessionNotifier) subscribe(id string) (<-chan struct{}, func()) {
+	notified := make(chan struct{}, 1)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subscribers[id] == nil {
+		n.subscribers[id] = map[chan struct{}]bool{}
+	}
+	n.subscribers[id][notified] = true
+	return notified, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		delete(n.subscribers[id], notified)
+		if len(n.subscribers[id]) == 0 {
+			delete(n.subscribers, id)
+		}
+	}
+}
+
+func (n *sessionNotifier) notify(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for notified := range n.subscribers[id] {
+		select {
+		case notified <- struct{}{}:
+		default: // A notification is already pending
+		}
+	}
+}
+
+// WithSessionStore returns a copy of the API that keeps the game sessions of
+// CreateSession, Session, DoSessionAction, SessionPGN and SessionEvents in the
+// given store.
+func (a API) WithSessionStore(s SessionStore) API {
+	a.sessions = s
+	a.sessionNotifier = &sessionNotifier{subscribers: map[string]map[chan struct{}]bool{}}
+	return a
+}
+
+// CreateSession creates a game session, so that clients can play a game without
+// supplying it on each call: they only supply its id (see DoSessionAction).
+//
+// The session starts from the given game, followed by the actions of the given
+// match in any notation supported by ParseNotation (e.g. PGN), if any; the match
+// must parse fully. Its PGN tags (except Result, which is the game's) are kept
+// for SessionPGN. The match's moves don't run the clock of timed games, but a
+// PGN's TimeControl tag makes the session timed if the game doesn't have a clock.
+//
+// It requires a session store (see WithSessionStore).
+func (a API) CreateSession(game InputGame, notationString string) (OutputSession, error) {
+	if a.sessions == nil {
+		return OutputSession{}, ErrNoSessionStore
+	}
+	parsedGame, err := a.parseGame(game)
+	if err != nil {
+		return OutputSession{}, err
+	}
+	session := Session{Game: game}
+	if strings.TrimSpace(notationString) != "" {
+		parsedNotation, result := parseNotationAutoDetect(parsedGame, notationString)
+		if !result.ParseWasSuccessful {
+			return OutputSession{}, ErrInvalidNotation.withCause(errors.New(result.Error)).with("notationString", "")
+		}
+		for _, gameStep := range parsedNotation.GameSteps {
+			if gameStep.StepAction == (core.Action{}) {
+				continue // Result markers (e.g. "1-0" in PGN) aren't actions
+			}
+			session.Actions = append(session.Actions, mapInternalActionToInputAction(gameStep.StepAction))
+		}
+		if tc, err := core.ParseTimeControl(result.Metadata["TimeControl"]); err == nil && game.Clock == nil {
+			session.Game.Clock = mapClockToOutputClock(core.NewClock(tc))
+		}
+		for k, v := range result.Metadata {
+			if k == "Result" {
+				continue
+			}
+			if session.Metadata == nil {
+				session.Metadata = map[string]string{}
+			}
+			session.Metadata[k] = v
+		}
+	}
+	gameSteps, newGame, err := a.replaySession(session)
+	if err != nil {
+		return OutputSession{}, err
+	}
+	if session.ID, err = a.sessions.Create(session); err != nil {
+		return OutputSession{}, err
+	}
+	return a.outputSession(session, gameSteps, newGame), nil
+}
+
+// Session returns the game session with the given id (see CreateSession).
+func (a API) Session(id string) (OutputSession, error) {
+	if a.sessions == nil {
+		return OutputSession{}, ErrNoSessionStore
+	}
+	session, err := a.sessions.Get(id)
+	if err != nil {
+		return OutputSession{}, err
+	}
+	gameSteps, game, err := a.replaySession(session)
+	if err != nil {
+		return OutputSession{}, err
+	}
+	return a.outputSession(session, gameSteps, game), nil
+}
+
+// DoSessionAction is like DoAction, but on the current game of the session with
+// the given id (see CreateSession), which it updates.
+//
+// Actions on the same session are done one at a time. If `actionCount` isn't
+// negative, the action is only done if the session has that many actions, and
+// ErrSessionConflict is returned otherwise: so, of simultaneous actions by
+// clients who saw the same game, only the first one is done, rather than the
+// others being done on a game they didn't see.
+func (a API) DoSessionAction(id string, action InputAction, actionCount int) (OutputSession, OutputAction, error) {
+	if a.sessions == nil {
+		return OutputSession{}, OutputAction{}, ErrNoSessionStore
+	}
+	if action.ElapsedMs < 0 {
+		return OutputSession{}, OutputAction{}, ErrInvalidElapsedTime.with("elapsedMs", "")
+	}
+	var (
+		gameSteps []core.GameStep
+		newGame   core.Game
+	)
+	session, err := a.sessions.Update(id, func(s Session) (Session, error) {
+		if actionCount >= 0 && actionCount != len(s.Actions) {
+			return Session{}, ErrSessionConflict
+		}
+		var (
+			game core.Game
+			err  error
+		)
+		if gameSteps, game, err = a.replaySession(s); err != nil {
+			return Session{}, err
+		}
+		parsedAction, err := a.parseAction(action, game)
+		if err != nil {
+			return Session{}, err
+		}
+		inputAction := mapInternalActionToInputAction(parsedAction)
+		inputAction.ElapsedMs = action.ElapsedMs
+		s.Actions = append(s.Actions, inputAction)
+		gameStep, err := sessionGameStep(game, parsedAction, action.ElapsedMs)
+		if err != nil {
+			return Session{}, err
+		}
+		gameSteps, newGame = append(gameSteps, gameStep), gameStep.StepGame
+		return s, nil
+	})
+	if err != nil {
+		return OutputSession{}, OutputAction{}, err
+	}
+	a.sessionNotifier.notify(id)
+	outputSession := a.outputSession(session, gameSteps, newGame)
+	return outputSession, outputSession.Actions[len(outputSession.Actions)-1], nil
+}
+
+// SessionEvents streams the events of the game session with the given id (see
+// CreateSession): one per action done on it, in order, starting after the event
+// with id `lastEventID` (0 for all of them). So a client that stops receiving
+// events can resume where it left off, without missing any.
+//
+// The channel is closed after the game's last event once it's over, or when ctx
+// is done. Only the actions done via this API (or copies of it) are streamed as
+// they happen; others are streamed with the next one.
+func (a API) SessionEvents(ctx context.Context, id string, lastEventID int) (<-chan OutputSessionEvent, error) {
+	if a.sessions == nil {
+		return nil, ErrNoSessionStore
+	}
+	// Subscribe before getting the session, so that no action goes unnoticed.
+	notified, unsubscribe := a.sessionNotifier.subscribe(id)
+	session, err := a.sessions.Get(id)
+	if err != nil {
+		unsubscribe()
+		return nil, err
+	}
+	if lastEventID < 0 || lastEventID > len(session.Actions) {
+		unsubscribe()
+		return nil, ErrInvalidLastEventID.with("lastEventId", strconv.Itoa(lastEventID))
+	}
+	events := make(chan OutputSessionEvent)
+	go func() {
+		defer close(events)
+		defer unsubscribe()
+		for {
+			gameSteps, game, err := a.replaySession(session)
+			if err != nil {
+				return
+			}
+			for ; lastEventID < len(gameSteps); lastEventID++ {
+				select {
+				case events <- mapGameStepToOutputSessionEvent(lastEventID+1, gameSteps[lastEventID]):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if game.IsGameOver {
+				return
+			}
+			select {
+			case <-notified:
+			case <-ctx.Done():
+				return
+			}
+			if session, err = a.sessions.Get(id); err != nil {
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// SessionPGN returns the PGN document of the game session with the given id (see
+// CreateSession), with its PGN tags. Timed games have the players' clocks.
+func (a API) SessionPGN(id string) (string, error) {
+	if a.sessions == nil {
+		return "", ErrNoSessionStore
+	}
+	session, err := a.sessions.Get(id)
+	if err != nil {
+		return "", err
+	}
+	gameSteps, _, err := a.replaySession(session)
+	if err != nil {
+		return "", err
+	}
+	// A move on which the player's flag fell wasn't made: only the result remains.
+	if n := len(gameSteps); n > 0 && gameSteps[n-1].StepGame.IsTimeout && !gameSteps[n-1].StepPreMoveGame.IsTimeout {
+		gameSteps[n-1].StepAction = core.Action{}
+	}
+	lines, err := printer.PGNPrinter{Metadata: session.Metadata}.PrintGame(gameSteps, printer.PGNCharacteristics())
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// replaySession does the session's actions on its game, returning a step per
+// action and the resulting game.
+func (a API) replaySession(s Session) ([]core.GameStep, core.Game, error) {
+	game, err := a.parseGame(s.Game)
+	if err != nil {
+		return nil, core.Game{}, err
+	}
+	gameSteps := make([]core.GameStep, 0, len(s.Actions))
+	for _, action := range s.Actions {
+		parsedAction, err := a.parseAction(action, game)
+		if err != nil {
+			return nil, core.Game{}, err
+		}
+		gameStep, err := sessionGameStep(game, parsedAction, action.ElapsedMs)
+		if err != nil {
+			return nil, core.Game{}, err
+		}
+		gameSteps = append(gameSteps, gameStep)
+		game = gameStep.StepGame
+	}
+	return gameSteps, game, nil
+}
+
+// sessionGameStep does the action on the game, returning its step with the
+// action in SAN.
+func sessionGameStep(game core.Game, action core.Action, elapsedMs int64) (core.GameStep, error) {
+	newGame := game.DoTimedAction(action, time.Duration(elapsedMs)*time.Millisecond)
+	gameStep := core.GameStep{StepAction: action, StepGame: newGame, StepPreMoveGame: game}
+	var err error
+	if gameStep.StepString, err = (printer.AlgebraicPrinter{}).PrintAction(gameStep, printer.SANCharacteristics()); err != nil {
+		return core.GameStep{}, err
+	}
+	return gameStep, nil
+}
+
+func (a API) outputSession(s Session, gameSteps []core.GameStep, game core.Game) OutputSession {
+	outputSession := OutputSession{
+		ID:       s.ID,
+		Game:     a.outputGame(game),
+		Actions:  make([]OutputAction, len(gameSteps)),
+		Metadata: s.Metadata,
+	}
+	for i, gameStep := range gameSteps {
+		outputSession.Actions[i] = mapInternalActionToAction(gameStep.StepAction)
+		outputSession.Actions[i].ActionString = gameStep.StepString
+	}
+	return outputSession
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	api := New().WithSessionStore(NewMemorySessionStore())
+
+	created, err := api.CreateSession(InputGame{Clock: &Clock{TimeControl: "180+2"}}, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, created.ID)
+	assert.Empty(t, created.Actions)
+
+	for i, actionString := range []string{"e4", "e5", "Nf3"} {
+		_, action, err := api.DoSessionAction(created.ID, InputAction{ActionString: actionString, ElapsedMs: 1000}, i)
+		require.NoError(t, err)
+		assert.Equal(t, actionString, action.ActionString)
+	}
+	_, _, err = api.DoSessionAction(created.ID, InputAction{FromSquare: "b8", ToSquare: "c6"}, -1)
+	require.NoError(t, err)
+
+	session, err := api.Session(created.ID)
+	require.NoError(t, err)
+	var actionStrings []string
+	for _, action := range session.Actions {
+		actionStrings = append(actionStrings, action.ActionString)
+	}
+	assert.Equal(t, []string{"e4", "e5", "Nf3", "Nc6"}, actionStrings)
+	assert.Equal(t, "r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - 2 3", session.Game.FENString)
+	assert.Equal(t, &Clock{TimeControl: "180+2", WhiteTimeMs: 182000, BlackTimeMs: 183000, WhiteMoves: 2, BlackMoves: 2}, session.Game.Clock)
+
+	pgn, err := api.SessionPGN(created.ID)
+	require.NoError(t, err)
+	assert.Contains(t, pgn, `[TimeControl "180+2"]`)
+	assert.True(t, strings.HasSuffix(pgn, "1. e4 {[%clk 0:03:01]} 1... e5 {[%clk 0:03:01]} 2. Nf3 {[%clk 0:03:02]} 2... Nc6\n{[%clk 0:03:03]} *\n"), pgn)
+
+	t.Run("a stale action count is a conflict", func(t *testing.T) {
+		_, _, err := api.DoSessionAction(created.ID, InputAction{ActionString: "Bb5"}, 3)
+		assert.ErrorIs(t, err, ErrSessionConflict)
+	})
+
+	t.Run("invalid actions don't change the session", func(t *testing.T) {
+		_, _, err := api.DoSessionAction(created.ID, InputAction{ActionString: "e4"}, -1)
+		assert.ErrorIs(t, err, ErrIllegalMove)
+		session, err := api.Session(created.ID)
+		require.NoError(t, err)
+		assert.Len(t, session.Actions, 4)
+	})
+
+	t.Run("unknown sessions", func(t *testing.T) {
+		_, err := api.Session("unknown")
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		_, _, err = api.DoSessionAction("unknown", InputAction{ActionString: "e4"}, -1)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		_, err = api.SessionPGN("unknown")
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+	})
+
+	t.Run("no session store", func(t *testing.T) {
+		_, err := New().CreateSession(InputGame{}, "")
+		assert.ErrorIs(t, err, ErrNoSessionStore)
+	})
+}
+
+func TestSessionFromPGN(t *testing.T) {
+	api := New().WithSessionStore(NewMemorySessionStore())
+
+	pgn := "[Event \"Casual game\"]\n[White \"Anderssen\"]\n[Result \"1-0\"]\n[TimeControl \"300\"]\n\n1. e4 e5 2. Bc4 1-0"
+	created, err := api.CreateSession(InputGame{}, pgn)
+	require.NoError(t, err)
+	assert.Len(t, created.Actions, 3)
+	assert.Equal(t, map[string]string{"Event": "Casual game", "White": "Anderssen", "TimeControl": "300"}, created.Metadata)
+	assert.Equal(t, &Clock{TimeControl: "300", WhiteTimeMs: 300000, BlackTimeMs: 300000, WhiteMoves: 2, BlackMoves: 1}, created.Game.Clock)
+
+	_, _, err = api.DoSessionAction(created.ID, InputAction{ActionString: "Nc6"}, 3)
+	require.NoError(t, err)
+	_, _, err = api.DoSessionAction(created.ID, InputAction{IsResign: true}, 4)
+	require.NoError(t, err)
+	exported, err := api.SessionPGN(created.ID)
+	require.NoError(t, err)
+	assert.Contains(t, exported, `[Event "Casual game"]`)
+	assert.Contains(t, exported, `[Result "0-1"]`, "White resigned")
+	assert.True(t, strings.HasSuffix(exported, "2... Nc6\n{[%clk 0:05:00]} 0-1\n"), exported)
+
+	_, err = api.CreateSession(InputGame{}, "1. e4 e5 2. Ke3")
+	assert.ErrorIs(t, err, ErrInvalidNotation)
+}
+
+func TestSessionFlagFall(t *testing.T) {
+	api := New().WithSessionStore(NewMemorySessionStore())
+	created, err := api.CreateSession(InputGame{Clock: &Clock{TimeControl: "60"}}, "1. e4")
+	require.NoError(t, err)
+	session, _, err := api.DoSessionAction(created.ID, InputAction{ActionString: "e5", ElapsedMs: 60000}, 1)
+	require.NoError(t, err)
+	assert.True(t, session.Game.IsTimeout)
+	assert.Equal(t, "White", session.Game.GameOverWinner)
+
+	pgn, err := api.SessionPGN(created.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(pgn, "1. e4 {[%clk 0:01:00]} 1-0\n"), pgn)
+}
+
+func TestSessionSimultaneousActions(t *testing.T) {
+	api := New().WithSessionStore(NewMemorySessionStore())
+	created, err := api.CreateSession(InputGame{}, "")
+	require.NoError(t, err)
+
+	// Clients who saw the same game all try to move: only one of them does.
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		done      int
+		conflicts int
+	)
+	for _, actionString := range []string{"e4", "d4", "c4", "Nf3", "Nc3", "g3", "b3", "f4"} {
+		wg.Add(1)
+		go func(actionString string) {
+			defer wg.Done()
+			_, _, err := api.DoSessionAction(created.ID, InputAction{ActionString: actionString}, 0)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				done++
+			} else if assert.ErrorIs(t, err, ErrSessionConflict) {
+				conflicts++
+			}
+		}(actionString)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, done)
+	assert.Equal(t, 7, conflicts)
+
+	// Without an action count, simultaneous actions are done one at a time.
+	for range [10]struct{}{} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			api.DoSessionAction(created.ID, InputAction{IsDrawOffer: true}, -1)
+		}()
+	}
+	wg.Wait()
+	session, err := api.Session(created.ID)
+	require.NoError(t, err)
+	assert.Len(t, session.Actions, 2, "the first offer is done, and then there's no offer action")
+	assert.True(t, session.Game.IsDrawOffered)
+}
+
+func TestSessionEvents(t *testing.T) {
+	api := New().WithSessionStore(NewMemorySessionStore())
+	created, err := api.CreateSession(InputGame{}, "1. f3 e5")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := api.SessionEvents(ctx, created.ID, 1)
+	require.NoError(t, err)
+	event := <-events
+	assert.Equal(t, 2, event.ID, "it resumes after the last event id")
+	assert.Equal(t, "e5", event.Action.ActionString)
+
+	for i, actionString := range []string{"g4", "Qh4#"} {
+		_, _, err := api.DoSessionAction(created.ID, InputAction{ActionString: actionString}, -1)
+		require.NoError(t, err)
+		event := <-events
+		assert.Equal(t, i+3, event.ID)
+		assert.Equal(t, actionString, event.Action.ActionString)
+	}
+	_, ok := <-events
+	assert.False(t, ok, "the game is over")
+
+	t.Run("a finished game's events", func(t *testing.T) {
+		events, err := api.SessionEvents(context.Background(), created.ID, 0)
+		require.NoError(t, err)
+		var last OutputSessionEvent
+		for event := range events {
+			last = event
+		}
+		assert.Equal(t, 4, last.ID)
+		assert.True(t, last.IsCheckmate)
+		assert.Equal(t, "Black", last.GameOverWinner)
+		assert.Equal(t, "rnb1kbnr/pppp1ppp/8/4p3/6Pq/5P2/PPPPP2P/RNBQKBNR w KQkq - 1 3", last.FENString)
+	})
+
+	t.Run("the stream ends with its context", func(t *testing.T) {
+		created, err := api.CreateSession(InputGame{}, "")
+		require.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		events, err := api.SessionEvents(ctx, created.ID, 0)
+		require.NoError(t, err)
+		cancel()
+		_, ok := <-events
+		assert.False(t, ok)
+	})
+
+	t.Run("invalid last event ids", func(t *testing.T) {
+		_, err := api.SessionEvents(context.Background(), created.ID, 5)
+		assert.ErrorIs(t, err, ErrInvalidLastEventID)
+		_, err = api.SessionEvents(context.Background(), created.ID, -1)
+		assert.ErrorIs(t, err, ErrInvalidLastEventID)
+		_, err = api.SessionEvents(context.Background(), "unknown", 0)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+	})
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTablebase opens a hand-built KQvK WDL table, as no real Syzygy tables are
+// checked in. It says that the side with the queen always wins.
+func newTestTablebase(t *testing.T) *core.Tablebase {
+	t.Helper()
+	dir := t.TempDir()
+	data := []byte{
+		0x71, 0xE8, 0x23, 0x5D, // WDL magic
+		0x01,             // one subtable per side to move
+		0x00,             // groups order
+		0x55, 0x66, 0xEE, // pieces: wQ, wK, bK
+		0x00,    // alignment
+		0x80, 4, // white to move: always a win
+		0x80, 0, // black to move: always a loss
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "KQvK.rtbw"), data, 0o644))
+	tb, err := core.OpenTablebase(dir)
+	require.NoError(t, err)
+	return tb
+}
+
+func TestParseGame_Tablebase(t *testing.T) {
+	a := New().WithTablebase(newTestTablebase(t))
+
+	ts := []struct {
+		name              string
+		fen               string
+		isTablebaseResult bool
+		wdl               string
+	}{
+		{name: "win", fen: "4k3/8/8/8/8/8/8/3QK3 w - - 0 1", isTablebaseResult: true, wdl: "Win"},
+		{name: "loss", fen: "4k3/8/8/8/8/8/8/3QK3 b - - 0 1", isTablebaseResult: true, wdl: "Loss"},
+		{name: "not covered", fen: "4k3/8/8/8/8/8/8/3RK3 w - - 0 1"},
+		{name: "too many pieces", fen: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			game, err := a.ParseGame(InputGame{FENString: tc.fen})
+			require.NoError(t, err)
+			assert.Equal(t, tc.isTablebaseResult, game.IsTablebaseResult)
+			assert.Equal(t, tc.wdl, game.TablebaseWDL)
+			assert.Equal(t, 0, game.TablebaseDTZ, "there are no DTZ tables")
+		})
+	}
+
+	t.Run("without a tablebase", func(t *testing.T) {
+		game, err := New().ParseGame(InputGame{FENString: "4k3/8/8/8/8/8/8/3QK3 w - - 0 1"})
+		require.NoError(t, err)
+		assert.False(t, game.IsTablebaseResult)
+	})
+}
+
+func TestAIMove_Tablebase(t *testing.T) {
+	a := New().WithTablebase(newTestTablebase(t))
+
+	game, _, ok, err := a.AIMove(InputGame{FENString: "4k3/8/8/8/8/8/8/3QK3 w - - 0 1"}, "medium")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, game.IsTablebaseResult)
+	assert.Equal(t, "Loss", game.TablebaseWDL, "the queen isn't given away")
+}
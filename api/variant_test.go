@@ -0,0 +1,126 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGameVariant(t *testing.T) {
+	outputGame, err := New().ParseGame(InputGame{Variant: "racingKings"})
+	require.NoError(t, err)
+	assert.Equal(t, "racingKings", outputGame.Variant)
+	assert.Equal(t, "8/8/8/8/8/8/krbnNBRK/qrbnNBRQ w - - 0 1", outputGame.FENString)
+	assert.Len(t, outputGame.Actions, 21+2) // Plus resigning and offering a draw
+
+	outputGame, err = New().ParseGame(InputGame{FENString: "4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +2+1", Variant: "threeCheck"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, outputGame.WhiteChecks)
+	assert.Equal(t, 1, outputGame.BlackChecks)
+
+	_, err = New().ParseGame(InputGame{Variant: "chess960"})
+	assert.ErrorIs(t, err, ErrInvalidVariant)
+	assert.Equal(t, "variant", ErrorOf(err).Field)
+
+	_, err = New().ParseGame(InputGame{FENString: "4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +2+1"})
+	assert.ErrorIs(t, err, ErrInvalidFEN, "only Three-check FEN strings have checks")
+}
+
+func TestDoActionVariant(t *testing.T) {
+	t.Run("the third check wins in Three-check", func(t *testing.T) {
+		game := InputGame{FENString: "4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +2+1", Variant: "threeCheck"}
+		outputGame, _, err := New().DoAction(game, InputAction{ActionString: "Ra8+"})
+		require.NoError(t, err)
+		assert.Equal(t, "R3k3/8/8/8/8/8/8/4K3 b - - 1 1 +3+1", outputGame.FENString)
+		assert.Equal(t, 3, outputGame.WhiteChecks)
+		assert.True(t, outputGame.IsVariantEnd)
+		assert.True(t, outputGame.IsGameOver)
+		assert.Equal(t, "White", outputGame.GameOverWinner)
+	})
+
+	t.Run("reaching the hill wins in King of the Hill", func(t *testing.T) {
+		game := InputGame{FENString: "7k/8/8/8/8/3K4/8/8 w - - 0 1", Variant: "kingOfTheHill"}
+		outputGame, _, err := New().DoAction(game, InputAction{FromSquare: "d3", ToSquare: "d4"})
+		require.NoError(t, err)
+		assert.Equal(t, "kingOfTheHill", outputGame.Variant)
+		assert.True(t, outputGame.IsVariantEnd)
+		assert.Equal(t, "White", outputGame.GameOverWinner)
+		assert.Empty(t, outputGame.Actions)
+	})
+
+	t.Run("an OutputGame keeps its variant as an InputGame", func(t *testing.T) {
+		outputGame, _, err := New().DoAction(InputGame{Variant: "kingOfTheHill"}, InputAction{ActionString: "e4"})
+		require.NoError(t, err)
+		outputGame, _, err = New().DoAction(InputGame{FENString: outputGame.FENString, Variant: outputGame.Variant}, InputAction{ActionString: "e5"})
+		require.NoError(t, err)
+		assert.Equal(t, "kingOfTheHill", outputGame.Variant)
+	})
+
+	t.Run("captured pieces are dropped in Crazyhouse", func(t *testing.T) {
+		game := InputGame{FENString: "r1bqkbnr/pppp1ppp/8/4n3/4P3/8/PPPP1PPP/RNBQKB1R[Pn] w KQkq - 0 4", Variant: "crazyhouse"}
+		outputGame, outputAction, err := New().DoAction(game, InputAction{ActionString: "P@d4"})
+		require.NoError(t, err)
+		assert.True(t, outputAction.IsDrop)
+		assert.Equal(t, "P@d4", outputAction.ActionString)
+		assert.Equal(t, "r1bqkbnr/pppp1ppp/8/4n3/3PP3/8/PPPP1PPP/RNBQKB1R[n] b KQkq - 0 4", outputGame.FENString)
+		assert.Empty(t, outputGame.WhitePocket)
+		assert.Equal(t, map[string]int{"Knight": 1}, outputGame.BlackPocket)
+
+		_, _, err = New().DoAction(InputGame{FENString: outputGame.FENString, Variant: "crazyhouse"}, InputAction{FromSquare: "f3", ToSquare: "f3"})
+		assert.ErrorIs(t, err, ErrIllegalMove, "drops are only supplied as action strings")
+	})
+
+	t.Run("blowing up the enemy king wins in Atomic", func(t *testing.T) {
+		game := InputGame{FENString: "rnbqkbnr/pppppppp/8/6N1/8/8/PPPPPPPP/RNBQKB1R w KQkq - 0 1", Variant: "atomic"}
+		outputGame, _, err := New().DoAction(game, InputAction{ActionString: "Nxf7"})
+		require.NoError(t, err)
+		assert.Equal(t, "rnbq3r/ppppp1pp/8/8/8/8/PPPPPPPP/RNBQKB1R b KQ - 0 1", outputGame.FENString)
+		assert.Equal(t, "", outputGame.BlackKing)
+		assert.Equal(t, "e1", outputGame.WhiteKing)
+		assert.True(t, outputGame.IsVariantEnd)
+		assert.Equal(t, "White", outputGame.GameOverWinner)
+	})
+
+	t.Run("capturing is compulsory in Antichess", func(t *testing.T) {
+		game := InputGame{FENString: "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w - - 0 2", Variant: "antichess"}
+		_, _, err := New().DoAction(game, InputAction{ActionString: "e5"})
+		assert.ErrorIs(t, err, ErrIllegalMove)
+		_, _, err = New().DoAction(game, InputAction{ActionString: "exd5"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("capturing all of White's pieces wins in Horde", func(t *testing.T) {
+		outputGame, err := New().ParseGame(InputGame{Variant: "horde"})
+		require.NoError(t, err)
+		assert.Equal(t, "", outputGame.WhiteKing)
+		assert.True(t, outputGame.KingsOptional)
+		assert.Len(t, outputGame.Actions, 8+2) // Plus resigning and offering a draw
+
+		game := InputGame{FENString: "8/8/8/8/8/8/k7/P7 b - - 0 1", Variant: "horde"}
+		outputGame, _, err = New().DoAction(game, InputAction{ActionString: "Kxa1"})
+		require.NoError(t, err)
+		assert.True(t, outputGame.IsVariantEnd)
+		assert.Equal(t, "Black", outputGame.GameOverWinner)
+	})
+
+	t.Run("kings are optional if requested", func(t *testing.T) {
+		game := InputGame{FENString: "8/8/8/8/8/3p4/4P3/8 w - - 0 1"}
+		_, err := New().ParseGame(game)
+		assert.ErrorIs(t, err, ErrInvalidFEN)
+
+		game.KingsOptional = true
+		outputGame, _, err := New().DoAction(game, InputAction{ActionString: "exd3"})
+		require.NoError(t, err)
+		assert.Equal(t, "standard", outputGame.Variant)
+		assert.True(t, outputGame.KingsOptional)
+		assert.True(t, outputGame.IsGameOver)
+		assert.Equal(t, "White", outputGame.GameOverWinner)
+	})
+
+	t.Run("checks are illegal in Racing Kings", func(t *testing.T) {
+		game := InputGame{FENString: "8/8/8/8/k7/8/8/1R5K w - - 0 1", Variant: "racingKings"}
+		_, _, err := New().DoAction(game, InputAction{FromSquare: "b1", ToSquare: "a1"})
+		assert.ErrorIs(t, err, ErrIllegalMove)
+	})
+}
@@ -0,0 +1,170 @@
+// Package book reads, queries and writes opening books in the Polyglot format,
+// the de facto standard format of opening books (.bin files).
+//
+// A Polyglot book is a list of 16-byte entries sorted by position, each one a
+// move that can be played in the position and its weight, which is how often it
+// should be played relative to the position's other moves.
+// http://hgm.nubati.net/book_format.html
+package book
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+
+	"github.com/marianogappa/cheesse/core"
+)
+
+// entrySize is the size in bytes of an Entry in a Polyglot file.
+const entrySize = 16
+
+// ErrInvalidBook is returned when reading a file that isn't a Polyglot book.
+var ErrInvalidBook = errors.New("book: invalid Polyglot book: its size isn't a multiple of 16 bytes")
+
+// Entry is a Polyglot book entry: a move of the position with the given key (see
+// core.Game.PolyglotHash), in Polyglot's move encoding.
+type Entry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+// Book is a Polyglot opening book.
+type Book struct {
+	entries []Entry
+}
+
+// Move is a book move of a position.
+type Move struct {
+	Action core.Action
+	// Weight is how often the move should be played relative to the position's
+	// other book moves.
+	Weight int
+}
+
+// New creates a book with the given entries, in any order.
+func New(entries []Entry) *Book {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return &Book{entries: sorted}
+}
+
+// Open reads the Polyglot book at the given path.
+func Open(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// Read reads a Polyglot book.
+func Read(r io.Reader) (*Book, error) {
+	var (
+		entries []Entry
+		buf     [entrySize]byte
+		br      = bufio.NewReader(r)
+	)
+	for {
+		n, err := io.ReadFull(br, buf[:])
+		if err == io.EOF {
+			break
+		}
+		if err == io.ErrUnexpectedEOF || (err == nil && n < entrySize) {
+			return nil, ErrInvalidBook
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{
+			Key:    binary.BigEndian.Uint64(buf[0:8]),
+			Move:   binary.BigEndian.Uint16(buf[8:10]),
+			Weight: binary.BigEndian.Uint16(buf[10:12]),
+			Learn:  binary.BigEndian.Uint32(buf[12:16]),
+		})
+	}
+	// Books should already be sorted, but New doesn't rely on it
+	return New(entries), nil
+}
+
+// WriteTo writes the book in the Polyglot format. It implements io.WriterTo.
+func (b *Book) WriteTo(w io.Writer) (int64, error) {
+	var (
+		buf     [entrySize]byte
+		written int64
+		bw      = bufio.NewWriter(w)
+	)
+	for _, e := range b.entries {
+		binary.BigEndian.PutUint64(buf[0:8], e.Key)
+		binary.BigEndian.PutUint16(buf[8:10], e.Move)
+		binary.BigEndian.PutUint16(buf[10:12], e.Weight)
+		binary.BigEndian.PutUint32(buf[12:16], e.Learn)
+		n, err := bw.Write(buf[:])
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, bw.Flush()
+}
+
+// Len returns the number of entries of the book.
+func (b *Book) Len() int {
+	return len(b.entries)
+}
+
+// Entries returns the entries of the position with the given key.
+func (b *Book) Entries(key uint64) []Entry {
+	i := sort.Search(len(b.entries), func(i int) bool { return b.entries[i].Key >= key })
+	j := i
+	for j < len(b.entries) && b.entries[j].Key == key {
+		j++
+	}
+	return b.entries[i:j]
+}
+
+// Moves returns the book moves of the game's position, heaviest first. Moves with
+// no weight, and moves that aren't legal in the game (e.g. because of a hash
+// collision), are left out.
+func (b *Book) Moves(g core.Game) []Move {
+	var moves []Move
+	for _, e := range b.Entries(g.PolyglotHash()) {
+		if e.Weight == 0 {
+			continue
+		}
+		if action, ok := DecodeMove(g, e.Move); ok {
+			moves = append(moves, Move{Action: action, Weight: int(e.Weight)})
+		}
+	}
+	sort.SliceStable(moves, func(i, j int) bool { return moves[i].Weight > moves[j].Weight })
+	return moves
+}
+
+// PickMove picks one of the book moves of the game's position at random, with a
+// probability proportional to its weight. Returns false when the position isn't
+// in the book.
+func (b *Book) PickMove(g core.Game, rng *rand.Rand) (Move, bool) {
+	moves := b.Moves(g)
+	total := 0
+	for _, m := range moves {
+		total += m.Weight
+	}
+	if total == 0 {
+		return Move{}, false
+	}
+	n := rng.Intn(total)
+	for _, m := range moves {
+		if n < m.Weight {
+			return m, true
+		}
+		n -= m.Weight
+	}
+	return moves[len(moves)-1], true
+}
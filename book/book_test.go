@@ -0,0 +1,211 @@
+package book
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustGame(t *testing.T, fen string) core.Game {
+	t.Helper()
+	g, err := core.NewGameFromFEN(fen)
+	require.NoError(t, err)
+	return g
+}
+
+// polyglotMove encodes a move given in long algebraic notation (e.g. "e2e4").
+func polyglotMove(s string) uint16 {
+	square := func(s string) uint16 { return uint16(s[1]-'1')*8 + uint16(s[0]-'a') }
+	move := square(s[2:4]) | square(s[0:2])<<6
+	if len(s) == 5 {
+		move |= uint16(strings.IndexByte(" nbrq", s[4])) << 12
+	}
+	return move
+}
+
+// lan returns an action in long algebraic notation (e.g. "e2e4").
+func lan(a core.Action) string {
+	return a.FromPiece.XY.ToAlgebraic() + a.ToXY.ToAlgebraic()
+}
+
+func TestReadWrite(t *testing.T) {
+	b := New([]Entry{
+		{Key: 0x463b96181691fc9c, Move: polyglotMove("e2e4"), Weight: 2, Learn: 7},
+		{Key: 0x0000000000000001, Move: polyglotMove("a2a3"), Weight: 1},
+		{Key: 0x463b96181691fc9c, Move: polyglotMove("d2d4"), Weight: 1},
+	})
+	var buf bytes.Buffer
+	n, err := b.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3*entrySize), n)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 1}, buf.Bytes()[:8], "entries are sorted by key")
+
+	read, err := Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, b, read)
+
+	_, err = Read(bytes.NewReader(make([]byte, entrySize+1)))
+	assert.ErrorIs(t, err, ErrInvalidBook)
+}
+
+func TestMoves(t *testing.T) {
+	g := mustGame(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	b := New([]Entry{
+		{Key: g.PolyglotHash(), Move: polyglotMove("d2d4"), Weight: 5},
+		{Key: g.PolyglotHash(), Move: polyglotMove("e2e5"), Weight: 100}, // illegal
+		{Key: g.PolyglotHash(), Move: polyglotMove("c2c4"), Weight: 0},
+		{Key: g.PolyglotHash(), Move: polyglotMove("e2e4"), Weight: 10},
+		{Key: g.PolyglotHash() + 1, Move: polyglotMove("g1f3"), Weight: 10},
+	})
+	moves := b.Moves(g)
+	require.Len(t, moves, 2)
+	assert.Equal(t, "e2e4", lan(moves[0].Action))
+	assert.Equal(t, 10, moves[0].Weight)
+	assert.Equal(t, "d2d4", lan(moves[1].Action))
+	assert.Equal(t, 5, moves[1].Weight)
+
+	assert.Empty(t, b.Moves(g.DoAction(moves[0].Action)))
+}
+
+func TestEncodeDecodeMove(t *testing.T) {
+	ts := []struct {
+		name   string
+		fen    string
+		move   string
+		assert func(t *testing.T, a core.Action)
+	}{
+		{
+			name: "kingside castling is the king taking its rook",
+			fen:  "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			move: "e1h1",
+			assert: func(t *testing.T, a core.Action) {
+				assert.True(t, a.IsKingsideCastle)
+			},
+		},
+		{
+			name: "queenside castling is the king taking its rook",
+			fen:  "r3k2r/8/8/8/8/8/8/R3K2R b KQkq - 0 1",
+			move: "e8a8",
+			assert: func(t *testing.T, a core.Action) {
+				assert.True(t, a.IsQueensideCastle)
+			},
+		},
+		{
+			name: "underpromotion",
+			fen:  "8/4P3/5k2/8/8/8/8/K7 w - - 0 1",
+			move: "e7e8n",
+			assert: func(t *testing.T, a core.Action) {
+				assert.Equal(t, core.PieceType(core.PieceKnight), a.PromotionPieceType)
+			},
+		},
+		{
+			name: "en passant",
+			fen:  "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1",
+			move: "e5d6",
+			assert: func(t *testing.T, a core.Action) {
+				assert.True(t, a.IsEnPassantCapture)
+			},
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			g := mustGame(t, tc.fen)
+			a, ok := DecodeMove(g, polyglotMove(tc.move))
+			require.True(t, ok)
+			tc.assert(t, a)
+			assert.Equal(t, polyglotMove(tc.move), EncodeMove(g, a))
+		})
+	}
+}
+
+func TestPickMove(t *testing.T) {
+	g := mustGame(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	b := New([]Entry{
+		{Key: g.PolyglotHash(), Move: polyglotMove("e2e4"), Weight: 3},
+		{Key: g.PolyglotHash(), Move: polyglotMove("d2d4"), Weight: 1},
+	})
+	rng := rand.New(rand.NewSource(1))
+	picks := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		m, ok := b.PickMove(g, rng)
+		require.True(t, ok)
+		picks[lan(m.Action)]++
+	}
+	assert.InDelta(t, 3000, picks["e2e4"], 150)
+	assert.InDelta(t, 1000, picks["d2d4"], 150)
+
+	_, ok := b.PickMove(g.DoAction(g.Actions[0]), rng)
+	assert.False(t, ok)
+}
+
+func TestBuilder(t *testing.T) {
+	const games = `[Event "1"]
+[Result "1-0"]
+
+1. e4 e5 2. Nf3 Nc6 1-0
+
+[Event "2"]
+[Result "1/2-1/2"]
+
+1. e4 c5 1/2-1/2
+
+[Event "3"]
+[Result "0-1"]
+
+1. d4 d5 0-1
+
+[Event "4"]
+[Result "*"]
+
+1. c4 *
+
+[Event "5"]
+[Variant "Atomic"]
+[Result "1-0"]
+
+1. Nf3 f6 1-0
+`
+	builder := NewBuilder()
+	builder.MaxPly = 3
+	added, err := builder.AddPGN(strings.NewReader(games))
+	require.NoError(t, err)
+	assert.Equal(t, 3, added)
+	b := builder.Book()
+
+	weights := func(g core.Game) map[string]int {
+		ws := map[string]int{}
+		for _, m := range b.Moves(g) {
+			ws[lan(m.Action)] = m.Weight
+		}
+		return ws
+	}
+	start := mustGame(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	assert.Equal(t, map[string]int{"e2e4": 3}, weights(start), "d4 only lost, c4's game has no result, and Nf3's is of Atomic")
+
+	afterE4 := mustGame(t, "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 1")
+	assert.Equal(t, map[string]int{"c7c5": 1}, weights(afterE4))
+
+	afterD4 := mustGame(t, "rnbqkbnr/pppppppp/8/8/3P4/8/PPP1PPPP/RNBQKBNR b KQkq - 0 1")
+	assert.Equal(t, map[string]int{"d7d5": 2}, weights(afterD4))
+
+	afterE5 := mustGame(t, "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2")
+	assert.Equal(t, map[string]int{"g1f3": 2}, weights(afterE5))
+
+	afterNf3 := mustGame(t, "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2")
+	assert.Empty(t, weights(afterNf3), "beyond MaxPly")
+}
+
+func TestBuilder_ScalesWeights(t *testing.T) {
+	builder := NewBuilder()
+	builder.add(1, polyglotMove("e2e4"), 2*maxWeight)
+	builder.add(1, polyglotMove("d2d4"), 10)
+	entries := builder.Book().Entries(1)
+	require.Len(t, entries, 2)
+	assert.Equal(t, uint16(maxWeight), entries[0].Weight)
+	assert.Equal(t, uint16(5), entries[1].Weight)
+}
@@ -0,0 +1,130 @@
+package book
+
+import (
+	"errors"
+	"io"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/parser/pgn"
+)
+
+// DefaultMaxPly is the default number of plies of each game a Builder adds.
+const DefaultMaxPly = 30
+
+// maxWeight is the largest weight an Entry can hold.
+const maxWeight = 1<<16 - 1
+
+// Builder builds a book out of games. Following Polyglot's make-book, each move
+// scores 2 points when its side went on to win the game and 1 point when the game
+// was drawn, and a move's weight is its total score.
+type Builder struct {
+	// MaxPly is the number of plies of each game that are added to the book.
+	MaxPly int
+
+	scores map[uint64]map[uint16]int
+	order  []bookMove // first appearance of each move, to build a stable book
+}
+
+type bookMove struct {
+	key  uint64
+	move uint16
+}
+
+// NewBuilder creates an empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{MaxPly: DefaultMaxPly, scores: map[uint64]map[uint16]int{}}
+}
+
+// AddGame adds the first MaxPly moves of a game with the given result (1-0, 0-1 or
+// 1/2-1/2). Games with any other result, Chess960 games and games of other
+// variants (e.g. Crazyhouse, as read from a PGN's Variant tag) are ignored, as
+// Polyglot books are of standard chess. Returns whether the game was added.
+func (b *Builder) AddGame(steps []core.GameStep, result string) bool {
+	if result != "1-0" && result != "0-1" && result != "1/2-1/2" {
+		return false
+	}
+	ply := 0
+	for _, step := range steps {
+		if ply >= b.MaxPly {
+			break
+		}
+		g, a := step.StepPreMoveGame, step.StepAction
+		if g.IsChess960 || g.Variant() != core.VariantStandard {
+			return false
+		}
+		if !a.IsMove() {
+			continue
+		}
+		score := 0
+		switch {
+		case result == "1/2-1/2":
+			score = 1
+		case (result == "1-0") == (g.Turn() == core.ColorWhite):
+			score = 2
+		}
+		b.add(g.PolyglotHash(), EncodeMove(g, a), score)
+		ply++
+	}
+	return true
+}
+
+func (b *Builder) add(key uint64, move uint16, score int) {
+	moves, ok := b.scores[key]
+	if !ok {
+		moves = map[uint16]int{}
+		b.scores[key] = moves
+	}
+	if _, ok := moves[move]; !ok {
+		b.order = append(b.order, bookMove{key, move})
+	}
+	moves[move] += score
+}
+
+// AddPGN adds every game of a PGN database. Games that can't be parsed are
+// skipped. Returns the number of games added.
+func (b *Builder) AddPGN(r io.Reader) (int, error) {
+	var (
+		reader = pgn.NewReader(r)
+		added  = 0
+	)
+	for {
+		game, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return added, nil
+		}
+		var gameErr *pgn.GameError
+		if errors.As(err, &gameErr) {
+			continue
+		}
+		if err != nil {
+			return added, err
+		}
+		if b.AddGame(game.GameSteps, game.Result) {
+			added++
+		}
+	}
+}
+
+// Book returns the book built so far. Moves that never scored (i.e. only played
+// in lost games) are left out, and weights are scaled down to fit in an Entry if
+// necessary.
+func (b *Builder) Book() *Book {
+	highest := 0
+	for _, moves := range b.scores {
+		for _, score := range moves {
+			highest = max(highest, score)
+		}
+	}
+	entries := make([]Entry, 0, len(b.order))
+	for _, m := range b.order {
+		score := b.scores[m.key][m.move]
+		if score == 0 {
+			continue
+		}
+		if highest > maxWeight {
+			score = max(1, score*maxWeight/highest)
+		}
+		entries = append(entries, Entry{Key: m.key, Move: m.move, Weight: uint16(score)})
+	}
+	return New(entries)
+}
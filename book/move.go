@@ -0,0 +1,59 @@
+package book
+
+import "github.com/marianogappa/cheesse/core"
+
+// Polyglot moves are encoded in 16 bits: the destination file and row (bits 0-5),
+// the origin file and row (bits 6-11) and the promotion piece (bits 12-14). Rows
+// start at rank 1. Castling is encoded as the king capturing its own rook.
+
+var polyglotPromotions = [...]core.PieceType{1: core.PieceKnight, 2: core.PieceBishop, 3: core.PieceRook, 4: core.PieceQueen}
+
+// EncodeMove encodes an action in Polyglot's move encoding.
+func EncodeMove(g core.Game, a core.Action) uint16 {
+	to := a.ToXY
+	if a.IsCastle {
+		to = g.CastlingRookXY(a.FromPiece.Owner, a.IsKingsideCastle)
+	}
+	move := encodeSquare(to) | encodeSquare(a.FromPiece.XY)<<6
+	if a.IsPromotion {
+		for i, t := range polyglotPromotions {
+			if t == a.PromotionPieceType && i > 0 {
+				move |= uint16(i) << 12
+			}
+		}
+	}
+	return move
+}
+
+// DecodeMove returns the action of the game encoded in Polyglot's move encoding.
+// Returns false if it isn't a legal action of the game.
+func DecodeMove(g core.Game, move uint16) (core.Action, bool) {
+	to, from := decodeSquare(move), decodeSquare(move>>6)
+	promotion := core.PieceType(core.PieceNone)
+	if i := int(move>>12) & 7; i < len(polyglotPromotions) {
+		promotion = polyglotPromotions[i]
+	}
+	for _, a := range g.Actions {
+		if !a.IsMove() || a.FromPiece.XY != from {
+			continue
+		}
+		if a.IsCastle {
+			if g.CastlingRookXY(a.FromPiece.Owner, a.IsKingsideCastle) == to {
+				return a, true
+			}
+			continue
+		}
+		if a.ToXY == to && a.PromotionPieceType == promotion {
+			return a, true
+		}
+	}
+	return core.Action{}, false
+}
+
+func encodeSquare(xy core.XY) uint16 {
+	return uint16((7-xy.Y)*8 + xy.X)
+}
+
+func decodeSquare(move uint16) core.XY {
+	return core.XY{X: int(move & 7), Y: 7 - int(move>>3&7)}
+}
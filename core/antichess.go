@@ -0,0 +1,69 @@
+package core
+
+import "math/bits"
+
+// antichess is Antichess: capturing is compulsory, and losing all of one's pieces,
+// or having no legal moves, wins. The king is an ordinary piece, so there's no
+// check nor castling, and pawns may promote to kings too.
+// https://lichess.org/variant/antichess
+type antichess struct{}
+
+// antichessValidation lets kings be missing, or more than one of a color.
+var antichessValidation = Validation{MaxWhitePieces: 16, MaxBlackPieces: 16, KingsOptional: true, ManyKings: true}
+
+func (antichess) Name() string                      { return "antichess" }
+func (antichess) StartFEN() string                  { return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w - - 0 1" }
+func (antichess) Validation() Validation            { return antichessValidation }
+func (antichess) allowsMove(g Game, a Action) bool  { return !a.IsCastle }
+func (antichess) exposesKing(g Game, a Action) bool { return false }
+func (antichess) checkers(g Game, c color) uint64   { return 0 }
+func (antichess) updateState(g *Game, a Action)     {}
+func (antichess) hasPockets() bool                  { return false }
+
+// antichessPromotionPieceTypes are the piece types that pawns may promote to in
+// Antichess.
+var antichessPromotionPieceTypes = [5]PieceType{PieceQueen, PieceBishop, PieceKnight, PieceRook, PieceKing}
+
+func (antichess) promotions() []PieceType { return antichessPromotionPieceTypes[:] }
+
+// filterMoves keeps only the captures, if there are any.
+func (antichess) filterMoves(moves []Action) []Action {
+	captures := moves[:0]
+	for _, a := range moves {
+		if a.IsCapture {
+			captures = append(captures, a)
+		}
+	}
+	if len(captures) == 0 {
+		return moves
+	}
+	return captures
+}
+
+// outcome is a win for a side with no pieces, or for the side to move if it has
+// no legal moves.
+func (antichess) outcome(g Game) (bool, color) {
+	for _, c := range [2]color{ColorWhite, ColorBlack} {
+		if g.occ[c] == 0 {
+			return true, c
+		}
+	}
+	turn := g.Turn()
+	var buf [16]Action
+	for occ := g.occ[turn]; occ != 0; occ &= occ - 1 {
+		if len(g.pieceAtSq(bits.TrailingZeros64(occ)).appendActions(buf[:0], g)) > 0 {
+			return false, -1
+		}
+	}
+	return true, turn
+}
+
+// isInsufficientMaterial is only true with a lone bishop each, on squares of
+// different colors, which then can't ever capture each other.
+func (antichess) isInsufficientMaterial(g Game) bool {
+	const lightSquares = 0x55AA55AA55AA55AA
+	black, white := g.bb[ColorBlack][PieceBishop], g.bb[ColorWhite][PieceBishop]
+	return g.occ[ColorBlack] == black && g.occ[ColorWhite] == white &&
+		bits.OnesCount64(black) == 1 && bits.OnesCount64(white) == 1 &&
+		(black&lightSquares == 0) != (white&lightSquares == 0)
+}
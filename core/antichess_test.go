@@ -0,0 +1,83 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAntichess(t *testing.T) {
+	t.Run("perft", func(t *testing.T) {
+		// https://github.com/niklasf/python-chess/blob/master/examples/perft/antichess.perft
+		g := NewVariantGame(VariantAntichess)
+		for depth, nodes := range map[int]int{1: 20, 2: 400, 3: 8067, 4: 153299} {
+			assert.Equal(t, nodes, Perft(g, depth), "depth %v", depth)
+		}
+		if testing.Short() {
+			return
+		}
+		assert.Equal(t, 2732672, Perft(g, 5))
+	})
+
+	t.Run("capturing is compulsory", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w - d6 0 2", VariantAntichess)
+		var moves []Action
+		for _, a := range g.Actions {
+			if a.IsMove() {
+				moves = append(moves, a)
+			}
+		}
+		require.Len(t, moves, 1)
+		assert.True(t, moves[0].IsCapture)
+	})
+
+	t.Run("kings are ordinary pieces", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/8/8/8/8/8/3k4/3QK3 b - - 0 1", VariantAntichess)
+		assert.False(t, g.IsCheck)
+		g = doMoves(t, g, "d2", "d1")
+		assert.Equal(t, "8/8/8/8/8/8/8/3kK3 w - - 0 2", g.ToFEN())
+		g = doMoves(t, g, "e1", "d1")
+		assert.True(t, g.IsGameOver, "Black has no pieces left")
+		assert.Equal(t, color(ColorBlack), g.GameOverWinner)
+
+		g = mustVariantGameFromFEN(t, "8/4P3/8/8/8/8/8/k7 w - - 0 1", VariantAntichess)
+		var promotions []PieceType
+		for _, a := range g.Actions {
+			if a.IsPromotion {
+				promotions = append(promotions, a.PromotionPieceType)
+			}
+		}
+		assert.ElementsMatch(t, []PieceType{PieceQueen, PieceBishop, PieceKnight, PieceRook, PieceKing}, promotions)
+
+		g = mustVariantGameFromFEN(t, "4K3/8/8/8/8/8/8/KK6 b - - 0 1", VariantAntichess)
+		assert.Equal(t, "4K3/8/8/8/8/8/8/KK6 b - - 0 1", g.ToFEN(), "there may be any number of kings")
+		assert.True(t, g.IsGameOver)
+
+		g = mustVariantGameFromFEN(t, "8/8/8/8/8/8/8/3k4 w - - 0 1", VariantAntichess)
+		assert.True(t, g.IsGameOver, "White has no pieces left, though it's White's turn")
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+		g = mustVariantGameFromFEN(t, "8/8/8/8/8/8/8/3k4 b - - 0 1", VariantAntichess)
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner, "White has no pieces left")
+	})
+
+	t.Run("having no legal moves wins", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/8/8/8/8/p7/P7/8 w - - 0 1", VariantAntichess)
+		assert.True(t, g.IsGameOver)
+		assert.True(t, g.IsVariantEnd)
+		assert.False(t, g.IsStalemate)
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+	})
+
+	t.Run("there's no castling", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 1", VariantAntichess)
+		for _, a := range g.Actions {
+			assert.False(t, a.IsCastle)
+		}
+	})
+
+	t.Run("lone bishops on squares of different colors are insufficient material", func(t *testing.T) {
+		assert.True(t, mustVariantGameFromFEN(t, "8/8/8/8/8/8/8/bB6 w - - 0 1", VariantAntichess).IsDraw)
+		assert.False(t, mustVariantGameFromFEN(t, "8/8/8/8/8/8/8/b1B5 w - - 0 1", VariantAntichess).IsDraw)
+	})
+}
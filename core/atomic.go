@@ -0,0 +1,108 @@
+package core
+
+import "math/bits"
+
+// atomic is Atomic: a capture explodes, removing the capturing piece and every
+// piece but pawns on the squares around the capture square. Blowing up the enemy
+// king wins, and blowing up one's own is illegal, so kings can't capture. Kings
+// next to each other can't be captured, so they don't give check to each other.
+// https://lichess.org/variant/atomic
+type atomic struct{}
+
+// atomicValidation lets kings be missing, once blown up.
+var atomicValidation = Validation{MaxWhitePieces: 16, MaxBlackPieces: 16, KingsOptional: true}
+
+func (atomic) Name() string                        { return "atomic" }
+func (atomic) StartFEN() string                    { return VariantStandard.StartFEN() }
+func (atomic) Validation() Validation              { return atomicValidation }
+func (atomic) allowsMove(g Game, a Action) bool    { return true }
+func (atomic) hasPockets() bool                    { return false }
+func (atomic) promotions() []PieceType             { return promotionPieceTypes[:] }
+func (atomic) filterMoves(moves []Action) []Action { return moves }
+
+// exposesKing is true for the captures by kings and the moves that blow up the
+// mover's king, and otherwise for those that leave it in check, unless they blow
+// up the enemy king.
+func (atomic) exposesKing(g Game, a Action) bool {
+	owner := a.FromPiece.Owner
+	if a.IsCapture {
+		if a.FromPiece.PieceType == PieceKing {
+			return true
+		}
+		g.explode(g.explosion(a))
+	}
+	switch {
+	case g.bb[owner][PieceKing] == 0:
+		return true
+	case g.bb[opponent(owner)][PieceKing] == 0:
+		return false
+	}
+	return atomic{}.checkers(g, owner) != 0
+}
+
+// checkers are none while the kings are next to each other.
+func (atomic) checkers(g Game, c color) uint64 {
+	if g.bb[c][PieceKing] == 0 || kingAttacks[g.kingSq[c]]&g.bb[opponent(c)][PieceKing] != 0 {
+		return 0
+	}
+	return g.kingAttackers(c)
+}
+
+func (atomic) updateState(g *Game, a Action) {
+	g.explode(g.explosion(a))
+}
+
+func (atomic) outcome(g Game) (bool, color) {
+	for _, c := range [2]color{ColorWhite, ColorBlack} {
+		if g.bb[c][PieceKing] == 0 {
+			return true, opponent(c)
+		}
+	}
+	return false, -1
+}
+
+// isInsufficientMaterial is true when neither side can blow up the enemy king: a
+// bare king can't, and against a bare king, neither can a lone knight, bishop or
+// rook, nor two knights.
+func (atomic) isInsufficientMaterial(g Game) bool {
+	for _, c := range [2]color{ColorWhite, ColorBlack} {
+		pieces, opp := g.occ[c]&^g.bb[c][PieceKing], opponent(c)
+		switch {
+		case pieces == 0:
+		case g.occ[opp] != g.bb[opp][PieceKing], g.bb[c][PieceQueen]|g.bb[c][PiecePawn] != 0:
+			return false
+		case bits.OnesCount64(pieces) == 1:
+		case pieces == g.bb[c][PieceKnight] && bits.OnesCount64(pieces) == 2:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// explosion returns the squares of the pieces that the given move blows up in
+// Atomic, whose pieces are moved: if it's a capture, the capturing piece's and
+// those of the pieces but pawns around it. It's none in other variants.
+func (g Game) explosion(a Action) uint64 {
+	if g.variant != VariantAtomic || !a.IsCapture {
+		return 0
+	}
+	to := sqOf(a.ToXY)
+	pawns := g.bb[ColorBlack][PiecePawn] | g.bb[ColorWhite][PiecePawn]
+	return sqBit(to) | kingAttacks[to]&g.occAll()&^pawns
+}
+
+// explode removes the pieces on the given squares, and the castling rights of the
+// kings and rooks among them.
+func (g *Game) explode(sqs uint64) {
+	for ; sqs != 0; sqs &= sqs - 1 {
+		sq := bits.TrailingZeros64(sqs)
+		p := g.pieceAtSq(sq)
+		g.clearSq(p.Owner, p.PieceType, sq)
+		for _, ct := range [2]castleType{castleTypeQueenside, castleTypeKingside} {
+			if p.PieceType == PieceKing || p.PieceType == PieceRook && p.XY == (XY{g.castleRookX(p.Owner, ct), homeRank(p.Owner)}) {
+				g.revokeCastlingRight(p.Owner, ct)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtomic(t *testing.T) {
+	t.Run("perft", func(t *testing.T) {
+		// https://github.com/niklasf/python-chess/blob/master/examples/perft/atomic.perft
+		for _, c := range []struct {
+			fen    string
+			nodes  []int
+			isSlow bool
+		}{
+			{VariantAtomic.StartFEN(), []int{20, 400, 8902}, false},
+			{VariantAtomic.StartFEN(), []int{20, 400, 8902, 197326}, true},
+			{"rn2kb1r/1pp1p2p/p2q1pp1/3P4/2P3b1/4PN2/PP3PPP/R2QKB1R b KQkq - 0 1", []int{40, 1238, 45237}, false},
+			{"rn1qkb1r/p5pp/2p5/3p4/N3P3/5P2/PPP4P/R1BQK3 w Qkq - 0 1", []int{28, 833, 23353}, false},
+		} {
+			if c.isSlow && testing.Short() {
+				continue
+			}
+			g := mustVariantGameFromFEN(t, c.fen, VariantAtomic)
+			for i, nodes := range c.nodes {
+				assert.Equal(t, nodes, Perft(g, i+1), "%v at depth %v", c.fen, i+1)
+			}
+		}
+	})
+
+	t.Run("captures explode, and blowing up the enemy king wins", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "rnbqkbnr/pppppppp/8/6N1/8/8/PPPPPPPP/RNBQKB1R w KQkq - 0 1", VariantAtomic)
+		g = doMoves(t, g, "g5", "f7")
+		assert.Equal(t, "rnbq3r/ppppp1pp/8/8/8/8/PPPPPPPP/RNBQKB1R b KQ - 0 1", g.ToFEN())
+		assert.True(t, g.IsGameOver)
+		assert.True(t, g.IsVariantEnd)
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+		assert.Empty(t, g.Actions)
+		assert.Equal(t, PieceType(PieceNone), g.King(ColorBlack).PieceType)
+
+		g = mustVariantGameFromFEN(t, "rnbq3r/ppppp1pp/8/8/8/8/PPPPPPPP/RNBQKB1R b KQ - 0 1", VariantAtomic)
+		assert.True(t, g.IsGameOver, "FEN strings may lack a blown up king")
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+	})
+
+	t.Run("explosions take castling rights away", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "r3k2r/1n6/8/8/8/8/8/1R2K2R w Kkq - 0 1", VariantAtomic)
+		g = doMoves(t, g, "b1", "b7")
+		assert.Equal(t, "4k2r/8/8/8/8/8/8/4K2R b Kk - 0 1", g.ToFEN())
+	})
+
+	t.Run("kings can't capture, nor give check to each other", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/8/8/8/8/4n3/3kK2r/8 w - - 0 1", VariantAtomic)
+		assert.False(t, g.IsCheck, "the kings are next to each other")
+		var kingMoves []string
+		for _, a := range g.Actions {
+			if a.IsMove() {
+				kingMoves = append(kingMoves, a.ToXY.ToAlgebraic())
+			}
+		}
+		assert.ElementsMatch(t, []string{"d1", "e1", "d3", "f3"}, kingMoves)
+	})
+
+	t.Run("insufficient material", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/8/4k3/8/8/2R1K3/8/8 w - - 0 1", VariantAtomic)
+		assert.True(t, g.IsDraw, "a lone rook can't blow up a bare king")
+		g = mustVariantGameFromFEN(t, "8/8/4k3/8/8/2N1K3/8/1n6 w - - 0 1", VariantAtomic)
+		assert.False(t, g.IsDraw, "the knights may explode next to the kings")
+	})
+
+	t.Run("positions agree with games", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "r3k2r/1n6/8/8/8/8/8/1R2K2R w Kkq - 0 1", VariantAtomic)
+		p := NewPosition(g)
+		for _, a := range p.LegalActions() {
+			if a.IsCapture {
+				p.MakeMove(a)
+				break
+			}
+		}
+		expected := doMoves(t, g, "b1", "b7")
+		assert.Equal(t, expected.ToFEN(), p.Game().ToFEN())
+		assert.Equal(t, expected.Hash(), p.Hash())
+		assert.Len(t, p.LegalActions(), len(expected.Actions)-2) // Minus resigning and offering a draw
+
+		p.UnmakeMove()
+		assert.Equal(t, g.Hash(), p.Hash())
+		assert.Equal(t, g.ToFEN(), p.Game().ToFEN())
+	})
+}
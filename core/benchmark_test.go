@@ -54,6 +54,14 @@ func BenchmarkNewGameFromFEN(b *testing.B) {
 }
 
 func BenchmarkPerft3_Start(b *testing.B) {
+	g, _ := NewGameFromFEN(fenStart)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Perft(g, 3)
+	}
+}
+
+func BenchmarkPerft3_Start_DoAction(b *testing.B) {
 	g, _ := NewGameFromFEN(fenStart)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -61,13 +69,35 @@ func BenchmarkPerft3_Start(b *testing.B) {
 	}
 }
 
+func BenchmarkLegalActions_Kiwipete(b *testing.B) {
+	g, _ := NewGameFromFEN(fenKiwipete)
+	p := NewPosition(g)
+	actions := make([]Action, 0, 64)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		actions = p.AppendLegalActions(actions[:0])
+	}
+}
+
+func BenchmarkMakeUnmakeMove_Kiwipete(b *testing.B) {
+	g, _ := NewGameFromFEN(fenKiwipete)
+	p := NewPosition(g)
+	action := g.Actions[0]
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.MakeMove(action)
+		p.UnmakeMove()
+	}
+}
+
+// perft is Perft on top of Game.DoAction, to compare with Position's.
 func perft(g Game, depth int) int {
 	if depth == 0 {
 		return 1
 	}
 	nodes := 0
 	for _, action := range g.Actions {
-		if action.IsResign || action.IsDraw {
+		if !action.IsMove() {
 			continue
 		}
 		nodes += perft(g.DoAction(action), depth-1)
@@ -125,12 +125,14 @@ func queenAttacks(sq int, occ uint64) uint64 {
 }
 
 // squares[sq] encodes the piece at sq as pieceType<<1|color, or 0 if empty.
+// placementHash is kept up to date with the pieces on the board (see Hash).
 
 func (g *Game) setSq(c color, t PieceType, sq int) {
 	b := sqBit(sq)
 	g.bb[c][t] |= b
 	g.occ[c] |= b
 	g.squares[sq] = uint8(t)<<1 | uint8(c)
+	g.placementHash ^= zobristPieces[c][t][sq]
 	if t == PieceKing {
 		g.kingSq[c] = int8(sq)
 	}
@@ -141,6 +143,7 @@ func (g *Game) clearSq(c color, t PieceType, sq int) {
 	g.bb[c][t] &^= b
 	g.occ[c] &^= b
 	g.squares[sq] = 0
+	g.placementHash ^= zobristPieces[c][t][sq]
 }
 
 func (g Game) pieceAtSq(sq int) Piece {
@@ -158,3 +161,29 @@ func (g Game) hasPieceAt(c color, t PieceType, xy XY) bool {
 func (g Game) occAll() uint64 {
 	return g.occ[ColorBlack] | g.occ[ColorWhite]
 }
+
+// Mobility returns the number of squares the piece at xy attacks, excluding those
+// occupied by pieces of its own color. Pawn pushes, pins and checks aren't taken
+// into account. Returns 0 if the square is empty.
+func (g Game) Mobility(xy XY) int {
+	sq := sqOf(xy)
+	p := g.pieceAtSq(sq)
+	var attacks uint64
+	switch p.PieceType {
+	case PieceNone:
+		return 0
+	case PiecePawn:
+		attacks = pawnCaptureAttacks[p.Owner][sq]
+	case PieceKnight:
+		attacks = knightAttacks[sq]
+	case PieceBishop:
+		attacks = bishopAttacks(sq, g.occAll())
+	case PieceRook:
+		attacks = rookAttacks(sq, g.occAll())
+	case PieceQueen:
+		attacks = queenAttacks(sq, g.occAll())
+	case PieceKing:
+		attacks = kingAttacks[sq]
+	}
+	return bits.OnesCount64(attacks &^ g.occ[p.Owner])
+}
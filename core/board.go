@@ -26,19 +26,63 @@ var (
 	errBoardKingMissing                  = errors.New("board is missing one of the kings")
 	errBoardDimensionsWrong              = errors.New("board dimensions are wrong; should be 8x8")
 	errBoardPawnInImpossibleRank         = errors.New("impossible rank for pawn")
-	errBoardBlackHasMoreThan16Pieces     = errors.New("black has more than 16 pieces")
-	errBoardWhiteHasMoreThan16Pieces     = errors.New("white has more than 16 pieces")
+	errBoardBlackHasTooManyPieces        = errors.New("black has more pieces than allowed (16, unless the variant allows more)")
+	errBoardWhiteHasTooManyPieces        = errors.New("white has more pieces than allowed (16, unless the variant allows more)")
 	errBoardSideNotToMoveInCheck         = errors.New("side not to move is in check")
 	// TODO check if King is in checkmate that couldn't have been reached
 	// TODO don't allow more than 8 pawns of any color
 )
 
+// BoardError is the error of an invalid Board, with the name of the Board field
+// that makes it invalid (e.g. "Turn").
+type BoardError struct {
+	Field string
+	Err   error
+}
+
+func (e *BoardError) Error() string { return e.Err.Error() }
+
+func (e *BoardError) Unwrap() error { return e.Err }
+
 func NewDefaultGame() Game {
 	g, _ := NewGameFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
 	return g
 }
 
+// NewVariantGame returns the initial game of the given variant (see Variant).
+func NewVariantGame(v Variant) Game {
+	g, _ := NewVariantGameFromFEN(v.StartFEN(), v)
+	return g
+}
+
 func NewGameFromBoard(b Board) (Game, error) {
+	return NewGameFromBoardWithValidation(b, VariantStandard, standardValidation)
+}
+
+// NewGameFromBoardWithValidation returns the game of the given variant on the
+// board, which is valid by the given validation policy, e.g. the variant's (see
+// Variant.Validation), or a more relaxed one for teaching positions without kings.
+func NewGameFromBoardWithValidation(b Board, v Variant, val Validation) (Game, error) {
+	g, err := gameFromBoard(b, v, val)
+	if err != nil {
+		field := "Board"
+		switch err {
+		case errBoardTurnMustBeBlackOrWhite, errBoardSideNotToMoveInCheck:
+			field = "Turn"
+		case errBoardInvalidEnPassantTargetSquare:
+			field = "EnPassantTargetSquare"
+		}
+		return Game{}, &BoardError{Field: field, Err: err}
+	}
+	if v == VariantStandard && val == standardValidation {
+		return g, nil
+	}
+	return g.WithVariant(v), nil
+}
+
+// gameFromBoard returns the game on the board, as a standard game whose flags are
+// only calculated if both the variant and the validation policy are standard.
+func gameFromBoard(b Board, v Variant, val Validation) (Game, error) {
 	g := Game{
 		CanWhiteCastle:          b.CanWhiteKingsideCastle && b.CanWhiteQueensideCastle,
 		CanWhiteKingsideCastle:  b.CanWhiteKingsideCastle,
@@ -50,6 +94,7 @@ func NewGameFromBoard(b Board) (Game, error) {
 		HalfMoveClock:           b.HalfMoveClock,
 		MoveNumber:              (b.FullMoveNumber - 1) * 2,
 		kingSq:                  [2]int8{-1, -1},
+		validation:              val,
 	}
 
 	// Move number
@@ -81,18 +126,18 @@ func NewGameFromBoard(b Board) (Game, error) {
 		for _, p := range b.Board[y] {
 			switch p {
 			case '♛', '♚', '♜', '♝', '♞', '♟':
-				if p == '♚' && g.kingSq[ColorBlack] >= 0 {
+				if p == '♚' && g.kingSq[ColorBlack] >= 0 && !val.ManyKings {
 					return Game{}, errBoardDuplicateKing
 				}
 				g.setSq(ColorBlack, pieceTypeMap[p], sqOf(XY{lenX, lenY}))
 			case '♕', '♔', '♖', '♗', '♘', '♙':
-				if p == '♔' && g.kingSq[ColorWhite] >= 0 {
+				if p == '♔' && g.kingSq[ColorWhite] >= 0 && !val.ManyKings {
 					return Game{}, errBoardDuplicateKing
 				}
 				g.setSq(ColorWhite, pieceTypeMap[p], sqOf(XY{lenX, lenY}))
 			default:
 			}
-			if (p == '♟' || p == '♙') && (lenY == 0 || lenY == 7) {
+			if p == '♟' && !val.allowsPawnAt(ColorBlack, lenY) || p == '♙' && !val.allowsPawnAt(ColorWhite, lenY) {
 				return Game{}, errBoardPawnInImpossibleRank
 			}
 			lenX++
@@ -105,14 +150,14 @@ func NewGameFromBoard(b Board) (Game, error) {
 	if lenY != 8 {
 		return Game{}, errBoardDimensionsWrong
 	}
-	if g.kingSq[ColorBlack] < 0 || g.kingSq[ColorWhite] < 0 {
+	if (g.kingSq[ColorBlack] < 0 || g.kingSq[ColorWhite] < 0) && !val.KingsOptional {
 		return Game{}, errBoardKingMissing
 	}
-	if bits.OnesCount64(g.occ[ColorBlack]) > 16 {
-		return Game{}, errBoardBlackHasMoreThan16Pieces
+	if limit := val.maxPieces(ColorBlack); limit > 0 && bits.OnesCount64(g.occ[ColorBlack]) > limit {
+		return Game{}, errBoardBlackHasTooManyPieces
 	}
-	if bits.OnesCount64(g.occ[ColorWhite]) > 16 {
-		return Game{}, errBoardWhiteHasMoreThan16Pieces
+	if limit := val.maxPieces(ColorWhite); limit > 0 && bits.OnesCount64(g.occ[ColorWhite]) > limit {
+		return Game{}, errBoardWhiteHasTooManyPieces
 	}
 
 	// Castling auto-correction: rights inconsistent with king/rook placement are
@@ -150,11 +195,14 @@ func NewGameFromBoard(b Board) (Game, error) {
 	// The side not to move must not be in check: such a position is unreachable
 	// and move generation semantics break down.
 	sideNotToMove := opponent(g.Turn())
-	if g.attackersOf(int(g.kingSq[sideNotToMove]), sideNotToMove) != 0 {
+	if v.checkers(g, sideNotToMove) != 0 {
 		return Game{}, errBoardSideNotToMoveInCheck
 	}
 
-	g.positionHistory = []uint64{g.positionHash()}
+	g.positionHistory = []uint64{g.Hash()}
+	if v != VariantStandard || val != standardValidation {
+		return g, nil // Calculated by the variant's rules, e.g. without kings
+	}
 	return g.calculateCriticalFlags(), nil
 }
 
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -371,7 +372,7 @@ func TestBoardErrors(t *testing.T) {
 			err: errBoardPawnInImpossibleRank,
 		},
 		{
-			name: "errBoardBlackHasMoreThan16Pieces: black has 17 pieces",
+			name: "errBoardBlackHasTooManyPieces: black has 17 pieces",
 			board: Board{
 				Board: []string{
 					"♜♞♝♛♚♝♞♜",
@@ -385,10 +386,10 @@ func TestBoardErrors(t *testing.T) {
 				},
 				Turn: "Black",
 			},
-			err: errBoardBlackHasMoreThan16Pieces,
+			err: errBoardBlackHasTooManyPieces,
 		},
 		{
-			name: "errBoardWhiteHasMoreThan16Pieces: white has 17 pieces",
+			name: "errBoardWhiteHasTooManyPieces: white has 17 pieces",
 			board: Board{
 				Board: []string{
 					"♜♞♝♛♚♝♞♜",
@@ -402,13 +403,13 @@ func TestBoardErrors(t *testing.T) {
 				},
 				Turn: "Black",
 			},
-			err: errBoardWhiteHasMoreThan16Pieces,
+			err: errBoardWhiteHasTooManyPieces,
 		},
 	}
 	for _, tc := range ts {
 		t.Run(tc.name, func(t *testing.T) {
 			_, err := NewGameFromBoard(tc.board)
-			assert.Equal(t, tc.err, err)
+			assert.ErrorIs(t, err, tc.err)
 		})
 	}
 }
@@ -6438,7 +6439,7 @@ func TestBoardConvertsBackToItself(t *testing.T) {
 	for _, tc := range ts {
 		t.Run(fmt.Sprintf("Board converts %v back to itself", tc), func(t *testing.T) {
 			g, err := NewGameFromBoard(tc)
-			if err == errBoardSideNotToMoveInCheck {
+			if errors.Is(err, errBoardSideNotToMoveInCheck) {
 				// Some auto-generated fixtures predate the side-not-to-move-in-check
 				// validation; rejecting them is the correct new behavior.
 				return
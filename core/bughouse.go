@@ -0,0 +1,39 @@
+package core
+
+// Bughouse is a game of Bughouse: two teams of two play on two boards, each a game
+// of VariantBughouse, and each player's teammate plays the other color on the
+// other board. So, the pieces captured on a board go to the pocket of their own
+// color on the other one, for the teammate to drop them.
+// https://en.wikipedia.org/wiki/Bughouse_chess
+type Bughouse struct {
+	Boards [2]Game
+}
+
+// NewBughouse creates a game of Bughouse with both boards in the starting position.
+func NewBughouse() Bughouse {
+	g := NewVariantGame(VariantBughouse)
+	return Bughouse{Boards: [2]Game{g, g.Clone()}}
+}
+
+// DoAction does the given action, which must be one of the board's Actions, on the
+// given board (0 or 1). The piece it captures, if any, goes to the pocket of its
+// color on the other board, as a pawn if it's a promoted pawn.
+func (b Bughouse) DoAction(board int, a Action) Bughouse {
+	g := b.Boards[board]
+	b.Boards[board] = g.DoAction(a)
+	if !a.IsCapture {
+		return b
+	}
+	other := b.Boards[1-board].Clone()
+	other.pockets[a.CapturedPiece.Owner][g.pocketPieceType(a.CapturedPiece)]++
+	// Pockets never shrink but by dropping, so earlier positions can't repeat.
+	other.positionHistory = []uint64{other.Hash()}
+	b.Boards[1-board] = other.calculateCriticalFlags()
+	return b
+}
+
+// IsGameOver returns whether the game is over, which it is as soon as either
+// board's is.
+func (b Bughouse) IsGameOver() bool {
+	return b.Boards[0].IsGameOver || b.Boards[1].IsGameOver
+}
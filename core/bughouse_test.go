@@ -0,0 +1,37 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBughouse(t *testing.T) {
+	b := NewBughouse()
+	assert.Equal(t, VariantBughouse, b.Boards[0].Variant())
+
+	do := func(board int, from, to string) {
+		t.Helper()
+		g := b.Boards[board]
+		for _, a := range g.Actions {
+			if a.IsMove() && !a.IsDrop && a.FromPiece.XY.ToAlgebraic() == from && a.ToXY.ToAlgebraic() == to {
+				b = b.DoAction(board, a)
+				return
+			}
+		}
+		t.Fatalf("no move from %v to %v in %v", from, to, g.ToFEN())
+	}
+	do(0, "e2", "e4")
+	do(0, "d7", "d5")
+	do(0, "e4", "d5")
+	assert.Equal(t, "rnbqkbnr/ppp1pppp/8/3P4/8/8/PPPP1PPP/RNBQKBNR[] b KQkq - 0 2", b.Boards[0].ToFEN(), "captures don't go to the board's own pockets")
+	assert.Equal(t, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[p] w KQkq - 0 1", b.Boards[1].ToFEN(), "but to the other board's, by color")
+
+	do(1, "e2", "e4")
+	found := false
+	for _, a := range b.Boards[1].Actions {
+		found = found || a.IsDrop && a.DropString() == "P@e5"
+	}
+	assert.True(t, found, "the teammate who plays Black on the other board may drop it")
+	assert.False(t, b.IsGameOver())
+}
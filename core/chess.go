@@ -16,6 +16,8 @@ func (g Game) shallowCloneForMove() Game {
 	clonedGame.IsDraw = false
 	clonedGame.CanClaimDraw = false
 	clonedGame.IsGameOver = false
+	clonedGame.IsTimeout = false
+	clonedGame.IsVariantEnd = false
 	clonedGame.GameOverWinner = 0
 	clonedGame.InCheckBy = nil
 	clonedGame.Actions = nil
@@ -30,10 +32,20 @@ func (g Game) shallowCloneForMove() Game {
 // Note that this method assumes the action is fully built and valid (bounds, no friendly piece at destination, etc.).
 func (g Game) updateBoardLayout(a Action) Game {
 	clonedGame := g.shallowCloneForMove()
+	clonedGame.movePieces(a)
+	return clonedGame
+}
 
-	// Special case for resignation and draw actions, because they don't require board changes
-	if a.IsResign || a.IsDraw {
-		return clonedGame
+// movePieces moves the pieces of the given action on the board, in place. It's a
+// no-op for actions that aren't moves (e.g. resignation), because they don't
+// require board changes.
+func (g *Game) movePieces(a Action) {
+	if !a.IsMove() {
+		return
+	}
+	if a.IsDrop {
+		g.setSq(a.FromPiece.Owner, a.FromPiece.PieceType, sqOf(a.ToXY))
+		return
 	}
 
 	owner := a.FromPiece.Owner
@@ -43,29 +55,62 @@ func (g Game) updateBoardLayout(a Action) Game {
 	}
 
 	// Remove pieces at {from, to} locations, and place fromPiece at "to" location
-	clonedGame.clearSq(owner, a.FromPiece.PieceType, sqOf(a.FromPiece.XY))
+	g.clearSq(owner, a.FromPiece.PieceType, sqOf(a.FromPiece.XY))
 	if a.IsCapture && !a.IsEnPassantCapture {
-		clonedGame.clearSq(a.CapturedPiece.Owner, a.CapturedPiece.PieceType, sqOf(a.CapturedPiece.XY))
+		g.clearSq(a.CapturedPiece.Owner, a.CapturedPiece.PieceType, sqOf(a.CapturedPiece.XY))
 	}
-	clonedGame.setSq(owner, toPieceType, sqOf(a.ToXY))
+	// Castling also moves the rook. It's lifted before the king lands, because in
+	// Chess960 the king may land on the rook's starting square.
+	if a.IsCastle {
+		rookFromXY, rookToXY := g.castleRookXYs(a)
+		g.clearSq(owner, PieceRook, sqOf(rookFromXY))
+		g.setSq(owner, PieceRook, sqOf(rookToXY))
+	}
+	g.setSq(owner, toPieceType, sqOf(a.ToXY))
 
-	// Extra movements and deletions in the case of en passant capture and castling
-	homeRank := 0
-	if owner == ColorWhite {
-		homeRank = 7
+	// Extra deletion in the case of en passant capture
+	if a.IsEnPassantCapture {
+		g.clearSq(a.CapturedPiece.Owner, PiecePawn, sqOf(a.CapturedPiece.XY))
 	}
-	switch {
-	case a.IsEnPassantCapture:
-		clonedGame.clearSq(a.CapturedPiece.Owner, PiecePawn, sqOf(a.CapturedPiece.XY))
-	case a.IsQueensideCastle:
-		clonedGame.clearSq(owner, PieceRook, sqOf(XY{0, homeRank}))
-		clonedGame.setSq(owner, PieceRook, sqOf(XY{3, homeRank}))
-	case a.IsKingsideCastle:
-		clonedGame.clearSq(owner, PieceRook, sqOf(XY{7, homeRank}))
-		clonedGame.setSq(owner, PieceRook, sqOf(XY{5, homeRank}))
+}
+
+// unmovePieces undoes movePieces, in place.
+func (g *Game) unmovePieces(a Action) {
+	if !a.IsMove() {
+		return
+	}
+	if a.IsDrop {
+		g.clearSq(a.FromPiece.Owner, a.FromPiece.PieceType, sqOf(a.ToXY))
+		return
 	}
 
-	return clonedGame
+	owner := a.FromPiece.Owner
+	toPieceType := a.FromPiece.PieceType
+	if a.IsPromotion {
+		toPieceType = a.PromotionPieceType
+	}
+
+	// The king is lifted before the rook goes back, for the same reason as above
+	g.clearSq(owner, toPieceType, sqOf(a.ToXY))
+	if a.IsCastle {
+		rookFromXY, rookToXY := g.castleRookXYs(a)
+		g.clearSq(owner, PieceRook, sqOf(rookToXY))
+		g.setSq(owner, PieceRook, sqOf(rookFromXY))
+	}
+	g.setSq(owner, a.FromPiece.PieceType, sqOf(a.FromPiece.XY))
+	if a.IsCapture {
+		g.setSq(a.CapturedPiece.Owner, a.CapturedPiece.PieceType, sqOf(a.CapturedPiece.XY))
+	}
+}
+
+// castleRookXYs returns where the rook of a castling action starts and lands.
+func (g Game) castleRookXYs(a Action) (XY, XY) {
+	ct, rookToX := castleType(castleTypeQueenside), 3
+	if a.IsKingsideCastle {
+		ct, rookToX = castleTypeKingside, 5
+	}
+	owner := a.FromPiece.Owner
+	return XY{g.castleRookX(owner, ct), homeRank(owner)}, XY{rookToX, homeRank(owner)}
 }
 
 func (g Game) calculateAllActions() []Action {
@@ -73,17 +118,50 @@ func (g Game) calculateAllActions() []Action {
 		return []Action{}
 	}
 	turn := g.Turn()
-	actions := make([]Action, 0, 64)
-	for occ := g.occ[turn]; occ != 0; occ &= occ - 1 {
-		actions = g.pieceAtSq(bits.TrailingZeros64(occ)).appendActions(actions, g)
-	}
-	// Terminal actions: resigning is always possible, and any player may propose a
-	// draw at any point (the engine assumes the opponent accepts).
+	actions := g.appendMoves(make([]Action, 0, 64))
+	// Actions that aren't moves: resigning is always possible. A draw may be
+	// offered unless one is pending, in which case the opponent of the offerer may
+	// accept or decline it, and claimed when the 50-move rule or threefold
+	// repetition allow it. Agreeing to a draw outright isn't (see DrawAgreement).
 	actions = append(actions, Action{FromPiece: Piece{Owner: turn}, IsResign: true})
-	actions = append(actions, Action{FromPiece: Piece{Owner: turn}, IsDraw: true})
+	switch {
+	case !g.IsDrawOffered:
+		actions = append(actions, Action{FromPiece: Piece{Owner: turn}, IsDrawOffer: true})
+	case g.DrawOfferedBy != turn:
+		actions = append(actions, Action{FromPiece: Piece{Owner: turn}, IsDrawAccept: true})
+		actions = append(actions, Action{FromPiece: Piece{Owner: turn}, IsDrawDecline: true})
+	}
+	if g.CanClaimDraw {
+		actions = append(actions, Action{FromPiece: Piece{Owner: turn}, IsDrawClaim: true})
+	}
 	return actions
 }
 
+// DrawAgreement returns the action that records a draw agreed by both players, e.g.
+// a game record's "1/2-1/2" result, for importers of finished games. It isn't one
+// of the game's Actions: games played through the engine are drawn by an accepted
+// offer or a claim instead.
+func (g Game) DrawAgreement() Action {
+	return Action{FromPiece: Piece{Owner: g.Turn()}, IsDraw: true}
+}
+
+// appendMoves appends the legal moves of the side to move to the given slice:
+// those of its pieces, its drops in variants with pockets, and only those that
+// the variant doesn't filter out.
+func (g Game) appendMoves(actions []Action) []Action {
+	start := len(actions)
+	for occ := g.occ[g.Turn()]; occ != 0; occ &= occ - 1 {
+		actions = g.pieceAtSq(bits.TrailingZeros64(occ)).appendActions(actions, g)
+	}
+	if g.variant == nil {
+		return actions
+	}
+	if g.variant.hasPockets() {
+		actions = g.appendDropActions(actions)
+	}
+	return actions[:start+len(g.variant.filterMoves(actions[start:]))]
+}
+
 func (g Game) Turn() color {
 	if g.MoveNumber%2 == 0 {
 		return ColorWhite
@@ -135,15 +213,16 @@ func (p Piece) appendActions(actions []Action, g Game) []Action {
 }
 
 // pawnTargets computes the pseudo-legal destination squares for a pawn: forward pushes
-// (single, and double from the home rank) onto empty squares, plus diagonal captures
-// onto opponent pieces or the en passant target square.
+// (single, and double from the second rank, or the first where pawns may be there,
+// e.g. in Horde) onto empty squares, plus diagonal captures onto opponent pieces or
+// the en passant target square.
 func (p Piece) pawnTargets(g Game, sq int, occ uint64) uint64 {
 	var targets uint64
 	if p.Owner == ColorBlack && p.XY.Y < 7 {
 		fwd := sq + 8
 		if occ&sqBit(fwd) == 0 {
 			targets |= sqBit(fwd)
-			if p.XY.Y == 1 && occ&sqBit(fwd+8) == 0 {
+			if p.XY.Y <= 1 && occ&sqBit(fwd+8) == 0 {
 				targets |= sqBit(fwd + 8)
 			}
 		}
@@ -152,7 +231,7 @@ func (p Piece) pawnTargets(g Game, sq int, occ uint64) uint64 {
 		fwd := sq - 8
 		if occ&sqBit(fwd) == 0 {
 			targets |= sqBit(fwd)
-			if p.XY.Y == 6 && occ&sqBit(fwd-8) == 0 {
+			if p.XY.Y >= 6 && occ&sqBit(fwd-8) == 0 {
 				targets |= sqBit(fwd - 8)
 			}
 		}
@@ -187,36 +266,54 @@ func (p Piece) appendActionsTo(actions []Action, g Game, toXY XY) []Action {
 	}
 
 	// check if moving puts the owner's King in check (the promoted piece type cannot
-	// affect this, so the check is done once for all 4 promotion actions)
-	newGame := g.updateBoardLayout(a)
-	if newGame.attackersOf(int(newGame.kingSq[p.Owner]), p.Owner) != 0 {
+	// affect this, so the check is done once for all 4 promotion actions). g is a
+	// copy, so the action is tried on it in place. Variants may expose the king
+	// otherwise (e.g. by blowing it up in Atomic), or not at all.
+	g.movePieces(a)
+	var leavesKingInCheck bool
+	if g.variant == nil {
+		leavesKingInCheck = g.bb[p.Owner][PieceKing] != 0 && g.attackersOf(int(g.kingSq[p.Owner]), p.Owner) != 0
+	} else {
+		leavesKingInCheck = g.variant.exposesKing(g, a)
+	}
+	g.unmovePieces(a)
+	if leavesKingInCheck {
 		return actions
 	}
 
-	// Set promotion context
+	// Set promotion context. The variant may allow some promotions but not others
+	// (e.g. Racing Kings forbids those that give check), or others (e.g. to a king
+	// in Antichess).
 	if p.PieceType == PiecePawn && (toXY.Y == 0 || toXY.Y == 7) {
 		a.IsPromotion = true
-		for _, promotionPieceType := range promotionPieceTypes {
+		types := promotionPieceTypes[:]
+		if g.variant != nil {
+			types = g.variant.promotions()
+		}
+		for _, promotionPieceType := range types {
 			a.PromotionPieceType = promotionPieceType
-			actions = append(actions, a)
+			if g.variant == nil || g.variantAllows(a) {
+				actions = append(actions, a)
+			}
 		}
 		return actions
 	}
 
+	if g.variant != nil && !g.variantAllows(a) {
+		return actions
+	}
 	return append(actions, a)
 }
 
 func (p Piece) appendCastleActions(actions []Action, g Game) []Action {
 	var canQueenside, canKingside bool
-	homeRank := 0
 	switch p.Owner {
 	case ColorBlack:
 		canQueenside, canKingside = g.CanBlackCastle && g.CanBlackQueensideCastle, g.CanBlackCastle && g.CanBlackKingsideCastle
 	case ColorWhite:
 		canQueenside, canKingside = g.CanWhiteCastle && g.CanWhiteQueensideCastle, g.CanWhiteCastle && g.CanWhiteKingsideCastle
-		homeRank = 7
 	}
-	if (!canQueenside && !canKingside) || !p.XY.eq(XY{4, homeRank}) {
+	if (!canQueenside && !canKingside) || p.XY.Y != homeRank(p.Owner) || (!g.IsChess960 && p.XY.X != 4) {
 		return actions
 	}
 
@@ -230,23 +327,100 @@ func (p Piece) appendCastleActions(actions []Action, g Game) []Action {
 		{canKingside, castleTypeKingside, 6},
 	}
 	for _, c := range castles {
-		if !c.allowed ||
-			occ&castleEmptyMasks[p.Owner][c.castleType] != 0 ||
-			g.anySqThreatened(castleUnthreatenedSqs[p.Owner][c.castleType], p.Owner) {
+		if !c.allowed {
+			continue
+		}
+		emptyMask, unthreatenedSqs := castleEmptyMasks[p.Owner][c.castleType], castleUnthreatenedSqs[p.Owner][c.castleType][:]
+		if g.IsChess960 {
+			emptyMask, unthreatenedSqs = g.chess960CastleSqs(p, c.castleType, c.toX)
+		}
+		// The king and the castling rook don't block attacks: in Chess960 the rook
+		// may otherwise hide one on the king's destination (e.g. a queen on a1
+		// behind a rook on b1, when castling queenside with a king on c1).
+		rookSq := sqOf(XY{g.castleRookX(p.Owner, c.castleType), p.XY.Y})
+		pathOcc := occ &^ sqBit(sqOf(p.XY)) &^ sqBit(rookSq)
+		if occ&emptyMask != 0 || g.anySqThreatened(unthreatenedSqs, p.Owner, pathOcc) {
 			continue
 		}
 		a := Action{
 			FromPiece:         p,
-			ToXY:              XY{c.toX, homeRank},
+			ToXY:              XY{c.toX, p.XY.Y},
 			IsCastle:          true,
 			IsQueensideCastle: c.castleType == castleTypeQueenside,
 			IsKingsideCastle:  c.castleType == castleTypeKingside,
 		}
+		if g.variant != nil && !g.variantAllows(a) {
+			continue
+		}
 		actions = append(actions, a)
 	}
 	return actions
 }
 
+// chess960CastleSqs computes, for a Chess960 castle, the squares that must be empty
+// (every square the king and rook cross or land on, other than their own starting
+// squares) and the squares the king must not be threatened on (from its starting
+// square to its destination, inclusive).
+func (g Game) chess960CastleSqs(king Piece, ct castleType, kingToX int) (uint64, []int8) {
+	rank := king.XY.Y
+	kingFromX, rookFromX := king.XY.X, g.castleRookX(king.Owner, ct)
+	rookToX := 3
+	if ct == castleTypeKingside {
+		rookToX = 5
+	}
+	var emptyMask uint64
+	for x := minInt(kingFromX, kingToX, rookFromX, rookToX); x <= maxInt(kingFromX, kingToX, rookFromX, rookToX); x++ {
+		if x != kingFromX && x != rookFromX {
+			emptyMask |= sqBit(sqOf(XY{x, rank}))
+		}
+	}
+	unthreatenedSqs := make([]int8, 0, 8)
+	for x := minInt(kingFromX, kingToX); x <= maxInt(kingFromX, kingToX); x++ {
+		unthreatenedSqs = append(unthreatenedSqs, int8(sqOf(XY{x, rank})))
+	}
+	return emptyMask, unthreatenedSqs
+}
+
+// castleRookX returns the file of the given color's castling rook for the given
+// castle type: the a/h files in standard chess, or the game's own in Chess960.
+func (g Game) castleRookX(c color, ct castleType) int {
+	if g.IsChess960 {
+		return int(g.castlingRookX[c][ct])
+	}
+	if ct == castleTypeKingside {
+		return 7
+	}
+	return 0
+}
+
+// CastlingRookXY returns the starting square of the rook the given color castles
+// with, kingside or queenside. In Chess960 it's the square a king-takes-rook
+// castling input (e.g. UCI "e1h1" style) targets.
+func (g Game) CastlingRookXY(c Color, isKingside bool) XY {
+	ct := castleType(castleTypeQueenside)
+	if isKingside {
+		ct = castleTypeKingside
+	}
+	return XY{g.castleRookX(c, ct), homeRank(c)}
+}
+
+// revokeCastlingRight drops a color's castling right of the given type, keeping
+// the color's aggregate CanXCastle flag consistent.
+func (g *Game) revokeCastlingRight(c color, ct castleType) {
+	switch {
+	case c == ColorBlack && ct == castleTypeQueenside:
+		g.CanBlackQueensideCastle = false
+	case c == ColorBlack && ct == castleTypeKingside:
+		g.CanBlackKingsideCastle = false
+	case c == ColorWhite && ct == castleTypeQueenside:
+		g.CanWhiteQueensideCastle = false
+	case c == ColorWhite && ct == castleTypeKingside:
+		g.CanWhiteKingsideCastle = false
+	}
+	g.CanBlackCastle = g.CanBlackKingsideCastle || g.CanBlackQueensideCastle
+	g.CanWhiteCastle = g.CanWhiteKingsideCastle || g.CanWhiteQueensideCastle
+}
+
 // doAction executes the given action on the given game.
 // It assumes that the game is in a state where this action can be executed.
 // It assumes that the action is fully-correctly created and it's valid.
@@ -265,97 +439,112 @@ func (g Game) DoAction(a Action) Game {
 		return newGame
 	}
 
-	// Special case for draw action (proposing a draw, assumed accepted)
-	if a.IsDraw {
+	// Special cases for draw actions that aren't moves: agreeing to a draw,
+	// accepting an offer or claiming end the game, while offering or declining
+	// only change the pending offer
+	switch {
+	case a.IsDraw, a.IsDrawAccept, a.IsDrawClaim && !a.IsMove():
 		newGame.IsGameOver = true
 		newGame.IsDraw = true
 		newGame.GameOverWinner = -1
 		return newGame
+	case a.IsDrawOffer:
+		return g.withDrawOffer(true, lastTurn)
+	case a.IsDrawDecline:
+		return g.withDrawOffer(false, 0)
 	}
 
-	// Castling context update: moving player's king or rook
-	switch {
-	case lastTurn == ColorBlack && (a.IsCastle || a.FromPiece.PieceType == PieceKing):
-		newGame.CanBlackCastle = false
-		newGame.CanBlackQueensideCastle = false
-		newGame.CanBlackKingsideCastle = false
-	case lastTurn == ColorWhite && (a.IsCastle || a.FromPiece.PieceType == PieceKing):
-		newGame.CanWhiteCastle = false
-		newGame.CanWhiteQueensideCastle = false
-		newGame.CanWhiteKingsideCastle = false
-	case lastTurn == ColorBlack && a.FromPiece.PieceType == PieceRook && a.FromPiece.XY == XY{X: 0, Y: 0}:
-		newGame.CanBlackQueensideCastle = false
-		newGame.CanBlackCastle = newGame.CanBlackKingsideCastle
-	case lastTurn == ColorBlack && a.FromPiece.PieceType == PieceRook && a.FromPiece.XY == XY{X: 7, Y: 0}:
-		newGame.CanBlackKingsideCastle = false
-		newGame.CanBlackCastle = newGame.CanBlackQueensideCastle
-	case lastTurn == ColorWhite && a.FromPiece.PieceType == PieceRook && a.FromPiece.XY == XY{X: 0, Y: 7}:
-		newGame.CanWhiteQueensideCastle = false
-		newGame.CanWhiteCastle = newGame.CanWhiteKingsideCastle
-	case lastTurn == ColorWhite && a.FromPiece.PieceType == PieceRook && a.FromPiece.XY == XY{X: 7, Y: 7}:
-		newGame.CanWhiteKingsideCastle = false
-		newGame.CanWhiteCastle = newGame.CanWhiteQueensideCastle
-	}
-
-	// Castling context update: opponent's rook captured on its home square
-	if a.IsCapture {
-		switch a.ToXY {
-		case XY{X: 0, Y: 0}:
-			newGame.CanBlackQueensideCastle = false
-			newGame.CanBlackCastle = newGame.CanBlackKingsideCastle
-		case XY{X: 7, Y: 0}:
-			newGame.CanBlackKingsideCastle = false
-			newGame.CanBlackCastle = newGame.CanBlackQueensideCastle
-		case XY{X: 0, Y: 7}:
-			newGame.CanWhiteQueensideCastle = false
-			newGame.CanWhiteCastle = newGame.CanWhiteKingsideCastle
-		case XY{X: 7, Y: 7}:
-			newGame.CanWhiteKingsideCastle = false
-			newGame.CanWhiteCastle = newGame.CanWhiteQueensideCastle
+	newGame.updateState(a)
+
+	// Maintain position history for repetition detection. Captures and pawn moves are
+	// irreversible, so earlier positions can never repeat and the history restarts.
+	if newGame.HalfMoveClock > 0 {
+		newGame.positionHistory = append(newGame.positionHistory, g.positionHistory...)
+	}
+	newGame.positionHistory = append(newGame.positionHistory, newGame.Hash())
+
+	newGame = newGame.calculateCriticalFlags().withCheckKinds(a)
+
+	// A claim made with the move that allows it ends the game; otherwise the move
+	// stands, and the game goes on
+	if a.IsDrawClaim && newGame.CanClaimDraw {
+		newGame.IsGameOver = true
+		newGame.IsDraw = true
+		newGame.CanClaimDraw = false
+		newGame.GameOverWinner = -1
+		newGame.Actions = nil
+	}
+	return newGame
+}
+
+// updateState updates the game's castling rights, move counters and en passant
+// target square after the given action, once its pieces are moved.
+func (g *Game) updateState(a Action) {
+	lastTurn := g.Turn()
+
+	// Castling context update: moving player's king or castling rook, or a castling
+	// rook captured on its starting square
+	if a.IsCastle || a.FromPiece.PieceType == PieceKing {
+		g.revokeCastlingRight(lastTurn, castleTypeQueenside)
+		g.revokeCastlingRight(lastTurn, castleTypeKingside)
+	}
+	for _, c := range [2]color{ColorBlack, ColorWhite} {
+		for _, ct := range [2]castleType{castleTypeQueenside, castleTypeKingside} {
+			rookXY := XY{g.castleRookX(c, ct), homeRank(c)}
+			movesRook := c == lastTurn && a.FromPiece.PieceType == PieceRook && a.FromPiece.XY == rookXY
+			capturesRook := c != lastTurn && a.IsCapture && a.ToXY == rookXY
+			if movesRook || capturesRook {
+				g.revokeCastlingRight(c, ct)
+			}
 		}
 	}
 
-	newGame.MoveNumber = g.MoveNumber + 1
+	g.MoveNumber++
 	if lastTurn == ColorBlack {
-		newGame.FullMoveNumber = g.FullMoveNumber + 1
+		g.FullMoveNumber++
 	}
-	isDoubleAdvance := a.FromPiece.PieceType == PiecePawn && abs(a.ToXY.Y-a.FromPiece.XY.Y) == 2
-	newGame.IsLastMoveEnPassant = isDoubleAdvance
+	// Pawns advancing two squares from the first rank (e.g. in Horde) can't be
+	// captured en passant.
+	isDoubleAdvance := a.FromPiece.PieceType == PiecePawn && abs(a.ToXY.Y-a.FromPiece.XY.Y) == 2 && a.FromPiece.XY.Y != homeRank(lastTurn)
+	g.IsLastMoveEnPassant = isDoubleAdvance
 	if isDoubleAdvance && lastTurn == ColorBlack {
-		newGame.EnPassantTargetSquare = XY{X: a.ToXY.X, Y: a.ToXY.Y - 1}
+		g.EnPassantTargetSquare = XY{X: a.ToXY.X, Y: a.ToXY.Y - 1}
 	}
 	if isDoubleAdvance && lastTurn == ColorWhite {
-		newGame.EnPassantTargetSquare = XY{X: a.ToXY.X, Y: a.ToXY.Y + 1}
+		g.EnPassantTargetSquare = XY{X: a.ToXY.X, Y: a.ToXY.Y + 1}
 	}
 
-	newGame.HalfMoveClock = g.HalfMoveClock + 1
+	g.HalfMoveClock++
 	if a.IsCapture || a.FromPiece.PieceType == PiecePawn {
-		newGame.HalfMoveClock = 0
+		g.HalfMoveClock = 0
 	}
 
-	// Maintain position history for repetition detection. Captures and pawn moves are
-	// irreversible, so earlier positions can never repeat and the history restarts.
-	if newGame.HalfMoveClock > 0 {
-		newGame.positionHistory = append(newGame.positionHistory, g.positionHistory...)
+	// Moving instead of answering a draw offer declines it
+	if g.IsDrawOffered && g.DrawOfferedBy != lastTurn {
+		g.IsDrawOffered, g.DrawOfferedBy = false, 0
 	}
-	newGame.positionHistory = append(newGame.positionHistory, newGame.positionHash())
 
-	newGame = newGame.calculateCriticalFlags()
+	if g.variant != nil {
+		g.variant.updateState(g, a)
+	}
+}
 
-	if newGame.IsCheck {
-		newGame.IsDoubleCheck = len(newGame.InCheckBy) >= 2
+// withCheckKinds sets whether the check that the given (last) action gave, if
+// any, is a double check or a discovered check.
+func (g Game) withCheckKinds(a Action) Game {
+	if g.IsCheck {
+		g.IsDoubleCheck = len(g.InCheckBy) >= 2
 
 		hasRevealedChecker := false
-		for _, checker := range newGame.InCheckBy {
+		for _, checker := range g.InCheckBy {
 			if checker.XY != a.ToXY {
 				hasRevealedChecker = true
 				break
 			}
 		}
-		newGame.IsDiscoverCheck = hasRevealedChecker
+		g.IsDiscoverCheck = hasRevealedChecker
 	}
-
-	return newGame
+	return g
 }
 
 func (g Game) calculateCriticalFlags() Game {
@@ -369,18 +558,42 @@ func (g Game) calculateCriticalFlags() Game {
 	g.IsDraw = false
 	g.CanClaimDraw = false
 	g.IsGameOver = false
+	g.IsVariantEnd = false
 	g.GameOverWinner = -1
 	g.InCheckBy = []Piece{}
 
-	g.InCheckBy = g.King(turn).threatenedBy(g)
+	for checkers := g.checkers(turn); checkers != 0; checkers &= checkers - 1 {
+		g.InCheckBy = append(g.InCheckBy, g.pieceAtSq(bits.TrailingZeros64(checkers)))
+	}
 	if len(g.InCheckBy) > 0 {
 		g.IsCheck = true
 	}
 
+	// The variant's own rules may have ended the game already, in which case there
+	// are no actions.
+	if isOver, winner := g.outcome(); isOver {
+		g.IsVariantEnd, g.IsGameOver, g.GameOverWinner = true, true, winner
+		g.IsDraw = winner == -1
+		g.Actions = g.calculateAllActions()
+		return g
+	}
+
+	// Draw rules, per FIDE: the 75-move rule, fivefold repetition and insufficient
+	// material (dead position) end the game automatically; the 50-move rule and
+	// threefold repetition make a draw claimable by the player to move. They're
+	// checked first, because claiming is one of the actions.
+	repetitions := g.repetitionCount()
+	switch {
+	case g.HalfMoveClock >= 150, repetitions >= 5, g.Variant().isInsufficientMaterial(g):
+		g.IsDraw = true
+	case g.HalfMoveClock >= 100, repetitions >= 3:
+		g.CanClaimDraw = true
+	}
+
 	g.Actions = g.calculateAllActions() // This is incredibly expensive!
 	hasBoardActions := false
 	for _, a := range g.Actions {
-		if !a.IsResign && !a.IsDraw {
+		if a.IsMove() {
 			hasBoardActions = true
 			break
 		}
@@ -390,17 +603,6 @@ func (g Game) calculateCriticalFlags() Game {
 		g.IsStalemate = !g.IsCheck
 	}
 
-	// Draw rules, per FIDE: the 75-move rule, fivefold repetition and insufficient
-	// material (dead position) end the game automatically; the 50-move rule and
-	// threefold repetition make a draw claimable by the player to move.
-	repetitions := g.repetitionCount()
-	switch {
-	case g.HalfMoveClock >= 150, repetitions >= 5, g.isInsufficientMaterial():
-		g.IsDraw = true
-	case g.HalfMoveClock >= 100, repetitions >= 3:
-		g.CanClaimDraw = true
-	}
-
 	if g.IsCheckmate || g.IsStalemate || g.IsDraw {
 		g.IsGameOver = true
 	}
@@ -430,6 +632,10 @@ func (g Game) repetitionCount() int {
 // isInsufficientMaterial reports whether neither side can possibly checkmate: K vs K,
 // KB vs K, KN vs K, and KB vs KB with both bishops on the same color complex.
 func (g Game) isInsufficientMaterial() bool {
+	// Without kings, capturing all of a color's pieces wins, so any piece is.
+	if g.bb[ColorBlack][PieceKing] == 0 || g.bb[ColorWhite][PieceKing] == 0 {
+		return false
+	}
 	// Any pawn, rook or queen is (potentially) sufficient material.
 	if g.bb[ColorBlack][PiecePawn]|g.bb[ColorWhite][PiecePawn]|
 		g.bb[ColorBlack][PieceRook]|g.bb[ColorWhite][PieceRook]|
@@ -475,9 +681,15 @@ func (p Piece) isInBounds(xy XY) bool {
 	return true
 }
 
-func (g Game) anySqThreatened(sqs [3]int8, owner color) bool {
+func (g Game) anySqThreatened(sqs []int8, owner color, occ uint64) bool {
+	// In Atomic, the squares next to the enemy king are safe: capturing there would
+	// blow it up.
+	var safe uint64
+	if opp := opponent(owner); g.variant == VariantAtomic && g.bb[opp][PieceKing] != 0 {
+		safe = kingAttacks[g.kingSq[opp]]
+	}
 	for _, sq := range sqs {
-		if g.attackersOf(int(sq), owner) != 0 {
+		if safe&sqBit(int(sq)) == 0 && g.attackersOfOcc(int(sq), owner, occ) != 0 {
 			return true
 		}
 	}
@@ -486,8 +698,12 @@ func (g Game) anySqThreatened(sqs [3]int8, owner color) bool {
 
 // attackersOf returns the bitboard of opponent pieces attacking the given square.
 func (g Game) attackersOf(sq int, owner color) uint64 {
+	return g.attackersOfOcc(sq, owner, g.occAll())
+}
+
+// attackersOfOcc is attackersOf with the given occupancy for sliding attacks.
+func (g Game) attackersOfOcc(sq int, owner color, occ uint64) uint64 {
 	opp := opponent(owner)
-	occ := g.occAll()
 	return knightAttacks[sq]&g.bb[opp][PieceKnight] |
 		rookAttacks(sq, occ)&(g.bb[opp][PieceRook]|g.bb[opp][PieceQueen]) |
 		bishopAttacks(sq, occ)&(g.bb[opp][PieceBishop]|g.bb[opp][PieceQueen]) |
@@ -510,6 +726,32 @@ func (p Piece) threatenedBy(g Game) []Piece {
 	return g.xyThreatenedBy(p.XY, p.Owner, true /* checkAllThreats */)
 }
 
+// homeRank returns the Y coordinate of the given color's first rank.
+func homeRank(c color) int {
+	if c == ColorWhite {
+		return 7
+	}
+	return 0
+}
+
+func minInt(a int, bs ...int) int {
+	for _, b := range bs {
+		if b < a {
+			a = b
+		}
+	}
+	return a
+}
+
+func maxInt(a int, bs ...int) int {
+	for _, b := range bs {
+		if b > a {
+			a = b
+		}
+	}
+	return a
+}
+
 func abs(a int) int {
 	if a < 0 {
 		return -a
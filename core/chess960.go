@@ -0,0 +1,61 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var errChess960InvalidStartPositionID = errors.New("chess960 start position id must be between 0 and 959")
+
+// Chess960StandardStartPositionID is the Scharnagl number of the standard chess
+// starting position (RNBQKBNR).
+const Chess960StandardStartPositionID = 518
+
+// NewChess960Game returns the Chess960 starting position with the given Scharnagl
+// number (0-959), with full castling rights.
+// https://www.chessprogramming.org/Reinhard_Scharnagl#Chess960Numbering
+func NewChess960Game(id int) (Game, error) {
+	backRank, err := chess960BackRank(id)
+	if err != nil {
+		return Game{}, err
+	}
+	return NewChess960GameFromFEN(fmt.Sprintf("%v/pppppppp/8/8/8/8/PPPPPPPP/%v w KQkq - 0 1", strings.ToLower(backRank), backRank))
+}
+
+// chess960BackRank returns White's back rank (a-file first) for the given Scharnagl
+// number: the number's digits in mixed radix 4, 4, 6, 10 place the light-squared
+// bishop, the dark-squared bishop, the queen and the knights, and the king goes
+// between the two rooks on the remaining squares.
+func chess960BackRank(id int) (string, error) {
+	if id < 0 || id > 959 {
+		return "", errChess960InvalidStartPositionID
+	}
+	var backRank [8]byte
+	backRank[id%4*2+1] = 'B'
+	id /= 4
+	backRank[id%4*2] = 'B'
+	id /= 4
+	placeOnNthEmpty := func(piece byte, n int) {
+		for x := range backRank {
+			if backRank[x] != 0 {
+				continue
+			}
+			if n == 0 {
+				backRank[x] = piece
+				return
+			}
+			n--
+		}
+	}
+	placeOnNthEmpty('Q', id%6)
+	id /= 6
+	knightPlacements := [10][2]int{{0, 0}, {0, 1}, {0, 2}, {0, 3}, {1, 1}, {1, 2}, {1, 3}, {2, 2}, {2, 3}, {3, 3}}
+	// The second knight's index is relative to the squares left after the first.
+	placeOnNthEmpty('N', knightPlacements[id][0])
+	placeOnNthEmpty('N', knightPlacements[id][1])
+	placeOnNthEmpty('R', 0)
+	placeOnNthEmpty('K', 0)
+	placeOnNthEmpty('R', 0)
+	return string(backRank[:]), nil
+}
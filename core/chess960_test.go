@@ -0,0 +1,210 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewChess960Game(t *testing.T) {
+	ts := []struct {
+		id          int
+		fen         string
+		shredderFEN string
+	}{
+		{0, "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1", "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w HFhf - 0 1"},
+		{1, "bqnbnrkr/pppppppp/8/8/8/8/PPPPPPPP/BQNBNRKR w KQkq - 0 1", "bqnbnrkr/pppppppp/8/8/8/8/PPPPPPPP/BQNBNRKR w HFhf - 0 1"},
+		{518, "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w HAha - 0 1"},
+		{959, "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w KQkq - 0 1", "rkrnnqbb/pppppppp/8/8/8/8/PPPPPPPP/RKRNNQBB w CAca - 0 1"},
+	}
+	for _, tc := range ts {
+		t.Run(fmt.Sprintf("id %d", tc.id), func(t *testing.T) {
+			g, err := NewChess960Game(tc.id)
+			require.NoError(t, err)
+			assert.True(t, g.IsChess960)
+			assert.Equal(t, tc.fen, g.ToFEN())
+			assert.Equal(t, tc.shredderFEN, g.ToShredderFEN())
+			assert.Len(t, g.Actions, 20+2) // 20 board actions, plus resign and draw offer
+		})
+	}
+
+	t.Run("all ids are distinct and valid", func(t *testing.T) {
+		seen := map[string]int{}
+		for id := 0; id < 960; id++ {
+			backRank, err := chess960BackRank(id)
+			require.NoError(t, err)
+			prev, ok := seen[backRank]
+			require.False(t, ok, "ids %d and %d share back rank %v", prev, id, backRank)
+			seen[backRank] = id
+		}
+	})
+
+	t.Run("out of range ids", func(t *testing.T) {
+		for _, id := range []int{-1, 960} {
+			_, err := NewChess960Game(id)
+			assert.Equal(t, errChess960InvalidStartPositionID, err)
+		}
+	})
+}
+
+func TestChess960FEN(t *testing.T) {
+	ts := []struct {
+		name        string
+		fen         string
+		isChess960  bool
+		expectedFEN string
+	}{
+		{
+			name:        "Shredder-FEN makes a Chess960 game and renders as X-FEN",
+			fen:         "4k3/8/8/8/8/8/8/1R3KR1 w GB - 0 1",
+			isChess960:  true,
+			expectedFEN: "4k3/8/8/8/8/8/8/1R3KR1 w KQ - 0 1",
+		},
+		{
+			name:        "X-FEN file letter for a rook that isn't the outermost",
+			fen:         "4k3/8/8/8/8/8/8/RR2K3 w B - 0 1",
+			isChess960:  true,
+			expectedFEN: "4k3/8/8/8/8/8/8/RR2K3 w B - 0 1",
+		},
+		{
+			name:        "Shredder-FEN for a standard position is still Chess960",
+			fen:         "r3k2r/8/8/8/8/8/8/R3K2R w HAha - 0 1",
+			isChess960:  true,
+			expectedFEN: "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+		},
+		{
+			name:        "file letter without a rook on it is dropped",
+			fen:         "4k3/8/8/8/8/8/8/1R3KR1 w HB - 0 1",
+			isChess960:  true,
+			expectedFEN: "4k3/8/8/8/8/8/8/1R3KR1 w Q - 0 1",
+		},
+		{
+			name:        "plain KQkq is standard castling",
+			fen:         "4k3/8/8/8/8/8/8/1R3KR1 w KQ - 0 1",
+			isChess960:  false,
+			expectedFEN: "4k3/8/8/8/8/8/8/1R3KR1 w - - 0 1",
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := NewGameFromFEN(tc.fen)
+			require.NoError(t, err)
+			assert.Equal(t, tc.isChess960, g.IsChess960)
+			assert.Equal(t, tc.expectedFEN, g.ToFEN())
+		})
+	}
+
+	t.Run("X-FEN KQkq refers to the outermost rooks", func(t *testing.T) {
+		g, err := NewChess960GameFromFEN("rk4r1/8/8/8/8/8/8/RK4R1 w KQkq - 0 1")
+		require.NoError(t, err)
+		assert.Equal(t, "rk4r1/8/8/8/8/8/8/RK4R1 w GAga - 0 1", g.ToShredderFEN())
+		assert.Equal(t, XY{6, 7}, g.CastlingRookXY(ColorWhite, true))
+		assert.Equal(t, XY{0, 0}, g.CastlingRookXY(ColorBlack, false))
+	})
+}
+
+func TestChess960Castling(t *testing.T) {
+	castle := func(t *testing.T, g Game, isKingside bool) (Game, bool) {
+		t.Helper()
+		for _, a := range g.Actions {
+			if a.IsCastle && a.IsKingsideCastle == isKingside {
+				return g.DoAction(a), true
+			}
+		}
+		return g, false
+	}
+
+	t.Run("king lands on the castling rook's square", func(t *testing.T) {
+		g, err := NewGameFromFEN("4k3/8/8/8/8/8/8/1R3KR1 w GB - 0 1")
+		require.NoError(t, err)
+		g, ok := castle(t, g, true)
+		require.True(t, ok)
+		assert.Equal(t, "4k3/8/8/8/8/8/8/1R3RK1 b - - 1 1", g.ToFEN())
+	})
+
+	t.Run("rook lands on the king's square", func(t *testing.T) {
+		g, err := NewGameFromFEN("4k3/8/8/8/8/8/8/1R3KR1 w GB - 0 1")
+		require.NoError(t, err)
+		g, ok := castle(t, g, false)
+		require.True(t, ok)
+		assert.Equal(t, "4k3/8/8/8/8/8/8/2KR2R1 b - - 1 1", g.ToFEN())
+	})
+
+	t.Run("king doesn't move", func(t *testing.T) {
+		g, err := NewGameFromFEN("4k3/8/8/8/8/8/8/6KR w H - 0 1")
+		require.NoError(t, err)
+		g, ok := castle(t, g, true)
+		require.True(t, ok)
+		assert.Equal(t, "4k3/8/8/8/8/8/8/5RK1 b - - 1 1", g.ToFEN())
+	})
+
+	t.Run("black castles queenside", func(t *testing.T) {
+		g, err := NewGameFromFEN("1r4k1/8/8/8/8/8/8/6K1 b b - 0 1")
+		require.NoError(t, err)
+		g, ok := castle(t, g, false)
+		require.True(t, ok)
+		assert.Equal(t, "2kr4/8/8/8/8/8/8/6K1 w - - 1 2", g.ToFEN())
+	})
+
+	t.Run("a piece between the rook and its destination blocks castling", func(t *testing.T) {
+		g, err := NewGameFromFEN("4k3/8/8/8/8/8/8/R1N1K3 w A - 0 1")
+		require.NoError(t, err)
+		_, ok := castle(t, g, false)
+		assert.False(t, ok)
+	})
+
+	t.Run("a threatened square on the king's path forbids castling", func(t *testing.T) {
+		g, err := NewGameFromFEN("4k3/8/8/8/8/8/4r3/1K4R1 w G - 0 1")
+		require.NoError(t, err)
+		_, ok := castle(t, g, true)
+		assert.False(t, ok)
+	})
+
+	t.Run("the castling rook doesn't hide a threat on the king's destination", func(t *testing.T) {
+		g, err := NewGameFromFEN("4k3/8/8/8/8/8/8/qRK5 w B - 0 1")
+		require.NoError(t, err)
+		_, ok := castle(t, g, false)
+		assert.False(t, ok)
+	})
+
+	t.Run("moving a castling rook only revokes its own right", func(t *testing.T) {
+		g, err := NewGameFromFEN("4k3/8/8/8/8/8/8/1R3KR1 w GB - 0 1")
+		require.NoError(t, err)
+		for _, a := range g.Actions {
+			if a.FromPiece.XY == (XY{6, 7}) && a.ToXY == (XY{6, 6}) {
+				g = g.DoAction(a)
+				break
+			}
+		}
+		assert.Equal(t, "4k3/8/8/8/8/8/6R1/1R3K2 b Q - 1 1", g.ToFEN())
+		assert.False(t, g.CanWhiteKingsideCastle)
+		assert.True(t, g.CanWhiteQueensideCastle)
+	})
+}
+
+func TestChess960Perft(t *testing.T) {
+	// https://www.chessprogramming.org/Chess960_Perft_Results
+	ts := []struct {
+		fen    string
+		depths map[int]int
+	}{
+		{"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9", map[int]int{1: 21, 2: 528, 3: 12189, 4: 326672}},
+		{"2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9", map[int]int{1: 21, 2: 807, 3: 18002, 4: 667366}},
+		{"b1q1rrkb/pppppppp/3nn3/8/P7/1PPP4/4PPPP/BQNNRKRB w GE - 1 9", map[int]int{1: 20, 2: 479, 3: 10471, 4: 273318}},
+	}
+	for _, tc := range ts {
+		t.Run(tc.fen, func(t *testing.T) {
+			g, err := NewGameFromFEN(tc.fen)
+			require.NoError(t, err)
+			require.True(t, g.IsChess960)
+			for depth, expected := range tc.depths {
+				if depth >= 4 && testing.Short() {
+					continue
+				}
+				assert.Equal(t, expected, Perft(g, depth), "depth %d", depth)
+			}
+		})
+	}
+}
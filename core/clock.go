@@ -0,0 +1,309 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DelayKind is how a TimePeriod's delay spares a player's time.
+type DelayKind int
+
+const (
+	// DelaySimple (also US delay) only starts running a player's clock once the
+	// delay has passed on each of their moves.
+	DelaySimple DelayKind = iota
+	// DelayBronstein gives a player back the time they used on each of their
+	// moves, up to the delay.
+	DelayBronstein
+)
+
+// TimePeriod is one of the periods of a TimeControl, e.g. "40 moves in 2 hours".
+type TimePeriod struct {
+	// Moves is the number of moves each player must make in the period, or 0 if
+	// the period lasts for the rest of the game (sudden death).
+	Moves int
+	// Time is the time each player gets for the period.
+	Time time.Duration
+	// Increment is added to a player's time after each of their moves in the
+	// period (Fischer increment).
+	Increment time.Duration
+	// Delay spares some of the time of each move in the period, as DelayKind says.
+	Delay     time.Duration
+	DelayKind DelayKind
+}
+
+// TimeControl is the time control of a timed game: one or more periods, e.g.
+// 40 moves in 2 hours and then 30 minutes for the rest of the game. The last
+// period repeats if it has a number of moves. The zero value is no time control.
+type TimeControl struct {
+	Periods []TimePeriod
+}
+
+var errInvalidTimeControl = errors.New("invalid time control")
+
+// ParseTimeControl parses a time control in the format of PGN's TimeControl tag:
+// periods separated by ":", each of them a number of seconds for the rest of the
+// game (e.g. "300"), optionally preceded by a number of moves (e.g. "40/7200")
+// and followed by an increment (e.g. "180+2"). So FIDE's classical time control
+// is "40/5400+30:1800+30".
+//
+// As an extension, a period may have a simple delay (e.g. "300d5") or a Bronstein
+// delay (e.g. "300b5") instead of an increment, in seconds. "-" and "?" (no and
+// unknown time control) are the zero TimeControl. Sandclock time controls (e.g.
+// "*180") aren't supported.
+func ParseTimeControl(s string) (TimeControl, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "-" || s == "?" {
+		return TimeControl{}, nil
+	}
+	var tc TimeControl
+	for _, field := range strings.Split(s, ":") {
+		period, err := parseTimePeriod(field)
+		if err != nil {
+			return TimeControl{}, fmt.Errorf("%w %q: %v", errInvalidTimeControl, s, err)
+		}
+		tc.Periods = append(tc.Periods, period)
+	}
+	for _, period := range tc.Periods[:len(tc.Periods)-1] {
+		if period.Moves == 0 {
+			return TimeControl{}, fmt.Errorf("%w %q: only the last period may be for the rest of the game", errInvalidTimeControl, s)
+		}
+	}
+	return tc, nil
+}
+
+func parseTimePeriod(s string) (TimePeriod, error) {
+	var (
+		period TimePeriod
+		err    error
+	)
+	if moves, rest, ok := strings.Cut(s, "/"); ok {
+		if period.Moves, err = strconv.Atoi(moves); err != nil || period.Moves <= 0 {
+			return TimePeriod{}, fmt.Errorf("invalid number of moves %q", moves)
+		}
+		s = rest
+	}
+	var extra *time.Duration
+	if i := strings.IndexAny(s, "+db"); i >= 0 {
+		switch s[i] {
+		case '+':
+			extra = &period.Increment
+		case 'b':
+			period.DelayKind = DelayBronstein
+			fallthrough
+		default:
+			extra = &period.Delay
+		}
+		if *extra, err = parseSeconds(s[i+1:]); err != nil {
+			return TimePeriod{}, err
+		}
+		s = s[:i]
+	}
+	if period.Time, err = parseSeconds(s); err != nil {
+		return TimePeriod{}, err
+	}
+	return period, nil
+}
+
+func parseSeconds(s string) (time.Duration, error) {
+	seconds, err := strconv.Atoi(s)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid number of seconds %q", s)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// String returns the time control in the format of ParseTimeControl, or "-" if
+// there's none.
+func (tc TimeControl) String() string {
+	if len(tc.Periods) == 0 {
+		return "-"
+	}
+	fields := make([]string, len(tc.Periods))
+	for i, period := range tc.Periods {
+		var sb strings.Builder
+		if period.Moves > 0 {
+			fmt.Fprintf(&sb, "%d/", period.Moves)
+		}
+		fmt.Fprintf(&sb, "%d", int(period.Time/time.Second))
+		switch {
+		case period.Increment > 0:
+			fmt.Fprintf(&sb, "+%d", int(period.Increment/time.Second))
+		case period.Delay > 0 && period.DelayKind == DelayBronstein:
+			fmt.Fprintf(&sb, "b%d", int(period.Delay/time.Second))
+		case period.Delay > 0:
+			fmt.Fprintf(&sb, "d%d", int(period.Delay/time.Second))
+		}
+		fields[i] = sb.String()
+	}
+	return strings.Join(fields, ":")
+}
+
+// period returns the period of a player's move, given how many moves they made
+// before it.
+func (tc TimeControl) period(moves int) TimePeriod {
+	for i, period := range tc.Periods {
+		if period.Moves == 0 || moves < period.Moves || i == len(tc.Periods)-1 {
+			return period
+		}
+		moves -= period.Moves
+	}
+	return TimePeriod{}
+}
+
+// nextPeriod returns the period that starts after a player's given number of
+// moves, if their last move ended one.
+func (tc TimeControl) nextPeriod(moves int) (TimePeriod, bool) {
+	for i, period := range tc.Periods {
+		switch {
+		case period.Moves == 0 || moves < period.Moves:
+			return TimePeriod{}, false
+		case i == len(tc.Periods)-1:
+			return period, moves%period.Moves == 0
+		case moves == period.Moves:
+			return tc.Periods[i+1], true
+		}
+		moves -= period.Moves
+	}
+	return TimePeriod{}, false
+}
+
+// Clock is the state of the clocks of a timed game.
+type Clock struct {
+	TimeControl TimeControl
+	// Remaining is each player's time left, by color (e.g. Remaining[ColorWhite]).
+	Remaining [2]time.Duration
+	// Moves is the number of moves each player made on the clock, by color, which
+	// tells the period they're in.
+	Moves [2]int
+}
+
+// NewClock creates the clocks of a game that starts with the given time control.
+func NewClock(tc TimeControl) Clock {
+	c := Clock{TimeControl: tc}
+	if len(tc.Periods) > 0 {
+		c.Remaining = [2]time.Duration{tc.Periods[0].Time, tc.Periods[0].Time}
+	}
+	return c
+}
+
+// IsTimed returns whether the clock has a time control. Games without one, such
+// as those created from a FEN string, are untimed.
+func (c Clock) IsTimed() bool {
+	return len(c.TimeControl.Periods) > 0
+}
+
+// Period returns the time period of the given player's next move.
+func (c Clock) Period(player Color) TimePeriod {
+	return c.TimeControl.period(c.Moves[player])
+}
+
+// afterMove returns the clock after the given player makes a move that took the
+// given time, and whether their flag fell (i.e. they ran out of time) before it.
+func (c Clock) afterMove(player Color, elapsed time.Duration) (Clock, bool) {
+	period := c.Period(player)
+	used := elapsed
+	if period.DelayKind == DelaySimple {
+		used = max(elapsed-period.Delay, 0)
+	}
+	if used > 0 && used >= c.Remaining[player] {
+		c.Remaining[player] = 0
+		return c, true
+	}
+	c.Remaining[player] -= used
+	if period.DelayKind == DelayBronstein {
+		c.Remaining[player] += min(elapsed, period.Delay)
+	}
+	c.Remaining[player] += period.Increment
+	c.Moves[player]++
+	if next, ok := c.TimeControl.nextPeriod(c.Moves[player]); ok {
+		c.Remaining[player] += next.Time
+	}
+	return c, false
+}
+
+// WithClock returns a copy of the game with the given clocks, e.g. a NewClock to
+// start a timed game, or the Clock of a previous game to continue it across
+// stateless API calls.
+func (g Game) WithClock(c Clock) Game {
+	g.Clock = c
+	return g
+}
+
+// DoTimedAction is like DoAction, but the action took the given time of the
+// clock of the player to move: it's deducted from their time, as the time
+// control says (see TimePeriod). If their time runs out first, the action isn't
+// done: their flag falls instead (see FlagFall).
+//
+// Actions that aren't moves (see Action.IsMove) don't run the clock, and untimed
+// games (see Clock.IsTimed) ignore the time. Note that DoAction doesn't run the
+// clock either.
+func (g Game) DoTimedAction(a Action, elapsed time.Duration) Game {
+	if !g.Clock.IsTimed() || !a.IsMove() || g.IsGameOver {
+		return g.DoAction(a)
+	}
+	clock, isFlagFall := g.Clock.afterMove(g.Turn(), elapsed)
+	if isFlagFall {
+		return g.WithClock(clock).FlagFall()
+	}
+	newGame := g.DoAction(a)
+	newGame.Clock = clock
+	return newGame
+}
+
+// FlagFall returns the game after the player to move runs out of time: they
+// lose, unless their opponent can't checkmate them by any series of legal moves,
+// in which case the game is drawn, as per FIDE's Laws of Chess (6.9).
+//
+// The opponent is considered unable to checkmate when they only have their king,
+// or when neither player has enough material to checkmate (see IsDraw).
+func (g Game) FlagFall() Game {
+	turn := g.Turn()
+	newGame := g.shallowCloneForMove()
+	newGame.Clock.Remaining[turn] = 0
+	newGame.IsTimeout = true
+	newGame.IsGameOver = true
+	newGame.GameOverWinner = opponent(turn)
+	if !g.canCheckmate(opponent(turn)) {
+		newGame.IsDraw = true
+		newGame.GameOverWinner = -1
+	}
+	return newGame
+}
+
+// canCheckmate returns whether the given player has enough material to checkmate
+// their opponent, with the opponent's help if necessary.
+func (g Game) canCheckmate(player color) bool {
+	return g.occ[player] != g.bb[player][PieceKing] && !g.Variant().isInsufficientMaterial(g)
+}
+
+// ParseClockTime parses a clock time as in PGN's [%clk] command, i.e. hours,
+// minutes and seconds, optionally with a fraction of a second (e.g. "1:59:58" or
+// "0:00:09.8").
+func ParseClockTime(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid clock time %q: expected h:mm:ss", s)
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil || hours < 0 || minutes < 0 || minutes >= 60 || seconds < 0 || seconds >= 60 {
+		return 0, fmt.Errorf("invalid clock time %q: expected h:mm:ss", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second)).Round(time.Millisecond), nil
+}
+
+// FormatClockTime formats a clock time as ParseClockTime parses it, with tenths
+// of a second only when it has some (e.g. "1:59:58" or "0:00:09.8").
+func FormatClockTime(d time.Duration) string {
+	d = max(d, 0).Truncate(100 * time.Millisecond)
+	s := fmt.Sprintf("%d:%02d:%02d", int(d/time.Hour), int(d/time.Minute)%60, int(d/time.Second)%60)
+	if tenths := int(d/(100*time.Millisecond)) % 10; tenths > 0 {
+		s += fmt.Sprintf(".%d", tenths)
+	}
+	return s
+}
@@ -0,0 +1,193 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	ts := []struct {
+		name     string
+		s        string
+		expected TimeControl
+		str      string
+	}{
+		{name: "none", s: "-", str: "-"},
+		{name: "unknown", s: "?", str: "-"},
+		{name: "sudden death", s: "300", expected: TimeControl{Periods: []TimePeriod{{Time: 5 * time.Minute}}}, str: "300"},
+		{name: "increment", s: "180+2", expected: TimeControl{Periods: []TimePeriod{{Time: 3 * time.Minute, Increment: 2 * time.Second}}}, str: "180+2"},
+		{name: "simple delay", s: "300d5", expected: TimeControl{Periods: []TimePeriod{{Time: 5 * time.Minute, Delay: 5 * time.Second}}}, str: "300d5"},
+		{name: "bronstein delay", s: "300b5", expected: TimeControl{Periods: []TimePeriod{{Time: 5 * time.Minute, Delay: 5 * time.Second, DelayKind: DelayBronstein}}}, str: "300b5"},
+		{name: "repeating period", s: "40/9000", expected: TimeControl{Periods: []TimePeriod{{Moves: 40, Time: 150 * time.Minute}}}, str: "40/9000"},
+		{
+			name: "multiple periods",
+			s:    "40/7200:1800+30",
+			expected: TimeControl{Periods: []TimePeriod{
+				{Moves: 40, Time: 2 * time.Hour},
+				{Time: 30 * time.Minute, Increment: 30 * time.Second},
+			}},
+			str: "40/7200:1800+30",
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := ParseTimeControl(tc.s)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.str, actual.String())
+		})
+	}
+
+	for _, s := range []string{"*180", "abc", "40/", "0/300", "300+x", "1800:40/7200", "-5"} {
+		t.Run("invalid "+s, func(t *testing.T) {
+			_, err := ParseTimeControl(s)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestDoTimedAction(t *testing.T) {
+	mustTimeControl := func(t *testing.T, s string) TimeControl {
+		tc, err := ParseTimeControl(s)
+		require.NoError(t, err)
+		return tc
+	}
+	// play shuffles the kingside knights back and forth, each move taking the given
+	// time, and returns the remaining time of the player who made the last move
+	play := func(t *testing.T, g Game, elapsed ...time.Duration) (Game, time.Duration) {
+		var player Color
+		for i, e := range elapsed {
+			player = g.Turn()
+			from, to := "g1", "f3"
+			if player == ColorBlack {
+				from, to = "g8", "f6"
+			}
+			if g.PieceAt(XY{int(from[0] - 'a'), int('8' - from[1])}).PieceType == PieceNone {
+				from, to = to, from
+			}
+			g = doTimedMove(t, g, from, to, e)
+			require.False(t, g.IsTimeout, "move %d", i)
+		}
+		return g, g.Clock.Remaining[player]
+	}
+
+	t.Run("increment", func(t *testing.T) {
+		g := NewDefaultGame().WithClock(NewClock(mustTimeControl(t, "180+2")))
+		g, remaining := play(t, g, 10*time.Second)
+		assert.Equal(t, 172*time.Second, remaining)
+		assert.Equal(t, 180*time.Second, g.Clock.Remaining[ColorBlack])
+		assert.Equal(t, [2]int{0, 1}, g.Clock.Moves)
+	})
+
+	t.Run("simple delay", func(t *testing.T) {
+		g := NewDefaultGame().WithClock(NewClock(mustTimeControl(t, "300d5")))
+		_, remaining := play(t, g, 3*time.Second)
+		assert.Equal(t, 300*time.Second, remaining)
+		_, remaining = play(t, g, 8*time.Second)
+		assert.Equal(t, 297*time.Second, remaining)
+	})
+
+	t.Run("bronstein delay", func(t *testing.T) {
+		g := NewDefaultGame().WithClock(NewClock(mustTimeControl(t, "300b5")))
+		_, remaining := play(t, g, 3*time.Second)
+		assert.Equal(t, 300*time.Second, remaining)
+		_, remaining = play(t, g, 8*time.Second)
+		assert.Equal(t, 297*time.Second, remaining)
+	})
+
+	t.Run("the next period's time is added after the period's moves", func(t *testing.T) {
+		g := NewDefaultGame().WithClock(NewClock(mustTimeControl(t, "2/60:30+1")))
+		g, remaining := play(t, g, 10*time.Second, 0, 10*time.Second)
+		assert.Equal(t, 70*time.Second, remaining, "60s - 20s + 30s")
+		assert.Equal(t, time.Second, g.Clock.Period(ColorWhite).Increment)
+		assert.Equal(t, time.Duration(0), g.Clock.Period(ColorBlack).Increment)
+
+		_, remaining = play(t, g, 0, 5*time.Second)
+		assert.Equal(t, 66*time.Second, remaining, "70s - 5s + 1s")
+	})
+
+	t.Run("a repeating period", func(t *testing.T) {
+		g := NewDefaultGame().WithClock(NewClock(mustTimeControl(t, "2/60")))
+		_, remaining := play(t, g, 10*time.Second, 0, 10*time.Second, 0, 10*time.Second, 0, 10*time.Second)
+		assert.Equal(t, 140*time.Second, remaining)
+	})
+
+	t.Run("the flag falls when the move takes all the remaining time", func(t *testing.T) {
+		g := NewDefaultGame().WithClock(NewClock(mustTimeControl(t, "60")))
+		newGame := doTimedMove(t, g, "g1", "f3", time.Minute)
+		assert.True(t, newGame.IsTimeout)
+		assert.True(t, newGame.IsGameOver)
+		assert.False(t, newGame.IsDraw)
+		assert.Equal(t, color(ColorBlack), newGame.GameOverWinner)
+		assert.Equal(t, time.Duration(0), newGame.Clock.Remaining[ColorWhite])
+		assert.Equal(t, g.ToFEN(), newGame.ToFEN(), "the move isn't made")
+		assert.Empty(t, newGame.Actions)
+	})
+
+	t.Run("the flag falls but the opponent can't checkmate", func(t *testing.T) {
+		g := mustGameFromFEN(t, "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1").WithClock(NewClock(mustTimeControl(t, "60")))
+		newGame := g.FlagFall()
+		assert.True(t, newGame.IsTimeout)
+		assert.True(t, newGame.IsDraw)
+		assert.Equal(t, color(-1), newGame.GameOverWinner)
+
+		// Neither can a lone knight against a lone king
+		g = mustGameFromFEN(t, "4k3/8/8/8/8/8/8/3NK3 b - - 0 1")
+		assert.True(t, g.FlagFall().IsDraw)
+
+		// But a knight can, with the help of the opponent's pieces
+		g = mustGameFromFEN(t, "4k3/4p3/8/8/8/8/8/3NK3 b - - 0 1")
+		assert.False(t, g.FlagFall().IsDraw)
+	})
+
+	t.Run("untimed games and actions that aren't moves don't run the clock", func(t *testing.T) {
+		g := NewDefaultGame()
+		assert.Equal(t, g.DoAction(g.Actions[0]), g.DoTimedAction(g.Actions[0], time.Hour))
+
+		g = g.WithClock(NewClock(mustTimeControl(t, "60")))
+		offer, ok := drawActionOf(g, isDrawOffer)
+		require.True(t, ok)
+		newGame := g.DoTimedAction(offer, time.Hour)
+		assert.False(t, newGame.IsTimeout)
+		assert.Equal(t, g.Clock, newGame.Clock)
+	})
+}
+
+func doTimedMove(t *testing.T, g Game, from, to string, elapsed time.Duration) Game {
+	t.Helper()
+	fromXY := XY{int(from[0] - 'a'), int('8' - from[1])}
+	toXY := XY{int(to[0] - 'a'), int('8' - to[1])}
+	for _, a := range g.Actions {
+		if a.IsMove() && a.FromPiece.XY == fromXY && a.ToXY == toXY {
+			return g.DoTimedAction(a, elapsed)
+		}
+	}
+	require.Fail(t, "move not found", "%s%s", from, to)
+	return g
+}
+
+func TestClockTime(t *testing.T) {
+	ts := []struct {
+		s        string
+		expected time.Duration
+	}{
+		{s: "0:03:00", expected: 3 * time.Minute},
+		{s: "1:59:58", expected: time.Hour + 59*time.Minute + 58*time.Second},
+		{s: "0:00:09.8", expected: 9800 * time.Millisecond},
+	}
+	for _, tc := range ts {
+		t.Run(tc.s, func(t *testing.T) {
+			actual, err := ParseClockTime(tc.s)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+			assert.Equal(t, tc.s, FormatClockTime(actual))
+		})
+	}
+	for _, s := range []string{"3:00", "0:60:00", "a:00:00", "0:00:-1"} {
+		_, err := ParseClockTime(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"errors"
+	"math/bits"
+	"regexp"
+	"strings"
+)
+
+// crazyhouse is Crazyhouse: captured pieces go to the capturer's pocket, as its
+// own, and may be dropped onto an empty square instead of moving (see
+// Action.IsDrop). Captured promoted pawns go to the pocket as pawns. The pockets
+// are recorded in FEN strings after the piece placement, as "[QNp]" (White's in
+// upper case, Black's in lower case), and promoted pieces with a "~" after them.
+// https://lichess.org/variant/crazyhouse
+type crazyhouse struct{}
+
+func (crazyhouse) Name() string { return "crazyhouse" }
+func (crazyhouse) StartFEN() string {
+	return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR[] w KQkq - 0 1"
+}
+
+// Validation has no limit of pieces, since drops may make more than 16 of a color.
+func (crazyhouse) Validation() Validation { return Validation{} }
+
+func (crazyhouse) allowsMove(g Game, a Action) bool { return true }
+func (crazyhouse) updateState(g *Game, a Action)    { g.updatePockets(a, true) }
+func (crazyhouse) outcome(g Game) (bool, color)     { return false, -1 }
+func (crazyhouse) hasPockets() bool                 { return true }
+func (crazyhouse) exposesKing(g Game, a Action) bool {
+	return g.kingAttackers(a.FromPiece.Owner) != 0
+}
+func (crazyhouse) checkers(g Game, c color) uint64     { return g.kingAttackers(c) }
+func (crazyhouse) promotions() []PieceType             { return promotionPieceTypes[:] }
+func (crazyhouse) filterMoves(moves []Action) []Action { return moves }
+
+// isInsufficientMaterial is never true, since captured pieces come back as drops.
+func (crazyhouse) isInsufficientMaterial(g Game) bool { return false }
+
+// bughouse is one of the boards of a Bughouse game (see Bughouse): as Crazyhouse,
+// but the captured pieces go to the pocket of the capturer's teammate, on the
+// other board, so captures don't fill the board's own pockets.
+// https://en.wikipedia.org/wiki/Bughouse_chess
+type bughouse struct{}
+
+func (bughouse) Name() string                       { return "bughouse" }
+func (bughouse) StartFEN() string                   { return VariantCrazyhouse.StartFEN() }
+func (bughouse) Validation() Validation             { return Validation{} }
+func (bughouse) allowsMove(g Game, a Action) bool   { return true }
+func (bughouse) updateState(g *Game, a Action)      { g.updatePockets(a, false) }
+func (bughouse) outcome(g Game) (bool, color)       { return false, -1 }
+func (bughouse) hasPockets() bool                   { return true }
+func (bughouse) isInsufficientMaterial(g Game) bool { return false }
+
+func (bughouse) exposesKing(g Game, a Action) bool   { return g.kingAttackers(a.FromPiece.Owner) != 0 }
+func (bughouse) checkers(g Game, c color) uint64     { return g.kingAttackers(c) }
+func (bughouse) promotions() []PieceType             { return promotionPieceTypes[:] }
+func (bughouse) filterMoves(moves []Action) []Action { return moves }
+
+// PocketCount returns the number of pieces of the given type that the given
+// color may drop, in variants with drops (see Action.IsDrop).
+func (g Game) PocketCount(c Color, t PieceType) int {
+	return g.pockets[c][t]
+}
+
+// IsPromoted returns whether the piece at the given square is a promoted pawn, as
+// tracked in variants with drops, where it goes to the pocket as a pawn when
+// captured.
+func (g Game) IsPromoted(xy XY) bool {
+	return g.promoted&sqBit(sqOf(xy)) != 0
+}
+
+// pocketPieceType returns the type that the given piece has in a pocket once
+// captured: a pawn if it's a promoted pawn, its own otherwise.
+func (g Game) pocketPieceType(p Piece) PieceType {
+	if g.promoted&sqBit(sqOf(p.XY)) != 0 {
+		return PiecePawn
+	}
+	return p.PieceType
+}
+
+// updatePockets updates the pockets and the promoted pieces after the given move,
+// whose pieces are moved: a drop takes the piece from the mover's pocket and, if
+// pocketsCaptures, a capture puts the captured piece in it.
+func (g *Game) updatePockets(a Action, pocketsCaptures bool) {
+	owner := a.FromPiece.Owner
+	if a.IsDrop {
+		g.pockets[owner][a.FromPiece.PieceType]--
+		return
+	}
+	if a.IsCapture {
+		if pocketsCaptures {
+			g.pockets[owner][g.pocketPieceType(a.CapturedPiece)]++
+		}
+		g.promoted &^= sqBit(sqOf(a.CapturedPiece.XY))
+	}
+	from := sqBit(sqOf(a.FromPiece.XY))
+	if a.IsPromotion || g.promoted&from != 0 {
+		g.promoted = g.promoted&^from | sqBit(sqOf(a.ToXY))
+	}
+}
+
+// dropPieceTypes are the types of the pieces that pockets hold, in the order FEN
+// strings list them.
+var dropPieceTypes = [5]PieceType{PieceQueen, PieceRook, PieceBishop, PieceKnight, PiecePawn}
+
+// backRanks are the squares of the 1st and 8th ranks, where pawns can't be dropped.
+const backRanks = uint64(0xFF) | uint64(0xFF)<<56
+
+// appendDropActions appends the legal drops of the side to move: any piece in its
+// pocket onto any empty square, but pawns onto the 1st and 8th ranks. In check,
+// only the drops that block it are legal.
+func (g Game) appendDropActions(actions []Action) []Action {
+	turn := g.Turn()
+	targets := ^g.occAll()
+	if g.kingAttackers(turn) != 0 {
+		targets = g.checkBlockingSqs(targets)
+	}
+	for _, t := range dropPieceTypes {
+		if g.pockets[turn][t] == 0 {
+			continue
+		}
+		sqs := targets
+		if t == PiecePawn {
+			sqs &^= backRanks
+		}
+		for ; sqs != 0; sqs &= sqs - 1 {
+			xy := xyOfSq(bits.TrailingZeros64(sqs))
+			actions = append(actions, Action{FromPiece: Piece{PieceType: t, Owner: turn, XY: xy}, ToXY: xy, IsDrop: true})
+		}
+	}
+	return actions
+}
+
+// checkBlockingSqs returns which of the given empty squares block the check that
+// the side to move is in, if a piece is dropped on them. g is a copy, so the drops
+// are tried on it in place; which piece is dropped doesn't matter.
+func (g Game) checkBlockingSqs(sqs uint64) uint64 {
+	turn := g.Turn()
+	var blocking uint64
+	for ; sqs != 0; sqs &= sqs - 1 {
+		sq := bits.TrailingZeros64(sqs)
+		g.setSq(turn, PieceKnight, sq)
+		if g.attackersOf(int(g.kingSq[turn]), turn) == 0 {
+			blocking |= sqBit(sq)
+		}
+		g.clearSq(turn, PieceKnight, sq)
+	}
+	return blocking
+}
+
+var errFENInvalidPockets = errors.New("FEN string's pockets must be like [QNp], after the piece placement, with pieces other than kings")
+
+var rxFENPockets = regexp.MustCompile(`^[QRBNPqrbnp]*$`)
+
+// cutFENPockets cuts the pockets from the piece placement of the given FEN
+// string, either as "[QNp]" after it or as a 9th rank (".../RNBQKBNR/QNp"), and
+// the "~" marks of the promoted pieces. It returns the rest, the pockets by color
+// and the squares of the promoted pieces. The pockets' absence means empty ones.
+func cutFENPockets(s string) (string, [2][7]int, uint64, error) {
+	var pockets [2][7]int
+	placement, rest, _ := strings.Cut(s, " ")
+	pocket := ""
+	if i := strings.IndexByte(placement, '['); i >= 0 && strings.HasSuffix(placement, "]") {
+		placement, pocket = placement[:i], placement[i+1:len(placement)-1]
+	} else if ranks := strings.Split(placement, "/"); len(ranks) == 9 {
+		placement, pocket = strings.Join(ranks[:8], "/"), ranks[8]
+	}
+	if !rxFENPockets.MatchString(pocket) {
+		return "", pockets, 0, errFENInvalidPockets
+	}
+	pieceTypeMap := map[byte]PieceType{'Q': PieceQueen, 'B': PieceBishop, 'N': PieceKnight, 'R': PieceRook, 'P': PiecePawn}
+	for i := 0; i < len(pocket); i++ {
+		if b := pocket[i]; b >= 'a' {
+			pockets[ColorBlack][pieceTypeMap[b-'a'+'A']]++
+		} else {
+			pockets[ColorWhite][pieceTypeMap[b]]++
+		}
+	}
+
+	// A "~" marks the piece before it as promoted. Any other "~" is left for the
+	// placement to be invalid.
+	var (
+		sb       strings.Builder
+		promoted uint64
+		x, y     int
+	)
+	for i := 0; i < len(placement); i++ {
+		b := placement[i]
+		switch {
+		case b == '/':
+			x, y = 0, y+1
+		case b >= '1' && b <= '8':
+			x += int(b - '0')
+		case b == '~' && i > 0 && strings.IndexByte("QBNRqbnr", placement[i-1]) >= 0:
+			if x <= 8 && y < 8 {
+				promoted |= sqBit(sqOf(XY{x - 1, y}))
+			}
+			continue
+		default:
+			x++
+		}
+		sb.WriteByte(b)
+	}
+	return sb.String() + " " + rest, pockets, promoted, nil
+}
+
+// pocketsFEN renders the pockets of a game of a variant with drops as after the
+// piece placement of its FEN string, e.g. "[QNp]".
+func (g Game) pocketsFEN() string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for _, c := range [2]color{ColorWhite, ColorBlack} {
+		for _, t := range dropPieceTypes {
+			letter := t.ToAlgebraic()
+			if t == PiecePawn {
+				letter = "P"
+			}
+			if c == ColorBlack {
+				letter = strings.ToLower(letter)
+			}
+			sb.WriteString(strings.Repeat(letter, g.pockets[c][t]))
+		}
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findDrop returns the game's drop of the given piece type onto the given square.
+func findDrop(t *testing.T, g Game, pt PieceType, to XY) Action {
+	t.Helper()
+	for _, a := range g.Actions {
+		if a.IsDrop && a.FromPiece.PieceType == pt && a.ToXY == to {
+			return a
+		}
+	}
+	t.Fatalf("no drop of a %v on %v in %v", pt, to.ToAlgebraic(), g.ToFEN())
+	return Action{}
+}
+
+func TestCrazyhouse(t *testing.T) {
+	t.Run("perft", func(t *testing.T) {
+		// https://github.com/niklasf/python-chess/blob/master/examples/perft/crazyhouse.perft
+		g := mustVariantGameFromFEN(t, "2k5/8/8/8/8/8/8/4K3[QRBNPqrbnp] w - - 0 1", VariantCrazyhouse)
+		assert.Equal(t, 301, Perft(g, 1))
+		assert.Equal(t, 75353, Perft(g, 2))
+		if testing.Short() {
+			return
+		}
+		assert.Equal(t, 4888832, Perft(NewVariantGame(VariantCrazyhouse), 5))
+	})
+
+	t.Run("pockets and promoted pieces are read from and written to FEN strings", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "4k3/1Q~6/8/8/4b3/8/Kpp5/8[QNp] b - - 0 1", VariantCrazyhouse)
+		assert.Equal(t, 1, g.PocketCount(ColorWhite, PieceQueen))
+		assert.Equal(t, 1, g.PocketCount(ColorWhite, PieceKnight))
+		assert.Equal(t, 1, g.PocketCount(ColorBlack, PiecePawn))
+		assert.True(t, g.IsPromoted(XY{1, 1}))
+		assert.Equal(t, "4k3/1Q~6/8/8/4b3/8/Kpp5/8[QNp] b - - 0 1", g.ToFEN())
+
+		g = mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/4K3/Nq w - - 0 1", VariantCrazyhouse)
+		assert.Equal(t, "4k3/8/8/8/8/8/8/4K3[Nq] w - - 0 1", g.ToFEN())
+		g = mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/4K3 w - - 0 1", VariantCrazyhouse)
+		assert.Equal(t, "4k3/8/8/8/8/8/8/4K3[] w - - 0 1", g.ToFEN())
+	})
+
+	t.Run("invalid pockets", func(t *testing.T) {
+		for _, fen := range []string{
+			"4k3/8/8/8/8/8/8/4K3[Kq] w - - 0 1",
+			"4k3/8/8/8/8/8/8/4K3[2] w - - 0 1",
+		} {
+			_, err := NewVariantGameFromFEN(fen, VariantCrazyhouse)
+			var fenErr *FENError
+			require.ErrorAs(t, err, &fenErr, fen)
+			assert.Equal(t, FENFieldPockets, fenErr.Field)
+			assert.ErrorIs(t, err, errFENInvalidPockets)
+		}
+		_, err := NewGameFromFEN("4k3/8/8/8/8/8/8/4K3[Nq] w - - 0 1")
+		assert.ErrorIs(t, err, errFENRegexDoesNotMatch, "only FEN strings of variants with drops have pockets")
+	})
+
+	t.Run("captured pieces go to the capturer's pocket, promoted ones as pawns", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "4k3/1Q~6/8/8/4b3/8/Kpp5/8[] b - - 0 1", VariantCrazyhouse)
+		g = doMoves(t, g, "e4", "b7")
+		assert.Equal(t, 1, g.PocketCount(ColorBlack, PiecePawn))
+		assert.Equal(t, 0, g.PocketCount(ColorBlack, PieceQueen))
+		assert.False(t, g.IsPromoted(XY{1, 1}), "the capturer isn't promoted")
+
+		g = doMoves(t, g, "a2", "b2")
+		assert.Equal(t, 1, g.PocketCount(ColorWhite, PiecePawn))
+		assert.Equal(t, "4k3/1b6/8/8/8/8/1Kp5/8[Pp] b - - 0 2", g.ToFEN())
+	})
+
+	t.Run("promotions are tracked as the promoted pieces move", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "7K/8/8/8/8/8/2p5/4k3[] b - - 0 1", VariantCrazyhouse)
+		for _, a := range g.Actions {
+			if a.IsPromotion && a.PromotionPieceType == PieceQueen {
+				g = g.DoAction(a)
+				break
+			}
+		}
+		assert.Equal(t, "7K/8/8/8/8/8/8/2q~1k3[] w - - 0 2", g.ToFEN())
+		g = doMoves(t, g, "h8", "g7", "c1", "c5")
+		assert.Equal(t, "8/6K1/8/2q~5/8/8/8/4k3[] w - - 2 3", g.ToFEN())
+	})
+
+	t.Run("drops", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/4K3[Pn] w - - 0 1", VariantCrazyhouse)
+		for _, a := range g.Actions {
+			if a.IsDrop {
+				assert.Equal(t, PieceType(PiecePawn), a.FromPiece.PieceType, "only White's pocket")
+				assert.NotContains(t, []int{0, 7}, a.ToXY.Y, "no pawn drops on the 1st and 8th ranks")
+			}
+		}
+
+		g = g.DoAction(findDrop(t, g, PiecePawn, XY{3, 4}))
+		assert.Equal(t, "4k3/8/8/8/3P4/8/8/4K3[n] b - - 0 1", g.ToFEN())
+		g = g.DoAction(findDrop(t, g, PieceKnight, XY{3, 5}))
+		assert.True(t, g.IsCheck)
+		assert.Equal(t, "4k3/8/8/8/3P4/3n4/8/4K3[] w - - 1 2", g.ToFEN())
+	})
+
+	t.Run("in check, only blocking drops are legal", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/r3K3[N] w - - 0 1", VariantCrazyhouse)
+		var drops []string
+		for _, a := range g.Actions {
+			if a.IsDrop {
+				drops = append(drops, a.DropString())
+			}
+		}
+		assert.ElementsMatch(t, []string{"N@b1", "N@c1", "N@d1"}, drops)
+
+		g = mustVariantGameFromFEN(t, "4k3/8/8/8/8/3n4/8/r3K3[N] w - - 0 1", VariantCrazyhouse)
+		for _, a := range g.Actions {
+			assert.False(t, a.IsDrop, "double checks can't be blocked")
+		}
+	})
+
+	t.Run("bare kings aren't insufficient material", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/4K3[] w - - 0 1", VariantCrazyhouse)
+		assert.False(t, g.IsDraw)
+	})
+
+	t.Run("positions agree with games", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "4k3/1Q~6/8/8/4b3/8/Kpp5/8[] b - - 0 1", VariantCrazyhouse)
+		p := NewPosition(g)
+		for _, a := range p.LegalActions() {
+			if a.ToXY == (XY{1, 1}) {
+				p.MakeMove(a)
+				break
+			}
+		}
+		expected := doMoves(t, g, "e4", "b7")
+		assert.Equal(t, expected.ToFEN(), p.Game().ToFEN())
+		assert.Equal(t, expected.Hash(), p.Hash())
+		assert.Len(t, p.LegalActions(), len(expected.Actions)-2) // Minus resigning and offering a draw
+
+		p.UnmakeMove()
+		assert.Equal(t, g.Hash(), p.Hash())
+		assert.Equal(t, g.ToFEN(), p.Game().ToFEN())
+		assert.NotEqual(t, g.Hash(), mustVariantGameFromFEN(t, "4k3/1Q~6/8/8/4b3/8/Kpp5/8[p] b - - 0 1", VariantCrazyhouse).Hash())
+	})
+}
@@ -200,27 +200,26 @@ func TestThreefoldRepetition(t *testing.T) {
 }
 
 func TestDrawAction(t *testing.T) {
-	t.Run("draw action is always available", func(t *testing.T) {
+	t.Run("draw action is never available", func(t *testing.T) {
 		g := NewDefaultGame()
-		var drawAction Action
-		for _, a := range g.Actions {
-			if a.IsDraw {
-				drawAction = a
+		for i := 0; i < 2; i++ {
+			for _, a := range g.Actions {
+				assert.False(t, a.IsDraw, "players must offer and accept a draw, or claim one")
 			}
+			g = g.DoAction(g.Actions[0])
 		}
-		require.True(t, drawAction.IsDraw, "draw action should be among the available actions")
+	})
+
+	t.Run("the draw agreement is the side to move's", func(t *testing.T) {
+		g := NewDefaultGame()
+		drawAction := g.DrawAgreement()
+		assert.True(t, drawAction.IsDraw)
 		assert.Equal(t, color(ColorWhite), drawAction.FromPiece.Owner)
 	})
 
 	t.Run("doing the draw action ends the game in a draw", func(t *testing.T) {
 		g := NewDefaultGame()
-		var drawAction Action
-		for _, a := range g.Actions {
-			if a.IsDraw {
-				drawAction = a
-			}
-		}
-		newGame := g.DoAction(drawAction)
+		newGame := g.DoAction(g.DrawAgreement())
 		assert.True(t, newGame.IsDraw)
 		assert.True(t, newGame.IsGameOver)
 		assert.Equal(t, color(-1), newGame.GameOverWinner)
@@ -230,13 +229,7 @@ func TestDrawAction(t *testing.T) {
 
 	t.Run("no actions after a draw", func(t *testing.T) {
 		g := NewDefaultGame()
-		var drawAction Action
-		for _, a := range g.Actions {
-			if a.IsDraw {
-				drawAction = a
-			}
-		}
-		newGame := g.DoAction(drawAction)
+		newGame := g.DoAction(g.DrawAgreement())
 		assert.Empty(t, newGame.calculateAllActions())
 	})
 
@@ -257,3 +250,145 @@ func TestStalemateIsNotAffectedByDrawRules(t *testing.T) {
 	assert.True(t, g.IsGameOver)
 	assert.False(t, g.IsCheckmate)
 }
+
+// drawActionOf returns the game's action that isn't a move and matches the given
+// predicate, if any.
+func drawActionOf(g Game, isKind func(Action) bool) (Action, bool) {
+	for _, a := range g.Actions {
+		if !a.IsMove() && isKind(a) {
+			return a, true
+		}
+	}
+	return Action{}, false
+}
+
+func isDrawOffer(a Action) bool   { return a.IsDrawOffer }
+func isDrawAccept(a Action) bool  { return a.IsDrawAccept }
+func isDrawDecline(a Action) bool { return a.IsDrawDecline }
+func isDrawClaim(a Action) bool   { return a.IsDrawClaim }
+
+func TestDrawOffer(t *testing.T) {
+	offer := func(t *testing.T, g Game) Game {
+		t.Helper()
+		a, ok := drawActionOf(g, isDrawOffer)
+		require.True(t, ok, "a draw offer should be among the available actions")
+		return g.DoAction(a)
+	}
+
+	t.Run("offering keeps the turn and the board", func(t *testing.T) {
+		g := NewDefaultGame()
+		newGame := offer(t, g)
+		assert.True(t, newGame.IsDrawOffered)
+		assert.Equal(t, color(ColorWhite), newGame.DrawOfferedBy)
+		assert.Equal(t, g.Turn(), newGame.Turn())
+		assert.Equal(t, g.ToFEN(), newGame.ToFEN())
+		assert.Equal(t, g.PositionHistory(), newGame.PositionHistory())
+		assert.False(t, newGame.IsGameOver)
+
+		_, ok := drawActionOf(newGame, isDrawOffer)
+		assert.False(t, ok, "the offerer can't offer again")
+		_, ok = drawActionOf(newGame, isDrawAccept)
+		assert.False(t, ok, "the offerer can't accept their own offer")
+	})
+
+	t.Run("the opponent may accept after the offerer moves", func(t *testing.T) {
+		g := doMoves(t, offer(t, NewDefaultGame()), "e2", "e4")
+		assert.True(t, g.IsDrawOffered, "the offer stands after the offerer moves")
+		_, ok := drawActionOf(g, isDrawOffer)
+		assert.False(t, ok, "the opponent answers the pending offer rather than making another")
+
+		accept, ok := drawActionOf(g, isDrawAccept)
+		require.True(t, ok)
+		assert.Equal(t, color(ColorBlack), accept.FromPiece.Owner)
+		newGame := g.DoAction(accept)
+		assert.True(t, newGame.IsDraw)
+		assert.True(t, newGame.IsGameOver)
+		assert.Equal(t, color(-1), newGame.GameOverWinner)
+	})
+
+	t.Run("declining clears the offer and keeps the turn", func(t *testing.T) {
+		g := doMoves(t, offer(t, NewDefaultGame()), "e2", "e4")
+		decline, ok := drawActionOf(g, isDrawDecline)
+		require.True(t, ok)
+		newGame := g.DoAction(decline)
+		assert.False(t, newGame.IsDrawOffered)
+		assert.False(t, newGame.IsGameOver)
+		assert.Equal(t, color(ColorBlack), newGame.Turn())
+		_, ok = drawActionOf(newGame, isDrawOffer)
+		assert.True(t, ok, "a draw may be offered again")
+	})
+
+	t.Run("moving declines the offer", func(t *testing.T) {
+		g := doMoves(t, offer(t, NewDefaultGame()), "e2", "e4", "e7", "e5")
+		assert.False(t, g.IsDrawOffered)
+		_, ok := drawActionOf(g, isDrawAccept)
+		assert.False(t, ok)
+	})
+
+	t.Run("a pending offer can be restored", func(t *testing.T) {
+		g := doMoves(t, NewDefaultGame(), "e2", "e4").WithDrawOfferedBy(ColorWhite)
+		assert.True(t, g.IsDrawOffered)
+		_, ok := drawActionOf(g, isDrawAccept)
+		assert.True(t, ok)
+	})
+
+	t.Run("make and unmake restore a pending offer", func(t *testing.T) {
+		g := doMoves(t, NewDefaultGame(), "e2", "e4").WithDrawOfferedBy(ColorWhite)
+		p := NewPosition(g)
+		for _, a := range p.LegalActions() {
+			p.MakeMove(a)
+			require.Equal(t, g.DoAction(a), p.Game())
+			p.UnmakeMove()
+		}
+		assert.True(t, p.Snapshot().IsDrawOffered)
+	})
+}
+
+func TestDrawClaim(t *testing.T) {
+	t.Run("no claim without the 50-move rule or threefold repetition", func(t *testing.T) {
+		_, ok := drawActionOf(NewDefaultGame(), isDrawClaim)
+		assert.False(t, ok)
+	})
+
+	t.Run("claiming under the 50-move rule ends the game", func(t *testing.T) {
+		g := mustGameFromFEN(t, "8/8/4k3/8/8/4K3/8/6R1 w - - 100 80")
+		claim, ok := drawActionOf(g, isDrawClaim)
+		require.True(t, ok)
+		newGame := g.DoAction(claim)
+		assert.True(t, newGame.IsDraw)
+		assert.True(t, newGame.IsGameOver)
+		assert.Equal(t, color(-1), newGame.GameOverWinner)
+	})
+
+	t.Run("claiming threefold repetition with the intended move", func(t *testing.T) {
+		// The last knight move reaches the starting position for the third time
+		g := doMoves(t, NewDefaultGame(),
+			"g1", "f3", "g8", "f6", "f3", "g1", "f6", "g8",
+			"g1", "f3", "g8", "f6", "f3", "g1",
+		)
+		require.False(t, g.CanClaimDraw)
+		var intended Action
+		for _, a := range g.Actions {
+			if a.FromPiece.XY == (XY{5, 2}) && a.ToXY == (XY{6, 0}) {
+				intended = a
+			}
+		}
+		require.True(t, intended.IsMove())
+		afterMove := g.DoAction(intended)
+		intended.IsDrawClaim = true
+		newGame := g.DoAction(intended)
+		assert.True(t, newGame.IsDraw)
+		assert.True(t, newGame.IsGameOver)
+		assert.Equal(t, afterMove.ToFEN(), newGame.ToFEN(), "the move is made")
+	})
+
+	t.Run("an incorrect claim with a move only makes the move", func(t *testing.T) {
+		g := NewDefaultGame()
+		intended := g.Actions[0]
+		intended.IsDrawClaim = true
+		newGame := g.DoAction(intended)
+		assert.False(t, newGame.IsDraw)
+		assert.False(t, newGame.IsGameOver)
+		assert.Equal(t, g.DoAction(g.Actions[0]), newGame)
+	})
+}
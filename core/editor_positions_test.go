@@ -119,7 +119,7 @@ func TestFENRejectsTrulyUnplayablePositions(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			_, err := NewGameFromFEN(tc.fen)
-			assert.Equal(t, tc.err, err)
+			assert.ErrorIs(t, err, tc.err)
 		})
 	}
 }
@@ -215,7 +215,7 @@ func TestBoardAutoCorrection(t *testing.T) {
 			Turn:           "White",
 		}
 		_, err := NewGameFromBoard(b)
-		assert.Equal(t, errBoardSideNotToMoveInCheck, err)
+		assert.ErrorIs(t, err, errBoardSideNotToMoveInCheck)
 	})
 }
 
@@ -22,6 +22,7 @@ type Game struct {
 	occ                     [2]uint64    // occupancy per color
 	squares                 [64]uint8    // pieceType<<1|color per square, 0 if empty
 	kingSq                  [2]int8
+	placementHash           uint64 // Zobrist hash of the pieces on the board (see Hash)
 	IsCheck                 bool
 	IsDoubleCheck           bool
 	IsDiscoverCheck         bool
@@ -29,10 +30,40 @@ type Game struct {
 	IsStalemate             bool
 	IsDraw                  bool
 	CanClaimDraw            bool
-	IsGameOver              bool
-	GameOverWinner          color
-	InCheckBy               []Piece
-	Actions                 []Action
+	// IsDrawOffered is set when DrawOfferedBy offered a draw (see
+	// Action.IsDrawOffer) that the opponent hasn't accepted or declined yet.
+	IsDrawOffered  bool
+	DrawOfferedBy  color
+	IsGameOver     bool
+	GameOverWinner color
+	InCheckBy      []Piece
+	Actions        []Action
+	// IsChess960 is set for Chess960 (Fischer Random) games, where kings and rooks
+	// may start on any file of the home rank (see CastlingRookXY).
+	IsChess960 bool
+	// Clock holds the clocks of timed games (see Clock.IsTimed), which
+	// DoTimedAction runs. IsTimeout is set when a player ran out of time (see
+	// FlagFall).
+	Clock     Clock
+	IsTimeout bool
+	// IsVariantEnd is set when the rules of the game's variant (see Variant) ended
+	// it, e.g. by a king reaching the hill in King of the Hill, or its validation
+	// policy did (see Validation.KingsOptional).
+	IsVariantEnd bool
+	// variant is the game's variant, or nil for standard chess.
+	variant Variant
+	// checksGiven is the number of checks each color gave, in Three-check games.
+	checksGiven [2]int
+	// pockets holds the number of pieces of each type that each color may drop,
+	// and promoted the squares of pieces that are promoted pawns, in variants
+	// with drops (see Action.IsDrop).
+	pockets  [2][7]int
+	promoted uint64
+	// validation is the validation policy that the game was created with.
+	validation Validation
+	// castlingRookX is the file of each color's castling rook per castleType. Only
+	// read when IsChess960; standard games always castle with the a/h-file rooks.
+	castlingRookX [2][2]int8
 	// positionHistory holds the Zobrist hashes of positions reached since the last
 	// irreversible move (pawn move, capture or castling-right change), most recent
 	// last. Used for threefold/fivefold repetition detection.
@@ -54,7 +85,7 @@ func (g Game) PositionHistory() []uint64 {
 // last entry, so both "history up to and including this position" and "history of
 // prior positions" are accepted.
 func (g Game) WithPositionHistory(history []uint64) Game {
-	currentHash := g.positionHash()
+	currentHash := g.Hash()
 	g.positionHistory = make([]uint64, 0, len(history)+1)
 	g.positionHistory = append(g.positionHistory, history...)
 	if len(g.positionHistory) == 0 || g.positionHistory[len(g.positionHistory)-1] != currentHash {
@@ -63,6 +94,24 @@ func (g Game) WithPositionHistory(history []uint64) Game {
 	return g.calculateCriticalFlags()
 }
 
+// WithDrawOfferedBy returns a copy of the game where the given color has offered
+// a draw (see Action.IsDrawOffer), e.g. to restore the pending offer of a game
+// across stateless API calls.
+func (g Game) WithDrawOfferedBy(c color) Game {
+	return g.withDrawOffer(true, c)
+}
+
+// withDrawOffer returns a copy of the game with the given draw offer state, and
+// its actions recalculated accordingly.
+func (g Game) withDrawOffer(isOffered bool, by color) Game {
+	g.IsDrawOffered, g.DrawOfferedBy = isOffered, by
+	if !isOffered {
+		g.DrawOfferedBy = 0
+	}
+	g.Actions = g.calculateAllActions()
+	return g
+}
+
 // Color is the exported name for the color of a player or piece (e.g. core.ColorWhite).
 type Color = color
 
@@ -80,8 +129,16 @@ func (g Game) PieceAt(xy XY) Piece {
 	return g.pieceAtSq(sqOf(xy))
 }
 
-// King returns the king of the given color.
+// King returns the king of the given color, or the zero Piece if it has none, as
+// in Atomic and Antichess. Antichess may have more than one, of which it's any.
 func (g Game) King(c color) Piece {
+	kings := g.bb[c][PieceKing]
+	switch {
+	case kings == 0:
+		return Piece{}
+	case kings&sqBit(int(g.kingSq[c])) == 0:
+		return g.pieceAtSq(bits.TrailingZeros64(kings))
+	}
 	return g.pieceAtSq(int(g.kingSq[c]))
 }
 
@@ -117,11 +174,27 @@ const (
 )
 
 type Action struct {
-	FromPiece          Piece
-	ToXY               XY
-	IsCapture          bool
-	IsResign           bool
-	IsDraw             bool
+	FromPiece Piece
+	ToXY      XY
+	IsCapture bool
+	IsResign  bool
+	// IsDraw ends the game in a draw agreed by both players, as recorded e.g. by a
+	// game record's "1/2-1/2" result (see Game.DrawAgreement). It's never among a
+	// game's Actions: games played through the engine offer and accept draws
+	// instead (see IsDrawOffer).
+	IsDraw bool
+	// IsDrawOffer offers the opponent a draw. The turn doesn't change: the offerer
+	// still moves, and the offer stands until the opponent accepts it
+	// (IsDrawAccept), declines it (IsDrawDecline) or moves.
+	IsDrawOffer   bool
+	IsDrawAccept  bool
+	IsDrawDecline bool
+	// IsDrawClaim claims a draw by the 50-move rule or threefold repetition, and
+	// ends the game, if the game CanClaimDraw. A claim can also be made with the
+	// move that leads to such a position, by setting it on one of the game's moves
+	// (e.g. a.IsDrawClaim = true): the move is done, and the game is drawn if the
+	// claim holds after it, as per FIDE's Laws of Chess (9.2 and 9.3).
+	IsDrawClaim        bool
 	IsPromotion        bool
 	IsEnPassantCapture bool
 	IsCastle           bool
@@ -129,12 +202,52 @@ type Action struct {
 	IsQueensideCastle  bool
 	PromotionPieceType PieceType
 	CapturedPiece      Piece
+	// IsDrop drops a piece of FromPiece's type from its owner's pocket onto ToXY
+	// (e.g. "N@f3"), in variants with drops such as Crazyhouse. FromPiece.XY is
+	// ToXY.
+	IsDrop bool
+}
+
+// IsMove returns whether the action moves pieces on the board, i.e. it isn't a
+// resignation, a draw agreement, nor a draw offer, acceptance, decline or claim
+// without a move.
+func (a Action) IsMove() bool {
+	return a.FromPiece.PieceType != PieceNone
 }
 
 func (a Action) ICCF() string {
 	return fmt.Sprintf("%v%v%v", a.FromPiece.XY.ToICCF(), a.ToXY.ToICCF(), a.PromotionPieceType.ToICCF())
 }
 
+// UCI returns the given action of the game in the notation of the UCI protocol
+// (e.g. "e2e4", or "e7e8q" for a promotion). Castling is the king's move (e.g.
+// "e1g1"), except in Chess960, where it's the king moving to its rook's square
+// (e.g. "e1h1"). Drops are as in SAN (e.g. "N@f3").
+func (g Game) UCI(a Action) string {
+	if a.IsDrop {
+		return a.DropString()
+	}
+	to := a.ToXY
+	if a.IsCastle && g.IsChess960 {
+		to = g.CastlingRookXY(a.FromPiece.Owner, a.IsKingsideCastle)
+	}
+	s := a.FromPiece.XY.ToAlgebraic() + to.ToAlgebraic()
+	if a.IsPromotion {
+		s += strings.ToLower(a.PromotionPieceType.ToAlgebraic())
+	}
+	return s
+}
+
+// DropString returns a drop (see IsDrop) as in SAN and UCI: the piece's letter,
+// "P" for pawns, then "@" and the square (e.g. "N@f3" or "P@e4").
+func (a Action) DropString() string {
+	piece := a.FromPiece.PieceType.ToAlgebraic()
+	if a.FromPiece.PieceType == PiecePawn {
+		piece = "P"
+	}
+	return piece + "@" + a.ToXY.ToAlgebraic()
+}
+
 func (a Action) String() string {
 	switch {
 	case a.IsEnPassantCapture:
@@ -143,10 +256,20 @@ func (a Action) String() string {
 		return fmt.Sprintf("%s's %s at %v captures %s's %s at %v while promoting to %v", a.FromPiece.Owner, a.FromPiece.PieceType, a.FromPiece.XY.ToAlgebraic(), a.CapturedPiece.Owner, a.CapturedPiece.PieceType, a.CapturedPiece.XY.ToAlgebraic(), a.PromotionPieceType)
 	case a.IsCapture:
 		return fmt.Sprintf("%s's %s at %v captures %s's %s at %v", a.FromPiece.Owner, a.FromPiece.PieceType, a.FromPiece.XY.ToAlgebraic(), a.CapturedPiece.Owner, a.CapturedPiece.PieceType, a.CapturedPiece.XY.ToAlgebraic())
+	case a.IsDrop:
+		return fmt.Sprintf("%s drops a %s at %v", a.FromPiece.Owner, a.FromPiece.PieceType, a.ToXY.ToAlgebraic())
 	case a.IsResign:
 		return fmt.Sprintf("%s resigns", a.FromPiece.Owner)
 	case a.IsDraw:
 		return fmt.Sprintf("%s draws", a.FromPiece.Owner)
+	case a.IsDrawOffer:
+		return fmt.Sprintf("%s offers a draw", a.FromPiece.Owner)
+	case a.IsDrawAccept:
+		return fmt.Sprintf("%s accepts the draw offer", a.FromPiece.Owner)
+	case a.IsDrawDecline:
+		return fmt.Sprintf("%s declines the draw offer", a.FromPiece.Owner)
+	case a.IsDrawClaim && !a.IsMove():
+		return fmt.Sprintf("%s claims a draw", a.FromPiece.Owner)
 	case a.IsPromotion:
 		return fmt.Sprintf("%s's Pawn at %v promotes to %v", a.FromPiece.Owner, a.FromPiece.XY.ToAlgebraic(), a.PromotionPieceType)
 	case a.IsKingsideCastle:
@@ -422,6 +545,32 @@ type GameStep struct {
 	StepPreMoveGame Game
 }
 
+// MoveNode is a half move in a move tree (e.g. a PGN movetext with variations):
+// the GameStep itself, its annotations, and the alternative lines that could have
+// been played instead of it.
+//
+// Each variation is a line of MoveNodes starting from this node's StepPreMoveGame,
+// so variations may in turn contain variations.
+type MoveNode struct {
+	GameStep
+	// NAGs are the node's Numeric Annotation Glyphs (e.g. 1 for "!" or "$1").
+	NAGs []int
+	// PreComment is a comment that precedes the move, e.g. at the start of a
+	// variation. Comments following the move are in StepComment.
+	PreComment string
+	// Commands are the commands embedded in the comment following the move (e.g.
+	// PGN's [%clk 0:03:00] or [%eval 0.17]), in order. They're not in StepComment.
+	Commands   []CommentCommand
+	Variations [][]MoveNode
+}
+
+// CommentCommand is a command embedded in a comment, e.g. PGN's [%eval 0.17] is
+// {Name: "eval", Value: "0.17"}.
+type CommentCommand struct {
+	Name  string
+	Value string
+}
+
 func (s GameStep) Clone() GameStep {
 	return GameStep{
 		StepString:      s.StepString,
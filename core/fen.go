@@ -10,20 +10,148 @@ import (
 )
 
 var (
-	errFENRegexDoesNotMatch        = errors.New("FEN string does not match FEN regexp")
-	errFENRankLargerThan8Squares   = errors.New("FEN string has a rank larger than 8 squares")
-	errFENDuplicateKing            = errors.New("FEN string has more than one king of the same color")
-	errFENKingMissing              = errors.New("FEN string is lacking one of the kings")
-	errFENPawnInImpossibleRank     = errors.New("impossible rank for pawn")
-	errFENBlackHasMoreThan16Pieces = errors.New("black has more than 16 pieces")
-	errFENWhiteHasMoreThan16Pieces = errors.New("white has more than 16 pieces")
-	errFENSideNotToMoveInCheck     = errors.New("side not to move is in check")
+	errFENRegexDoesNotMatch      = errors.New("FEN string does not match FEN regexp")
+	errFENRankLargerThan8Squares = errors.New("FEN string has a rank larger than 8 squares")
+	errFENDuplicateKing          = errors.New("FEN string has more than one king of the same color")
+	errFENKingMissing            = errors.New("FEN string is lacking one of the kings")
+	errFENPawnInImpossibleRank   = errors.New("impossible rank for pawn")
+	errFENBlackHasTooManyPieces  = errors.New("black has more pieces than allowed (16, unless the variant allows more)")
+	errFENWhiteHasTooManyPieces  = errors.New("white has more pieces than allowed (16, unless the variant allows more)")
+	errFENSideNotToMoveInCheck   = errors.New("side not to move is in check")
 	// TODO check if King is in checkmate that couldn't have been reached
 	// TODO don't allow more than 8 pawns of any color
 )
 
+// The fields of a FEN string, as FENError names them.
+const (
+	FENFieldPiecePlacement = "piecePlacement"
+	FENFieldActiveColor    = "activeColor"
+	FENFieldCastling       = "castling"
+	FENFieldEnPassant      = "enPassant"
+	FENFieldHalfMoveClock  = "halfMoveClock"
+	FENFieldFullMoveNumber = "fullMoveNumber"
+	// FENFieldChecks is the checks given that Three-check FEN strings end with.
+	FENFieldChecks = "checks"
+	// FENFieldPockets is the pockets that FEN strings of variants with drops
+	// have after the piece placement.
+	FENFieldPockets = "pockets"
+)
+
+// FENError is the error of an invalid FEN string, with the field that makes it
+// invalid (one of the FENField constants), or "" if it doesn't have six fields.
+type FENError struct {
+	Field string
+	Err   error
+}
+
+func (e *FENError) Error() string { return e.Err.Error() }
+
+func (e *FENError) Unwrap() error { return e.Err }
+
+// fenFieldRegexps match each of the fields of a FEN string, in order.
+var fenFieldRegexps = []struct {
+	field string
+	rx    *regexp.Regexp
+}{
+	{FENFieldPiecePlacement, regexp.MustCompile(`^[1-8rnbqkpRNBQKP]{1,8}(\/[1-8rnbqkpRNBQKP]{1,8}){7}$`)},
+	{FENFieldActiveColor, regexp.MustCompile(`^[wb]$`)},
+	{FENFieldCastling, regexp.MustCompile(`^([KQkqA-Ha-h]{0,4}|-)$`)},
+	{FENFieldEnPassant, regexp.MustCompile(`^([a-h][36]|-)$`)},
+	{FENFieldHalfMoveClock, regexp.MustCompile(`^[0-9]{1,3}$`)},
+	{FENFieldFullMoveNumber, regexp.MustCompile(`^[0-9]{1,3}$`)},
+}
+
+// fenErrorField returns the field of the FEN string that the error is about.
+func fenErrorField(s string, err error) string {
+	switch err {
+	case errFENSideNotToMoveInCheck:
+		return FENFieldActiveColor
+	case errFENRegexDoesNotMatch:
+		fields := strings.Split(s, " ")
+		if len(fields) != len(fenFieldRegexps) {
+			return ""
+		}
+		for i, field := range fields {
+			if !fenFieldRegexps[i].rx.MatchString(field) {
+				return fenFieldRegexps[i].field
+			}
+		}
+		return ""
+	default:
+		return FENFieldPiecePlacement
+	}
+}
+
+// NewGameFromFEN parses a FEN string. Shredder-FEN and X-FEN castling fields (rook
+// files as letters, e.g. "HAha" or "Kq" plus "Bb") make it a Chess960 game; plain
+// "KQkq" always refers to standard castling.
 func NewGameFromFEN(s string) (Game, error) {
-	rxFEN := regexp.MustCompile(`^([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8}) ([wb]) ([KQkq]{0,4}|-) ([a-h][36]|-) ([0-9]{1,3}) ([0-9]{1,3})$`)
+	return newGameFromFEN(s, false, VariantStandard, standardValidation)
+}
+
+// NewChess960GameFromFEN parses a FEN string of a Chess960 game. Castling fields may
+// be Shredder-FEN (rook files, e.g. "HAha") or X-FEN, in which "KQkq" refer to the
+// outermost rook on each side of the king.
+func NewChess960GameFromFEN(s string) (Game, error) {
+	return newGameFromFEN(s, true, VariantStandard, standardValidation)
+}
+
+// NewVariantGameFromFEN parses a FEN string of a game of the given variant (see
+// Variant). Three-check FEN strings may end with the checks given, e.g. "+2+1",
+// and those of variants with drops may have pockets, e.g. "[QNp]".
+func NewVariantGameFromFEN(s string, v Variant) (Game, error) {
+	return newGameFromFEN(s, false, v, v.Validation())
+}
+
+// NewGameFromFENWithValidation parses a FEN string of a game of the given variant,
+// which is valid by the given validation policy rather than the variant's, e.g. a
+// teaching position without kings (see Validation.KingsOptional).
+func NewGameFromFENWithValidation(s string, v Variant, val Validation) (Game, error) {
+	return newGameFromFEN(s, false, v, val)
+}
+
+// NewChess960GameFromFENWithValidation is NewGameFromFENWithValidation for a
+// Chess960 game, whose castling fields are read as in NewChess960GameFromFEN.
+func NewChess960GameFromFENWithValidation(s string, v Variant, val Validation) (Game, error) {
+	return newGameFromFEN(s, true, v, val)
+}
+
+func newGameFromFEN(s string, isChess960 bool, v Variant, val Validation) (Game, error) {
+	var (
+		checks   [2]int
+		pockets  [2][7]int
+		promoted uint64
+		err      error
+	)
+	if v == VariantThreeCheck {
+		if s, checks, err = cutFENChecks(s); err != nil {
+			return Game{}, &FENError{Field: FENFieldChecks, Err: err}
+		}
+	}
+	if v.hasPockets() {
+		if s, pockets, promoted, err = cutFENPockets(s); err != nil {
+			return Game{}, &FENError{Field: FENFieldPockets, Err: err}
+		}
+	}
+	game, err := parseFEN(s, isChess960, v, val)
+	if err != nil {
+		return Game{}, &FENError{Field: fenErrorField(s, err), Err: err}
+	}
+	if v == VariantStandard && val == standardValidation {
+		return game, nil
+	}
+	game.checksGiven = checks
+	game.pockets, game.promoted = pockets, promoted&game.occAll()
+	game.positionHistory = []uint64{game.Hash()}
+	return game.WithVariant(v), nil
+}
+
+// parseFEN parses a FEN string of a game of the given variant, valid by the given
+// validation policy, without the variant's own fields (e.g. Three-check's checks).
+// It's a standard game, whose flags are only calculated if both the variant and
+// the policy are standard.
+func parseFEN(s string, isChess960 bool, v Variant, val Validation) (Game, error) {
+	rxFEN := regexp.MustCompile(`^([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8})\/([1-8rnbqkpRNBQKP]{1,8}) ([wb]) ([KQkqA-Ha-h]{0,4}|-) ([a-h][36]|-) ([0-9]{1,3}) ([0-9]{1,3})$`)
 	matches := rxFEN.FindAllStringSubmatch(s, -1)
 	if matches == nil {
 		return Game{}, errFENRegexDoesNotMatch
@@ -50,15 +178,11 @@ func NewGameFromFEN(s string) (Game, error) {
 		enPassantTargetSquare = XY{int(matches[0][11][0] - 'a'), int('8' - matches[0][11][1])}
 	}
 
-	// Castling calculation
-	castlingMap := map[byte]bool{}
-	for i := 0; i < len(matches[0][10]); i++ {
-		castlingMap[matches[0][10][i]] = true
+	// Castling: rook files in the castling field mean Chess960
+	castlingField := matches[0][10]
+	if strings.ContainsAny(castlingField, "ABCDEFGHabcdefgh") {
+		isChess960 = true
 	}
-	canWhiteKingsideCastle := castlingMap['K']
-	canWhiteQueensideCastle := castlingMap['Q']
-	canBlackKingsideCastle := castlingMap['k']
-	canBlackQueensideCastle := castlingMap['q']
 
 	// Pieces and kings calculation
 	pieceTypeMap := map[byte]PieceType{'Q': PieceQueen, 'K': PieceKing, 'B': PieceBishop, 'N': PieceKnight, 'R': PieceRook, 'P': PiecePawn}
@@ -68,7 +192,9 @@ func NewGameFromFEN(s string) (Game, error) {
 		IsLastMoveEnPassant:   isLastMoveEnPassant,
 		EnPassantTargetSquare: enPassantTargetSquare,
 		MoveNumber:            moveNumber,
+		IsChess960:            isChess960,
 		kingSq:                [2]int8{-1, -1},
+		validation:            val,
 	}
 	for y, row := range []string{matches[0][1], matches[0][2], matches[0][3], matches[0][4], matches[0][5], matches[0][6], matches[0][7], matches[0][8]} {
 		x := 0
@@ -79,13 +205,13 @@ func NewGameFromFEN(s string) (Game, error) {
 			}
 			switch b {
 			case 'Q', 'K', 'B', 'N', 'R', 'P':
-				if b == 'K' && game.kingSq[ColorWhite] >= 0 {
+				if b == 'K' && game.kingSq[ColorWhite] >= 0 && !val.ManyKings {
 					return Game{}, errFENDuplicateKing
 				}
 				game.setSq(ColorWhite, pieceTypeMap[b], sqOf(XY{x, y}))
 				x++
 			case 'q', 'k', 'b', 'n', 'r', 'p':
-				if b == 'k' && game.kingSq[ColorBlack] >= 0 {
+				if b == 'k' && game.kingSq[ColorBlack] >= 0 && !val.ManyKings {
 					return Game{}, errFENDuplicateKing
 				}
 				game.setSq(ColorBlack, pieceTypeMap[b-'a'+'A'], sqOf(XY{x, y}))
@@ -93,19 +219,19 @@ func NewGameFromFEN(s string) (Game, error) {
 			case '1', '2', '3', '4', '5', '6', '7', '8':
 				x += int(b - '0')
 			}
-			if (b == 'p' || b == 'P') && (y == 0 || y == 7) {
+			if b == 'p' && !val.allowsPawnAt(ColorBlack, y) || b == 'P' && !val.allowsPawnAt(ColorWhite, y) {
 				return Game{}, errFENPawnInImpossibleRank
 			}
 		}
 	}
-	if game.kingSq[ColorBlack] < 0 || game.kingSq[ColorWhite] < 0 {
+	if (game.kingSq[ColorBlack] < 0 || game.kingSq[ColorWhite] < 0) && !val.KingsOptional {
 		return Game{}, errFENKingMissing
 	}
-	if bits.OnesCount64(game.occ[ColorBlack]) > 16 {
-		return Game{}, errFENBlackHasMoreThan16Pieces
+	if limit := val.maxPieces(ColorBlack); limit > 0 && bits.OnesCount64(game.occ[ColorBlack]) > limit {
+		return Game{}, errFENBlackHasTooManyPieces
 	}
-	if bits.OnesCount64(game.occ[ColorWhite]) > 16 {
-		return Game{}, errFENWhiteHasMoreThan16Pieces
+	if limit := val.maxPieces(ColorWhite); limit > 0 && bits.OnesCount64(game.occ[ColorWhite]) > limit {
+		return Game{}, errFENWhiteHasTooManyPieces
 	}
 
 	// En passant auto-correction: an impossible e.p. target (no pawn of the right
@@ -123,6 +249,7 @@ func NewGameFromFEN(s string) (Game, error) {
 	// Castling auto-correction: rights inconsistent with king/rook placement are
 	// silently narrowed rather than rejected (a board editor's "KQkq" with a moved
 	// king just means no castling).
+	canWhiteKingsideCastle, canWhiteQueensideCastle, canBlackKingsideCastle, canBlackQueensideCastle := game.parseCastlingField(castlingField)
 	canWhiteKingsideCastle, canWhiteQueensideCastle, canBlackKingsideCastle, canBlackQueensideCastle = narrowCastlingRights(
 		game,
 		canWhiteKingsideCastle, canWhiteQueensideCastle, canBlackKingsideCastle, canBlackQueensideCastle,
@@ -137,11 +264,14 @@ func NewGameFromFEN(s string) (Game, error) {
 	// The side not to move must not be in check: such a position is unreachable
 	// and move generation semantics break down (the opponent's king is capturable).
 	sideNotToMove := opponentOfTurn(turn)
-	if game.attackersOf(int(game.kingSq[sideNotToMove]), sideNotToMove) != 0 {
+	if v.checkers(game, sideNotToMove) != 0 {
 		return Game{}, errFENSideNotToMoveInCheck
 	}
 
-	game.positionHistory = []uint64{game.positionHash()}
+	game.positionHistory = []uint64{game.Hash()}
+	if v != VariantStandard || val != standardValidation {
+		return game, nil // Calculated by the variant's rules, e.g. without kings
+	}
 	return game.calculateCriticalFlags(), nil
 }
 
@@ -152,26 +282,82 @@ func opponentOfTurn(turn string) color {
 	return ColorWhite
 }
 
-// narrowCastlingRights drops any castling right that is inconsistent with the actual
-// king and rook placement.
-func narrowCastlingRights(g Game, wk, wq, bk, bq bool) (bool, bool, bool, bool) {
-	if g.kingSq[ColorWhite] != int8(sqOf(XY{4, 7})) {
-		wk, wq = false, false
-	}
-	if g.kingSq[ColorBlack] != int8(sqOf(XY{4, 0})) {
-		bk, bq = false, false
+// parseCastlingField reads the castling field of a FEN string into rights (as wk,
+// wq, bk, bq), recording the castling rook files when the game is Chess960. A
+// right whose rook can't be located is dropped.
+func (g *Game) parseCastlingField(field string) (bool, bool, bool, bool) {
+	var rights [2][2]bool
+	for i := 0; i < len(field); i++ {
+		c, letter := color(ColorWhite), field[i]
+		if letter >= 'a' && letter <= 'z' {
+			c, letter = ColorBlack, letter-'a'+'A'
+		}
+		var (
+			ct    castleType
+			rookX int
+		)
+		switch letter {
+		case '-':
+			continue
+		case 'K':
+			ct, rookX = castleTypeKingside, g.outermostRookX(c, 1)
+		case 'Q':
+			ct, rookX = castleTypeQueenside, g.outermostRookX(c, -1)
+		default:
+			ct, rookX = castleTypeQueenside, int(letter-'A')
+			if rookX > xyOfSq(int(g.kingSq[c])).X {
+				ct = castleTypeKingside
+			}
+		}
+		if !g.IsChess960 {
+			rights[c][ct] = true
+			continue
+		}
+		if rookX >= 0 {
+			rights[c][ct] = true
+			g.castlingRookX[c][ct] = int8(rookX)
+		}
 	}
-	if !g.hasPieceAt(ColorWhite, PieceRook, XY{7, 7}) {
-		wk = false
+	return rights[ColorWhite][castleTypeKingside], rights[ColorWhite][castleTypeQueenside], rights[ColorBlack][castleTypeKingside], rights[ColorBlack][castleTypeQueenside]
+}
+
+// outermostRookX returns the file of the given color's rook on its home rank that
+// is furthest from the king in the given direction (1 towards the h-file, -1
+// towards the a-file), or -1 if there's none.
+func (g Game) outermostRookX(c color, dir int) int {
+	kingXY := xyOfSq(int(g.kingSq[c]))
+	if kingXY.Y != homeRank(c) {
+		return -1
 	}
-	if !g.hasPieceAt(ColorWhite, PieceRook, XY{0, 7}) {
-		wq = false
+	rookX := -1
+	for x := kingXY.X + dir; x >= 0 && x <= 7; x += dir {
+		if g.hasPieceAt(c, PieceRook, XY{x, kingXY.Y}) {
+			rookX = x
+		}
 	}
-	if !g.hasPieceAt(ColorBlack, PieceRook, XY{7, 0}) {
-		bk = false
+	return rookX
+}
+
+// narrowCastlingRights drops any castling right that is inconsistent with the actual
+// king and rook placement.
+func narrowCastlingRights(g Game, wk, wq, bk, bq bool) (bool, bool, bool, bool) {
+	rights := [2][2]*bool{
+		ColorBlack: {castleTypeQueenside: &bq, castleTypeKingside: &bk},
+		ColorWhite: {castleTypeQueenside: &wq, castleTypeKingside: &wk},
 	}
-	if !g.hasPieceAt(ColorBlack, PieceRook, XY{0, 0}) {
-		bq = false
+	for c := range rights {
+		kingXY := xyOfSq(int(g.kingSq[c]))
+		for ct, right := range rights[c] {
+			rookXY := XY{g.castleRookX(color(c), castleType(ct)), homeRank(color(c))}
+			switch {
+			case g.bb[c][PieceKing] == 0,
+				kingXY.Y != homeRank(color(c)),
+				!g.IsChess960 && kingXY.X != 4,
+				!g.hasPieceAt(color(c), PieceRook, rookXY),
+				(rookXY.X > kingXY.X) != (ct == castleTypeKingside):
+				*right = false
+			}
+		}
 	}
 	return wk, wq, bk, bq
 }
@@ -182,7 +368,21 @@ func atoi(s string) int {
 	return n
 }
 
+// ToFEN renders the game as a FEN string. Chess960 games use X-FEN castling fields:
+// "KQkq" when the castling rook is the outermost one, its file letter otherwise.
+// Three-check games end with the checks given, e.g. "+2+1", and games of variants
+// with drops have their pockets after the piece placement, e.g. "[QNp]".
 func (g Game) ToFEN() string {
+	return g.toFEN(false)
+}
+
+// ToShredderFEN renders the game as a Shredder-FEN string, which always names the
+// castling rooks by file (e.g. "HAha"). Standard games render as in ToFEN.
+func (g Game) ToShredderFEN() string {
+	return g.toFEN(true)
+}
+
+func (g Game) toFEN(isShredder bool) string {
 	var sb strings.Builder
 	pieceTypeMap := map[PieceType]byte{PieceQueen: 'Q', PieceKing: 'K', PieceBishop: 'B', PieceKnight: 'N', PieceRook: 'R', PiecePawn: 'P'}
 	for y := 0; y < 8; y++ {
@@ -205,6 +405,9 @@ func (g Game) ToFEN() string {
 				count = 0
 				sb.WriteByte(pieceTypeMap[p.PieceType])
 			}
+			if p.PieceType != PieceNone && g.promoted&sqBit(sqOf(XY{x, y})) != 0 {
+				sb.WriteByte('~')
+			}
 		}
 		if count > 0 {
 			sb.WriteString(fmt.Sprintf("%v", count))
@@ -213,29 +416,16 @@ func (g Game) ToFEN() string {
 			sb.WriteByte('/')
 		}
 	}
+	if g.Variant().hasPockets() {
+		sb.WriteString(g.pocketsFEN())
+	}
 
 	turn := "b"
 	if g.Turn() == ColorWhite {
 		turn = "w"
 	}
 
-	var castlingSB strings.Builder
-	if g.CanWhiteKingsideCastle {
-		castlingSB.WriteByte('K')
-	}
-	if g.CanWhiteQueensideCastle {
-		castlingSB.WriteByte('Q')
-	}
-	if g.CanBlackKingsideCastle {
-		castlingSB.WriteByte('k')
-	}
-	if g.CanBlackQueensideCastle {
-		castlingSB.WriteByte('q')
-	}
-	castling := castlingSB.String()
-	if castling == "" {
-		castling = "-"
-	}
+	castling := g.castlingFieldFEN(isShredder)
 
 	enPassant := "-"
 	if g.IsLastMoveEnPassant {
@@ -243,6 +433,47 @@ func (g Game) ToFEN() string {
 	}
 
 	sb.WriteString(fmt.Sprintf(" %v %v %v %v %v", turn, castling, enPassant, g.HalfMoveClock, g.FullMoveNumber))
+	if g.variant == VariantThreeCheck {
+		sb.WriteString(g.checksFEN())
+	}
 
 	return sb.String()
 }
+
+// castlingFieldFEN renders the castling field of a FEN string. Chess960 rights are
+// rendered as the castling rook's file letter when isShredder is set or when the
+// rook isn't the outermost one on its side of the king (X-FEN).
+func (g Game) castlingFieldFEN(isShredder bool) string {
+	var sb strings.Builder
+	for _, r := range []struct {
+		c       color
+		ct      castleType
+		allowed bool
+		letter  byte
+	}{
+		{ColorWhite, castleTypeKingside, g.CanWhiteKingsideCastle, 'K'},
+		{ColorWhite, castleTypeQueenside, g.CanWhiteQueensideCastle, 'Q'},
+		{ColorBlack, castleTypeKingside, g.CanBlackKingsideCastle, 'k'},
+		{ColorBlack, castleTypeQueenside, g.CanBlackQueensideCastle, 'q'},
+	} {
+		if !r.allowed {
+			continue
+		}
+		letter := r.letter
+		dir := -1
+		if r.ct == castleTypeKingside {
+			dir = 1
+		}
+		if g.IsChess960 && (isShredder || g.outermostRookX(r.c, dir) != g.castleRookX(r.c, r.ct)) {
+			letter = byte('A' + g.castleRookX(r.c, r.ct))
+			if r.c == ColorBlack {
+				letter = byte('a' + g.castleRookX(r.c, r.ct))
+			}
+		}
+		sb.WriteByte(letter)
+	}
+	if sb.Len() == 0 {
+		return "-"
+	}
+	return sb.String()
+}
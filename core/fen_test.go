@@ -1,6 +1,7 @@
 package core
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -140,20 +141,44 @@ func TestFENErrors(t *testing.T) {
 			err:       errFENPawnInImpossibleRank,
 		},
 		{
-			name:      "errFENBlackHasMoreThan16Pieces: black has 17 pieces",
+			name:      "errFENBlackHasTooManyPieces: black has 17 pieces",
 			fenString: "rnbqkbnr/pppppppp/p7/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
-			err:       errFENBlackHasMoreThan16Pieces,
+			err:       errFENBlackHasTooManyPieces,
 		},
 		{
-			name:      "errFENWhiteHasMoreThan16Pieces: white has 17 pieces",
+			name:      "errFENWhiteHasTooManyPieces: white has 17 pieces",
 			fenString: "rnbqkbnr/pppppppp/8/8/8/P7/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
-			err:       errFENWhiteHasMoreThan16Pieces,
+			err:       errFENWhiteHasTooManyPieces,
 		},
 	}
 	for _, tc := range ts {
 		t.Run(tc.name, func(t *testing.T) {
 			_, err := NewGameFromFEN(tc.fenString)
-			assert.Equal(t, tc.err, err)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestFENErrorFields(t *testing.T) {
+	ts := []struct {
+		fenString string
+		field     string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP w KQkq - 0 1", FENFieldPiecePlacement},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR x KQkq - 0 1", FENFieldActiveColor},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkqX - 0 1", FENFieldCastling},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq e4 0 1", FENFieldEnPassant},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - x 1", FENFieldHalfMoveClock},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1000", FENFieldFullMoveNumber},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQQBNR w KQkq - 0 1", FENFieldPiecePlacement},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -", ""},
+	}
+	for _, tc := range ts {
+		t.Run(tc.fenString, func(t *testing.T) {
+			_, err := NewGameFromFEN(tc.fenString)
+			var fenErr *FENError
+			require.True(t, errors.As(err, &fenErr))
+			assert.Equal(t, tc.field, fenErr.Field)
 		})
 	}
 }
@@ -465,7 +490,7 @@ func TestFENConvertsBackToItself(t *testing.T) {
 	for _, tc := range ts {
 		t.Run(fmt.Sprintf("FEN converts %v back to itself", tc), func(t *testing.T) {
 			g, err := NewGameFromFEN(tc)
-			if err == errFENSideNotToMoveInCheck {
+			if errors.Is(err, errFENSideNotToMoveInCheck) {
 				// Some auto-generated fixtures predate the side-not-to-move-in-check
 				// validation; rejecting them is the correct new behavior.
 				return
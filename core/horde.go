@@ -0,0 +1,37 @@
+package core
+
+// horde is Horde: White has a horde of 36 pawns and no king, and wins by
+// checkmating Black, who wins by capturing all of White's pieces. White's pawns on
+// the first rank may advance two squares, but can't be captured en passant then.
+// https://lichess.org/variant/horde
+type horde struct{}
+
+// hordeValidation lets White have the horde and no king, and pawns on the first
+// rank.
+var hordeValidation = Validation{MaxWhitePieces: 36, MaxBlackPieces: 16, KingsOptional: true, FirstRankPawns: true}
+
+func (horde) Name() string { return "horde" }
+func (horde) StartFEN() string {
+	return "rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1"
+}
+func (horde) Validation() Validation           { return hordeValidation }
+func (horde) allowsMove(g Game, a Action) bool { return true }
+func (horde) updateState(g *Game, a Action)    {}
+func (horde) hasPockets() bool                 { return false }
+
+func (horde) exposesKing(g Game, a Action) bool   { return g.kingAttackers(a.FromPiece.Owner) != 0 }
+func (horde) checkers(g Game, c color) uint64     { return g.kingAttackers(c) }
+func (horde) promotions() []PieceType             { return promotionPieceTypes[:] }
+func (horde) filterMoves(moves []Action) []Action { return moves }
+
+// outcome is a win for Black once White has no pieces left.
+func (horde) outcome(g Game) (bool, color) {
+	if g.occ[ColorWhite] == 0 {
+		return true, ColorBlack
+	}
+	return false, -1
+}
+
+// isInsufficientMaterial is never true, since Black's king alone may capture the
+// last of White's pieces.
+func (horde) isInsufficientMaterial(g Game) bool { return false }
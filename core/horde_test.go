@@ -0,0 +1,64 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHorde(t *testing.T) {
+	t.Run("perft", func(t *testing.T) {
+		// https://github.com/niklasf/python-chess/blob/master/examples/perft/horde.perft
+		g := NewVariantGame(VariantHorde)
+		for depth, nodes := range map[int]int{1: 8, 2: 128, 3: 1274, 4: 23310} {
+			assert.Equal(t, nodes, Perft(g, depth), "depth %v", depth)
+		}
+		if testing.Short() {
+			return
+		}
+		assert.Equal(t, 265223, Perft(g, 5))
+	})
+
+	t.Run("pawns on the first rank advance two squares, but can't be captured en passant", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "4k3/8/8/8/8/1p6/8/P7 w - - 0 1", VariantHorde)
+		assert.False(t, g.IsCheck)
+		g = doMoves(t, g, "a1", "a3")
+		assert.Equal(t, "4k3/8/8/8/8/Pp6/8/8 b - - 0 1", g.ToFEN())
+		for _, a := range g.Actions {
+			assert.False(t, a.IsEnPassantCapture)
+		}
+
+		g = mustVariantGameFromFEN(t, "4k3/8/8/8/1p6/8/P7/8 w - - 0 1", VariantHorde)
+		g = doMoves(t, g, "a2", "a4")
+		assert.Equal(t, "4k3/8/8/8/Pp6/8/8/8 b - a3 0 1", g.ToFEN(), "those on the second rank can")
+	})
+
+	t.Run("capturing all of White's pieces wins", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/8/8/8/8/8/k7/P7 b - - 0 1", VariantHorde)
+		g = doMoves(t, g, "a2", "a1")
+		assert.True(t, g.IsGameOver)
+		assert.True(t, g.IsVariantEnd)
+		assert.False(t, g.IsStalemate)
+		assert.Equal(t, color(ColorBlack), g.GameOverWinner)
+		assert.Empty(t, g.Actions)
+
+		p := NewPosition(mustVariantGameFromFEN(t, "8/8/8/8/8/8/8/k7 w - - 0 1", VariantHorde))
+		isOver, winner := p.VariantEnd()
+		assert.True(t, isOver)
+		assert.Equal(t, color(ColorBlack), winner)
+	})
+
+	t.Run("checkmating Black wins", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "k7/Pn6/PPP5/8/8/8/8/8 w - - 0 1", VariantHorde)
+		g = doMoves(t, g, "c6", "b7")
+		assert.True(t, g.IsCheckmate)
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+	})
+
+	t.Run("White may have up to 36 pieces", func(t *testing.T) {
+		_, err := NewVariantGameFromFEN("rnbqkbnr/pppppppp/8/1PP2PP1/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1", VariantStandard)
+		assert.ErrorIs(t, err, errFENPawnInImpossibleRank)
+		_, err = NewVariantGameFromFEN("rnbqkbnr/pppppppp/8/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP/PPPPPPPP w kq - 0 1", VariantHorde)
+		assert.ErrorIs(t, err, errFENWhiteHasTooManyPieces)
+	})
+}
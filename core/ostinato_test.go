@@ -117,7 +117,7 @@ func TestOstinatoPawns(t *testing.T) {
 			require.NoError(t, err)
 			count := 0
 			for _, a := range g.Actions {
-				if !a.IsResign && !a.IsDraw && a.FromPiece.XY == tc.pieceXY {
+				if a.IsMove() && a.FromPiece.XY == tc.pieceXY {
 					count++
 				}
 			}
@@ -204,7 +204,7 @@ func TestOstinatoGameEnding(t *testing.T) {
 		require.NoError(t, err)
 		mateCount := 0
 		for _, a := range g.Actions {
-			if a.IsResign || a.IsDraw {
+			if !a.IsMove() {
 				continue
 			}
 			newGame := g.DoAction(a)
@@ -260,11 +260,11 @@ func TestOstinatoGameEnding(t *testing.T) {
 		assert.False(t, newGame.IsCheckmate)
 	})
 
-	t.Run("draw and resign are always available", func(t *testing.T) {
+	t.Run("offering a draw and resigning are always available", func(t *testing.T) {
 		g := NewDefaultGame()
 		hasDraw, hasResign := false, false
 		for _, a := range g.Actions {
-			if a.IsDraw {
+			if a.IsDrawOffer {
 				hasDraw = true
 			}
 			if a.IsResign {
@@ -278,7 +278,7 @@ func TestOstinatoGameEnding(t *testing.T) {
 		g2 := g.DoAction(firstMove)
 		hasDraw, hasResign = false, false
 		for _, a := range g2.Actions {
-			if a.IsDraw {
+			if a.IsDrawOffer {
 				hasDraw = true
 			}
 			if a.IsResign {
@@ -356,7 +356,7 @@ func TestOstinatoChessBoard(t *testing.T) {
 			}
 			var move Action
 			for _, a := range g.Actions {
-				if !a.IsResign && !a.IsDraw {
+				if a.IsMove() {
 					move = a
 					break
 				}
@@ -374,7 +374,7 @@ func TestOstinatoChessGame(t *testing.T) {
 	countPieceActions := func(g Game, xy XY) int {
 		count := 0
 		for _, a := range g.Actions {
-			if !a.IsResign && !a.IsDraw && a.FromPiece.XY == xy {
+			if a.IsMove() && a.FromPiece.XY == xy {
 				count++
 			}
 		}
@@ -418,7 +418,7 @@ func TestOstinatoChessGame(t *testing.T) {
 		require.NoError(t, err)
 		boardActions := 0
 		for _, a := range g.Actions {
-			if !a.IsResign && !a.IsDraw {
+			if a.IsMove() {
 				boardActions++
 			}
 		}
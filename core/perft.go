@@ -1,18 +1,119 @@
 package core
 
+import "sort"
+
 // Perft counts the number of leaf nodes in the move tree at the given depth.
 // It is the standard method for validating move generation correctness in chess engines.
 // https://www.chessprogramming.org/Perft
 func Perft(g Game, depth int) int {
+	return PerftWithTable(g, depth, nil)
+}
+
+// PerftWithTable is like Perft, but stores the node counts of the subtrees it
+// walks in the given table, and reuses them when a position is reached again by a
+// different move order, which makes deep perfts much faster. A nil table is none.
+func PerftWithTable(g Game, depth int, t *PerftTable) int {
+	if depth <= 0 {
+		return 1
+	}
+	return NewPosition(g).perft(depth, make([][]Action, depth), t)
+}
+
+// PerftDivision is the number of leaf nodes under one of the root's actions.
+type PerftDivision struct {
+	Action Action
+	// Move is the action in UCI notation (e.g. "e2e4"), see Game.UCI.
+	Move  string
+	Nodes int
+}
+
+// PerftDivide is like PerftWithTable, but returns the number of leaf nodes under
+// each of the root's actions ("divide"), sorted by Move. Comparing a divide with
+// another engine's, and then dividing the action whose count differs, and so on,
+// leads to the position whose actions are wrong.
+func PerftDivide(g Game, depth int, t *PerftTable) []PerftDivision {
+	if depth <= 0 {
+		return nil
+	}
+	var (
+		p         = NewPosition(g)
+		buffers   = make([][]Action, depth)
+		divisions []PerftDivision
+	)
+	for _, a := range p.LegalActions() {
+		p.MakeMove(a)
+		divisions = append(divisions, PerftDivision{Action: a, Move: g.UCI(a), Nodes: p.perft(depth-1, buffers, t)})
+		p.UnmakeMove()
+	}
+	sort.Slice(divisions, func(i, j int) bool { return divisions[i].Move < divisions[j].Move })
+	return divisions
+}
+
+// perft is Perft on a Position, with one actions buffer per remaining depth, so
+// that the walk doesn't allocate. The leaves' parents count their legal actions
+// rather than making them (bulk counting).
+func (p *Position) perft(depth int, buffers [][]Action, t *PerftTable) int {
 	if depth == 0 {
 		return 1
 	}
-	nodes := 0
-	for _, a := range g.Actions {
-		if a.IsResign || a.IsDraw {
-			continue
+	if t != nil && depth > 1 {
+		if nodes, ok := t.probe(p.Hash(), depth); ok {
+			return nodes
 		}
-		nodes += Perft(g.DoAction(a), depth-1)
+	}
+	actions := p.AppendLegalActions(buffers[depth-1][:0])
+	buffers[depth-1] = actions
+	if depth == 1 {
+		return len(actions)
+	}
+	nodes := 0
+	for _, a := range actions {
+		p.MakeMove(a)
+		nodes += p.perft(depth-1, buffers, t)
+		p.UnmakeMove()
+	}
+	if t != nil {
+		t.store(p.Hash(), depth, nodes)
 	}
 	return nodes
 }
+
+// PerftTable is a hash table of perft subtree node counts, by position and depth.
+// Entries are replaced on collisions, so its size bounds its memory. It's not safe
+// for concurrent use.
+type PerftTable struct {
+	entries []perftEntry
+	mask    uint64
+}
+
+type perftEntry struct {
+	hash  uint64
+	nodes uint64
+	depth int32
+}
+
+// perftEntrySize is the size of a perftEntry in memory, in bytes.
+const perftEntrySize = 24
+
+// NewPerftTable creates a PerftTable of about the given size in megabytes (at
+// least 1), rounded down to a power of two entries.
+func NewPerftTable(sizeMB int) *PerftTable {
+	count := uint64(max(sizeMB, 1)) << 20 / perftEntrySize
+	size := uint64(1)
+	for size*2 <= count {
+		size *= 2
+	}
+	return &PerftTable{entries: make([]perftEntry, size), mask: size - 1}
+}
+
+func (t *PerftTable) probe(hash uint64, depth int) (int, bool) {
+	e := t.entries[hash&t.mask]
+	if e.hash != hash || int(e.depth) != depth {
+		return 0, false
+	}
+	return int(e.nodes), true
+}
+
+func (t *PerftTable) store(hash uint64, depth, nodes int) {
+	t.entries[hash&t.mask] = perftEntry{hash: hash, nodes: uint64(nodes), depth: int32(depth)}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PerftSuiteResult is the outcome of one of a perft suite's positions at one depth.
+type PerftSuiteResult struct {
+	// Line is the suite's line of the position, starting at 1.
+	Line     int
+	FEN      string
+	Depth    int
+	Expected int
+	Nodes    int
+}
+
+// Passed returns whether Perft found the expected number of nodes.
+func (r PerftSuiteResult) Passed() bool {
+	return r.Nodes == r.Expected
+}
+
+// RunPerftSuite runs a perft suite in EPD format, as the widely used
+// perftsuite.epd: one position per line, followed by the expected node counts by
+// depth, e.g.
+//
+//	rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 ;D1 20 ;D2 400 ;D3 8902
+//
+// Positions may omit the move counters, and blank lines and lines starting with
+// "#" are skipped. Every position is run at every depth up to maxDepth (all of
+// them if zero), with the given table (see PerftWithTable), and report, if not
+// nil, is called with every result as soon as it's known.
+//
+// Returns the results that didn't pass, or an error if the suite is invalid.
+func RunPerftSuite(r io.Reader, maxDepth int, t *PerftTable, report func(PerftSuiteResult)) ([]PerftSuiteResult, error) {
+	var (
+		scanner  = bufio.NewScanner(r)
+		failures []PerftSuiteResult
+	)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fen, expected, err := parsePerftSuiteLine(text)
+		if err != nil {
+			return failures, fmt.Errorf("perft suite line %d: %w", line, err)
+		}
+		g, err := NewGameFromFEN(fen)
+		if err != nil {
+			return failures, fmt.Errorf("perft suite line %d: %w", line, err)
+		}
+		for depth := 1; depth < len(expected) && (maxDepth == 0 || depth <= maxDepth); depth++ {
+			if expected[depth] < 0 {
+				continue
+			}
+			result := PerftSuiteResult{Line: line, FEN: fen, Depth: depth, Expected: expected[depth], Nodes: PerftWithTable(g, depth, t)}
+			if report != nil {
+				report(result)
+			}
+			if !result.Passed() {
+				failures = append(failures, result)
+			}
+		}
+	}
+	return failures, scanner.Err()
+}
+
+// parsePerftSuiteLine returns the FEN of a perft suite's line, and its expected
+// node counts by depth (-1 for missing depths).
+func parsePerftSuiteLine(line string) (string, []int, error) {
+	parts := strings.Split(line, ";")
+	fen := strings.Join(strings.Fields(parts[0]), " ")
+	if len(strings.Fields(fen)) == 4 {
+		fen += " 0 1"
+	}
+	expected := []int{-1}
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 || !strings.HasPrefix(fields[0], "D") {
+			return "", nil, fmt.Errorf("invalid depth %q: expected e.g. \"D1 20\"", strings.TrimSpace(part))
+		}
+		depth, err := strconv.Atoi(fields[0][1:])
+		if err != nil || depth < 1 {
+			return "", nil, fmt.Errorf("invalid depth %q", fields[0])
+		}
+		nodes, err := strconv.Atoi(fields[1])
+		if err != nil || nodes < 0 {
+			return "", nil, fmt.Errorf("invalid node count %q", fields[1])
+		}
+		for len(expected) <= depth {
+			expected = append(expected, -1)
+		}
+		expected[depth] = nodes
+	}
+	return fen, expected, nil
+}
@@ -2,8 +2,11 @@ package core
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -90,3 +93,74 @@ func TestPerft(t *testing.T) {
 		})
 	}
 }
+
+func TestPerftDivide(t *testing.T) {
+	g := NewDefaultGame()
+	divisions := PerftDivide(g, 3, nil)
+	require.Len(t, divisions, 20)
+	assert.Equal(t, "a2a3", divisions[0].Move)
+	assert.Equal(t, 380, divisions[0].Nodes)
+	assert.Equal(t, "h2h4", divisions[len(divisions)-1].Move)
+	total := 0
+	for _, d := range divisions {
+		total += d.Nodes
+	}
+	assert.Equal(t, Perft(g, 3), total)
+	assert.Empty(t, PerftDivide(g, 0, nil))
+
+	t.Run("promotions and chess960 castling", func(t *testing.T) {
+		g, err := NewGameFromFEN("1r4kr/P7/8/8/8/8/8/1R4KR w HBhb - 0 1")
+		require.NoError(t, err)
+		moves := map[string]int{}
+		for _, d := range PerftDivide(g, 1, nil) {
+			moves[d.Move] = d.Nodes
+		}
+		assert.Contains(t, moves, "a7a8q")
+		assert.Contains(t, moves, "a7b8n")
+		assert.Contains(t, moves, "g1h1", "kingside castling is the king taking its rook")
+	})
+}
+
+func TestPerftWithTable(t *testing.T) {
+	g, err := NewGameFromFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	require.NoError(t, err)
+	table := NewPerftTable(1)
+	assert.Equal(t, 97862, PerftWithTable(g, 3, table))
+	assert.Equal(t, 97862, PerftWithTable(g, 3, table), "reusing the table")
+	assert.Equal(t, 2039, PerftWithTable(g, 2, table), "other depths aren't mixed up")
+}
+
+func TestRunPerftSuite(t *testing.T) {
+	f, err := os.Open("testdata/perftsuite.epd")
+	require.NoError(t, err)
+	defer f.Close()
+	maxDepth := 0
+	if testing.Short() {
+		maxDepth = 3
+	}
+	results := 0
+	failures, err := RunPerftSuite(f, maxDepth, NewPerftTable(16), func(PerftSuiteResult) { results++ })
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+	assert.Greater(t, results, 14)
+
+	t.Run("mismatches", func(t *testing.T) {
+		suite := "4k3/8/8/8/8/8/8/4K2R w K - ;D1 15 ;D2 67\n\n# comment\n4k3/8/8/8/8/8/8/R3K3 w Q - 0 1 ;D2 71"
+		failures, err := RunPerftSuite(strings.NewReader(suite), 0, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []PerftSuiteResult{{Line: 1, FEN: "4k3/8/8/8/8/8/8/4K2R w K - 0 1", Depth: 2, Expected: 67, Nodes: 66}}, failures)
+	})
+
+	t.Run("invalid suites", func(t *testing.T) {
+		for _, suite := range []string{
+			"4k3/8/8/8/8/8/8/4K2R w K - 0 1 ;D1",
+			"4k3/8/8/8/8/8/8/4K2R w K - 0 1 ;X1 15",
+			"4k3/8/8/8/8/8/8/4K2R w K - 0 1 ;D0 1",
+			"4k3/8/8/8/8/8/8/4K2R w K - 0 1 ;D1 many",
+			"not a fen ;D1 15",
+		} {
+			_, err := RunPerftSuite(strings.NewReader(suite), 0, nil, nil)
+			assert.ErrorContains(t, err, "perft suite line 1", suite)
+		}
+	})
+}
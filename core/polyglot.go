@@ -0,0 +1,50 @@
+package core
+
+import "math/bits"
+
+// Offsets of the non-piece keys in polyglotRandom64.
+const (
+	polyglotCastlingOffset  = 768
+	polyglotEnPassantOffset = 772
+	polyglotTurnOffset      = 780
+)
+
+// polyglotPieceKind is the index of each piece type in the Polyglot format, which
+// has black's piece right before white's: black pawn 0, white pawn 1, black knight
+// 2, ..., white king 11.
+var polyglotPieceKind = [7]int{PiecePawn: 0, PieceKnight: 2, PieceBishop: 4, PieceRook: 6, PieceQueen: 8, PieceKing: 10}
+
+// PolyglotHash returns the hash of the position in the Polyglot opening book
+// format. Unlike Hash, it's the same across chess programs, so it can be used to
+// look positions up in Polyglot books.
+//
+// As in the format, the en passant file is only hashed when a pawn can actually
+// capture en passant. It's calculated from scratch on every call.
+func (g Game) PolyglotHash() uint64 {
+	var h uint64
+	for c := ColorBlack; c <= ColorWhite; c++ {
+		for occ := g.occ[c]; occ != 0; occ &= occ - 1 {
+			sq := bits.TrailingZeros64(occ)
+			kind := polyglotPieceKind[g.squares[sq]>>1] + c
+			// Polyglot's rows start at rank 1, whereas y = 0 is rank 8
+			row, file := 7-sq>>3, sq&7
+			h ^= polyglotRandom64[64*kind+8*row+file]
+		}
+	}
+	for i, canCastle := range [4]bool{g.CanWhiteKingsideCastle, g.CanWhiteQueensideCastle, g.CanBlackKingsideCastle, g.CanBlackQueensideCastle} {
+		if canCastle {
+			h ^= polyglotRandom64[polyglotCastlingOffset+i]
+		}
+	}
+	if g.IsLastMoveEnPassant {
+		turn := g.Turn()
+		target := sqOf(g.EnPassantTargetSquare)
+		if pawnCaptureAttacks[turn.Opponent()][target]&g.bb[turn][PiecePawn] != 0 {
+			h ^= polyglotRandom64[polyglotEnPassantOffset+g.EnPassantTargetSquare.X]
+		}
+	}
+	if g.Turn() == ColorWhite {
+		h ^= polyglotRandom64[polyglotTurnOffset]
+	}
+	return h
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolyglotHash(t *testing.T) {
+	// The test positions of the Polyglot book format specification
+	// http://hgm.nubati.net/book_format.html
+	ts := []struct {
+		moves    []string
+		expected uint64
+	}{
+		{moves: []string{}, expected: 0x463b96181691fc9c},
+		{moves: []string{"e2e4"}, expected: 0x823c9b50fd114196},
+		{moves: []string{"e2e4", "d7d5"}, expected: 0x0756b94461c50fb0},
+		{moves: []string{"e2e4", "d7d5", "e4e5"}, expected: 0x662fafb965db29d4},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5"}, expected: 0x22a48b5a8e47ff78},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2"}, expected: 0x652a607ca3f242c1},
+		{moves: []string{"e2e4", "d7d5", "e4e5", "f7f5", "e1e2", "e8f7"}, expected: 0x00fdd303c946bdd9},
+		{moves: []string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4"}, expected: 0x3c8123ea7b067637},
+		{moves: []string{"a2a4", "b7b5", "h2h4", "b5b4", "c2c4", "b4c3", "a1a3"}, expected: 0x5c3f9b829b279560},
+	}
+	for _, tc := range ts {
+		t.Run(fmt.Sprint(tc.moves), func(t *testing.T) {
+			g := NewDefaultGame()
+			for _, move := range tc.moves {
+				found := false
+				for _, a := range g.Actions {
+					if a.IsMove() && a.FromPiece.XY.ToAlgebraic()+a.ToXY.ToAlgebraic() == move {
+						g, found = g.DoAction(a), true
+						break
+					}
+				}
+				require.True(t, found, move)
+			}
+			assert.Equal(t, fmt.Sprintf("%016x", tc.expected), fmt.Sprintf("%016x", g.PolyglotHash()))
+		})
+	}
+}
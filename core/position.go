@@ -0,0 +1,233 @@
+package core
+
+import "math/bits"
+
+// Position is a mutable position, for engines that visit millions of positions,
+// such as Perft and the ai package's search. Unlike Game.DoAction, which returns a
+// new Game with every derived field calculated (e.g. all of its actions),
+// MakeMove updates the position in place and UnmakeMove takes the move back,
+// and legal actions are only generated when asked for.
+//
+// The zero value isn't usable: create positions with NewPosition.
+type Position struct {
+	// g holds the board and the state of the position. Its derived fields (e.g.
+	// Actions, IsCheck or IsGameOver) aren't maintained.
+	g Game
+	// root is the game the position was created from.
+	root Game
+	// states has one entry per made move, to unmake it.
+	states []positionState
+	// history holds the hashes of the positions reached, most recent last, and
+	// historyStart is the index of the first one since the last irreversible move.
+	history      []uint64
+	historyStart int
+	// exploded holds the pieces that Atomic's explosions removed, to put them back
+	// when unmaking their moves.
+	exploded []Piece
+}
+
+// positionState is what UnmakeMove needs to restore a position, besides the
+// pieces that the move moved.
+type positionState struct {
+	action                  Action
+	canWhiteKingsideCastle  bool
+	canWhiteQueensideCastle bool
+	canBlackKingsideCastle  bool
+	canBlackQueensideCastle bool
+	halfMoveClock           int
+	fullMoveNumber          int
+	isLastMoveEnPassant     bool
+	enPassantTargetSquare   XY
+	isDrawOffered           bool
+	drawOfferedBy           color
+	checksGiven             [2]int
+	pockets                 [2][7]int
+	promoted                uint64
+	historyStart            int
+	exploded                int
+}
+
+// NewPosition creates a position from the given game, including its position
+// history, so that repetitions of earlier positions are detected.
+func NewPosition(g Game) *Position {
+	p := &Position{g: g.shallowCloneForMove(), root: g}
+	p.history = append(p.history, g.positionHistory...)
+	if len(p.history) == 0 || p.history[len(p.history)-1] != g.Hash() {
+		p.history = append(p.history, g.Hash())
+	}
+	return p
+}
+
+// MakeMove does the given action, which must be one of the position's legal
+// actions, in place. Actions that aren't moves (see Action.IsMove) aren't
+// supported.
+func (p *Position) MakeMove(a Action) {
+	p.states = append(p.states, positionState{
+		action:                  a,
+		canWhiteKingsideCastle:  p.g.CanWhiteKingsideCastle,
+		canWhiteQueensideCastle: p.g.CanWhiteQueensideCastle,
+		canBlackKingsideCastle:  p.g.CanBlackKingsideCastle,
+		canBlackQueensideCastle: p.g.CanBlackQueensideCastle,
+		halfMoveClock:           p.g.HalfMoveClock,
+		fullMoveNumber:          p.g.FullMoveNumber,
+		isLastMoveEnPassant:     p.g.IsLastMoveEnPassant,
+		enPassantTargetSquare:   p.g.EnPassantTargetSquare,
+		isDrawOffered:           p.g.IsDrawOffered,
+		drawOfferedBy:           p.g.DrawOfferedBy,
+		checksGiven:             p.g.checksGiven,
+		pockets:                 p.g.pockets,
+		promoted:                p.g.promoted,
+		historyStart:            p.historyStart,
+		exploded:                len(p.exploded),
+	})
+	p.g.movePieces(a)
+	for sqs := p.g.explosion(a); sqs != 0; sqs &= sqs - 1 {
+		p.exploded = append(p.exploded, p.g.pieceAtSq(bits.TrailingZeros64(sqs)))
+	}
+	p.g.updateState(a)
+	if p.g.HalfMoveClock == 0 {
+		p.historyStart = len(p.history)
+	}
+	p.history = append(p.history, p.g.Hash())
+}
+
+// UnmakeMove takes back the last move made with MakeMove. It's a no-op if there's
+// none.
+func (p *Position) UnmakeMove() {
+	if len(p.states) == 0 {
+		return
+	}
+	s := p.states[len(p.states)-1]
+	p.states = p.states[:len(p.states)-1]
+	p.history = p.history[:len(p.history)-1]
+	p.historyStart = s.historyStart
+
+	for _, piece := range p.exploded[s.exploded:] {
+		p.g.setSq(piece.Owner, piece.PieceType, sqOf(piece.XY))
+	}
+	p.exploded = p.exploded[:s.exploded]
+	p.g.unmovePieces(s.action)
+	p.g.MoveNumber--
+	p.g.CanWhiteKingsideCastle, p.g.CanWhiteQueensideCastle = s.canWhiteKingsideCastle, s.canWhiteQueensideCastle
+	p.g.CanBlackKingsideCastle, p.g.CanBlackQueensideCastle = s.canBlackKingsideCastle, s.canBlackQueensideCastle
+	p.g.CanWhiteCastle = p.g.CanWhiteKingsideCastle || p.g.CanWhiteQueensideCastle
+	p.g.CanBlackCastle = p.g.CanBlackKingsideCastle || p.g.CanBlackQueensideCastle
+	p.g.HalfMoveClock, p.g.FullMoveNumber = s.halfMoveClock, s.fullMoveNumber
+	p.g.IsLastMoveEnPassant, p.g.EnPassantTargetSquare = s.isLastMoveEnPassant, s.enPassantTargetSquare
+	p.g.IsDrawOffered, p.g.DrawOfferedBy = s.isDrawOffered, s.drawOfferedBy
+	p.g.checksGiven = s.checksGiven
+	p.g.pockets, p.g.promoted = s.pockets, s.promoted
+}
+
+// Ply returns the number of moves made (and not unmade) since NewPosition.
+func (p *Position) Ply() int {
+	return len(p.states)
+}
+
+// Turn returns the color of the side to move.
+func (p *Position) Turn() Color {
+	return p.g.Turn()
+}
+
+// Hash returns the Zobrist hash of the position, as Game.Hash does. It's updated
+// incrementally by MakeMove and UnmakeMove.
+func (p *Position) Hash() uint64 {
+	return p.history[len(p.history)-1]
+}
+
+// HalfMoveClock returns the number of moves since the last capture or pawn move.
+func (p *Position) HalfMoveClock() int {
+	return p.g.HalfMoveClock
+}
+
+// PieceCount returns the number of pieces on the board, kings included.
+func (p *Position) PieceCount() int {
+	return bits.OnesCount64(p.g.occAll())
+}
+
+// IsCheck returns whether the side to move is in check.
+func (p *Position) IsCheck() bool {
+	return p.g.checkers(p.g.Turn()) != 0
+}
+
+// IsDraw returns whether the position is drawn regardless of its actions, as
+// Game.IsDraw: by the 75-move rule, fivefold repetition or insufficient material.
+// Checkmate and stalemate are up to the caller, as they require generating the
+// legal actions.
+func (p *Position) IsDraw() bool {
+	return p.g.HalfMoveClock >= 150 || p.repetitionCount() >= 5 || p.g.Variant().isInsufficientMaterial(p.g)
+}
+
+// VariantEnd returns whether the rules of the position's variant, or its
+// validation policy, ended the game, as Game.IsVariantEnd, with its winner, or -1
+// if it's a draw. It's never true in standard chess with both kings.
+func (p *Position) VariantEnd() (bool, Color) {
+	return p.g.outcome()
+}
+
+// repetitionCount returns how many times the current position has occurred since
+// the last irreversible move.
+func (p *Position) repetitionCount() int {
+	current, count := p.Hash(), 0
+	for _, h := range p.history[p.historyStart:] {
+		if h == current {
+			count++
+		}
+	}
+	return count
+}
+
+// LegalActions returns the legal actions of the side to move, as in Game.Actions
+// but only its moves (see Action.IsMove).
+func (p *Position) LegalActions() []Action {
+	return p.AppendLegalActions(make([]Action, 0, 64))
+}
+
+// AppendLegalActions appends the legal actions of the side to move to the given
+// slice, so that callers can reuse it to avoid allocations. There are none if the
+// rules of the position's variant ended the game (see VariantEnd).
+func (p *Position) AppendLegalActions(actions []Action) []Action {
+	if isEnd, _ := p.VariantEnd(); isEnd {
+		return actions
+	}
+	return p.g.appendMoves(actions)
+}
+
+// HasLegalActions returns whether the side to move has any legal action, which is
+// cheaper than generating all of them.
+func (p *Position) HasLegalActions() bool {
+	if isEnd, _ := p.VariantEnd(); isEnd {
+		return false
+	}
+	var buf [16]Action
+	for occ := p.g.occ[p.g.Turn()]; occ != 0; occ &= occ - 1 {
+		if len(p.g.pieceAtSq(bits.TrailingZeros64(occ)).appendActions(buf[:0], p.g)) > 0 {
+			return true
+		}
+	}
+	if p.g.variant != nil && p.g.variant.hasPockets() {
+		return len(p.g.appendDropActions(buf[:0])) > 0
+	}
+	return false
+}
+
+// Snapshot returns the position as a Game, but without its derived fields: it
+// has its board, side to move, castling rights, en passant target square and
+// clocks, while e.g. Actions and IsGameOver aren't calculated, and IsCheck is the
+// only flag set. It's cheap, e.g. to evaluate the position.
+func (p *Position) Snapshot() Game {
+	g := p.g
+	g.IsCheck = p.IsCheck()
+	return g
+}
+
+// Game returns the position as a Game, with every field calculated as if the
+// moves were done with Game.DoAction.
+func (p *Position) Game() Game {
+	if len(p.states) == 0 {
+		return p.root
+	}
+	g := p.g
+	g.positionHistory = append([]uint64(nil), p.history[p.historyStart:]...)
+	return g.calculateCriticalFlags().withCheckKinds(p.states[len(p.states)-1].action)
+}
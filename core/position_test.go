@@ -0,0 +1,113 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPosition_MakeUnmakeMove(t *testing.T) {
+	ts := []struct {
+		name string
+		fen  string
+	}{
+		{name: "kiwipete", fen: "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1"},
+		{name: "en passant and promotions", fen: "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1"},
+		{name: "black to move, en passant", fen: "rnbqkbnr/ppp1pppp/8/8/3pP3/5N2/PPPP1PPP/RNBQKB1R b KQkq e3 0 3"},
+		{name: "chess960, king lands on the rook's square", fen: "1r4kr/8/8/8/8/8/8/1R4KR w HBhb - 0 1"},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			g := mustGameFromFEN(t, tc.fen)
+			p := NewPosition(g)
+			snapshot := p.Snapshot()
+			require.Equal(t, nonTerminalActions(g.Actions), p.LegalActions())
+
+			// Two plies deep, every made move must match DoAction, and every unmade
+			// move must restore the position
+			for _, a := range p.LegalActions() {
+				p.MakeMove(a)
+				expected := g.DoAction(a)
+				require.Equal(t, expected, p.Game(), "after %v", a)
+				require.Equal(t, expected.Hash(), p.Hash())
+
+				for _, reply := range p.LegalActions() {
+					p.MakeMove(reply)
+					require.Equal(t, expected.DoAction(reply), p.Game(), "after %v %v", a, reply)
+					p.UnmakeMove()
+				}
+
+				p.UnmakeMove()
+				require.Equal(t, snapshot, p.Snapshot(), "after unmaking %v", a)
+				require.Equal(t, g.Hash(), p.Hash())
+			}
+			assert.Equal(t, g, p.Game())
+			assert.Equal(t, 0, p.Ply())
+		})
+	}
+}
+
+func TestPosition_GameOver(t *testing.T) {
+	ts := []struct {
+		name            string
+		fen             string
+		hasLegalActions bool
+		isCheck         bool
+		isDraw          bool
+	}{
+		{name: "checkmate", fen: "3k4/3Q4/3K4/8/8/8/8/8 b - - 0 1", isCheck: true},
+		{name: "stalemate", fen: "k7/2Q5/1K6/8/8/8/8/8 b - - 0 1"},
+		{name: "insufficient material", fen: "4k3/8/8/8/8/8/8/3NK3 w - - 0 1", hasLegalActions: true, isDraw: true},
+		{name: "75-move rule", fen: "4k3/8/8/8/8/8/8/3RK3 w - - 150 100", hasLegalActions: true, isDraw: true},
+		{name: "check", fen: "4k3/8/8/8/8/8/8/4RK2 b - - 0 1", hasLegalActions: true, isCheck: true},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewPosition(mustGameFromFEN(t, tc.fen))
+			assert.Equal(t, tc.hasLegalActions, p.HasLegalActions())
+			assert.Equal(t, tc.hasLegalActions, len(p.LegalActions()) > 0)
+			assert.Equal(t, tc.isCheck, p.IsCheck())
+			assert.Equal(t, tc.isDraw, p.IsDraw())
+		})
+	}
+}
+
+func TestPosition_Repetition(t *testing.T) {
+	g := mustGameFromFEN(t, "4k3/8/8/8/8/8/8/R3K1N1 w - - 0 1")
+	p := NewPosition(g)
+	shuffle := func() {
+		for _, move := range [][2]XY{{{6, 7}, {5, 5}}, {{4, 0}, {3, 0}}, {{5, 5}, {6, 7}}, {{3, 0}, {4, 0}}} {
+			for _, a := range p.LegalActions() {
+				if a.FromPiece.XY == move[0] && a.ToXY == move[1] {
+					p.MakeMove(a)
+					g = g.DoAction(a)
+					break
+				}
+			}
+		}
+	}
+	for i := 1; i < 4; i++ {
+		shuffle()
+		assert.False(t, p.IsDraw(), "repeated %d times", i+1)
+	}
+	shuffle()
+	assert.True(t, p.IsDraw(), "fivefold repetition")
+	assert.True(t, g.IsDraw)
+	assert.Equal(t, g, p.Game())
+	assert.Equal(t, 16, p.Ply())
+
+	// A history from an earlier game counts too
+	p = NewPosition(g)
+	assert.True(t, p.IsDraw())
+}
+
+func nonTerminalActions(actions []Action) []Action {
+	var out []Action
+	for _, a := range actions {
+		if a.IsMove() {
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,331 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WDL is the outcome of a position with perfect play, from the point of view of
+// the side to move, as stored in Syzygy tablebases. Cursed wins and blessed losses
+// are wins and losses that the 50-move rule turns into draws.
+type WDL int
+
+const (
+	WDLLoss        WDL = -2
+	WDLBlessedLoss WDL = -1
+	WDLDraw        WDL = 0
+	WDLCursedWin   WDL = 1
+	WDLWin         WDL = 2
+)
+
+func (w WDL) String() string {
+	switch w {
+	case WDLLoss:
+		return "Loss"
+	case WDLBlessedLoss:
+		return "BlessedLoss"
+	case WDLDraw:
+		return "Draw"
+	case WDLCursedWin:
+		return "CursedWin"
+	case WDLWin:
+		return "Win"
+	}
+	return fmt.Sprintf("WDL(%d)", int(w))
+}
+
+// DTZBeforeZeroing returns the DTZ of a position of this WDL whose best move
+// zeroes the 50-move counter, i.e. a capture or a pawn move.
+func (w WDL) DTZBeforeZeroing() int {
+	switch w {
+	case WDLWin:
+		return 1
+	case WDLCursedWin:
+		return 101
+	case WDLBlessedLoss:
+		return -101
+	case WDLLoss:
+		return -1
+	}
+	return 0
+}
+
+// TablebaseResult is the outcome of a position according to a tablebase.
+type TablebaseResult struct {
+	WDL WDL
+	// DTZ is the distance to zeroing the 50-move counter (by a capture or a pawn
+	// move) with perfect play, in plies. See Tablebase.ProbeDTZ.
+	DTZ int
+}
+
+// ErrNotInTablebase is returned when probing a position that a Tablebase doesn't
+// cover: one with too many pieces, with castling rights, or whose table is missing.
+var ErrNotInTablebase = errors.New("position not in tablebase")
+
+// Tablebase is a set of Syzygy endgame tablebases, which tell the outcome (WDL)
+// and the distance to zeroing (DTZ) of every position with up to 7 pieces. Tables
+// are read into memory when first probed. It's safe for concurrent use.
+// https://www.chessprogramming.org/Syzygy_Bases
+type Tablebase struct {
+	wdl       map[string]*syzygyTable // by material, with either color first
+	dtz       map[string]*syzygyTable
+	maxPieces int
+}
+
+// OpenTablebase finds the Syzygy tables (.rtbw and .rtbz files) in the given
+// directories, separated by os.PathListSeparator as in PATH.
+func OpenTablebase(path string) (*Tablebase, error) {
+	tb := &Tablebase{wdl: map[string]*syzygyTable{}, dtz: map[string]*syzygyTable{}}
+	for _, dir := range filepath.SplitList(path) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			tables, isDTZ := tb.wdl, false
+			switch filepath.Ext(name) {
+			case syzygyWDLSuffix:
+			case syzygyDTZSuffix:
+				tables, isDTZ = tb.dtz, true
+			default:
+				continue
+			}
+			t, err := newSyzygyTable(filepath.Join(dir, name), strings.TrimSuffix(name, filepath.Ext(name)), isDTZ)
+			if err != nil {
+				return nil, err
+			}
+			tables[t.key], tables[t.key2] = t, t
+			if !isDTZ && t.pieceCount > tb.maxPieces {
+				tb.maxPieces = t.pieceCount
+			}
+		}
+	}
+	if len(tb.wdl) == 0 {
+		return nil, fmt.Errorf("no Syzygy WDL tables (%s files) found in %q", syzygyWDLSuffix, path)
+	}
+	return tb, nil
+}
+
+// MaxPieces returns the number of pieces of the largest WDL table, kings included.
+func (tb *Tablebase) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// ProbeTablebase returns the outcome of the game's position according to the
+// tablebase: its WDL and, if the DTZ tables are available, its DTZ; otherwise DTZ
+// is zero. Returns ErrNotInTablebase if the tablebase doesn't cover the position.
+func (g Game) ProbeTablebase(tb *Tablebase) (TablebaseResult, error) {
+	wdl, err := tb.ProbeWDL(g)
+	if err != nil {
+		return TablebaseResult{}, err
+	}
+	dtz, err := tb.ProbeDTZ(g)
+	if errors.Is(err, ErrNotInTablebase) {
+		return TablebaseResult{WDL: wdl}, nil
+	}
+	return TablebaseResult{WDL: wdl, DTZ: dtz}, err
+}
+
+// ProbeWDL returns the outcome of the game's position with perfect play, from the
+// point of view of the side to move. Only the WDL tables are read.
+func (tb *Tablebase) ProbeWDL(g Game) (WDL, error) {
+	if err := tb.covers(g); err != nil {
+		return WDLDraw, err
+	}
+	switch {
+	case g.IsCheckmate:
+		return WDLLoss, nil
+	case g.IsStalemate:
+		return WDLDraw, nil
+	}
+	wdl, _, err := tb.search(g, false)
+	return wdl, err
+}
+
+// ProbeDTZ returns the distance to zeroing the 50-move counter of the game's
+// position with perfect play, in plies, from the point of view of the side to move:
+//
+//	n < -100       loss, but a draw by the 50-move rule
+//	-100 <= n < -1 loss in n plies (with the 50-move counter at zero)
+//	-1             loss, the side to move is checkmated
+//	0              draw
+//	1 < n <= 100   win in n plies (with the 50-move counter at zero)
+//	100 < n        win, but a draw by the 50-move rule
+//
+// A DTZ may be one ply longer than the shortest one, as some tables store moves
+// rather than plies. Both the WDL and the DTZ tables are read.
+func (tb *Tablebase) ProbeDTZ(g Game) (int, error) {
+	if err := tb.covers(g); err != nil {
+		return 0, err
+	}
+	switch {
+	case g.IsCheckmate:
+		return -1, nil
+	case g.IsStalemate:
+		return 0, nil
+	}
+	return tb.probeDTZ(g)
+}
+
+// covers returns ErrNotInTablebase if the game's position can't be in the
+// tablebase, which is cheap to tell. The tables are of standard chess, so they
+// don't cover games of other variants, nor those without both kings.
+func (tb *Tablebase) covers(g Game) error {
+	if g.variant != nil || g.bb[ColorBlack][PieceKing] == 0 || g.bb[ColorWhite][PieceKing] == 0 || g.CanWhiteCastle || g.CanBlackCastle || bits.OnesCount64(g.occAll()) > tb.maxPieces {
+		return ErrNotInTablebase
+	}
+	return nil
+}
+
+// syzygyProbeState tells how to interpret the result of a Tablebase.search.
+type syzygyProbeState int
+
+const (
+	syzygyStateOK syzygyProbeState = iota
+	// syzygyStateZeroingBestMove means the best move zeroes the 50-move counter,
+	// so the DTZ tables can't be trusted for the position.
+	syzygyStateZeroingBestMove
+)
+
+// search returns the WDL of the game. Tables don't store positions where a capture
+// is the best move, as the capture's outcome is in a smaller table, so captures
+// (and pawn moves, when checkZeroingMoves) are searched, and the best of them and
+// the table's value is the position's WDL.
+func (tb *Tablebase) search(g Game, checkZeroingMoves bool) (WDL, syzygyProbeState, error) {
+	var (
+		best       = WDLLoss
+		moveCount  = 0
+		totalCount = 0
+	)
+	for _, a := range g.Actions {
+		if !a.IsMove() {
+			continue
+		}
+		totalCount++
+		if !a.IsCapture && (!checkZeroingMoves || a.FromPiece.PieceType != PiecePawn) {
+			continue
+		}
+		moveCount++
+		value, _, err := tb.search(g.DoAction(a), false)
+		if err != nil {
+			return WDLDraw, syzygyStateOK, err
+		}
+		value = -value
+		if value > best {
+			best = value
+			if value >= WDLWin {
+				return value, syzygyStateZeroingBestMove, nil
+			}
+		}
+	}
+
+	// When every move was searched, tables may store a wrong value (e.g. they
+	// ignore en passant captures), so it's not probed
+	noMoreMoves := moveCount > 0 && moveCount == totalCount
+	value := best
+	if !noMoreMoves {
+		v, _, err := tb.probeTable(g, tb.wdl, WDLDraw)
+		if err != nil {
+			return WDLDraw, syzygyStateOK, err
+		}
+		value = WDL(v)
+	}
+	if best >= value {
+		if best > WDLDraw || noMoreMoves {
+			return best, syzygyStateZeroingBestMove, nil
+		}
+		return best, syzygyStateOK, nil
+	}
+	return value, syzygyStateOK, nil
+}
+
+// probeDTZ is ProbeDTZ, for a game with legal moves.
+func (tb *Tablebase) probeDTZ(g Game) (int, error) {
+	wdl, state, err := tb.search(g, true)
+	if err != nil || wdl == WDLDraw {
+		return 0, err
+	}
+	if state == syzygyStateZeroingBestMove {
+		return wdl.DTZBeforeZeroing(), nil
+	}
+
+	dtz, result, err := tb.probeTable(g, tb.dtz, wdl)
+	if err != nil {
+		return 0, err
+	}
+	if result != syzygyChangeSTM {
+		if wdl == WDLBlessedLoss || wdl == WDLCursedWin {
+			dtz += 100
+		}
+		return dtz * sign(int(wdl)), nil
+	}
+
+	// The table only stores the other side to move, so the DTZ is the best of the
+	// moves' DTZ plus one ply
+	minDTZ := 0xFFFF
+	for _, a := range g.Actions {
+		if !a.IsMove() {
+			continue
+		}
+		newGame := g.DoAction(a)
+		zeroing := a.IsCapture || a.FromPiece.PieceType == PiecePawn
+		switch {
+		case newGame.IsCheckmate:
+			dtz = 1
+		case zeroing:
+			// The DTZ before the zeroing move, with the outcome after it
+			w, _, err := tb.search(newGame, false)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -w.DTZBeforeZeroing()
+		default:
+			d, err := tb.probeDTZ(newGame)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -d + sign(-d)
+		}
+		if dtz == 1 && newGame.IsCheckmate {
+			minDTZ = 1
+		}
+		if dtz < minDTZ && sign(dtz) == sign(int(wdl)) {
+			minDTZ = dtz
+		}
+	}
+	if minDTZ == 0xFFFF {
+		return -1, nil
+	}
+	return minDTZ, nil
+}
+
+// probeTable returns the value that the table of the game's material, among the
+// given ones, stores for the game.
+func (tb *Tablebase) probeTable(g Game, tables map[string]*syzygyTable, wdl WDL) (int, syzygyProbeResult, error) {
+	if bits.OnesCount64(g.occAll()) == 2 {
+		return int(WDLDraw), syzygyOK, nil // KvK
+	}
+	t, ok := tables[syzygyMaterialKey(g)]
+	if !ok {
+		return 0, syzygyOK, ErrNotInTablebase
+	}
+	if err := t.load(); err != nil {
+		return 0, syzygyOK, err
+	}
+	return t.probe(g, wdl)
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
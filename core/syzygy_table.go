@@ -0,0 +1,851 @@
+package core
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// This file reads Syzygy tables, following the reference prober by Ronald de Man
+// (https://github.com/syzygy1/tb) as ported to Stockfish. Squares, pieces and
+// colors are the tables', not the Game's: square 0 is a1 and square 7 is h1, white
+// pieces are 1 (pawn) to 6 (king) and black pieces are the same plus 8.
+
+// syzygyMaxPieces is the largest number of pieces of a Syzygy table.
+const syzygyMaxPieces = 7
+
+const (
+	syzygyWDLSuffix = ".rtbw"
+	syzygyDTZSuffix = ".rtbz"
+)
+
+var (
+	syzygyWDLMagic = [4]byte{0x71, 0xE8, 0x23, 0x5D}
+	syzygyDTZMagic = [4]byte{0xD7, 0x66, 0x0C, 0xA5}
+)
+
+// Flags of a syzygyPairs.
+const (
+	syzygyFlagSTM         = 1
+	syzygyFlagMapped      = 2
+	syzygyFlagWinPlies    = 4
+	syzygyFlagLossPlies   = 8
+	syzygyFlagWide        = 16
+	syzygyFlagSingleValue = 128
+)
+
+// syzygyPieceType is the table's number of each piece type, for white.
+var syzygyPieceType = [7]uint8{PiecePawn: 1, PieceKnight: 2, PieceBishop: 3, PieceRook: 4, PieceQueen: 5, PieceKing: 6}
+
+// syzygyPieceLetters are the piece types in the order of table names (e.g. KRPvKR).
+var syzygyPieceLetters = []struct {
+	letter byte
+	t      PieceType
+}{{'K', PieceKing}, {'Q', PieceQueen}, {'R', PieceRook}, {'B', PieceBishop}, {'N', PieceKnight}, {'P', PiecePawn}}
+
+// Lookup tables of the position indexing, see init.
+var (
+	syzygyMapB1H1H7     [64]int
+	syzygyMapA1D1D4     [64]int
+	syzygyMapKK         [10][64]int
+	syzygyBinomial      [6][64]uint64
+	syzygyMapPawns      [64]int
+	syzygyLeadPawnIdx   [6][64]uint64
+	syzygyLeadPawnsSize [6][4]uint64
+)
+
+// syzygyPairs is the data of one of a table's subtables, which are compressed with
+// "Recursive Pairing" and a canonical Huffman code. Offsets are relative to the
+// start of the table's file.
+type syzygyPairs struct {
+	flags           uint8
+	pieces          [syzygyMaxPieces]uint8
+	groupLen        [syzygyMaxPieces + 1]int
+	groupIdx        [syzygyMaxPieces + 1]uint64
+	sizeofBlock     uint64
+	span            uint64
+	sparseIndexSize uint64
+	numBlocks       uint64
+	blockLengthSize uint64
+	maxSymLen       int
+	minSymLen       int
+	lowestSym       int // offset of the lowest symbol of each length, as uint16s
+	base64          []uint64
+	symlen          []uint8
+	btree           int // offset of the symbol pairs, as 3 bytes each
+	sparseIndex     int // offset of the sparse index, as 6 bytes each
+	blockLength     int // offset of the block lengths, as uint16s
+	data            int // offset of the compressed blocks
+	mapIdx          [4]int
+}
+
+// syzygyTable is a WDL or DTZ table of some material, e.g. KRvK. It's read on
+// first use.
+type syzygyTable struct {
+	path  string
+	isDTZ bool
+	// key is the table's material with white as the stronger side, and key2 the
+	// same material with the colors swapped. They're equal for symmetric material.
+	key, key2       string
+	pieceCount      int
+	hasPawns        bool
+	hasUniquePieces bool
+	pawnCount       [2]int // pawns of the leading color, and of the other one
+
+	once    sync.Once
+	err     error
+	data    []byte
+	mapping int // offset of the DTZ values map
+	pairs   [2][4]syzygyPairs
+}
+
+func init() {
+	offA1H8 := func(sq int) int { return sq>>3 - sq&7 }
+
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offA1H8(sq) < 0 {
+			syzygyMapB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	var diagonal []int
+	code = 0
+	for sq := 0; sq <= 27; sq++ { // a1 to d4
+		switch {
+		case offA1H8(sq) < 0 && sq&7 <= 3:
+			syzygyMapA1D1D4[sq] = code
+			code++
+		case offA1H8(sq) == 0 && sq&7 <= 3:
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		syzygyMapA1D1D4[sq] = code
+		code++
+	}
+
+	// The 462 legal placements of two kings with the first one in the a1-d1-d4
+	// triangle, and the second one below the a1-h8 diagonal if the first is on it
+	type kings struct{ idx, sq int }
+	var bothOnDiagonal []kings
+	code = 0
+	for idx := 0; idx < 10; idx++ {
+		for sq1 := 0; sq1 <= 27; sq1++ {
+			if syzygyMapA1D1D4[sq1] != idx || (idx == 0 && sq1 != 1) { // b1 is 0
+				continue
+			}
+			for sq2 := 0; sq2 < 64; sq2++ {
+				switch {
+				case abs(sq1&7-sq2&7) <= 1 && abs(sq1>>3-sq2>>3) <= 1:
+					continue // adjacent kings
+				case offA1H8(sq1) == 0 && offA1H8(sq2) > 0:
+					continue
+				case offA1H8(sq1) == 0 && offA1H8(sq2) == 0:
+					bothOnDiagonal = append(bothOnDiagonal, kings{idx, sq2})
+				default:
+					syzygyMapKK[idx][sq2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, k := range bothOnDiagonal {
+		syzygyMapKK[k.idx][k.sq] = code
+		code++
+	}
+
+	syzygyBinomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				syzygyBinomial[k][n] += syzygyBinomial[k-1][n-1]
+			}
+			if k < n {
+				syzygyBinomial[k][n] += syzygyBinomial[k][n-1]
+			}
+		}
+	}
+
+	// MapPawns encodes a2-h7 so that the leading pawn, the one nearest the edge
+	// and lowest among those, has the highest value
+	availableSquares := 47
+	for leadPawnsCnt := 1; leadPawnsCnt <= 5; leadPawnsCnt++ {
+		for file := 0; file < 4; file++ {
+			var idx uint64
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				if leadPawnsCnt == 1 {
+					syzygyMapPawns[sq] = availableSquares
+					availableSquares--
+					syzygyMapPawns[sq^7] = availableSquares
+					availableSquares--
+				}
+				syzygyLeadPawnIdx[leadPawnsCnt][sq] = idx
+				idx += syzygyBinomial[leadPawnsCnt-1][syzygyMapPawns[sq]]
+			}
+			syzygyLeadPawnsSize[leadPawnsCnt][file] = idx
+		}
+	}
+}
+
+// newSyzygyTable creates the table of the material of a file name, e.g. KRvK.
+func newSyzygyTable(path, name string, isDTZ bool) (*syzygyTable, error) {
+	sides := strings.Split(name, "v")
+	if len(sides) != 2 {
+		return nil, fmt.Errorf("invalid Syzygy table name %q", name)
+	}
+	var counts [2][7]int // by table color (0 is white) and piece type
+	for i, side := range sides {
+		if !strings.HasPrefix(side, "K") || strings.Count(side, "K") != 1 {
+			return nil, fmt.Errorf("invalid Syzygy table name %q", name)
+		}
+		for j := 0; j < len(side); j++ {
+			k := strings.IndexByte("KQRBNP", side[j])
+			if k < 0 {
+				return nil, fmt.Errorf("invalid Syzygy table name %q", name)
+			}
+			counts[i][syzygyPieceLetters[k].t]++
+		}
+	}
+	t := &syzygyTable{
+		path:       path,
+		isDTZ:      isDTZ,
+		key:        name,
+		key2:       sides[1] + "v" + sides[0],
+		pieceCount: len(sides[0]) + len(sides[1]),
+		hasPawns:   counts[0][PiecePawn]+counts[1][PiecePawn] > 0,
+	}
+	if t.pieceCount > syzygyMaxPieces {
+		return nil, fmt.Errorf("invalid Syzygy table name %q: too many pieces", name)
+	}
+	for _, c := range counts {
+		for pt, n := range c {
+			if pt != PieceKing && n == 1 {
+				t.hasUniquePieces = true
+			}
+		}
+	}
+	// The leading color is the one with fewer pawns, but at least one, as that
+	// compresses better
+	whitePawns, blackPawns := counts[0][PiecePawn], counts[1][PiecePawn]
+	if blackPawns == 0 || (whitePawns > 0 && blackPawns >= whitePawns) {
+		t.pawnCount = [2]int{whitePawns, blackPawns}
+	} else {
+		t.pawnCount = [2]int{blackPawns, whitePawns}
+	}
+	return t, nil
+}
+
+// syzygyMaterialKey returns the name of the table of the game's material, with
+// white's pieces first, e.g. KRvK or KvKR.
+func syzygyMaterialKey(g Game) string {
+	var sb strings.Builder
+	for i, c := range [2]color{ColorWhite, ColorBlack} {
+		if i == 1 {
+			sb.WriteByte('v')
+		}
+		for _, p := range syzygyPieceLetters {
+			for n := bits.OnesCount64(g.bb[c][p.t]); n > 0; n-- {
+				sb.WriteByte(p.letter)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// pairsFor returns the subtable of the given side to move and leading pawn file.
+func (t *syzygyTable) pairsFor(stm, file int) *syzygyPairs {
+	if t.isDTZ {
+		stm = 0
+	}
+	if !t.hasPawns {
+		file = 0
+	}
+	return &t.pairs[stm][file]
+}
+
+// load reads and parses the table's file, once.
+func (t *syzygyTable) load() error {
+	t.once.Do(func() {
+		data, err := os.ReadFile(t.path)
+		if err != nil {
+			t.err = err
+			return
+		}
+		magic := syzygyWDLMagic
+		if t.isDTZ {
+			magic = syzygyDTZMagic
+		}
+		if len(data) < 5 || [4]byte(data[:4]) != magic {
+			t.err = fmt.Errorf("%s: not a Syzygy table", t.path)
+			return
+		}
+		t.data = data
+		t.err = t.parse()
+	})
+	return t.err
+}
+
+var errSyzygyCorrupt = errors.New("corrupt Syzygy table")
+
+// recoverOutOfRange turns the panic of reading the table's data out of range into
+// errSyzygyCorrupt, as the offsets and sizes come from the file, so a corrupt one
+// reads out of range. Any other panic is a bug, and isn't recovered.
+func (t *syzygyTable) recoverOutOfRange(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if re, ok := r.(runtime.Error); !ok || !strings.Contains(re.Error(), "out of range") {
+		panic(r)
+	}
+	*err = fmt.Errorf("%s: %w", t.path, errSyzygyCorrupt)
+}
+
+// parse reads the table's header, i.e. everything but the compressed blocks.
+func (t *syzygyTable) parse() (err error) {
+	defer t.recoverOutOfRange(&err)
+	data := t.data
+	off := 5 // after the magic and the flags, which the table's name already tells
+
+	sides := 1
+	if !t.isDTZ && t.key != t.key2 {
+		sides = 2
+	}
+	maxFile := 0
+	if t.hasPawns {
+		maxFile = 3
+	}
+	pp := 0 // pawns on both sides
+	if t.hasPawns && t.pawnCount[1] > 0 {
+		pp = 1
+	}
+
+	for f := 0; f <= maxFile; f++ {
+		order := [2][2]int{{int(data[off] & 0xF), 0xF}, {int(data[off] >> 4), 0xF}}
+		if pp == 1 {
+			order[0][1], order[1][1] = int(data[off+1]&0xF), int(data[off+1]>>4)
+		}
+		off += 1 + pp
+		for k := 0; k < t.pieceCount; k, off = k+1, off+1 {
+			t.pairs[0][f].pieces[k] = data[off] & 0xF
+			if sides == 2 {
+				t.pairs[1][f].pieces[k] = data[off] >> 4
+			}
+		}
+		for i := 0; i < sides; i++ {
+			t.setGroups(&t.pairs[i][f], order[i], f)
+		}
+	}
+	off += off & 1
+
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			if off, err = t.pairs[i][f].setSizes(data, off); err != nil {
+				return fmt.Errorf("%s: %w", t.path, err)
+			}
+		}
+	}
+	if t.isDTZ {
+		off = t.setDTZMap(off, maxFile)
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			t.pairs[i][f].sparseIndex = off
+			off += int(t.pairs[i][f].sparseIndexSize) * 6
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			t.pairs[i][f].blockLength = off
+			off += int(t.pairs[i][f].blockLengthSize) * 2
+		}
+	}
+	for f := 0; f <= maxFile; f++ {
+		for i := 0; i < sides; i++ {
+			off = (off + 0x3F) &^ 0x3F
+			t.pairs[i][f].data = off
+			if size := int(t.pairs[i][f].numBlocks * t.pairs[i][f].sizeofBlock); size > 0 {
+				off += size
+				if off > len(data) {
+					return fmt.Errorf("%s: %w", t.path, errSyzygyCorrupt)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// setGroups splits the subtable's pieces into the groups they're indexed by, e.g.
+// KRvKN (3 unique leading pieces, then N) or KRRvK (2 leading kings, then RR), and
+// calculates the index multiplier of each group.
+func (t *syzygyTable) setGroups(d *syzygyPairs, order [2]int, file int) {
+	n := 0
+	firstLen := 2
+	switch {
+	case t.hasPawns:
+		firstLen = 0
+	case t.hasUniquePieces:
+		firstLen = 3
+	}
+	d.groupLen[n] = 1
+	for i := 1; i < t.pieceCount; i++ {
+		firstLen--
+		if firstLen > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	pp := t.hasPawns && t.pawnCount[1] > 0
+	next := 1
+	freeSquares := 64 - d.groupLen[0]
+	if pp {
+		next = 2
+		freeSquares -= d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]: // leading pawns or pieces
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= syzygyLeadPawnsSize[d.groupLen[0]][file]
+			case t.hasUniquePieces:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]: // remaining pawns
+			d.groupIdx[1] = idx
+			idx *= syzygyBinomial[d.groupLen[1]][48-d.groupLen[0]]
+		default: // remaining pieces
+			d.groupIdx[next] = idx
+			idx *= syzygyBinomial[d.groupLen[next]][freeSquares]
+			freeSquares -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+}
+
+// setSizes reads the subtable's compression parameters at off, and returns the
+// offset that follows them.
+func (d *syzygyPairs) setSizes(data []byte, off int) (int, error) {
+	d.flags = data[off]
+	off++
+	if d.flags&syzygyFlagSingleValue != 0 {
+		d.minSymLen = int(data[off]) // the single value
+		return off + 1, nil
+	}
+
+	tbSize := d.groupIdx[syzygyMaxPieces]
+	for i := 0; i < syzygyMaxPieces; i++ {
+		if d.groupLen[i] == 0 {
+			tbSize = d.groupIdx[i]
+			break
+		}
+	}
+	if data[off] >= 32 || data[off+1] >= 32 {
+		return 0, errSyzygyCorrupt // e.g. a zero span would divide by zero
+	}
+	d.sizeofBlock = 1 << data[off]
+	d.span = 1 << data[off+1]
+	d.sparseIndexSize = (tbSize + d.span - 1) / d.span
+	padding := uint64(data[off+2])
+	d.numBlocks = uint64(binary.LittleEndian.Uint32(data[off+3:]))
+	d.blockLengthSize = d.numBlocks + padding
+	d.maxSymLen = int(data[off+7])
+	d.minSymLen = int(data[off+8])
+	off += 9
+	d.lowestSym = off
+
+	// base64[i] is the lowest code of length minSymLen+i, left-aligned to 64 bits,
+	// so that longer codes have lower values
+	d.base64 = make([]uint64, d.maxSymLen-d.minSymLen+1)
+	for i := len(d.base64) - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowestSymAt(data, i)) - uint64(d.lowestSymAt(data, i+1))) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= uint(64 - i - d.minSymLen)
+	}
+	off += len(d.base64) * 2
+
+	d.symlen = make([]uint8, binary.LittleEndian.Uint16(data[off:]))
+	off += 2
+	d.btree = off
+	visited := make([]bool, len(d.symlen))
+	for sym := range d.symlen {
+		if !visited[sym] {
+			d.symlen[sym] = d.setSymlen(data, uint16(sym), visited)
+		}
+	}
+	return off + len(d.symlen)*3 + len(d.symlen)&1, nil
+}
+
+// setSymlen returns the number of values a symbol expands into, minus one.
+func (d *syzygyPairs) setSymlen(data []byte, sym uint16, visited []bool) uint8 {
+	visited[sym] = true
+	right := d.right(data, sym)
+	if right == 0xFFF {
+		return 0
+	}
+	left := d.left(data, sym)
+	if !visited[left] {
+		d.symlen[left] = d.setSymlen(data, left, visited)
+	}
+	if !visited[right] {
+		d.symlen[right] = d.setSymlen(data, right, visited)
+	}
+	return d.symlen[left] + d.symlen[right] + 1
+}
+
+func (d *syzygyPairs) lowestSymAt(data []byte, i int) uint16 {
+	return binary.LittleEndian.Uint16(data[d.lowestSym+2*i:])
+}
+
+// left and right return the pair of symbols a symbol expands into. A symbol with
+// no right symbol is a value, stored as its left symbol.
+func (d *syzygyPairs) left(data []byte, sym uint16) uint16 {
+	lr := data[d.btree+3*int(sym):]
+	return uint16(lr[1]&0xF)<<8 | uint16(lr[0])
+}
+
+func (d *syzygyPairs) right(data []byte, sym uint16) uint16 {
+	lr := data[d.btree+3*int(sym):]
+	return uint16(lr[2])<<4 | uint16(lr[1]>>4)
+}
+
+// setDTZMap reads the maps of a DTZ table's stored values to distances, per WDL
+// outcome, and returns the offset that follows them.
+func (t *syzygyTable) setDTZMap(off, maxFile int) int {
+	t.mapping = off
+	for f := 0; f <= maxFile; f++ {
+		d := t.pairsFor(0, f)
+		if d.flags&syzygyFlagMapped == 0 {
+			continue
+		}
+		if d.flags&syzygyFlagWide != 0 {
+			off += off & 1
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = (off-t.mapping)/2 + 1
+				off += 2*int(binary.LittleEndian.Uint16(t.data[off:])) + 2
+			}
+		} else {
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = off - t.mapping + 1
+				off += int(t.data[off]) + 1
+			}
+		}
+	}
+	return off + off&1
+}
+
+// decompress returns the value stored at the given index of the subtable.
+func (d *syzygyPairs) decompress(data []byte, idx uint64) int {
+	if d.flags&syzygyFlagSingleValue != 0 {
+		return d.minSymLen
+	}
+
+	// The sparse index points to a block near the one that holds idx, as blocks
+	// hold a variable number of values
+	k := idx / d.span
+	entry := data[d.sparseIndex+6*int(k):]
+	block := int(binary.LittleEndian.Uint32(entry))
+	offset := int(binary.LittleEndian.Uint16(entry[4:]))
+	offset += int(idx%d.span) - int(d.span/2)
+	blockLength := func(block int) int {
+		return int(binary.LittleEndian.Uint16(data[d.blockLength+2*block:]))
+	}
+	for offset < 0 {
+		block--
+		offset += blockLength(block) + 1
+	}
+	for offset > blockLength(block) {
+		offset -= blockLength(block) + 1
+		block++
+	}
+
+	// Read Huffman codes until the symbol that expands into the offset's value
+	ptr := d.data + block*int(d.sizeofBlock)
+	buf64 := bigEndianAt(data, ptr, 8)
+	ptr += 8
+	buf64Size := 64
+	var sym uint16
+	for {
+		length := 0
+		for buf64 < d.base64[length] {
+			length++
+		}
+		sym = uint16((buf64 - d.base64[length]) >> uint(64-length-d.minSymLen))
+		sym += d.lowestSymAt(data, length)
+		if offset < int(d.symlen[sym])+1 {
+			break
+		}
+		offset -= int(d.symlen[sym]) + 1
+		length += d.minSymLen
+		buf64 <<= uint(length)
+		buf64Size -= length
+		if buf64Size <= 32 {
+			buf64Size += 32
+			buf64 |= bigEndianAt(data, ptr, 4) << uint(64-buf64Size)
+			ptr += 4
+		}
+	}
+
+	// Expand the symbol's pairs down to the value
+	for d.symlen[sym] != 0 {
+		left := d.left(data, sym)
+		if offset < int(d.symlen[left])+1 {
+			sym = left
+		} else {
+			offset -= int(d.symlen[left]) + 1
+			sym = d.right(data, sym)
+		}
+	}
+	return int(d.left(data, sym))
+}
+
+// bigEndianAt returns the n bytes of data at off as a big-endian number, reading
+// zeros past its end: decompress reads ahead of the codes it decodes, which may be
+// past the end of a table's last block.
+func bigEndianAt(data []byte, off, n int) uint64 {
+	var b [8]byte
+	if off < len(data) {
+		copy(b[:n], data[off:])
+	}
+	return binary.BigEndian.Uint64(b[:]) >> uint(64-8*n)
+}
+
+// syzygyProbeResult is the outcome of probing a single table.
+type syzygyProbeResult int
+
+const (
+	syzygyOK syzygyProbeResult = iota
+	// syzygyChangeSTM means that a DTZ table only has the other side to move.
+	syzygyChangeSTM
+)
+
+// probe returns the value the table stores for the game, which must have the
+// table's material. For DTZ tables, wdl is the game's WDL.
+func (t *syzygyTable) probe(g Game, wdl WDL) (int, syzygyProbeResult, error) {
+	var (
+		squares   [syzygyMaxPieces]int
+		pieces    [syzygyMaxPieces]uint8
+		size      int
+		leadPawns uint64 // in the game's square layout
+		leadCount int
+		file      int
+	)
+
+	// A table only stores positions with the stronger side as white, and with white
+	// to move if the material is symmetric, so other positions are looked up with
+	// the colors swapped
+	key := syzygyMaterialKey(g)
+	flip := (t.key == t.key2 && g.Turn() == ColorBlack) || key != t.key
+	flipColor, flipSquares := uint8(0), 0
+	stm := 0
+	if g.Turn() == ColorBlack {
+		stm = 1
+	}
+	if flip {
+		flipColor, flipSquares = 8, 56
+		stm ^= 1
+	}
+	// tableSq converts a square of the game to the table's layout
+	tableSq := func(sq int) int { return sq ^ 56 ^ flipSquares }
+
+	if t.hasPawns {
+		// The first piece of a pawn table is a leading pawn
+		pc := t.pairs[0][0].pieces[0] ^ flipColor
+		c := color(ColorWhite)
+		if pc&8 != 0 {
+			c = ColorBlack
+		}
+		leadPawns = g.bb[c][PiecePawn]
+		for b := leadPawns; b != 0; b &= b - 1 {
+			squares[size] = tableSq(bits.TrailingZeros64(b))
+			size++
+		}
+		leadCount = size
+		best := 0
+		for i := 1; i < leadCount; i++ {
+			if syzygyMapPawns[squares[i]] > syzygyMapPawns[squares[best]] {
+				best = i
+			}
+		}
+		squares[0], squares[best] = squares[best], squares[0]
+		file = squares[0] & 7
+		if file > 3 {
+			file = 7 - file
+		}
+	}
+
+	d := t.pairsFor(stm, file)
+	if t.isDTZ && int(d.flags&syzygyFlagSTM) != stm && (t.key != t.key2 || t.hasPawns) {
+		return 0, syzygyChangeSTM, nil
+	}
+
+	for b := g.occAll() &^ leadPawns; b != 0; b &= b - 1 {
+		sq := bits.TrailingZeros64(b)
+		v := g.squares[sq]
+		pc := syzygyPieceType[v>>1]
+		if color(v&1) == ColorBlack {
+			pc |= 8
+		}
+		squares[size] = tableSq(sq)
+		pieces[size] = pc ^ flipColor
+		size++
+	}
+
+	// Reorder the pieces as in the table's piece sequence
+	for i := leadCount; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+
+	// Mirror the board so that the leading piece is on files a to d
+	if squares[0]&7 > 3 {
+		for i := 0; i < size; i++ {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx uint64
+	if t.hasPawns {
+		idx = syzygyLeadPawnIdx[leadCount][squares[0]]
+		rest := squares[1:leadCount]
+		sort.SliceStable(rest, func(i, j int) bool { return syzygyMapPawns[rest[i]] < syzygyMapPawns[rest[j]] })
+		for i := 1; i < leadCount; i++ {
+			idx += syzygyBinomial[i][syzygyMapPawns[squares[i]]]
+		}
+	} else {
+		idx = t.leadingPiecesIndex(squares[:size], d)
+	}
+
+	// Encode the remaining groups, each by the combination of its squares
+	idx *= d.groupIdx[0]
+	groupStart := d.groupLen[0]
+	remainingPawns := t.hasPawns && t.pawnCount[1] > 0
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[groupStart : groupStart+d.groupLen[next]]
+		sort.Ints(group)
+		var n uint64
+		for i, sq := range group {
+			adjust := 0
+			for _, s := range squares[:groupStart] {
+				if sq > s {
+					adjust++
+				}
+			}
+			if remainingPawns {
+				adjust += 8
+			}
+			n += syzygyBinomial[i+1][sq-adjust]
+		}
+		remainingPawns = false
+		idx += n * d.groupIdx[next]
+		groupStart += d.groupLen[next]
+	}
+
+	value, err := t.value(d, file, idx, wdl)
+	return value, syzygyOK, err
+}
+
+// value returns the value stored at the given index of the subtable of the given
+// leading pawn file: a WDL, or a DTZ given the position's WDL.
+func (t *syzygyTable) value(d *syzygyPairs, file int, idx uint64, wdl WDL) (value int, err error) {
+	defer t.recoverOutOfRange(&err)
+	value = d.decompress(t.data, idx)
+	if !t.isDTZ {
+		return value - 2, nil
+	}
+	return t.dtzValue(file, value, wdl), nil
+}
+
+// leadingPiecesIndex mirrors a pawnless position so that the leading piece is in
+// the a1-d1-d4 triangle, and returns the index of the leading group.
+func (t *syzygyTable) leadingPiecesIndex(squares []int, d *syzygyPairs) uint64 {
+	offA1H8 := func(sq int) int { return sq>>3 - sq&7 }
+	if squares[0]>>3 > 3 {
+		for i := range squares {
+			squares[i] ^= 56
+		}
+	}
+	// Mirror along the a1-h8 diagonal so that the first leading piece off it is
+	// below it
+	for i := 0; i < d.groupLen[0]; i++ {
+		if offA1H8(squares[i]) == 0 {
+			continue
+		}
+		if offA1H8(squares[i]) > 0 {
+			for j := i; j < len(squares); j++ {
+				squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+			}
+		}
+		break
+	}
+
+	if !t.hasUniquePieces {
+		return uint64(syzygyMapKK[syzygyMapA1D1D4[squares[0]]][squares[1]])
+	}
+	adjust1 := 0
+	if squares[1] > squares[0] {
+		adjust1 = 1
+	}
+	adjust2 := 0
+	if squares[2] > squares[0] {
+		adjust2++
+	}
+	if squares[2] > squares[1] {
+		adjust2++
+	}
+	switch {
+	case offA1H8(squares[0]) != 0:
+		return uint64((syzygyMapA1D1D4[squares[0]]*63+squares[1]-adjust1)*62 + squares[2] - adjust2)
+	case offA1H8(squares[1]) != 0:
+		return uint64((6*63+(squares[0]>>3)*28+syzygyMapB1H1H7[squares[1]])*62 + squares[2] - adjust2)
+	case offA1H8(squares[2]) != 0:
+		return uint64(6*63*62 + 4*28*62 + (squares[0]>>3)*7*28 + (squares[1]>>3-adjust1)*28 + syzygyMapB1H1H7[squares[2]])
+	default:
+		return uint64(6*63*62 + 4*28*62 + 4*7*28 + (squares[0]>>3)*7*6 + (squares[1]>>3-adjust1)*6 + squares[2]>>3 - adjust2)
+	}
+}
+
+// dtzValue converts a value stored in a DTZ table to plies.
+func (t *syzygyTable) dtzValue(file, value int, wdl WDL) int {
+	d := t.pairsFor(0, file)
+	if d.flags&syzygyFlagMapped != 0 {
+		i := d.mapIdx[[5]int{1, 3, 0, 2, 0}[wdl+2]] + value
+		if d.flags&syzygyFlagWide != 0 {
+			value = int(binary.LittleEndian.Uint16(t.data[t.mapping+2*i:]))
+		} else {
+			value = int(t.data[t.mapping+i])
+		}
+	}
+	// Tables store distances in moves unless they're flagged as plies
+	if (wdl == WDLWin && d.flags&syzygyFlagWinPlies == 0) ||
+		(wdl == WDLLoss && d.flags&syzygyFlagLossPlies == 0) ||
+		wdl == WDLCursedWin || wdl == WDLBlessedLoss {
+		value *= 2
+	}
+	return value + 1
+}
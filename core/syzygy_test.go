@@ -0,0 +1,289 @@
+package core
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Most of these tests use tables built by hand. TestProbeTablebase_RealTables
+// checks the indexing, the decompression and the DTZ mapping against complete
+// KQvK, KRvK, KPvK and KRvKP tables, .rtbw and .rtbz, in syzygyTestdata, with the
+// KBvK and KNvK ones under-promotions lead to. Its generate.go writes them.
+const syzygyTestdata = "testdata/syzygy"
+
+// writeSingleValueKQvK writes a KQvK WDL table that stores a win for every
+// position with white to move and a loss for every position with black to move.
+func writeSingleValueKQvK(t *testing.T, dir string) {
+	t.Helper()
+	data := append(syzygyWDLMagic[:],
+		0x01,             // split: one subtable per side to move
+		0x00,             // groups order of both subtables
+		0x55, 0x66, 0xEE, // pieces (wQ, wK, bK) of both subtables
+		0x00,    // alignment
+		0x80, 4, // white to move: single value, win
+		0x80, 0, // black to move: single value, loss
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "KQvK.rtbw"), data, 0o644))
+}
+
+func mustGameFromFEN(t *testing.T, fen string) Game {
+	t.Helper()
+	g, err := NewGameFromFEN(fen)
+	require.NoError(t, err)
+	return g
+}
+
+func TestProbeTablebase(t *testing.T) {
+	dir := t.TempDir()
+	writeSingleValueKQvK(t, dir)
+	tb, err := OpenTablebase(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, tb.MaxPieces())
+
+	ts := []struct {
+		name     string
+		fen      string
+		expected WDL
+		err      error
+	}{
+		{name: "stronger side to move", fen: "4k3/8/8/8/8/8/8/3QK3 w - - 0 1", expected: WDLWin},
+		{name: "weaker side to move", fen: "4k3/8/8/8/8/8/8/3QK3 b - - 0 1", expected: WDLLoss},
+		{name: "colors swapped, stronger side to move", fen: "3qk3/8/8/8/8/8/8/4K3 b - - 0 1", expected: WDLWin},
+		{name: "colors swapped, weaker side to move", fen: "3qk3/8/8/8/8/8/8/4K3 w - - 0 1", expected: WDLLoss},
+		{name: "the queen hangs: captures beat the table", fen: "8/8/8/8/8/4k3/3Q4/7K b - - 0 1", expected: WDLDraw},
+		{name: "checkmate", fen: "3k4/3Q4/3K4/8/8/8/8/8 b - - 0 1", expected: WDLLoss},
+		{name: "stalemate", fen: "k7/2Q5/1K6/8/8/8/8/8 b - - 0 1", expected: WDLDraw},
+		{name: "bare kings need no table", fen: "4k3/8/8/8/8/8/8/4K3 w - - 0 1", expected: WDLDraw},
+		{name: "missing table", fen: "4k3/8/8/8/8/8/8/3RK3 w - - 0 1", err: ErrNotInTablebase},
+		{name: "too many pieces", fen: "4k3/8/8/8/8/8/8/2RQK3 w - - 0 1", err: ErrNotInTablebase},
+		{name: "castling rights", fen: "4k3/8/8/8/8/8/8/R3K3 w Q - 0 1", err: ErrNotInTablebase},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			wdl, err := tb.ProbeWDL(mustGameFromFEN(t, tc.fen))
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, wdl)
+		})
+	}
+
+	t.Run("without DTZ tables", func(t *testing.T) {
+		result, err := mustGameFromFEN(t, "4k3/8/8/8/8/8/8/3QK3 w - - 0 1").ProbeTablebase(tb)
+		require.NoError(t, err)
+		assert.Equal(t, TablebaseResult{WDL: WDLWin}, result)
+
+		_, err = tb.ProbeDTZ(mustGameFromFEN(t, "4k3/8/8/8/8/8/8/3QK3 w - - 0 1"))
+		assert.ErrorIs(t, err, ErrNotInTablebase)
+	})
+}
+
+func TestProbeTablebase_RealTables(t *testing.T) {
+	tb, err := OpenTablebase(syzygyTestdata)
+	require.NoError(t, err)
+	require.Equal(t, 4, tb.MaxPieces())
+
+	// Values of a retrograde analysis of each endgame. The DTZ tables may store a
+	// DTZ one ply longer (see ProbeDTZ).
+	ts := []struct {
+		name string
+		fen  string
+		wdl  WDL
+		dtz  int
+	}{
+		{name: "KQvK mate in 1", fen: "4k3/8/4K3/8/8/8/8/7Q w - - 0 1", wdl: WDLWin, dtz: 1},
+		{name: "KQvK mated in 2", fen: "4k3/8/4K3/8/8/8/8/7Q b - - 0 1", wdl: WDLLoss, dtz: -4},
+		{name: "KQvK", fen: "4k3/8/8/8/8/8/8/3QK3 w - - 0 1", wdl: WDLWin, dtz: 15},
+		{name: "KQvK, weaker side to move", fen: "4k3/8/8/8/8/8/8/3QK3 b - - 0 1", wdl: WDLLoss, dtz: -16},
+		{name: "KQvK, colors swapped", fen: "3qk3/8/8/8/8/8/8/4K3 b - - 0 1", wdl: WDLWin, dtz: 15},
+		{name: "KQvK, the queen hangs", fen: "8/8/8/8/8/4k3/3Q4/7K b - - 0 1", wdl: WDLDraw},
+		{name: "KRvK", fen: "4k3/8/8/8/8/8/8/R3K3 w - - 0 1", wdl: WDLWin, dtz: 23},
+		{name: "KRvK, weaker side to move", fen: "4k3/8/8/8/8/8/8/R3K3 b - - 0 1", wdl: WDLLoss, dtz: -28},
+		{name: "KRvK, weaker king in the corner", fen: "7k/8/8/8/8/8/8/K6R b - - 0 1", wdl: WDLLoss, dtz: -26},
+		{name: "KPvK, king in front of the pawn", fen: "8/8/8/8/8/4k3/4P3/4K3 w - - 0 1", wdl: WDLDraw},
+		{name: "KPvK, opposition", fen: "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1", wdl: WDLDraw},
+		{name: "KPvK, the king leads the pawn", fen: "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", wdl: WDLWin, dtz: 9},
+		{name: "KPvK, promotion", fen: "8/4P3/8/8/8/8/8/k1K5 w - - 0 1", wdl: WDLWin, dtz: 1},
+		{name: "KPvK, under-promotions", fen: "8/6P1/8/8/8/8/8/k1K5 w - - 0 1", wdl: WDLWin, dtz: 1},
+		{name: "KPvK, promotion next", fen: "8/4P3/8/8/8/8/8/k1K5 b - - 0 1", wdl: WDLLoss, dtz: -2},
+		{name: "KRvKP, the best move is a capture", fen: "7k/8/8/8/8/8/p7/R5K1 w - - 0 1", wdl: WDLWin, dtz: 1},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := mustGameFromFEN(t, tc.fen).ProbeTablebase(tb)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wdl, result.WDL)
+			assert.Equal(t, sign(tc.dtz), sign(result.DTZ), "DTZ %d", result.DTZ)
+			if diff := result.DTZ*sign(tc.dtz) - tc.dtz*sign(tc.dtz); diff != 0 && diff != 1 {
+				assert.Equal(t, tc.dtz, result.DTZ)
+			}
+		})
+	}
+}
+
+func TestOpenTablebase_Errors(t *testing.T) {
+	_, err := OpenTablebase(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+
+	_, err = OpenTablebase(t.TempDir())
+	assert.ErrorContains(t, err, "no Syzygy WDL tables")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "KXvK.rtbw"), nil, 0o644))
+	_, err = OpenTablebase(dir)
+	assert.ErrorContains(t, err, "invalid Syzygy table name")
+
+	dir = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "KRvK.rtbw"), []byte("not a table"), 0o644))
+	tb, err := OpenTablebase(dir)
+	require.NoError(t, err)
+	_, err = tb.ProbeWDL(mustGameFromFEN(t, "4k3/8/8/8/8/8/8/3RK3 w - - 0 1"))
+	assert.ErrorContains(t, err, "not a Syzygy table")
+}
+
+func TestProbeTablebase_CorruptTable(t *testing.T) {
+	// The white to move subtable's sparse index, block lengths and blocks are
+	// missing
+	dir := t.TempDir()
+	data := append(syzygyWDLMagic[:],
+		0x01,             // split: one subtable per side to move
+		0x00,             // groups order of both subtables
+		0x55, 0x66, 0xEE, // pieces (wQ, wK, bK) of both subtables
+		0x00,                      // alignment
+		0x00, 5, 6, 0, 0, 0, 0, 0, // white to move: sizes, and no blocks
+		1, 1, 0, 0, // symbol lengths, and the lowest symbol of each
+		1, 0, 0x04, 0xF0, 0xFF, 0x00, // one symbol: the value 4 (a win)
+		0x80, 0, // black to move: single value, loss
+	)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "KQvK.rtbw"), data, 0o644))
+	tb, err := OpenTablebase(dir)
+	require.NoError(t, err)
+
+	_, err = tb.ProbeWDL(mustGameFromFEN(t, "4k3/8/8/8/8/8/8/3QK3 w - - 0 1"))
+	assert.ErrorIs(t, err, errSyzygyCorrupt)
+	wdl, err := tb.ProbeWDL(mustGameFromFEN(t, "4k3/8/8/8/8/8/8/3QK3 b - - 0 1"))
+	require.NoError(t, err)
+	assert.Equal(t, WDLLoss, wdl)
+
+	t.Run("other panics aren't recovered", func(t *testing.T) {
+		table := &syzygyTable{path: "KQvK.rtbw"}
+		assert.PanicsWithValue(t, "a bug", func() {
+			var err error
+			defer table.recoverOutOfRange(&err)
+			panic("a bug")
+		})
+	})
+}
+
+func TestWDL_DTZBeforeZeroing(t *testing.T) {
+	assert.Equal(t, 1, WDLWin.DTZBeforeZeroing())
+	assert.Equal(t, 101, WDLCursedWin.DTZBeforeZeroing(), "a win, but a draw by the 50-move rule")
+	assert.Equal(t, 0, WDLDraw.DTZBeforeZeroing())
+	assert.Equal(t, -101, WDLBlessedLoss.DTZBeforeZeroing())
+	assert.Equal(t, -1, WDLLoss.DTZBeforeZeroing())
+}
+
+func TestSyzygyMaterialKey(t *testing.T) {
+	assert.Equal(t, "KQvK", syzygyMaterialKey(mustGameFromFEN(t, "4k3/8/8/8/8/8/8/3QK3 w - - 0 1")))
+	assert.Equal(t, "KRPvKBN", syzygyMaterialKey(mustGameFromFEN(t, "2b1kn2/8/8/8/8/8/4P3/3RK3 w - - 0 1")))
+	assert.Equal(t, "KvKQ", syzygyMaterialKey(mustGameFromFEN(t, "3qk3/8/8/8/8/8/8/4K3 w - - 0 1")))
+}
+
+func TestNewSyzygyTable(t *testing.T) {
+	table, err := newSyzygyTable("KRPvKR.rtbz", "KRPvKR", true)
+	require.NoError(t, err)
+	assert.Equal(t, "KRPvKR", table.key)
+	assert.Equal(t, "KRvKRP", table.key2)
+	assert.Equal(t, 5, table.pieceCount)
+	assert.True(t, table.hasPawns)
+	assert.True(t, table.hasUniquePieces)
+	assert.Equal(t, [2]int{1, 0}, table.pawnCount)
+
+	table, err = newSyzygyTable("KPvKPP.rtbw", "KPvKPP", false)
+	require.NoError(t, err)
+	assert.Equal(t, [2]int{1, 2}, table.pawnCount, "the side with fewer pawns leads")
+
+	table, err = newSyzygyTable("KRRvK.rtbw", "KRRvK", false)
+	require.NoError(t, err)
+	assert.False(t, table.hasUniquePieces)
+}
+
+func TestSyzygyIndexTables(t *testing.T) {
+	// Two kings can be placed in 462 ways, up to symmetry
+	seen := map[int]bool{}
+	for idx := range syzygyMapKK {
+		for sq, code := range syzygyMapKK[idx] {
+			if code != 0 || (idx == 0 && sq == 0) {
+				seen[code] = true
+			}
+		}
+	}
+	assert.Len(t, seen, 462)
+
+	for sq := 8; sq < 56; sq++ {
+		assert.Less(t, syzygyMapPawns[sq], 48)
+	}
+	assert.Equal(t, 47, syzygyMapPawns[8], "a2 leads")
+	assert.Equal(t, uint64(6), syzygyLeadPawnsSize[1][0])
+	assert.Equal(t, uint64(1225), syzygyBinomial[2][50])
+}
+
+func TestSyzygyDecompress(t *testing.T) {
+	// Two one-bit symbols, 0 and 1, that are the values 0 and 4. Each 16-byte block
+	// holds 64 values, in its first 8 bytes, and the data ends right after the
+	// codes of the last one.
+	const (
+		blocks = 4
+		values = blocks * 64
+	)
+	value := func(idx int) int {
+		if idx%3 == 0 {
+			return 4
+		}
+		return 0
+	}
+	var data []byte
+	d := syzygyPairs{sizeofBlock: 16, span: 128, minSymLen: 1, maxSymLen: 1, base64: []uint64{0}, symlen: []uint8{0, 0}}
+	d.lowestSym = len(data)
+	data = append(data, 0, 0)
+	d.btree = len(data)
+	data = append(data, 0, 0xF0, 0xFF, 4, 0xF0, 0xFF)
+	d.sparseIndex = len(data)
+	for k := 0; k < values/128; k++ {
+		// The value at k*128+64 is in either representation
+		block, offset := 2*k+1, 0
+		if k%2 == 1 {
+			block, offset = 2*k, 64
+		}
+		data = binary.LittleEndian.AppendUint32(data, uint32(block))
+		data = binary.LittleEndian.AppendUint16(data, uint16(offset))
+	}
+	d.blockLength = len(data)
+	for b := 0; b < blocks; b++ {
+		data = binary.LittleEndian.AppendUint16(data, 63)
+	}
+	d.data = len(data)
+	for b := 0; b < blocks; b++ {
+		var bits uint64
+		for i := 0; i < 64; i++ {
+			if value(b*64+i) == 4 {
+				bits |= 1 << (63 - i)
+			}
+		}
+		data = binary.BigEndian.AppendUint64(data, bits)
+		if b < blocks-1 {
+			data = append(data, make([]byte, 8)...)
+		}
+	}
+
+	for idx := 0; idx < values; idx++ {
+		require.Equal(t, value(idx), d.decompress(data, uint64(idx)), "index %d", idx)
+	}
+}
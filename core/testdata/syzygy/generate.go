@@ -0,0 +1,1177 @@
+//go:build ignore
+
+// Command generate writes the Syzygy tables of this directory, KQvK, KRvK, KPvK and
+// KRvKP, and KBvK and KNvK, which under-promotions lead to, both WDL (.rtbw) and
+// DTZ (.rtbz), for core's tests. It takes some minutes:
+//
+//	go run generate.go
+//
+// It solves every endgame by retrograde analysis, down to those its captures and
+// promotions lead to (e.g. KRvKQ), and writes the tables in the format of the
+// reference prober (https://github.com/syzygy1/tb): positions indexed up to
+// symmetry, and values compressed with Re-Pair and canonical Huffman codes. It
+// doesn't share any code with core, so that the prober is tested against tables
+// it didn't write. The official tables of the same names, which are compressed
+// better, may replace these.
+//
+// DTZ tables store plies, or moves when all the distances of an outcome are odd.
+// There are no cursed wins nor blessed losses in these endgames.
+package main
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"log"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Pieces, as in Syzygy tables: white pieces are 1 (pawn) to 6 (king), and black
+// ones the same plus 8. Squares are a1 = 0 to h8 = 63.
+const (
+	pawn   = 1
+	knight = 2
+	bishop = 3
+	rook   = 4
+	queen  = 5
+	king   = 6
+	black  = 8
+)
+
+const pieceLetters = " PNBRQK"
+
+// Values of the WDL solution, from the side to move's point of view.
+const (
+	loss    int8 = -1
+	draw    int8 = 0
+	win     int8 = 1
+	unknown int8 = 2
+	invalid int8 = -128
+)
+
+// tables are the tables to write: their material, with the stronger side as
+// white, the sequence of their pieces (see syzygyPairs.pieces) and the side to
+// move their DTZ table stores.
+var tables = []struct {
+	name     string
+	pieces   []int
+	dtzBlack bool
+}{
+	{"KQvK", []int{queen, king, king | black}, false},
+	{"KRvK", []int{king, king | black, rook}, false},
+	{"KPvK", []int{pawn, king | black, king}, true},
+	{"KRvKP", []int{pawn | black, king, rook, king | black}, false},
+	{"KBvK", []int{king, bishop, king | black}, false},
+	{"KNvK", []int{king | black, king, knight}, false},
+}
+
+func main() {
+	for _, t := range tables {
+		m := solve(t.name)
+		m.solveDTZ()
+		stm := 0
+		if t.dtzBlack {
+			stm = 1
+		}
+		for _, isDTZ := range []bool{false, true} {
+			w := newTableWriter(m, t.pieces, isDTZ, stm)
+			name, ext := t.name, ".rtbw"
+			if isDTZ {
+				ext = ".rtbz"
+			}
+			if err := os.WriteFile(name+ext, w.bytes(), 0o644); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+}
+
+// Attacks.
+
+var kingAttacks, knightAttacks [64]uint64
+
+var (
+	rookDirections   = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	bishopDirections = [][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+)
+
+func init() {
+	for sq := 0; sq < 64; sq++ {
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}} {
+			kingAttacks[sq] |= step(sq, d[0], d[1])
+		}
+		for _, d := range [][2]int{{1, 2}, {2, 1}, {-1, 2}, {-2, 1}, {1, -2}, {2, -1}, {-1, -2}, {-2, -1}} {
+			knightAttacks[sq] |= step(sq, d[0], d[1])
+		}
+	}
+	initIndexing()
+}
+
+// step returns the square dx files and dy ranks away from sq, or nothing if it's
+// off the board.
+func step(sq, dx, dy int) uint64 {
+	x, y := sq&7+dx, sq>>3+dy
+	if x < 0 || x > 7 || y < 0 || y > 7 {
+		return 0
+	}
+	return 1 << (y*8 + x)
+}
+
+func slide(sq int, occ uint64, directions [][2]int) uint64 {
+	var b uint64
+	for _, d := range directions {
+		for x, y := sq&7+d[0], sq>>3+d[1]; x >= 0 && x <= 7 && y >= 0 && y <= 7; x, y = x+d[0], y+d[1] {
+			b |= 1 << (y*8 + x)
+			if occ&(1<<(y*8+x)) != 0 {
+				break
+			}
+		}
+	}
+	return b
+}
+
+// attacks returns the squares the piece attacks from sq.
+func attacks(piece, sq int, occ uint64) uint64 {
+	switch piece &^ black {
+	case pawn:
+		if piece&black != 0 {
+			return step(sq, -1, -1) | step(sq, 1, -1)
+		}
+		return step(sq, -1, 1) | step(sq, 1, 1)
+	case knight:
+		return knightAttacks[sq]
+	case bishop:
+		return slide(sq, occ, bishopDirections)
+	case rook:
+		return slide(sq, occ, rookDirections)
+	case queen:
+		return slide(sq, occ, bishopDirections) | slide(sq, occ, rookDirections)
+	}
+	return kingAttacks[sq]
+}
+
+// Positions.
+
+// position is a position of up to 4 pieces. Captured pieces are 0.
+type position struct {
+	n      int
+	pieces [4]int
+	sqs    [4]int
+	stm    int // 0 is white, 1 is black
+}
+
+func colorOf(piece int) int { return piece >> 3 }
+
+func (p *position) occ() uint64 {
+	var b uint64
+	for i := 0; i < p.n; i++ {
+		if p.pieces[i] != 0 {
+			b |= 1 << p.sqs[i]
+		}
+	}
+	return b
+}
+
+// at returns the index of the piece on sq, or -1.
+func (p *position) at(sq int) int {
+	for i := 0; i < p.n; i++ {
+		if p.pieces[i] != 0 && p.sqs[i] == sq {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p *position) inCheck(side int) bool {
+	occ := p.occ()
+	kingSq := -1
+	for i := 0; i < p.n; i++ {
+		if p.pieces[i] == king|side*black {
+			kingSq = p.sqs[i]
+		}
+	}
+	for i := 0; i < p.n; i++ {
+		if p.pieces[i] != 0 && colorOf(p.pieces[i]) != side && attacks(p.pieces[i], p.sqs[i], occ)&(1<<kingSq) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// valid reports whether the pieces are on different squares, there are no pawns
+// on the first or last ranks and the side not to move isn't in check.
+func (p *position) valid() bool {
+	var occ uint64
+	for i := 0; i < p.n; i++ {
+		if occ&(1<<p.sqs[i]) != 0 {
+			return false
+		}
+		occ |= 1 << p.sqs[i]
+		if p.pieces[i]&^black == pawn && (p.sqs[i] < 8 || p.sqs[i] >= 56) {
+			return false
+		}
+	}
+	return !p.inCheck(p.stm ^ 1)
+}
+
+// Materials.
+
+// material is an endgame, e.g. KRvKP, with its pieces in a fixed order: white's
+// and then black's, by type from king to pawn. Positions are indexed by the side
+// to move and the squares of the pieces. No endgame has two pieces of the same
+// color and type.
+type material struct {
+	name     string
+	pieces   []int
+	wdl      []int8
+	dtz      []int16
+	children map[[2]int]*material // by captured piece and promotion
+}
+
+var materials = map[string]*material{}
+
+func materialName(pieces []int) string {
+	var sides [2]string
+	for _, letter := range "KQRBNP" {
+		for _, piece := range pieces {
+			if pieceLetters[piece&^black] == byte(letter) {
+				sides[colorOf(piece)] += string(letter)
+			}
+		}
+	}
+	return sides[0] + "v" + sides[1]
+}
+
+// solve returns the material of the given name, whose positions' WDL is solved.
+func solve(name string) *material {
+	if m, ok := materials[name]; ok {
+		return m
+	}
+	m := &material{name: name, children: map[[2]int]*material{}}
+	for i, side := range strings.Split(name, "v") {
+		for _, letter := range side {
+			m.pieces = append(m.pieces, strings.IndexRune(pieceLetters, letter)|i*black)
+		}
+	}
+	m.solveWDL()
+	materials[name] = m
+	log.Printf("%s: solved", name)
+	return m
+}
+
+func (m *material) size() int { return 2 << (6 * len(m.pieces)) }
+
+func (m *material) position(idx int) position {
+	p := position{n: len(m.pieces), stm: idx >> (6 * len(m.pieces))}
+	for i := len(m.pieces) - 1; i >= 0; i-- {
+		p.pieces[i], p.sqs[i] = m.pieces[i], idx&63
+		idx >>= 6
+	}
+	return p
+}
+
+// index returns the index of a position with the material's pieces, in any order.
+func (m *material) index(p *position) int {
+	idx := p.stm
+	for _, piece := range m.pieces {
+		for i := 0; i < p.n; i++ {
+			if p.pieces[i] == piece {
+				idx = idx<<6 | p.sqs[i]
+				break
+			}
+		}
+	}
+	return idx
+}
+
+// child returns the material after capturing the given piece (or 0) and promoting
+// to the given piece (or 0).
+func (m *material) child(captured, promotion int) *material {
+	key := [2]int{captured, promotion}
+	if c, ok := m.children[key]; ok {
+		return c
+	}
+	var pieces []int
+	for _, piece := range m.pieces {
+		switch {
+		case piece == captured:
+		case promotion != 0 && piece == pawn|promotion&black:
+			pieces = append(pieces, promotion)
+		default:
+			pieces = append(pieces, piece)
+		}
+	}
+	c := solve(materialName(pieces))
+	m.children[key] = c
+	return c
+}
+
+// forEachMove calls f with the position after each legal move of p, its material
+// and whether the move zeroes the 50-move counter.
+func (m *material) forEachMove(p *position, f func(q *position, c *material, zeroing bool)) {
+	occ := p.occ()
+	var own uint64
+	for i := 0; i < p.n; i++ {
+		if p.pieces[i] != 0 && colorOf(p.pieces[i]) == p.stm {
+			own |= 1 << p.sqs[i]
+		}
+	}
+	try := func(i, to, promotion int, isPawn bool) {
+		q := *p
+		captured := 0
+		if j := q.at(to); j >= 0 {
+			captured, q.pieces[j] = q.pieces[j], 0
+		}
+		q.sqs[i] = to
+		if promotion != 0 {
+			q.pieces[i] = promotion
+		}
+		if q.inCheck(p.stm) {
+			return
+		}
+		q.stm ^= 1
+		c := m
+		if captured != 0 || promotion != 0 {
+			c = m.child(captured, promotion)
+		}
+		f(&q, c, isPawn || captured != 0)
+	}
+	for i := 0; i < p.n; i++ {
+		piece := p.pieces[i]
+		if piece == 0 || colorOf(piece) != p.stm {
+			continue
+		}
+		from := p.sqs[i]
+		if piece&^black != pawn {
+			for b := attacks(piece, from, occ) &^ own; b != 0; b &= b - 1 {
+				try(i, bits.TrailingZeros64(b), 0, false)
+			}
+			continue
+		}
+		forward, startRank, lastRank := 8, 1, 7
+		if piece&black != 0 {
+			forward, startRank, lastRank = -8, 6, 0
+		}
+		targets := attacks(piece, from, occ) & occ &^ own
+		if occ&(1<<(from+forward)) == 0 {
+			targets |= 1 << (from + forward)
+			if from>>3 == startRank && occ&(1<<(from+2*forward)) == 0 {
+				targets |= 1 << (from + 2*forward)
+			}
+		}
+		for b := targets; b != 0; b &= b - 1 {
+			to := bits.TrailingZeros64(b)
+			if to>>3 != lastRank {
+				try(i, to, 0, true)
+				continue
+			}
+			for _, promotion := range []int{queen, rook, bishop, knight} {
+				try(i, to, promotion|piece&black, true)
+			}
+		}
+	}
+}
+
+// forEachUnmove calls f with each valid position from which a move leads to p
+// without leaving the material, i.e. without captures nor promotions; only moves
+// of pieces other than pawns unless withPawns.
+func (m *material) forEachUnmove(p *position, withPawns bool, f func(q *position)) {
+	occ := p.occ()
+	mover := p.stm ^ 1
+	for i := 0; i < p.n; i++ {
+		piece := p.pieces[i]
+		if colorOf(piece) != mover {
+			continue
+		}
+		to := p.sqs[i]
+		var froms uint64
+		switch {
+		case piece&^black != pawn:
+			froms = attacks(piece, to, occ) &^ occ
+		case !withPawns:
+			continue
+		case piece&black == 0:
+			if to>>3 >= 2 && occ&(1<<(to-8)) == 0 {
+				froms |= 1 << (to - 8)
+				if to>>3 == 3 && occ&(1<<(to-16)) == 0 {
+					froms |= 1 << (to - 16)
+				}
+			}
+		default:
+			if to>>3 <= 5 && occ&(1<<(to+8)) == 0 {
+				froms |= 1 << (to + 8)
+				if to>>3 == 4 && occ&(1<<(to+16)) == 0 {
+					froms |= 1 << (to + 16)
+				}
+			}
+		}
+		for b := froms; b != 0; b &= b - 1 {
+			q := *p
+			q.sqs[i], q.stm = bits.TrailingZeros64(b), mover
+			if q.valid() {
+				f(&q)
+			}
+		}
+	}
+}
+
+// solveWDL solves the material's WDL by retrograde analysis: a position is won if
+// a move leads to a lost one, and lost if all of its moves lead to won ones.
+// Captures and promotions lead to other materials, which are solved first.
+func (m *material) solveWDL() {
+	size := m.size()
+	m.wdl = make([]int8, size)
+	count := make([]uint8, size)    // of moves that stay in the material, unsolved
+	bestOther := make([]int8, size) // of moves that leave the material
+	var queue []int32
+	for idx := 0; idx < size; idx++ {
+		p := m.position(idx)
+		if !p.valid() {
+			m.wdl[idx] = invalid
+			continue
+		}
+		best, moves, inside := loss-1, 0, 0
+		m.forEachMove(&p, func(q *position, c *material, _ bool) {
+			moves++
+			if c != m {
+				best = max(best, -c.wdl[c.index(q)])
+				return
+			}
+			inside++
+		})
+		switch {
+		case moves == 0 && p.inCheck(p.stm):
+			m.wdl[idx] = loss
+		case moves == 0:
+			m.wdl[idx] = draw
+		case best == win || inside == 0:
+			m.wdl[idx] = max(best, loss)
+		default:
+			m.wdl[idx], count[idx], bestOther[idx] = unknown, uint8(inside), best
+			continue
+		}
+		if m.wdl[idx] != draw {
+			queue = append(queue, int32(idx))
+		}
+	}
+	for head := 0; head < len(queue); head++ {
+		idx := int(queue[head])
+		value := m.wdl[idx]
+		p := m.position(idx)
+		m.forEachUnmove(&p, true, func(q *position) {
+			prev := m.index(q)
+			if m.wdl[prev] != unknown {
+				return
+			}
+			if value == loss {
+				m.wdl[prev] = win
+				queue = append(queue, int32(prev))
+				return
+			}
+			if count[prev]--; count[prev] == 0 {
+				if bestOther[prev] == draw {
+					m.wdl[prev] = draw
+					return
+				}
+				m.wdl[prev] = loss
+				queue = append(queue, int32(prev))
+			}
+		})
+	}
+	for idx, value := range m.wdl {
+		if value == unknown {
+			m.wdl[idx] = draw
+		}
+	}
+}
+
+// solveDTZ solves the material's DTZ, the plies to zeroing the 50-move counter
+// (or mate) with perfect play: 1 if a winning move zeroes it, one more than the
+// shortest DTZ of the lost positions a winning position's moves lead to, and
+// minus one more than the longest DTZ of the won positions a lost position's moves
+// lead to.
+func (m *material) solveDTZ() {
+	size := m.size()
+	m.dtz = make([]int16, size)
+	count := make([]uint8, size) // of moves that don't zero, unsolved
+	var current, next []int32    // positions of the DTZ being solved, and of the next
+	for idx := 0; idx < size; idx++ {
+		value := m.wdl[idx]
+		if value != win && value != loss {
+			continue
+		}
+		p := m.position(idx)
+		moves, nonZeroing, winningZeroing := 0, 0, false
+		m.forEachMove(&p, func(q *position, c *material, zeroing bool) {
+			moves++
+			switch {
+			case !zeroing:
+				nonZeroing++
+			case c.wdl[c.index(q)] == loss:
+				winningZeroing = true
+			}
+		})
+		switch {
+		case value == win && winningZeroing:
+			m.dtz[idx] = 1
+			next = append(next, int32(idx))
+		case value == loss && moves == 0: // checkmate
+			m.dtz[idx] = -1
+			current = append(current, int32(idx))
+		case value == loss && nonZeroing == 0:
+			m.dtz[idx] = -1
+			next = append(next, int32(idx))
+		case value == loss:
+			count[idx] = uint8(nonZeroing)
+		}
+	}
+	for dtz := 0; len(current) > 0 || len(next) > 0; dtz++ {
+		for _, idx := range current {
+			value := m.wdl[idx]
+			p := m.position(int(idx))
+			m.forEachUnmove(&p, false, func(q *position) {
+				prev := m.index(q)
+				if m.dtz[prev] != 0 {
+					return
+				}
+				switch {
+				case value == loss && m.wdl[prev] == win:
+					m.dtz[prev] = int16(dtz + 1)
+					next = append(next, int32(prev))
+				case value == win && m.wdl[prev] == loss:
+					if count[prev]--; count[prev] == 0 {
+						m.dtz[prev] = -int16(dtz + 1)
+						next = append(next, int32(prev))
+					}
+				}
+			})
+		}
+		current, next = next, nil
+	}
+	for idx, value := range m.wdl {
+		if (value == win || value == loss) && m.dtz[idx] == 0 {
+			log.Fatalf("%s: unsolved DTZ of position %d", m.name, idx)
+		}
+		if m.dtz[idx] > 100 || m.dtz[idx] < -100 {
+			log.Fatalf("%s: position %d is drawn by the 50-move rule, which isn't supported", m.name, idx)
+		}
+	}
+}
+
+// Indexing, as in the reference prober.
+
+var (
+	mapB1H1H7     [64]int
+	mapA1D1D4     [64]int
+	binomial      [6][64]int
+	mapPawns      [64]int
+	leadPawnIdx   [6][64]int
+	leadPawnsSize [6][4]int
+)
+
+func offA1H8(sq int) int { return sq>>3 - sq&7 }
+
+func initIndexing() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if offA1H8(sq) < 0 {
+			mapB1H1H7[sq] = code
+			code++
+		}
+	}
+	var diagonal []int
+	code = 0
+	for sq := 0; sq <= 27; sq++ {
+		switch {
+		case offA1H8(sq) < 0 && sq&7 <= 3:
+			mapA1D1D4[sq] = code
+			code++
+		case offA1H8(sq) == 0 && sq&7 <= 3:
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		mapA1D1D4[sq] = code
+		code++
+	}
+
+	binomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < 6 && k <= n; k++ {
+			if k > 0 {
+				binomial[k][n] += binomial[k-1][n-1]
+			}
+			if k < n {
+				binomial[k][n] += binomial[k][n-1]
+			}
+		}
+	}
+
+	available := 47
+	for leadPawns := 1; leadPawns <= 5; leadPawns++ {
+		for file := 0; file < 4; file++ {
+			idx := 0
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				if leadPawns == 1 {
+					mapPawns[sq] = available
+					available--
+					mapPawns[sq^7] = available
+					available--
+				}
+				leadPawnIdx[leadPawns][sq] = idx
+				idx += binomial[leadPawns-1][mapPawns[sq]]
+			}
+			leadPawnsSize[leadPawns][file] = idx
+		}
+	}
+}
+
+// tableWriter writes a table of a material whose pieces are all different, and
+// with at most one pawn. The pieces are in the same sequence in every subtable.
+type tableWriter struct {
+	m        *material
+	isDTZ    bool
+	pieces   []int
+	hasPawns bool
+	dtzSTM   int
+	sides    int
+	files    int
+	groupLen []int
+	groupIdx [4][]int // by file
+	values   [2][4][]int32
+}
+
+func newTableWriter(m *material, pieces []int, isDTZ bool, dtzSTM int) *tableWriter {
+	w := &tableWriter{m: m, isDTZ: isDTZ, pieces: pieces, hasPawns: pieces[0]&^black == pawn, dtzSTM: dtzSTM, sides: 2, files: 1}
+	if isDTZ {
+		w.sides = 1
+	}
+	if w.hasPawns {
+		w.files = 4
+	}
+
+	// The leading group is the leading pawn, or the first 3 pieces, and every other
+	// piece is a group; they're encoded in that order
+	w.groupLen = []int{3}
+	if w.hasPawns {
+		w.groupLen = []int{1}
+	}
+	for i := w.groupLen[0]; i < len(pieces); i++ {
+		w.groupLen = append(w.groupLen, 1)
+	}
+	for f := 0; f < w.files; f++ {
+		idx, free := 1, 64-w.groupLen[0]
+		for g, n := range w.groupLen {
+			w.groupIdx[f] = append(w.groupIdx[f], idx)
+			switch {
+			case g > 0:
+				idx *= binomial[n][free]
+				free -= n
+			case w.hasPawns:
+				idx *= leadPawnsSize[1][f]
+			default:
+				idx *= 31332
+			}
+		}
+		w.groupIdx[f] = append(w.groupIdx[f], idx)
+		for side := 0; side < w.sides; side++ {
+			w.values[side][f] = make([]int32, idx)
+			for i := range w.values[side][f] {
+				w.values[side][f][i] = -1
+			}
+		}
+	}
+
+	maps := w.dtzMaps()
+	for idx := 0; idx < m.size(); idx++ {
+		value := m.wdl[idx]
+		p := m.position(idx)
+		if value == invalid || isDTZ && (p.stm != dtzSTM || value == draw) {
+			continue
+		}
+		stored := int32(value)*2 + 2
+		if isDTZ {
+			stored = int32(maps.index(m.dtz[idx]))
+		}
+		side, file, i := w.encode(&p)
+		if prev := w.values[side][file][i]; prev >= 0 && prev != stored {
+			log.Fatalf("%s: positions %d and another with index %d have different values", m.name, idx, i)
+		}
+		w.values[side][file][i] = stored
+	}
+	return w
+}
+
+// encode returns the subtable (side to move and leading pawn file) and index of a
+// position.
+func (w *tableWriter) encode(p *position) (int, int, int) {
+	var squares [4]int
+	for k, piece := range w.pieces {
+		for i := 0; i < p.n; i++ {
+			if p.pieces[i] == piece {
+				squares[k] = p.sqs[i]
+			}
+		}
+	}
+	n := len(w.pieces)
+	side := p.stm
+	if w.isDTZ {
+		side = 0
+	}
+
+	file := 0
+	if squares[0]&7 > 3 {
+		for i := 0; i < n; i++ {
+			squares[i] ^= 7
+		}
+	}
+	var idx int
+	if w.hasPawns {
+		file = squares[0] & 7
+		idx = leadPawnIdx[1][squares[0]]
+	} else {
+		if squares[0]>>3 > 3 {
+			for i := 0; i < n; i++ {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if offA1H8(squares[i]) == 0 {
+				continue
+			}
+			if offA1H8(squares[i]) > 0 {
+				for j := i; j < n; j++ {
+					squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+				}
+			}
+			break
+		}
+		adjust1, adjust2 := 0, 0
+		if squares[1] > squares[0] {
+			adjust1 = 1
+		}
+		if squares[2] > squares[0] {
+			adjust2++
+		}
+		if squares[2] > squares[1] {
+			adjust2++
+		}
+		switch {
+		case offA1H8(squares[0]) != 0:
+			idx = (mapA1D1D4[squares[0]]*63+squares[1]-adjust1)*62 + squares[2] - adjust2
+		case offA1H8(squares[1]) != 0:
+			idx = (6*63+(squares[0]>>3)*28+mapB1H1H7[squares[1]])*62 + squares[2] - adjust2
+		case offA1H8(squares[2]) != 0:
+			idx = 6*63*62 + 4*28*62 + (squares[0]>>3)*7*28 + (squares[1]>>3-adjust1)*28 + mapB1H1H7[squares[2]]
+		default:
+			idx = 6*63*62 + 4*28*62 + 4*7*28 + (squares[0]>>3)*7*6 + (squares[1]>>3-adjust1)*6 + squares[2]>>3 - adjust2
+		}
+	}
+
+	idx *= w.groupIdx[file][0]
+	start := w.groupLen[0]
+	for g := 1; g < len(w.groupLen); g++ {
+		sq := squares[start] // every group has a single piece
+		adjust := 0
+		for _, s := range squares[:start] {
+			if sq > s {
+				adjust++
+			}
+		}
+		idx += binomial[1][sq-adjust] * w.groupIdx[file][g]
+		start++
+	}
+	return side, file, idx
+}
+
+// dtzMaps are the values a DTZ table stores for each outcome, in plies or moves,
+// and then minus one. The table stores their indexes.
+type dtzMaps struct {
+	plies  [2]bool    // of wins and of losses
+	values [2][]int16 // of wins and of losses
+}
+
+func (w *tableWriter) dtzMaps() *dtzMaps {
+	maps := &dtzMaps{}
+	if !w.isDTZ {
+		return maps
+	}
+	var seen [2]map[int16]bool
+	for i := range seen {
+		seen[i] = map[int16]bool{}
+	}
+	for idx, dtz := range w.m.dtz {
+		if dtz != 0 && w.m.position(idx).stm == w.dtzSTM {
+			outcome := 0
+			if dtz < 0 {
+				outcome, dtz = 1, -dtz
+			}
+			seen[outcome][dtz] = true
+		}
+	}
+	for outcome, dtzs := range seen {
+		for dtz := range dtzs {
+			if dtz%2 == 0 {
+				maps.plies[outcome] = true
+			}
+		}
+		stored := map[int16]bool{}
+		for dtz := range dtzs {
+			stored[maps.stored(outcome, dtz)] = true
+		}
+		for value := range stored {
+			maps.values[outcome] = append(maps.values[outcome], value)
+		}
+		sort.Slice(maps.values[outcome], func(i, j int) bool { return maps.values[outcome][i] < maps.values[outcome][j] })
+		if len(maps.values[outcome]) > 255 {
+			log.Fatalf("%s: too many DTZ values", w.m.name)
+		}
+	}
+	return maps
+}
+
+func (maps *dtzMaps) stored(outcome int, dtz int16) int16 {
+	if maps.plies[outcome] {
+		return dtz - 1
+	}
+	return (dtz - 1) / 2
+}
+
+// index returns the index of the DTZ in the map of its outcome.
+func (maps *dtzMaps) index(dtz int16) int {
+	outcome := 0
+	if dtz < 0 {
+		outcome, dtz = 1, -dtz
+	}
+	value := maps.stored(outcome, dtz)
+	return sort.Search(len(maps.values[outcome]), func(i int) bool { return maps.values[outcome][i] >= value })
+}
+
+// Flags of a subtable.
+const (
+	flagSTM         = 1
+	flagMapped      = 2
+	flagWinPlies    = 4
+	flagLossPlies   = 8
+	flagSingleValue = 128
+)
+
+func (w *tableWriter) bytes() []byte {
+	var buf bytes.Buffer
+	magic := []byte{0x71, 0xE8, 0x23, 0x5D}
+	if w.isDTZ {
+		magic = []byte{0xD7, 0x66, 0x0C, 0xA5}
+	}
+	buf.Write(magic)
+	flags := byte(1) // split: the material isn't symmetric
+	if w.hasPawns {
+		flags |= 2
+	}
+	buf.WriteByte(flags)
+	for f := 0; f < w.files; f++ {
+		buf.WriteByte(0) // the leading group first, on both sides
+		for _, piece := range w.pieces {
+			buf.WriteByte(byte(piece | piece<<4))
+		}
+	}
+	pad(&buf, 2)
+
+	var maps *dtzMaps
+	var subtables [4][2]*compressed
+	for f := 0; f < w.files; f++ {
+		for side := 0; side < w.sides; side++ {
+			var flags byte
+			if w.isDTZ {
+				maps = w.dtzMaps()
+				flags = flagMapped | byte(w.dtzSTM)*flagSTM
+				if maps.plies[0] {
+					flags |= flagWinPlies
+				}
+				if maps.plies[1] {
+					flags |= flagLossPlies
+				}
+			}
+			subtables[f][side] = compress(w.values[side][f], flags)
+			buf.Write(subtables[f][side].sizes)
+		}
+	}
+	if w.isDTZ {
+		for f := 0; f < w.files; f++ {
+			// Wins, losses, cursed wins and blessed losses
+			for _, values := range [][]int16{maps.values[0], maps.values[1], nil, nil} {
+				buf.WriteByte(byte(len(values)))
+				for _, value := range values {
+					buf.WriteByte(byte(value))
+				}
+			}
+		}
+		pad(&buf, 2)
+	}
+	for f := 0; f < w.files; f++ {
+		for side := 0; side < w.sides; side++ {
+			buf.Write(subtables[f][side].sparseIndex)
+		}
+	}
+	for f := 0; f < w.files; f++ {
+		for side := 0; side < w.sides; side++ {
+			buf.Write(subtables[f][side].blockLengths)
+		}
+	}
+	for f := 0; f < w.files; f++ {
+		for side := 0; side < w.sides; side++ {
+			pad(&buf, 64)
+			buf.Write(subtables[f][side].blocks)
+		}
+	}
+	return buf.Bytes()
+}
+
+func pad(buf *bytes.Buffer, alignment int) {
+	for buf.Len()%alignment != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+// Compression.
+
+const (
+	log2BlockSize  = 8
+	log2Span       = 12
+	maxBlockValues = 60000
+	maxSymbolLen   = 256 // values a symbol expands into
+	maxPairs       = 1500
+)
+
+// compressed is a compressed subtable.
+type compressed struct {
+	sizes        []byte // flags and compression parameters
+	sparseIndex  []byte
+	blockLengths []byte
+	blocks       []byte
+}
+
+// symbol is a value (right is 0xFFF and left the value), or a pair of symbols.
+type symbol struct {
+	left, right int
+	length      int // of its values
+}
+
+func compress(values []int32, flags byte) *compressed {
+	// Positions that don't matter take the previous value, which makes longer runs
+	var first int32
+	for _, v := range values {
+		if v >= 0 {
+			first = v
+			break
+		}
+	}
+	filled := make([]int32, len(values))
+	last, single := first, true
+	for i, v := range values {
+		if v < 0 {
+			v = last
+		}
+		filled[i], last = v, v
+		single = single && v == first
+	}
+	if single {
+		return &compressed{sizes: []byte{flags | flagSingleValue, byte(first)}}
+	}
+
+	// Recursive pairing: replace the most frequent pair of adjacent symbols with a
+	// new one, again and again
+	var symbols []symbol
+	leaves := map[int32]int{}
+	seq := make([]int, len(filled))
+	for i, v := range filled {
+		s, ok := leaves[v]
+		if !ok {
+			s = len(symbols)
+			leaves[v] = s
+			symbols = append(symbols, symbol{left: int(v), right: 0xFFF, length: 1})
+		}
+		seq[i] = s
+	}
+	for len(symbols) < len(leaves)+maxPairs {
+		counts := map[[2]int]int{}
+		for i := 0; i+1 < len(seq); i++ {
+			pair := [2]int{seq[i], seq[i+1]}
+			if symbols[pair[0]].length+symbols[pair[1]].length > maxSymbolLen {
+				continue
+			}
+			counts[pair]++
+			if pair[0] == pair[1] && i+2 < len(seq) && seq[i+2] == seq[i] {
+				i++ // runs count non-overlapping pairs
+			}
+		}
+		best, bestCount := [2]int{}, 0
+		for pair, count := range counts {
+			if count > bestCount || count == bestCount && (pair[0] < best[0] || pair[0] == best[0] && pair[1] < best[1]) {
+				best, bestCount = pair, count
+			}
+		}
+		if bestCount < 8 {
+			break
+		}
+		s := len(symbols)
+		symbols = append(symbols, symbol{left: best[0], right: best[1], length: symbols[best[0]].length + symbols[best[1]].length})
+		out := seq[:0]
+		for i := 0; i < len(seq); i++ {
+			if i+1 < len(seq) && seq[i] == best[0] && seq[i+1] == best[1] {
+				out = append(out, s)
+				i++
+				continue
+			}
+			out = append(out, seq[i])
+		}
+		seq = out
+	}
+
+	// Canonical Huffman codes: symbols are renumbered so that the longest codes
+	// have the lowest numbers, and codes of the same length are consecutive
+	weights := make([]int, len(symbols))
+	for _, s := range seq {
+		weights[s]++
+	}
+	lengths := huffmanLengths(weights)
+	order := make([]int, len(symbols))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return lengths[order[i]] > lengths[order[j]] })
+	renumbered := make([]int, len(symbols))
+	for newSym, s := range order {
+		renumbered[s] = newSym
+	}
+	minLen, maxLen := lengths[order[len(order)-1]], lengths[order[0]]
+	count := make([]int, maxLen+1)
+	for _, l := range lengths {
+		count[l]++
+	}
+	lowestSym := make([]int, maxLen+1)
+	base := make([]int, maxLen+1)
+	for l := maxLen - 1; l >= minLen; l-- {
+		lowestSym[l] = lowestSym[l+1] + count[l+1]
+		base[l] = (base[l+1] + count[l+1]) / 2
+	}
+	codes := make([]uint64, len(symbols))
+	for newSym, s := range order {
+		codes[s] = uint64(base[lengths[s]] + newSym - lowestSym[lengths[s]])
+	}
+
+	c := &compressed{}
+	var blockLengths []int
+	var block []byte
+	bitCount, blockValues := 0, 0
+	flush := func() {
+		block = append(block, make([]byte, 1<<log2BlockSize-len(block))...)
+		c.blocks = append(c.blocks, block...)
+		blockLengths = append(blockLengths, blockValues)
+		block, bitCount, blockValues = nil, 0, 0
+	}
+	for _, s := range seq {
+		if bitCount+lengths[s] > 8<<log2BlockSize || blockValues+symbols[s].length > maxBlockValues {
+			flush()
+		}
+		for i := lengths[s] - 1; i >= 0; i-- {
+			if bitCount%8 == 0 {
+				block = append(block, 0)
+			}
+			if codes[s]>>i&1 != 0 {
+				block[bitCount/8] |= 0x80 >> (bitCount % 8)
+			}
+			bitCount++
+		}
+		blockValues += symbols[s].length
+	}
+	flush()
+
+	sizes := []byte{flags, log2BlockSize, log2Span, 0}
+	sizes = binary.LittleEndian.AppendUint32(sizes, uint32(len(blockLengths)))
+	sizes = append(sizes, byte(maxLen), byte(minLen))
+	for l := minLen; l <= maxLen; l++ {
+		sizes = binary.LittleEndian.AppendUint16(sizes, uint16(lowestSym[l]))
+	}
+	sizes = binary.LittleEndian.AppendUint16(sizes, uint16(len(symbols)))
+	for _, s := range order {
+		left, right := symbols[s].left, symbols[s].right
+		if right != 0xFFF {
+			left, right = renumbered[left], renumbered[right]
+		}
+		sizes = append(sizes, byte(left), byte(left>>8)|byte(right<<4), byte(right>>4))
+	}
+	if len(symbols)%2 == 1 {
+		sizes = append(sizes, 0)
+	}
+	c.sizes = sizes
+
+	// The sparse index tells the block, and the offset within it, of the value in
+	// the middle of each span
+	starts := make([]int, len(blockLengths))
+	for b := 1; b < len(blockLengths); b++ {
+		starts[b] = starts[b-1] + blockLengths[b-1]
+	}
+	for k := 0; k*(1<<log2Span) < len(values); k++ {
+		idx := k<<log2Span + 1<<(log2Span-1)
+		b := sort.Search(len(starts), func(b int) bool { return starts[b] > idx }) - 1
+		c.sparseIndex = binary.LittleEndian.AppendUint32(c.sparseIndex, uint32(b))
+		c.sparseIndex = binary.LittleEndian.AppendUint16(c.sparseIndex, uint16(idx-starts[b]))
+	}
+	for _, n := range blockLengths {
+		c.blockLengths = binary.LittleEndian.AppendUint16(c.blockLengths, uint16(n-1))
+	}
+	return c
+}
+
+// huffmanLengths returns the lengths of the Huffman codes of symbols of the given
+// weights, at most 32 bits long.
+func huffmanLengths(weights []int) []int {
+	for {
+		lengths := make([]int, len(weights))
+		h := &nodeHeap{}
+		for s, w := range weights {
+			heap.Push(h, &node{weight: w + 1, symbols: []int{s}})
+		}
+		for h.Len() > 1 {
+			a, b := heap.Pop(h).(*node), heap.Pop(h).(*node)
+			for _, s := range append(a.symbols, b.symbols...) {
+				lengths[s]++
+			}
+			heap.Push(h, &node{weight: a.weight + b.weight, symbols: append(a.symbols, b.symbols...)})
+		}
+		longest := 0
+		for _, l := range lengths {
+			longest = max(longest, l)
+		}
+		if longest <= 32 {
+			return lengths
+		}
+		for s := range weights {
+			weights[s] /= 2
+		}
+	}
+}
+
+type node struct {
+	weight  int
+	symbols []int
+}
+
+type nodeHeap []*node
+
+func (h nodeHeap) Len() int { return len(h) }
+func (h nodeHeap) Less(i, j int) bool {
+	return h[i].weight < h[j].weight || h[i].weight == h[j].weight && h[i].symbols[0] < h[j].symbols[0]
+}
+func (h nodeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nodeHeap) Push(x interface{}) { *h = append(*h, x.(*node)) }
+func (h *nodeHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
@@ -98,3 +98,27 @@ func TestCheckmate(t *testing.T) {
 		})
 	}
 }
+
+func TestMobility(t *testing.T) {
+	g := NewDefaultGame()
+	ts := []struct {
+		name     string
+		xy       XY
+		expected int
+	}{
+		{name: "knight on b1", xy: XY{1, 7}, expected: 2},
+		{name: "blocked bishop on c1", xy: XY{2, 7}, expected: 0},
+		{name: "blocked rook on a8", xy: XY{0, 0}, expected: 0},
+		{name: "pawn on e2", xy: XY{4, 6}, expected: 2},
+		{name: "empty square", xy: XY{4, 4}, expected: 0},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, g.Mobility(tc.xy))
+		})
+	}
+
+	g, err := NewGameFromFEN("4k3/8/8/3q4/8/8/3P4/4K3 b - - 0 1")
+	require.NoError(t, err)
+	assert.Equal(t, 26, g.Mobility(XY{3, 3}), "queen on d5 up to the d2 pawn, which it attacks")
+}
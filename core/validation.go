@@ -0,0 +1,63 @@
+package core
+
+// Validation is a policy of which positions are valid, besides what always makes
+// them invalid (e.g. a rank larger than 8 squares, or the side not to move being
+// in check). Each variant has its own (see Variant.Validation), and games may be
+// created with a more relaxed one, e.g. for teaching positions without kings (see
+// NewGameFromFENWithValidation and NewGameFromBoardWithValidation).
+type Validation struct {
+	// MaxWhitePieces and MaxBlackPieces are the maximum number of pieces of each
+	// color, or 0 for any number.
+	MaxWhitePieces int
+	MaxBlackPieces int
+	// KingsOptional lets a color have no king. Such a color can't be in check, and
+	// loses when it has no pieces left.
+	KingsOptional bool
+	// ManyKings lets a color have more than one king.
+	ManyKings bool
+	// FirstRankPawns lets pawns be on their color's first rank, from which they may
+	// advance two squares, but can't be captured en passant.
+	FirstRankPawns bool
+}
+
+// standardValidation is the validation policy of standard chess: both kings, and
+// at most 16 pieces of each color.
+var standardValidation = Validation{MaxWhitePieces: 16, MaxBlackPieces: 16}
+
+// Validation returns the validation policy that the game was created with.
+func (g Game) Validation() Validation {
+	return g.validation
+}
+
+// maxPieces returns the maximum number of pieces of the given color, or 0 for any
+// number.
+func (v Validation) maxPieces(c color) int {
+	if c == ColorWhite {
+		return v.MaxWhitePieces
+	}
+	return v.MaxBlackPieces
+}
+
+// allowsPawnAt reports whether a pawn of the given color may be at the given rank.
+func (v Validation) allowsPawnAt(c color, y int) bool {
+	return y != 0 && y != 7 || v.FirstRankPawns && y == homeRank(c)
+}
+
+// outcome reports whether the rules of the game's variant, or its validation
+// policy, end the game, with the winner, or -1 if it's a draw: where kings are
+// optional, a color with no pieces left loses.
+func (g Game) outcome() (bool, color) {
+	if g.variant != nil {
+		if isOver, winner := g.variant.outcome(g); isOver {
+			return isOver, winner
+		}
+	}
+	if g.validation.KingsOptional {
+		for _, c := range [2]color{ColorWhite, ColorBlack} {
+			if g.occ[c] == 0 {
+				return true, opponent(c)
+			}
+		}
+	}
+	return false, -1
+}
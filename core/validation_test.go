@@ -0,0 +1,103 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidation(t *testing.T) {
+	kingsOptional := Validation{MaxWhitePieces: 16, MaxBlackPieces: 16, KingsOptional: true}
+
+	t.Run("positions without kings are only valid if kings are optional", func(t *testing.T) {
+		fen := "8/8/8/8/8/3p4/4P3/8 w - - 0 1"
+		_, err := NewGameFromFEN(fen)
+		assert.ErrorIs(t, err, errFENKingMissing)
+
+		g, err := NewGameFromFENWithValidation(fen, VariantStandard, kingsOptional)
+		require.NoError(t, err)
+		assert.Equal(t, VariantStandard, g.Variant())
+		assert.Equal(t, kingsOptional, g.Validation())
+		assert.False(t, g.IsGameOver)
+		assert.False(t, g.IsDraw)
+		assert.Equal(t, fen, g.ToFEN())
+
+		g = doMoves(t, g, "e2", "d3")
+		assert.True(t, g.IsGameOver, "Black has no pieces left")
+		assert.True(t, g.IsVariantEnd)
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+		isOver, winner := NewPosition(g).VariantEnd()
+		assert.True(t, isOver)
+		assert.Equal(t, color(ColorWhite), winner)
+	})
+
+	t.Run("a color without a king can't be in check", func(t *testing.T) {
+		g, err := NewGameFromFENWithValidation("8/8/8/8/8/8/1q6/R3K3 b - - 0 1", VariantStandard, kingsOptional)
+		require.NoError(t, err)
+		assert.False(t, g.IsCheck)
+		assert.NotEmpty(t, g.Actions)
+	})
+
+	t.Run("boards", func(t *testing.T) {
+		b := Board{
+			Board: []string{
+				"        ",
+				"        ",
+				"        ",
+				"        ",
+				"        ",
+				"   ♟    ",
+				"    ♙   ",
+				"        ",
+			},
+			FullMoveNumber: 1,
+			Turn:           "White",
+		}
+		_, err := NewGameFromBoard(b)
+		assert.ErrorIs(t, err, errBoardKingMissing)
+
+		g, err := NewGameFromBoardWithValidation(b, VariantStandard, kingsOptional)
+		require.NoError(t, err)
+		assert.Equal(t, "8/8/8/8/8/3p4/4P3/8 w - - 0 1", g.ToFEN())
+		assert.False(t, g.IsGameOver)
+
+		b.Board = []string{
+			"rnbqkbnr",
+			"pppppppp",
+			"        ",
+			" PP  PP ",
+			"PPPPPPPP",
+			"PPPPPPPP",
+			"PPPPPPPP",
+			"PPPPPPPP",
+		}
+		for y, row := range b.Board {
+			b.Board[y] = toBoardRow(row)
+		}
+		b.CanBlackKingsideCastle, b.CanBlackQueensideCastle = true, true
+		_, err = NewGameFromBoard(b)
+		assert.ErrorIs(t, err, errBoardPawnInImpossibleRank)
+		g, err = NewGameFromBoardWithValidation(b, VariantHorde, VariantHorde.Validation())
+		require.NoError(t, err)
+		assert.Equal(t, VariantHorde.StartFEN(), g.ToFEN())
+		assert.Equal(t, VariantHorde, g.Variant())
+		assert.Len(t, g.Actions, 8+2) // Plus resigning and offering a draw
+	})
+}
+
+// toBoardRow turns a row of FEN-like piece letters into one of a Board.
+func toBoardRow(row string) string {
+	pieces := map[rune]rune{
+		'K': '♔', 'Q': '♕', 'R': '♖', 'B': '♗', 'N': '♘', 'P': '♙',
+		'k': '♚', 'q': '♛', 'r': '♜', 'b': '♝', 'n': '♞', 'p': '♟',
+	}
+	var out []rune
+	for _, r := range row {
+		if p, ok := pieces[r]; ok {
+			r = p
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
@@ -0,0 +1,305 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Variant is a set of rules of chess: which moves are legal, and when the game is
+// over. Games are played by the standard rules unless created with a variant (see
+// NewVariantGameFromFEN and Game.WithVariant).
+//
+// The rules of each variant are implemented in this package; the variants are
+// VariantStandard, VariantThreeCheck, VariantKingOfTheHill, VariantRacingKings,
+// VariantCrazyhouse, VariantBughouse, VariantAtomic, VariantAntichess and
+// VariantHorde.
+type Variant interface {
+	// Name returns the variant's name, e.g. "kingOfTheHill" (see VariantByName).
+	Name() string
+	// StartFEN returns the FEN string of the variant's starting position.
+	StartFEN() string
+	// Validation returns the variant's validation policy, which its games are
+	// created with unless given another one.
+	Validation() Validation
+
+	// allowsMove reports whether the variant allows the move, which doesn't leave
+	// the mover's king in check. g is the game with the move's pieces moved, but
+	// nothing else updated (e.g. it's still the mover's turn).
+	allowsMove(g Game, a Action) bool
+	// exposesKing reports whether the move leaves the mover's king in check, or
+	// otherwise exposed by the variant's rules, which makes it illegal. g is the
+	// game with the move's pieces moved, but nothing else updated.
+	exposesKing(g Game, a Action) bool
+	// checkers returns the pieces that give check to the given color's king.
+	checkers(g Game, c color) uint64
+	// promotions returns the piece types that pawns may promote to.
+	promotions() []PieceType
+	// filterMoves filters the legal moves of the side to move in place (e.g. to
+	// the captures, if there are any, in Antichess).
+	filterMoves(moves []Action) []Action
+	// updateState updates the variant's state after the given move, once the
+	// game's own state is updated (see Game.updateState).
+	updateState(g *Game, a Action)
+	// outcome reports whether the variant's own rules end the game (e.g. a king
+	// reaching the hill), with the winner, or -1 if it's a draw.
+	outcome(g Game) (bool, color)
+	// isInsufficientMaterial reports whether neither side can possibly win.
+	isInsufficientMaterial(g Game) bool
+	// hasPockets reports whether pieces may be dropped from pockets (see
+	// Action.IsDrop).
+	hasPockets() bool
+}
+
+// The variants, by name.
+var (
+	VariantStandard      Variant = standard{}
+	VariantThreeCheck    Variant = threeCheck{}
+	VariantKingOfTheHill Variant = kingOfTheHill{}
+	VariantRacingKings   Variant = racingKings{}
+	VariantCrazyhouse    Variant = crazyhouse{}
+	VariantBughouse      Variant = bughouse{}
+	VariantAtomic        Variant = atomic{}
+	VariantAntichess     Variant = antichess{}
+	VariantHorde         Variant = horde{}
+)
+
+// Variants are all the variants, standard first.
+var Variants = []Variant{VariantStandard, VariantThreeCheck, VariantKingOfTheHill, VariantRacingKings, VariantCrazyhouse, VariantBughouse, VariantAtomic, VariantAntichess, VariantHorde}
+
+// VariantByName returns the variant with the given name (see Variant.Name).
+func VariantByName(name string) (Variant, bool) {
+	for _, v := range Variants {
+		if v.Name() == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Variant returns the variant whose rules the game is played by.
+func (g Game) Variant() Variant {
+	if g.variant == nil {
+		return VariantStandard
+	}
+	return g.variant
+}
+
+// WithVariant returns a copy of the game played by the rules of the given
+// variant, with its flags recalculated accordingly.
+func (g Game) WithVariant(v Variant) Game {
+	g.variant = v
+	if v == VariantStandard {
+		g.variant = nil // So that standard games skip the variant hooks
+	}
+	return g.calculateCriticalFlags()
+}
+
+// ChecksGiven returns the number of checks that the given color gave, which
+// Three-check games count (see VariantThreeCheck).
+func (g Game) ChecksGiven(c Color) int {
+	return g.checksGiven[c]
+}
+
+// variantAllows reports whether the game's variant allows the move, which doesn't
+// leave the mover's king in check. g is a copy, so the move is tried on it.
+func (g Game) variantAllows(a Action) bool {
+	g.movePieces(a)
+	return g.variant.allowsMove(g, a)
+}
+
+// checkers returns the pieces that give check to the given color's king, by the
+// rules of the game's variant.
+func (g Game) checkers(c color) uint64 {
+	if g.variant == nil {
+		return g.kingAttackers(c)
+	}
+	return g.variant.checkers(g, c)
+}
+
+// kingAttackers returns the opponent pieces that attack the given color's king,
+// if it has one, which give check by the standard rules.
+func (g Game) kingAttackers(c color) uint64 {
+	if g.bb[c][PieceKing] == 0 {
+		return 0
+	}
+	return g.attackersOf(int(g.kingSq[c]), c)
+}
+
+// standard is standard chess.
+type standard struct{}
+
+func (standard) Name() string                       { return "standard" }
+func (standard) StartFEN() string                   { return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1" }
+func (standard) Validation() Validation             { return standardValidation }
+func (standard) allowsMove(g Game, a Action) bool   { return true }
+func (standard) updateState(g *Game, a Action)      {}
+func (standard) outcome(g Game) (bool, color)       { return false, -1 }
+func (standard) isInsufficientMaterial(g Game) bool { return g.isInsufficientMaterial() }
+func (standard) hasPockets() bool                   { return false }
+
+func (standard) exposesKing(g Game, a Action) bool   { return g.kingAttackers(a.FromPiece.Owner) != 0 }
+func (standard) checkers(g Game, c color) uint64     { return g.kingAttackers(c) }
+func (standard) promotions() []PieceType             { return promotionPieceTypes[:] }
+func (standard) filterMoves(moves []Action) []Action { return moves }
+
+// threeCheckLimit is the number of checks that win a Three-check game.
+const threeCheckLimit = 3
+
+// threeCheck is Three-check: giving a third check wins, besides checkmate. The
+// checks given are recorded in FEN strings after the full move number, as
+// "+2+1" (White's, then Black's).
+// https://lichess.org/variant/threeCheck
+type threeCheck struct{}
+
+func (threeCheck) Name() string { return "threeCheck" }
+func (threeCheck) StartFEN() string {
+	return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1 +0+0"
+}
+func (threeCheck) Validation() Validation           { return standardValidation }
+func (threeCheck) allowsMove(g Game, a Action) bool { return true }
+func (threeCheck) hasPockets() bool                 { return false }
+func (threeCheck) exposesKing(g Game, a Action) bool {
+	return g.kingAttackers(a.FromPiece.Owner) != 0
+}
+func (threeCheck) checkers(g Game, c color) uint64     { return g.kingAttackers(c) }
+func (threeCheck) promotions() []PieceType             { return promotionPieceTypes[:] }
+func (threeCheck) filterMoves(moves []Action) []Action { return moves }
+
+func (threeCheck) updateState(g *Game, a Action) {
+	turn := g.Turn()
+	if g.attackersOf(int(g.kingSq[turn]), turn) != 0 {
+		g.checksGiven[opponent(turn)]++
+	}
+}
+
+func (threeCheck) outcome(g Game) (bool, color) {
+	for _, c := range [2]color{ColorWhite, ColorBlack} {
+		if g.checksGiven[c] >= threeCheckLimit {
+			return true, c
+		}
+	}
+	return false, -1
+}
+
+// isInsufficientMaterial is only true with bare kings, since any other piece may
+// give checks.
+func (threeCheck) isInsufficientMaterial(g Game) bool {
+	return g.occAll() == g.bb[ColorBlack][PieceKing]|g.bb[ColorWhite][PieceKing]
+}
+
+// hill are the squares d4, e4, d5 and e5.
+const hill = uint64(1)<<27 | uint64(1)<<28 | uint64(1)<<35 | uint64(1)<<36
+
+// kingOfTheHill is King of the Hill: bringing the king to the hill (the four
+// center squares) wins, besides checkmate.
+// https://lichess.org/variant/kingOfTheHill
+type kingOfTheHill struct{}
+
+func (kingOfTheHill) Name() string                     { return "kingOfTheHill" }
+func (kingOfTheHill) StartFEN() string                 { return VariantStandard.StartFEN() }
+func (kingOfTheHill) Validation() Validation           { return standardValidation }
+func (kingOfTheHill) allowsMove(g Game, a Action) bool { return true }
+func (kingOfTheHill) updateState(g *Game, a Action)    {}
+func (kingOfTheHill) hasPockets() bool                 { return false }
+func (kingOfTheHill) exposesKing(g Game, a Action) bool {
+	return g.kingAttackers(a.FromPiece.Owner) != 0
+}
+func (kingOfTheHill) checkers(g Game, c color) uint64     { return g.kingAttackers(c) }
+func (kingOfTheHill) promotions() []PieceType             { return promotionPieceTypes[:] }
+func (kingOfTheHill) filterMoves(moves []Action) []Action { return moves }
+
+func (kingOfTheHill) outcome(g Game) (bool, color) {
+	for _, c := range [2]color{ColorWhite, ColorBlack} {
+		if g.bb[c][PieceKing]&hill != 0 {
+			return true, c
+		}
+	}
+	return false, -1
+}
+
+// isInsufficientMaterial is never true, since a bare king may still reach the hill.
+func (kingOfTheHill) isInsufficientMaterial(g Game) bool { return false }
+
+// racingKings is Racing Kings: checks aren't allowed, and the first king to reach
+// the 8th rank wins. If White's king gets there first, Black gets a last move to
+// draw by getting there too.
+// https://lichess.org/variant/racingKings
+type racingKings struct{}
+
+func (racingKings) Name() string           { return "racingKings" }
+func (racingKings) StartFEN() string       { return "8/8/8/8/8/8/krbnNBRK/qrbnNBRQ w - - 0 1" }
+func (racingKings) Validation() Validation { return standardValidation }
+
+// allowsMove only allows moves that don't give check.
+func (racingKings) allowsMove(g Game, a Action) bool {
+	opp := opponent(a.FromPiece.Owner)
+	return g.attackersOf(int(g.kingSq[opp]), opp) == 0
+}
+
+func (racingKings) updateState(g *Game, a Action) {}
+func (racingKings) hasPockets() bool              { return false }
+func (racingKings) exposesKing(g Game, a Action) bool {
+	return g.kingAttackers(a.FromPiece.Owner) != 0
+}
+func (racingKings) checkers(g Game, c color) uint64     { return g.kingAttackers(c) }
+func (racingKings) promotions() []PieceType             { return promotionPieceTypes[:] }
+func (racingKings) filterMoves(moves []Action) []Action { return moves }
+
+func (racingKings) outcome(g Game) (bool, color) {
+	const eighthRank = uint64(0xFF)
+	isWhiteHome, isBlackHome := g.bb[ColorWhite][PieceKing]&eighthRank != 0, g.bb[ColorBlack][PieceKing]&eighthRank != 0
+	switch {
+	case isWhiteHome && isBlackHome:
+		return true, -1
+	case isBlackHome:
+		return true, ColorBlack
+	case isWhiteHome && g.Turn() == ColorBlack && g.canKingReach(ColorBlack, eighthRank):
+		return false, -1
+	case isWhiteHome:
+		return true, ColorWhite
+	}
+	return false, -1
+}
+
+// isInsufficientMaterial is never true, since bare kings still race.
+func (racingKings) isInsufficientMaterial(g Game) bool { return false }
+
+// canKingReach returns whether the given color's king has a legal move to any of
+// the given squares.
+func (g Game) canKingReach(c color, sqs uint64) bool {
+	for _, a := range g.King(c).calculateAllActions(g) {
+		if sqBit(sqOf(a.ToXY))&sqs != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+var errFENInvalidChecks = errors.New("FEN string's checks must be like +2+1, with each count between 0 and 3")
+
+var rxFENChecks = regexp.MustCompile(`^\+([0-3])\+([0-3])$`)
+
+// cutFENChecks cuts the checks that Three-check FEN strings end with (e.g. "+2+1")
+// from the given FEN string, returning the rest and the checks by color. Their
+// absence means no checks.
+func cutFENChecks(s string) (string, [2]int, error) {
+	fields := strings.Split(s, " ")
+	if len(fields) != len(fenFieldRegexps)+1 {
+		return s, [2]int{}, nil
+	}
+	matches := rxFENChecks.FindStringSubmatch(fields[len(fields)-1])
+	if matches == nil {
+		return "", [2]int{}, errFENInvalidChecks
+	}
+	var checks [2]int
+	checks[ColorWhite], checks[ColorBlack] = atoi(matches[1]), atoi(matches[2])
+	return strings.Join(fields[:len(fields)-1], " "), checks, nil
+}
+
+// checksFEN renders the checks of a Three-check game as at the end of its FEN
+// string, e.g. " +2+1".
+func (g Game) checksFEN() string {
+	return fmt.Sprintf(" +%v+%v", g.checksGiven[ColorWhite], g.checksGiven[ColorBlack])
+}
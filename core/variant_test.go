@@ -0,0 +1,173 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustVariantGameFromFEN(t *testing.T, fen string, v Variant) Game {
+	t.Helper()
+	g, err := NewVariantGameFromFEN(fen, v)
+	require.NoError(t, err)
+	return g
+}
+
+func TestVariantByName(t *testing.T) {
+	for _, v := range Variants {
+		actual, ok := VariantByName(v.Name())
+		require.True(t, ok, v.Name())
+		assert.Equal(t, v, actual)
+		assert.Equal(t, v, NewVariantGame(v).Variant())
+	}
+	_, ok := VariantByName("chess960")
+	assert.False(t, ok)
+	assert.Equal(t, VariantStandard, NewDefaultGame().Variant())
+}
+
+func TestThreeCheck(t *testing.T) {
+	t.Run("checks are read from and written to FEN strings", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "rnbqkbnr/ppp2ppp/8/1B1pp3/4P3/8/PPPP1PPP/RNBQK1NR b KQkq - 1 3 +2+1", VariantThreeCheck)
+		assert.Equal(t, 2, g.ChecksGiven(ColorWhite))
+		assert.Equal(t, 1, g.ChecksGiven(ColorBlack))
+		assert.Equal(t, "rnbqkbnr/ppp2ppp/8/1B1pp3/4P3/8/PPPP1PPP/RNBQK1NR b KQkq - 1 3 +2+1", g.ToFEN())
+
+		g = mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/4K3 w - - 0 1", VariantThreeCheck)
+		assert.Equal(t, "4k3/8/8/8/8/8/8/4K3 w - - 0 1 +0+0", g.ToFEN())
+	})
+
+	t.Run("invalid checks", func(t *testing.T) {
+		for _, fen := range []string{
+			"4k3/8/8/8/8/8/8/4K3 w - - 0 1 +4+0",
+			"4k3/8/8/8/8/8/8/4K3 w - - 0 1 2+1",
+		} {
+			_, err := NewVariantGameFromFEN(fen, VariantThreeCheck)
+			var fenErr *FENError
+			require.ErrorAs(t, err, &fenErr, fen)
+			assert.Equal(t, FENFieldChecks, fenErr.Field)
+			assert.ErrorIs(t, err, errFENInvalidChecks)
+		}
+		_, err := NewGameFromFEN("4k3/8/8/8/8/8/8/4K3 w - - 0 1 +0+0")
+		assert.ErrorIs(t, err, errFENRegexDoesNotMatch, "only Three-check FEN strings have checks")
+	})
+
+	t.Run("checks are counted and the third one wins", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +1+0", VariantThreeCheck)
+		g = doMoves(t, g, "a1", "a8", "e8", "e7")
+		assert.Equal(t, 2, g.ChecksGiven(ColorWhite))
+		assert.False(t, g.IsGameOver)
+
+		g = doMoves(t, g, "a8", "a7")
+		assert.True(t, g.IsCheck)
+		assert.Equal(t, 3, g.ChecksGiven(ColorWhite))
+		assert.True(t, g.IsGameOver)
+		assert.True(t, g.IsVariantEnd)
+		assert.False(t, g.IsCheckmate)
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+		assert.Empty(t, g.Actions)
+	})
+
+	t.Run("checks are part of the position", func(t *testing.T) {
+		g1 := mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +0+0", VariantThreeCheck)
+		g2 := mustVariantGameFromFEN(t, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +1+0", VariantThreeCheck)
+		assert.NotEqual(t, g1.Hash(), g2.Hash())
+		assert.Equal(t, mustGameFromFEN(t, "4k3/8/8/8/8/8/8/R3K3 w - - 0 1").Hash(), g1.Hash())
+	})
+
+	t.Run("a lone bishop isn't insufficient material", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/8/4k3/8/8/2B1K3/8/8 w - - 0 1", VariantThreeCheck)
+		assert.False(t, g.IsDraw)
+		g = mustVariantGameFromFEN(t, "8/8/4k3/8/8/4K3/8/8 w - - 0 1", VariantThreeCheck)
+		assert.True(t, g.IsDraw)
+	})
+}
+
+func TestKingOfTheHill(t *testing.T) {
+	g := mustVariantGameFromFEN(t, "7k/8/8/8/8/3K4/8/8 w - - 0 1", VariantKingOfTheHill)
+	assert.False(t, g.IsDraw, "bare kings may still reach the hill")
+	assert.False(t, g.IsGameOver)
+
+	g = doMoves(t, g, "d3", "e4")
+	assert.True(t, g.IsGameOver)
+	assert.True(t, g.IsVariantEnd)
+	assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+	assert.Empty(t, g.Actions)
+
+	g = mustVariantGameFromFEN(t, "7k/8/8/8/8/3K4/8/8 w - - 0 1", VariantStandard)
+	assert.True(t, g.IsDraw, "bare kings are a draw in standard chess")
+}
+
+func TestRacingKings(t *testing.T) {
+	t.Run("perft", func(t *testing.T) {
+		g := NewVariantGame(VariantRacingKings)
+		// https://github.com/niklasf/python-chess/blob/master/examples/perft/racingkings.perft
+		for depth, nodes := range map[int]int{1: 21, 2: 421, 3: 11264} {
+			assert.Equal(t, nodes, Perft(g, depth), "depth %v", depth)
+		}
+	})
+
+	t.Run("moves that give check are illegal", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/8/8/8/k7/8/8/1R5K w - - 0 1", VariantRacingKings)
+		for _, a := range g.Actions {
+			assert.False(t, a.FromPiece.PieceType == PieceRook && a.ToXY.X == 0, "Ra1+ gives check")
+		}
+		g = doMoves(t, g, "b1", "b2")
+		assert.False(t, g.IsCheck)
+	})
+
+	t.Run("black wins by reaching the 8th rank", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/k7/8/8/8/8/6K1/8 b - - 0 1", VariantRacingKings)
+		g = doMoves(t, g, "a7", "a8")
+		assert.True(t, g.IsGameOver)
+		assert.True(t, g.IsVariantEnd)
+		assert.Equal(t, color(ColorBlack), g.GameOverWinner)
+	})
+
+	t.Run("white wins if black can't reach the 8th rank too", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/6K1/8/1k6/8/8/8/8 w - - 0 1", VariantRacingKings)
+		g = doMoves(t, g, "g7", "g8")
+		assert.True(t, g.IsGameOver)
+		assert.Equal(t, color(ColorWhite), g.GameOverWinner)
+	})
+
+	t.Run("black gets a last move to draw", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/1k4K1/8/8/8/8/8/8 w - - 0 1", VariantRacingKings)
+		g = doMoves(t, g, "g7", "g8")
+		assert.False(t, g.IsGameOver)
+
+		drawn := doMoves(t, g, "b7", "b8")
+		assert.True(t, drawn.IsGameOver)
+		assert.True(t, drawn.IsVariantEnd)
+		assert.True(t, drawn.IsDraw)
+		assert.Equal(t, color(-1), drawn.GameOverWinner)
+
+		lost := doMoves(t, g, "b7", "b6")
+		assert.True(t, lost.IsGameOver)
+		assert.Equal(t, color(ColorWhite), lost.GameOverWinner)
+	})
+
+	t.Run("positions agree with games", func(t *testing.T) {
+		g := mustVariantGameFromFEN(t, "8/1k4K1/8/8/8/8/8/8 w - - 0 1", VariantRacingKings)
+		p := NewPosition(g)
+		for _, a := range p.LegalActions() {
+			if a.ToXY == (XY{6, 0}) {
+				p.MakeMove(a)
+				break
+			}
+		}
+		isEnd, _ := p.VariantEnd()
+		assert.False(t, isEnd)
+		for _, a := range p.LegalActions() {
+			if a.ToXY == (XY{1, 2}) {
+				p.MakeMove(a)
+				break
+			}
+		}
+		isEnd, winner := p.VariantEnd()
+		assert.True(t, isEnd)
+		assert.Equal(t, color(ColorWhite), winner)
+		assert.Empty(t, p.LegalActions())
+		assert.True(t, p.Game().IsVariantEnd)
+	})
+}
@@ -1,9 +1,9 @@
 package core
 
-import "math/bits"
-
-// Zobrist hashing: a position (placement + turn + castling rights + e.p. target)
-// maps to a uint64 by xoring per-feature random keys. Used to detect repetitions.
+// Zobrist hashing: a position (placement + turn + castling rights + e.p. target,
+// plus the checks given in Three-check and the pockets in variants with drops)
+// maps to a uint64 by xoring per-feature random keys. Used to detect repetitions,
+// and by the ai package's transposition table.
 // https://www.chessprogramming.org/Zobrist_Hashing
 
 var (
@@ -11,8 +11,14 @@ var (
 	zobristWhiteTurn     uint64
 	zobristCastling      [4]uint64 // WK, WQ, BK, BQ
 	zobristEnPassantFile [8]uint64
+	zobristChecks        [2][threeCheckLimit]uint64        // per color, per number of checks given minus 1
+	zobristPockets       [2][7][zobristPocketCounts]uint64 // per color, per piece type, per count minus 1
 )
 
+// zobristPocketCounts is the number of pocket counts with their own keys: larger
+// counts share the last one.
+const zobristPocketCounts = 16
+
 func init() {
 	// SplitMix64 with a fixed seed: keys must be deterministic across runs so that
 	// hashes seeded from previous positions (e.g. via the API) remain comparable.
@@ -38,18 +44,30 @@ func init() {
 	for i := range zobristEnPassantFile {
 		zobristEnPassantFile[i] = next()
 	}
-}
-
-// positionHash returns the Zobrist hash of the position for repetition purposes:
-// piece placement, side to move, castling rights and en passant target square.
-func (g Game) positionHash() uint64 {
-	h := uint64(0)
-	for c := 0; c < 2; c++ {
-		for occ := g.occ[c]; occ != 0; occ &= occ - 1 {
-			sq := bits.TrailingZeros64(occ)
-			h ^= zobristPieces[c][g.squares[sq]>>1][sq]
+	// Generated last, so that the keys above stay the same as before they existed.
+	for c := range zobristChecks {
+		for i := range zobristChecks[c] {
+			zobristChecks[c][i] = next()
+		}
+	}
+	for c := range zobristPockets {
+		for _, t := range dropPieceTypes {
+			for i := range zobristPockets[c][t] {
+				zobristPockets[c][t][i] = next()
+			}
 		}
 	}
+}
+
+// Hash returns the Zobrist hash of the position: piece placement, side to move,
+// castling rights, en passant target square and, in Three-check, checks given, or,
+// in variants with drops, pockets. Games reached by different move orders have the
+// same hash if they have the same position.
+//
+// The placement's share of the hash is updated incrementally on every move, so
+// Hash is cheap.
+func (g Game) Hash() uint64 {
+	h := g.placementHash
 	if g.Turn() == ColorWhite {
 		h ^= zobristWhiteTurn
 	}
@@ -68,5 +86,17 @@ func (g Game) positionHash() uint64 {
 	if g.IsLastMoveEnPassant {
 		h ^= zobristEnPassantFile[g.EnPassantTargetSquare.X]
 	}
+	for c, checks := range g.checksGiven {
+		if checks > 0 {
+			h ^= zobristChecks[c][minInt(checks, threeCheckLimit)-1]
+		}
+	}
+	for c := range g.pockets {
+		for _, t := range dropPieceTypes {
+			if n := g.pockets[c][t]; n > 0 {
+				h ^= zobristPockets[c][t][minInt(n, zobristPocketCounts)-1]
+			}
+		}
+	}
 	return h
 }
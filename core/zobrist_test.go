@@ -0,0 +1,95 @@
+package core
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hashFromScratch computes Hash without the incrementally updated placement hash.
+func hashFromScratch(g Game) uint64 {
+	g.placementHash = 0
+	for c := 0; c < 2; c++ {
+		for occ := g.occ[c]; occ != 0; occ &= occ - 1 {
+			sq := bits.TrailingZeros64(occ)
+			g.placementHash ^= zobristPieces[c][g.squares[sq]>>1][sq]
+		}
+	}
+	return g.Hash()
+}
+
+func TestHashIsIncrementallyUpdated(t *testing.T) {
+	fens := []string{
+		"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		// Castling, promotions, en passant
+		"r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+		"n1n5/PPPk4/8/8/8/8/4Kppp/5N1N b - - 0 1",
+		"8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+		// Chess960 castling
+		"bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+	}
+	var walk func(t *testing.T, g Game, depth int)
+	walk = func(t *testing.T, g Game, depth int) {
+		require.Equal(t, hashFromScratch(g), g.Hash(), g.ToFEN())
+		if depth == 0 {
+			return
+		}
+		for _, a := range g.Actions {
+			walk(t, g.DoAction(a), depth-1)
+		}
+	}
+	for _, fen := range fens {
+		t.Run(fen, func(t *testing.T) {
+			g, err := NewGameFromFEN(fen)
+			require.NoError(t, err)
+			walk(t, g, 2)
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	play := func(t *testing.T, moves ...[2]XY) Game {
+		t.Helper()
+		g := NewDefaultGame()
+		for _, move := range moves {
+			found := false
+			for _, a := range g.Actions {
+				if a.FromPiece.XY == move[0] && a.ToXY == move[1] {
+					g, found = g.DoAction(a), true
+					break
+				}
+			}
+			require.True(t, found, "move %v", move)
+		}
+		return g
+	}
+	nf3, nc3, nf6, nc6 := [2]XY{{6, 7}, {5, 5}}, [2]XY{{1, 7}, {2, 5}}, [2]XY{{6, 0}, {5, 2}}, [2]XY{{1, 0}, {2, 2}}
+
+	t.Run("transpositions have the same hash", func(t *testing.T) {
+		assert.Equal(t, play(t, nf3, nf6, nc3, nc6).Hash(), play(t, nc3, nc6, nf3, nf6).Hash())
+	})
+
+	t.Run("the side to move is part of the hash", func(t *testing.T) {
+		g, err := NewGameFromFEN("4k3/8/8/8/8/8/8/4K3 w - - 0 1")
+		require.NoError(t, err)
+		h, err := NewGameFromFEN("4k3/8/8/8/8/8/8/4K3 b - - 0 1")
+		require.NoError(t, err)
+		assert.NotEqual(t, g.Hash(), h.Hash())
+	})
+
+	t.Run("castling rights are part of the hash", func(t *testing.T) {
+		g, err := NewGameFromFEN("r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1")
+		require.NoError(t, err)
+		h, err := NewGameFromFEN("r3k2r/8/8/8/8/8/8/R3K2R w Kkq - 0 1")
+		require.NoError(t, err)
+		assert.NotEqual(t, g.Hash(), h.Hash())
+	})
+
+	t.Run("is the last entry of the position history", func(t *testing.T) {
+		g := play(t, nf3, nf6)
+		history := g.PositionHistory()
+		assert.Equal(t, g.Hash(), history[len(history)-1])
+	})
+}
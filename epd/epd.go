@@ -0,0 +1,341 @@
+// Package epd reads and writes positions in Extended Position Description, the
+// format of test suites (e.g. Win at Chess) and of many opening databases: the
+// first four fields of a FEN string, followed by operations, each an opcode and
+// its operands ended by a semicolon, e.g.
+//
+//	r1bqkbnr/pppp1ppp/2n5/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R w KQkq - bm Bb5; id "Ruy Lopez";
+//
+// The opcodes of the moves to play (bm), to avoid (am), the position's id, its
+// comment (c0), and its analysis' depth (acd), evaluation (ce) and predicted
+// variation (pv) are read into a Record's fields, whose moves are resolved to
+// actions. Those of the halfmove clock (hmvc) and the full move number (fmvn) go
+// to the game, and any other operation is kept as is.
+// https://www.chessprogramming.org/Extended_Position_Description
+package epd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/parser"
+	"github.com/marianogappa/cheesse/parser/pgn"
+	"github.com/marianogappa/cheesse/printer"
+)
+
+var (
+	// ErrInvalidRecord is returned when a record has fewer than four fields.
+	ErrInvalidRecord = errors.New("epd: invalid record: it must start with the first four fields of a FEN string")
+	// ErrInvalidOperation is returned when an operation is malformed, e.g. with an
+	// unterminated string, or a non-numeric operand of acd or ce.
+	ErrInvalidOperation = errors.New("epd: invalid operation")
+	// ErrIllegalMove is returned when a move of bm, am or pv isn't a legal move in
+	// SAN, or more than one move matches it.
+	ErrIllegalMove = errors.New("epd: illegal or ambiguous move")
+)
+
+// Operation is an operation of a Record: its opcode (e.g. "bm") and its
+// operands, with strings unquoted.
+type Operation struct {
+	Opcode   string
+	Operands []string
+}
+
+// Record is a position in EPD, with its operations.
+type Record struct {
+	// Game is the game of the position, whose halfmove clock and full move number
+	// are those of the hmvc and fmvn operations, or 0 and 1.
+	Game core.Game
+	// BestMoves are the moves of the bm operation.
+	BestMoves []core.Action
+	// AvoidMoves are the moves of the am operation.
+	AvoidMoves []core.Action
+	// ID is the operand of the id operation.
+	ID string
+	// Comment is the operand of the c0 operation.
+	Comment string
+	// AnalysisDepth is the operand of the acd operation, in plies, or nil.
+	AnalysisDepth *int
+	// CentipawnEvaluation is the operand of the ce operation, from the point of
+	// view of the side to move, or nil.
+	CentipawnEvaluation *int
+	// PredictedVariation are the moves of the pv operation, the first of them
+	// played in Game.
+	PredictedVariation []core.Action
+	// Operations are the record's other operations, in order.
+	Operations []Operation
+}
+
+// Parse parses a record. Its position may have the last two fields of a FEN
+// string too, and its last operation may lack the semicolon.
+func Parse(s string) (Record, error) {
+	fields, rest, err := cutFields(s)
+	if err != nil {
+		return Record{}, err
+	}
+	operations, err := parseOperations(rest)
+	if err != nil {
+		return Record{}, err
+	}
+
+	var (
+		r      Record
+		counts = [2]string{"0", "1"}
+	)
+	for _, op := range operations {
+		switch op.Opcode {
+		case "hmvc", "fmvn":
+			if len(op.Operands) != 1 || !isNumber(op.Operands[0]) {
+				return Record{}, fmt.Errorf("%w: %s must be a number", ErrInvalidOperation, op.Opcode)
+			}
+			counts[map[string]int{"hmvc": 0, "fmvn": 1}[op.Opcode]] = op.Operands[0]
+		}
+	}
+	if len(fields) == 6 {
+		counts = [2]string{fields[4], fields[5]}
+	}
+	if r.Game, err = core.NewGameFromFEN(strings.Join(append(fields[:4:4], counts[:]...), " ")); err != nil {
+		return Record{}, fmt.Errorf("epd: invalid position: %w", err)
+	}
+
+	for _, op := range operations {
+		switch op.Opcode {
+		case "hmvc", "fmvn":
+		case "bm", "am":
+			moves := make([]core.Action, len(op.Operands))
+			for i, operand := range op.Operands {
+				if moves[i], err = resolveMove(r.Game, operand); err != nil {
+					return Record{}, fmt.Errorf("%w: %s %s", err, op.Opcode, operand)
+				}
+			}
+			if op.Opcode == "bm" {
+				r.BestMoves = moves
+			} else {
+				r.AvoidMoves = moves
+			}
+		case "pv":
+			g := r.Game
+			r.PredictedVariation = make([]core.Action, len(op.Operands))
+			for i, operand := range op.Operands {
+				if r.PredictedVariation[i], err = resolveMove(g, operand); err != nil {
+					return Record{}, fmt.Errorf("%w: pv %s", err, operand)
+				}
+				g = g.DoAction(r.PredictedVariation[i])
+			}
+		case "id", "c0":
+			if len(op.Operands) != 1 {
+				return Record{}, fmt.Errorf("%w: %s must be a single string", ErrInvalidOperation, op.Opcode)
+			}
+			if op.Opcode == "id" {
+				r.ID = op.Operands[0]
+			} else {
+				r.Comment = op.Operands[0]
+			}
+		case "acd", "ce":
+			if len(op.Operands) != 1 || !isNumber(strings.TrimPrefix(op.Operands[0], "-")) {
+				return Record{}, fmt.Errorf("%w: %s must be a number", ErrInvalidOperation, op.Opcode)
+			}
+			n, _ := strconv.Atoi(op.Operands[0])
+			if op.Opcode == "acd" {
+				r.AnalysisDepth = &n
+			} else {
+				r.CentipawnEvaluation = &n
+			}
+		default:
+			r.Operations = append(r.Operations, op)
+		}
+	}
+	return r, nil
+}
+
+// cutFields returns the position's fields of a record, and the rest of it.
+func cutFields(s string) ([]string, string, error) {
+	var fields []string
+	rest := strings.TrimSpace(s)
+	for len(fields) < 6 && rest != "" {
+		field, after, _ := strings.Cut(rest, " ")
+		if len(fields) >= 4 && !isNumber(field) {
+			break // The first operation, rather than the FEN's move counters
+		}
+		fields, rest = append(fields, field), strings.TrimSpace(after)
+	}
+	if len(fields) != 4 && len(fields) != 6 {
+		return nil, "", ErrInvalidRecord
+	}
+	return fields, rest, nil
+}
+
+// parseOperations parses the operations of a record.
+func parseOperations(s string) ([]Operation, error) {
+	var (
+		operations []Operation
+		op         *Operation
+	)
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == ';':
+			if op == nil {
+				return nil, fmt.Errorf("%w: missing opcode", ErrInvalidOperation)
+			}
+			op = nil
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if op == nil || end < 0 {
+				return nil, fmt.Errorf("%w: misplaced or unterminated string", ErrInvalidOperation)
+			}
+			op.Operands = append(op.Operands, s[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := strings.IndexAny(s[i:], " \t;")
+			if end < 0 {
+				end = len(s) - i
+			}
+			token := s[i : i+end]
+			i += end
+			if op != nil {
+				op.Operands = append(op.Operands, token)
+				continue
+			}
+			if !isOpcode(token) {
+				return nil, fmt.Errorf("%w: invalid opcode %q", ErrInvalidOperation, token)
+			}
+			operations = append(operations, Operation{Opcode: token})
+			op = &operations[len(operations)-1]
+		}
+	}
+	return operations, nil
+}
+
+// isOpcode reports whether s is a valid opcode: a letter followed by up to 14
+// letters, digits or underscores.
+func isOpcode(s string) bool {
+	if len(s) == 0 || len(s) > 15 || !isLetter(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isLetter(s[i]) && (s[i] < '0' || s[i] > '9') && s[i] != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isString reports whether the operands of the given opcode are strings, which
+// are quoted even without spaces: those of id and of the comments c0 to c9.
+func isString(opcode string) bool {
+	return opcode == "id" || len(opcode) == 2 && opcode[0] == 'c' && opcode[1] >= '0' && opcode[1] <= '9'
+}
+
+// resolveMove returns the action of the given game's move in SAN. Its check or
+// checkmate symbol, and any annotation (e.g. "!"), may be missing or wrong, as
+// they often are in test suites.
+func resolveMove(g core.Game, san string) (core.Action, error) {
+	san = strings.TrimRight(san, "+#!?")
+	var matches []core.Action
+	for _, suffix := range []string{"", "+", "#"} {
+		actions, err := parser.NewGenericNotationParser(pgn.NewVariantPGN()).MatchHalfMove(san+suffix, g)
+		if err != nil {
+			continue
+		}
+		for _, a := range actions {
+			if !containsAction(matches, a) {
+				matches = append(matches, a)
+			}
+		}
+	}
+	if len(matches) != 1 {
+		return core.Action{}, ErrIllegalMove
+	}
+	return matches[0], nil
+}
+
+func containsAction(actions []core.Action, a core.Action) bool {
+	for _, other := range actions {
+		if other == a {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the record in EPD, with its operations in the ASCII order of
+// their opcodes, as the standard recommends, and its moves in SAN. The hmvc and
+// fmvn operations are only written if the game's halfmove clock and full move
+// number aren't 0 and 1.
+func (r Record) String() string {
+	operations := append([]Operation(nil), r.Operations...)
+	addMoves := func(opcode string, g core.Game, moves []core.Action, isLine bool) {
+		if len(moves) == 0 {
+			return
+		}
+		op := Operation{Opcode: opcode}
+		for _, a := range moves {
+			next := g.DoAction(a)
+			san, _ := printer.AlgebraicPrinter{}.PrintAction(
+				core.GameStep{StepAction: a, StepGame: next, StepPreMoveGame: g},
+				printer.PGNCharacteristics(),
+			)
+			op.Operands = append(op.Operands, san)
+			if isLine {
+				g = next
+			}
+		}
+		operations = append(operations, op)
+	}
+	addMoves("bm", r.Game, r.BestMoves, false)
+	addMoves("am", r.Game, r.AvoidMoves, false)
+	addMoves("pv", r.Game, r.PredictedVariation, true)
+	for opcode, s := range map[string]string{"id": r.ID, "c0": r.Comment} {
+		if s != "" {
+			operations = append(operations, Operation{opcode, []string{s}})
+		}
+	}
+	for opcode, n := range map[string]*int{"acd": r.AnalysisDepth, "ce": r.CentipawnEvaluation} {
+		if n != nil {
+			operations = append(operations, Operation{opcode, []string{strconv.Itoa(*n)}})
+		}
+	}
+	if r.Game.HalfMoveClock != 0 || r.Game.FullMoveNumber != 1 {
+		operations = append(operations,
+			Operation{"fmvn", []string{strconv.Itoa(r.Game.FullMoveNumber)}},
+			Operation{"hmvc", []string{strconv.Itoa(r.Game.HalfMoveClock)}},
+		)
+	}
+	sort.SliceStable(operations, func(i, j int) bool { return operations[i].Opcode < operations[j].Opcode })
+
+	var sb strings.Builder
+	sb.WriteString(strings.Join(strings.Fields(r.Game.ToFEN())[:4], " "))
+	for _, op := range operations {
+		sb.WriteString(" " + op.Opcode)
+		for _, operand := range op.Operands {
+			if isString(op.Opcode) || operand == "" || strings.ContainsAny(operand, " \t;\"") {
+				operand = `"` + strings.ReplaceAll(operand, `"`, "'") + `"` // Strings can't have quotes
+			}
+			sb.WriteString(" " + operand)
+		}
+		sb.WriteString(";")
+	}
+	return sb.String()
+}
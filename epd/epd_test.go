@@ -0,0 +1,138 @@
+package epd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lan returns an action in long algebraic notation (e.g. "e2e4").
+func lan(a core.Action) string {
+	return a.FromPiece.XY.ToAlgebraic() + a.ToXY.ToAlgebraic()
+}
+
+func lans(actions []core.Action) []string {
+	s := make([]string, len(actions))
+	for i, a := range actions {
+		s[i] = lan(a)
+	}
+	return s
+}
+
+func TestParse(t *testing.T) {
+	r, err := Parse(`r1bq2rk/pp3pbp/2p1p1pQ/7P/3P4/2PB1N2/PP3PPR/2KR4 w - - bm Qxh7+; id "WAC.004"; c0 "mate; in 5"; acd 12; ce 32000; pv Qxh7+ Kxh7 hxg6+;`)
+	require.NoError(t, err)
+	assert.Equal(t, "r1bq2rk/pp3pbp/2p1p1pQ/7P/3P4/2PB1N2/PP3PPR/2KR4 w - - 0 1", r.Game.ToFEN())
+	assert.Equal(t, []string{"h6h7"}, lans(r.BestMoves))
+	assert.Empty(t, r.AvoidMoves)
+	assert.Equal(t, "WAC.004", r.ID)
+	assert.Equal(t, "mate; in 5", r.Comment)
+	require.NotNil(t, r.AnalysisDepth)
+	assert.Equal(t, 12, *r.AnalysisDepth)
+	require.NotNil(t, r.CentipawnEvaluation)
+	assert.Equal(t, 32000, *r.CentipawnEvaluation)
+	assert.Equal(t, []string{"h6h7", "h8h7", "h5g6"}, lans(r.PredictedVariation))
+	assert.Empty(t, r.Operations)
+
+	t.Run("moves may lack their check symbols, and there may be many", func(t *testing.T) {
+		r, err := Parse(`r1bq2rk/pp3pbp/2p1p1pQ/7P/3P4/2PB1N2/PP3PPR/2KR4 w - - am Qxh7 Qxg7+!? O-O`)
+		assert.ErrorIs(t, err, ErrIllegalMove, "White can't castle")
+		r, err = Parse(`r1bq2rk/pp3pbp/2p1p1pQ/7P/3P4/2PB1N2/PP3PPR/2KR4 w - - am Qxh7 Qxg7+!?`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"h6h7", "h6g7"}, lans(r.AvoidMoves))
+	})
+
+	t.Run("move counters and other operations", func(t *testing.T) {
+		r, err := Parse(`4k3/8/8/8/8/8/8/R3K3 w Q - hmvc 7; fmvn 42; dm 1; c1 "x"`)
+		require.NoError(t, err)
+		assert.Equal(t, "4k3/8/8/8/8/8/8/R3K3 w Q - 7 42", r.Game.ToFEN())
+		assert.Equal(t, []Operation{{"dm", []string{"1"}}, {"c1", []string{"x"}}}, r.Operations)
+
+		r, err = Parse(`4k3/8/8/8/8/8/8/R3K3 w Q - 3 9 bm Ra8+;`)
+		require.NoError(t, err, "positions may be full FEN strings")
+		assert.Equal(t, "4k3/8/8/8/8/8/8/R3K3 w Q - 3 9", r.Game.ToFEN())
+		assert.Equal(t, []string{"a1a8"}, lans(r.BestMoves))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for s, expected := range map[string]error{
+			"":                                       ErrInvalidRecord,
+			"4k3/8/8/8/8/8/8/R3K3 w Q":               ErrInvalidRecord,
+			"4k3/8/8/8/8/8/8/R3K3 w Q - 3 bm Ra8":    ErrInvalidRecord,
+			`4k3/8/8/8/8/8/8/R3K3 w Q - id "WAC`:     ErrInvalidOperation,
+			`4k3/8/8/8/8/8/8/R3K3 w Q - ; id "x"`:    ErrInvalidOperation,
+			`4k3/8/8/8/8/8/8/R3K3 w Q - 1x 2;`:       ErrInvalidOperation,
+			`4k3/8/8/8/8/8/8/R3K3 w Q - acd deep;`:   ErrInvalidOperation,
+			`4k3/8/8/8/8/8/8/R3K3 w Q - bm Ra9;`:     ErrIllegalMove,
+			`4k3/8/8/8/8/8/8/R3K3 w Q - pv Ra8 Ra7;`: ErrIllegalMove,
+		} {
+			_, err := Parse(s)
+			assert.ErrorIs(t, err, expected, s)
+		}
+		_, err := Parse(`4k3/8/8/8/8/8/8/R3K3 x Q - bm Ra8;`)
+		var fenErr *core.FENError
+		assert.True(t, errors.As(err, &fenErr))
+	})
+}
+
+func TestString(t *testing.T) {
+	for _, s := range []string{
+		`r1bq2rk/pp3pbp/2p1p1pQ/7P/3P4/2PB1N2/PP3PPR/2KR4 w - - acd 12; bm Qxh7+; c0 "mate; in 5"; ce 32000; id "WAC.004"; pv Qxh7+ Kxh7 hxg6#;`,
+		`4k3/8/8/8/8/8/8/R3K3 w Q - am Ra8+ O-O-O; c1 "x"; dm 1; fmvn 42; hmvc 7;`,
+		`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq -`,
+	} {
+		r, err := Parse(s)
+		require.NoError(t, err)
+		assert.Equal(t, s, r.String())
+	}
+
+	r, err := Parse(`4k3/8/8/8/8/8/8/R3K3 w Q - id WAC; bm Ra8`)
+	require.NoError(t, err)
+	assert.Equal(t, `4k3/8/8/8/8/8/8/R3K3 w Q - bm Ra8+; id "WAC";`, r.String(), "ids are strings")
+	r.ID = `say "hi"`
+	assert.Equal(t, `4k3/8/8/8/8/8/8/R3K3 w Q - bm Ra8+; id "say 'hi'";`, r.String(), "strings can't have quotes")
+}
+
+func TestReaderWriter(t *testing.T) {
+	suite := strings.Join([]string{
+		`# Win at Chess`,
+		`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";`,
+		``,
+		`8/7p/5k2/5p2/p1p2P2/Pr1pPK2/1P1R3P/8 b - - bm Rxb2; id "WAC.002";`,
+		`8/8/8/8/8/8/8/8 w - - id "no kings";`,
+		`5rk1/1ppb3p/p1pb4/6q1/3P1p1r/2P1R2P/PP1BQ1P1/5RKN w - - bm Rg3; id "WAC.003";`,
+	}, "\n")
+	var (
+		reader = NewReader(strings.NewReader(suite))
+		buf    bytes.Buffer
+		writer = NewWriter(&buf)
+		ids    []string
+	)
+	for {
+		r, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var recordErr *RecordError
+		if errors.As(err, &recordErr) {
+			assert.Equal(t, 5, recordErr.Line)
+			continue
+		}
+		require.NoError(t, err)
+		ids = append(ids, r.ID)
+		require.NoError(t, writer.Write(*r))
+	}
+	assert.Equal(t, []string{"WAC.001", "WAC.002", "WAC.003"}, ids)
+	assert.Equal(t, strings.Join([]string{
+		`2rr3k/pp3pp1/1nnqbN1p/3pN3/2pP4/2P3Q1/PPB4P/R4RK1 w - - bm Qg6; id "WAC.001";`,
+		`8/7p/5k2/5p2/p1p2P2/Pr1pPK2/1P1R3P/8 b - - bm Rxb2; id "WAC.002";`,
+		`5rk1/1ppb3p/p1pb4/6q1/3P1p1r/2P1R2P/PP1BQ1P1/5RKN w - - bm Rg3; id "WAC.003";`,
+		``,
+	}, "\n"), buf.String())
+}
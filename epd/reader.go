@@ -0,0 +1,79 @@
+package epd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RecordError is returned by Reader.Read when a record can't be parsed. The
+// record is skipped, and the next call to Read continues with the following one.
+type RecordError struct {
+	// Line is the 1-based line of the record.
+	Line int
+	Err  error
+}
+
+// Error implements error.
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record at line %d: %v", e.Line, e.Err)
+}
+
+// Unwrap returns the reason the record couldn't be parsed.
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the records of an EPD file (e.g. a test suite) one by one, one per
+// line. Blank lines and lines starting with "#" are skipped.
+type Reader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewReader creates a Reader of the EPD file in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{scanner: bufio.NewScanner(r)}
+}
+
+// Read reads and parses the next record. It returns io.EOF when there are no
+// more records.
+//
+// When a record fails to parse, it returns a *RecordError; reading can continue
+// with the next call. Any other error comes from the underlying reader, and ends
+// the reading.
+func (r *Reader) Read() (*Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		record, err := Parse(text)
+		if err != nil {
+			return nil, &RecordError{Line: r.line, Err: err}
+		}
+		return &record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Writer writes records to an EPD file, one per line (see Record.String).
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter creates a Writer of an EPD file to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write writes the record.
+func (w *Writer) Write(r Record) error {
+	_, err := fmt.Fprintln(w.w, r)
+	return err
+}
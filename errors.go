@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/marianogappa/cheesse/api"
+)
+
+// errOut is the envelope of errors, in the server's, CLI's and WASM binary's
+// responses: the error's message, and its api.Error code, field and detail.
+type errOut struct {
+	Error  string        `json:"error"`
+	Code   api.ErrorCode `json:"code"`
+	Field  string        `json:"field,omitempty"`
+	Detail string        `json:"detail,omitempty"`
+}
+
+func newErrOut(err error) errOut {
+	apiErr := api.ErrorOf(err)
+	return errOut{Error: apiErr.Error(), Code: apiErr.Code, Field: apiErr.Field, Detail: apiErr.Detail}
+}
+
+// invalidRequest returns the error of a request that can't be decoded.
+func invalidRequest(err error) error {
+	return &api.Error{Code: api.ErrInvalidRequest.Code, Message: fmt.Sprintf("%v: %v", api.ErrInvalidRequest, err)}
+}
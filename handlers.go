@@ -3,12 +3,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/marianogappa/cheesse/api"
+	"github.com/marianogappa/cheesse/book"
+	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/websocket"
 )
 
 var a = api.New()
@@ -22,16 +28,48 @@ func handleCliDefaultGame() {
 	fmt.Println(string(byts))
 }
 
+type defaultChess960GameRequest struct {
+	ID int `json:"id"`
+}
+
+func handleServerDefaultChess960Game(w http.ResponseWriter, r *http.Request) {
+	var input defaultChess960GameRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+	defer r.Body.Close()
+	outputGame, err := a.DefaultChess960Game(input.ID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(outputGame)
+}
+
+func handleCliDefaultChess960Game(flagDefaultChess960Game *string) {
+	var input defaultChess960GameRequest
+	if err := json.Unmarshal([]byte(*flagDefaultChess960Game), &input); err != nil {
+		mustCliFatal(invalidRequest(err))
+	}
+	outputGame, err := a.DefaultChess960Game(input.ID)
+	if err != nil {
+		mustCliFatal(err)
+	}
+	byts, _ := json.Marshal(outputGame)
+	fmt.Println(string(byts))
+}
+
 func handleServerParseGame(w http.ResponseWriter, r *http.Request) {
 	var ig api.InputGame
-	if err := json.NewDecoder(r.Body).Decode(&ig); err != nil {
-		fmt.Fprintln(w, formatError(err))
+	if err := decodeRequest(r, &ig); err != nil {
+		writeError(w, err)
 		return
 	}
 	defer r.Body.Close()
 	outputGame, err := a.ParseGame(ig)
 	if err != nil {
-		fmt.Fprintln(w, formatError(err))
+		writeError(w, err)
 		return
 	}
 	json.NewEncoder(w).Encode(outputGame)
@@ -40,7 +78,7 @@ func handleServerParseGame(w http.ResponseWriter, r *http.Request) {
 func handleCliParseGame(flagParseGame *string) {
 	var ig api.InputGame
 	if err := json.Unmarshal([]byte(*flagParseGame), &ig); err != nil {
-		mustCliFatal(err)
+		mustCliFatal(invalidRequest(err))
 	}
 	outputGame, err := a.ParseGame(ig)
 	if err != nil {
@@ -50,170 +88,603 @@ func handleCliParseGame(flagParseGame *string) {
 	fmt.Println(string(byts))
 }
 
+type doActionRequest struct {
+	Game   api.InputGame   `json:"game"`
+	Action api.InputAction `json:"action"`
+}
+
+type doActionResponse struct {
+	Game   api.OutputGame   `json:"game"`
+	Action api.OutputAction `json:"action"`
+}
+
 func handleServerDoAction(w http.ResponseWriter, r *http.Request) {
-	type args struct {
-		Game   api.InputGame   `json:"game"`
-		Action api.InputAction `json:"action"`
-	}
-	var input args
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		fmt.Fprintln(w, formatError(err))
+	var input doActionRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
 		return
 	}
 	defer r.Body.Close()
 	outputGame, outputAction, err := a.DoAction(input.Game, input.Action)
 	if err != nil {
-		fmt.Fprintln(w, formatError(err))
+		writeError(w, err)
 		return
 	}
-	type out struct {
-		Game   api.OutputGame   `json:"game"`
-		Action api.OutputAction `json:"action"`
-	}
-	json.NewEncoder(w).Encode(out{outputGame, outputAction})
+	json.NewEncoder(w).Encode(doActionResponse{outputGame, outputAction})
 }
 
 func handleCliDoAction(flagDoAction *string) {
-	type args struct {
-		Game   api.InputGame   `json:"game"`
-		Action api.InputAction `json:"action"`
-	}
-	var input args
+	var input doActionRequest
 	if err := json.Unmarshal([]byte(*flagDoAction), &input); err != nil {
-		mustCliFatal(err)
+		mustCliFatal(invalidRequest(err))
 	}
 	outputGame, outputAction, err := a.DoAction(input.Game, input.Action)
 	if err != nil {
 		mustCliFatal(err)
 	}
-	type out struct {
-		Game   api.OutputGame   `json:"game"`
-		Action api.OutputAction `json:"action"`
-	}
-	byts, _ := json.Marshal(out{outputGame, outputAction})
+	byts, _ := json.Marshal(doActionResponse{outputGame, outputAction})
 	fmt.Println(string(byts))
 }
 
+type parseNotationRequest struct {
+	Game           api.InputGame `json:"game"`
+	NotationString string        `json:"notationString"`
+}
+
+type parseNotationResponse struct {
+	Game        api.OutputGame        `json:"game"`
+	ParseResult api.OutputParseResult `json:"parseResult"`
+}
+
 func handleServerParseNotation(w http.ResponseWriter, r *http.Request) {
-	type args struct {
-		Game           api.InputGame `json:"game"`
-		NotationString string        `json:"notationString"`
-	}
-	var input args
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		fmt.Fprintln(w, formatError(err))
+	var input parseNotationRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
 		return
 	}
 	defer r.Body.Close()
 	outputGame, parseResult, err := a.ParseNotation(input.Game, input.NotationString)
 	if err != nil {
-		fmt.Fprintln(w, formatError(err))
+		writeError(w, err)
 		return
 	}
-	type out struct {
-		Game        api.OutputGame        `json:"game"`
-		ParseResult api.OutputParseResult `json:"parseResult"`
-	}
-	json.NewEncoder(w).Encode(out{outputGame, parseResult})
+	json.NewEncoder(w).Encode(parseNotationResponse{outputGame, parseResult})
 }
 
 func handleCliParseNotation(flagParseNotation *string) {
-	type args struct {
-		Game           api.InputGame `json:"game"`
-		NotationString string        `json:"notationString"`
-	}
-	var input args
+	var input parseNotationRequest
 	if err := json.Unmarshal([]byte(*flagParseNotation), &input); err != nil {
-		mustCliFatal(err)
+		mustCliFatal(invalidRequest(err))
 	}
 	outputGame, parseResult, err := a.ParseNotation(input.Game, input.NotationString)
 	if err != nil {
 		mustCliFatal(err)
 	}
-	type out struct {
-		Game        api.OutputGame        `json:"game"`
-		ParseResult api.OutputParseResult `json:"parseResult"`
-	}
-	byts, _ := json.Marshal(out{outputGame, parseResult})
+	byts, _ := json.Marshal(parseNotationResponse{outputGame, parseResult})
 	fmt.Println(string(byts))
 }
 
+type convertNotationRequest struct {
+	Game           api.InputGame `json:"game"`
+	NotationString string        `json:"notationString"`
+	TargetNotation string        `json:"targetNotation"`
+}
+
+type convertNotationResponse struct {
+	Game        api.OutputGame        `json:"game"`
+	ParseResult api.OutputParseResult `json:"parseResult"`
+}
+
 func handleServerConvertNotation(w http.ResponseWriter, r *http.Request) {
-	type args struct {
-		Game           api.InputGame `json:"game"`
-		NotationString string        `json:"notationString"`
-		TargetNotation string        `json:"targetNotation"`
-	}
-	var input args
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		fmt.Fprintln(w, formatError(err))
+	var input convertNotationRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
 		return
 	}
 	defer r.Body.Close()
 	outputGame, parseResult, err := a.ConvertNotation(input.Game, input.NotationString, input.TargetNotation)
 	if err != nil {
-		fmt.Fprintln(w, formatError(err))
+		writeError(w, err)
 		return
 	}
-	type out struct {
-		Game        api.OutputGame        `json:"game"`
-		ParseResult api.OutputParseResult `json:"parseResult"`
-	}
-	json.NewEncoder(w).Encode(out{outputGame, parseResult})
+	json.NewEncoder(w).Encode(convertNotationResponse{outputGame, parseResult})
 }
 
 func handleCliConvertNotation(flagConvertNotation *string) {
-	type args struct {
-		Game           api.InputGame `json:"game"`
-		NotationString string        `json:"notationString"`
-		TargetNotation string        `json:"targetNotation"`
-	}
-	var input args
+	var input convertNotationRequest
 	if err := json.Unmarshal([]byte(*flagConvertNotation), &input); err != nil {
-		mustCliFatal(err)
+		mustCliFatal(invalidRequest(err))
 	}
 	outputGame, parseResult, err := a.ConvertNotation(input.Game, input.NotationString, input.TargetNotation)
 	if err != nil {
 		mustCliFatal(err)
 	}
-	type out struct {
-		Game        api.OutputGame        `json:"game"`
-		ParseResult api.OutputParseResult `json:"parseResult"`
-	}
-	byts, _ := json.Marshal(out{outputGame, parseResult})
+	byts, _ := json.Marshal(convertNotationResponse{outputGame, parseResult})
 	fmt.Println(string(byts))
 }
 
+type aiMoveRequest struct {
+	Game api.InputGame `json:"game"`
+	Mode string        `json:"mode"`
+}
+
+type aiMoveResponse struct {
+	Game          api.OutputGame   `json:"game"`
+	Action        api.OutputAction `json:"action"`
+	MoveAvailable bool             `json:"moveAvailable"`
+}
+
 func handleServerAIMove(w http.ResponseWriter, r *http.Request) {
-	type args struct {
-		Game api.InputGame `json:"game"`
-		Mode string        `json:"mode"`
-	}
-	var input args
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		fmt.Fprintln(w, formatError(err))
+	var input aiMoveRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
 		return
 	}
 	defer r.Body.Close()
 	outputGame, outputAction, moveAvailable, err := a.AIMove(input.Game, input.Mode)
 	if err != nil {
-		fmt.Fprintln(w, formatError(err))
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(aiMoveResponse{outputGame, outputAction, moveAvailable})
+}
+
+type aiMoveWithLimitsRequest struct {
+	Game   api.InputGame     `json:"game"`
+	Limits api.InputAILimits `json:"limits"`
+}
+
+type aiMoveWithLimitsResponse struct {
+	Game          api.OutputGame         `json:"game"`
+	Action        api.OutputAction       `json:"action"`
+	SearchResult  api.OutputSearchResult `json:"searchResult"`
+	MoveAvailable bool                   `json:"moveAvailable"`
+}
+
+func handleServerAIMoveWithLimits(w http.ResponseWriter, r *http.Request) {
+	var input aiMoveWithLimitsRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+	defer r.Body.Close()
+	outputGame, outputAction, searchResult, moveAvailable, err := a.AIMoveWithLimits(r.Context(), input.Game, input.Limits)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(aiMoveWithLimitsResponse{outputGame, outputAction, searchResult, moveAvailable})
+}
+
+func handleCliAnalyze(flagAnalyze *string) {
+	var input analyzeRequest
+	if err := json.Unmarshal([]byte(*flagAnalyze), &input); err != nil {
+		mustCliFatal(invalidRequest(err))
+	}
+	results, err := a.Analyze(context.Background(), input.Game, input.Options)
+	if err != nil {
+		mustCliFatal(err)
+	}
+	byts, _ := json.Marshal(analyzeResponse{results})
+	fmt.Println(string(byts))
+}
+
+type analyzeRequest struct {
+	Game    api.InputGame           `json:"game"`
+	Options api.InputAnalyzeOptions `json:"options"`
+}
+
+type analyzeResponse struct {
+	Lines []api.OutputSearchResult `json:"lines"`
+}
+
+func handleServerAnalyze(w http.ResponseWriter, r *http.Request) {
+	var input analyzeRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+	defer r.Body.Close()
+	results, err := a.Analyze(r.Context(), input.Game, input.Options)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(analyzeResponse{results})
+}
+
+func handleCliBookMoves(flagBookMoves *string) {
+	var input bookMovesRequest
+	if err := json.Unmarshal([]byte(*flagBookMoves), &input); err != nil {
+		mustCliFatal(invalidRequest(err))
+	}
+	moves, err := a.BookMoves(input.Game)
+	if err != nil {
+		mustCliFatal(err)
+	}
+	byts, _ := json.Marshal(bookMovesResponse{moves})
+	fmt.Println(string(byts))
+}
+
+type bookMovesRequest struct {
+	Game api.InputGame `json:"game"`
+}
+
+type bookMovesResponse struct {
+	Moves []api.OutputBookMove `json:"moves"`
+}
+
+func handleServerBookMoves(w http.ResponseWriter, r *http.Request) {
+	var input bookMovesRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+	defer r.Body.Close()
+	moves, err := a.BookMoves(input.Game)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(bookMovesResponse{moves})
+}
+
+func handleCliParseEPD(flagParseEPD *string) {
+	var input parseEPDRequest
+	if err := json.Unmarshal([]byte(*flagParseEPD), &input); err != nil {
+		mustCliFatal(invalidRequest(err))
+	}
+	outputGame, outputEPD, err := a.ParseEPD(input.EPDString)
+	if err != nil {
+		mustCliFatal(err)
+	}
+	byts, _ := json.Marshal(parseEPDResponse{outputGame, outputEPD})
+	fmt.Println(string(byts))
+}
+
+type parseEPDRequest struct {
+	EPDString string `json:"epdString"`
+}
+
+type parseEPDResponse struct {
+	Game api.OutputGame `json:"game"`
+	EPD  api.OutputEPD  `json:"epd"`
+}
+
+func handleServerParseEPD(w http.ResponseWriter, r *http.Request) {
+	var input parseEPDRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+	defer r.Body.Close()
+	outputGame, outputEPD, err := a.ParseEPD(input.EPDString)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(parseEPDResponse{outputGame, outputEPD})
+}
+
+type createSessionRequest struct {
+	Game           api.InputGame `json:"game"`
+	NotationString string        `json:"notationString"`
+}
+
+// handleServerGames serves POST /games, which creates a game session.
+func handleServerGames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeMethodNotAllowed(w, r, http.MethodPost)
 		return
 	}
-	type out struct {
-		Game          api.OutputGame   `json:"game"`
-		Action        api.OutputAction `json:"action"`
-		MoveAvailable bool             `json:"moveAvailable"`
+	var input createSessionRequest
+	if err := decodeRequest(r, &input); err != nil {
+		writeError(w, err)
+		return
+	}
+	defer r.Body.Close()
+	session, err := a.CreateSession(input.Game, input.NotationString)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/games/"+session.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// sessionActionRequest is the request of POST /games/{id}/actions, and of the
+// messages of the game's WebSocket.
+type sessionActionRequest struct {
+	Action      api.InputAction `json:"action"`
+	ActionCount *int            `json:"actionCount"`
+}
+
+type sessionActionResponse struct {
+	Session api.OutputSession `json:"session"`
+	Action  api.OutputAction  `json:"action"`
+}
+
+// handleServerGame serves the routes of a game session: GET /games/{id},
+// POST /games/{id}/actions, GET /games/{id}/pgn, and its event streams
+// GET /games/{id}/events and GET /games/{id}/ws.
+func handleServerGame(w http.ResponseWriter, r *http.Request) {
+	id, route, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/games/"), "/")
+	switch {
+	case route == "" && r.Method == http.MethodGet:
+		session, err := a.Session(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(session)
+	case route == "actions" && r.Method == http.MethodPost:
+		var input sessionActionRequest
+		if err := decodeRequest(r, &input); err != nil {
+			writeError(w, err)
+			return
+		}
+		defer r.Body.Close()
+		actionCount := -1
+		if input.ActionCount != nil {
+			actionCount = *input.ActionCount
+		}
+		session, action, err := a.DoSessionAction(id, input.Action, actionCount)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(sessionActionResponse{session, action})
+	case route == "pgn" && r.Method == http.MethodGet:
+		pgn, err := a.SessionPGN(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-chess-pgn")
+		fmt.Fprint(w, pgn)
+	case route == "events" && r.Method == http.MethodGet:
+		handleServerGameEvents(w, r, id)
+	case route == "ws" && r.Method == http.MethodGet:
+		handleServerGameWebSocket(w, r, id)
+	case route == "" || route == "pgn" || route == "events" || route == "ws":
+		writeMethodNotAllowed(w, r, http.MethodGet)
+	case route == "actions":
+		writeMethodNotAllowed(w, r, http.MethodPost)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleServerGameEvents streams the events of a game session as Server-Sent
+// Events, resuming after the Last-Event-ID header or lastEventId query parameter.
+func handleServerGameEvents(w http.ResponseWriter, r *http.Request, id string) {
+	lastEventIDString := r.Header.Get("Last-Event-ID")
+	if lastEventIDString == "" {
+		lastEventIDString = r.URL.Query().Get("lastEventId")
+	}
+	lastEventID, err := parseLastEventID(lastEventIDString)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	session, err := a.Session(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// Once a finished game's events are all sent, 204 stops EventSource clients
+	// from reconnecting.
+	if session.Game.IsGameOver && lastEventID == len(session.Actions) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	events, err := a.SessionEvents(r.Context(), id, lastEventID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for event := range events {
+		byts, _ := json.Marshal(event)
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, byts)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// handleServerGameWebSocket streams the events of a game session over a
+// WebSocket, resuming after the lastEventId query parameter, and does the
+// actions it receives (as in POST /games/{id}/actions) on the session. Their
+// errors are sent back; their events are streamed like any other's.
+func handleServerGameWebSocket(w http.ResponseWriter, r *http.Request, id string) {
+	lastEventID, err := parseLastEventID(r.URL.Query().Get("lastEventId"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	events, err := a.SessionEvents(ctx, id, lastEventID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		writeError(w, invalidRequest(err))
+		return
+	}
+	defer conn.Close()
+
+	// The connection is closed after the game's last event.
+	go func() {
+		for event := range events {
+			byts, _ := json.Marshal(event)
+			if conn.WriteMessage(byts) != nil {
+				break
+			}
+		}
+		conn.Close()
+	}()
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var input sessionActionRequest
+		if err := decodeJSON(message, &input); err != nil {
+			conn.WriteMessage([]byte(formatError(err)))
+			continue
+		}
+		actionCount := -1
+		if input.ActionCount != nil {
+			actionCount = *input.ActionCount
+		}
+		if _, _, err := a.DoSessionAction(id, input.Action, actionCount); err != nil {
+			conn.WriteMessage([]byte(formatError(err)))
+		}
+	}
+}
+
+func parseLastEventID(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	lastEventID, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, &api.Error{Code: api.ErrInvalidLastEventID.Code, Message: api.ErrInvalidLastEventID.Message, Field: "lastEventId", Detail: s}
+	}
+	return lastEventID, nil
+}
+
+// errMethodNotAllowed is the code of requests to a route with the wrong method.
+const errMethodNotAllowed api.ErrorCode = "METHOD_NOT_ALLOWED"
+
+// errorCodeOutcomes are the HTTP status and CLI exit code of each error code.
+// Codes that aren't here are internal errors. Exit codes are grouped by kind:
+// 2 for requests, 10s for input games, 20s for actions, 30s for notations, 40s
+// for the AI, 50s for unavailable features and 60s for sessions.
+var errorCodeOutcomes = map[api.ErrorCode]struct{ status, exitCode int }{
+	api.ErrInternal.Code:               {http.StatusInternalServerError, 1},
+	api.ErrInvalidRequest.Code:         {http.StatusBadRequest, 2},
+	errMethodNotAllowed:                {http.StatusMethodNotAllowed, 2},
+	api.ErrInvalidFEN.Code:             {http.StatusBadRequest, 10},
+	api.ErrInvalidBoard.Code:           {http.StatusBadRequest, 11},
+	api.ErrInvalidChess960ID.Code:      {http.StatusBadRequest, 12},
+	api.ErrInvalidPositionHistory.Code: {http.StatusBadRequest, 13},
+	api.ErrInvalidDrawOfferedBy.Code:   {http.StatusBadRequest, 14},
+	api.ErrInvalidTimeControl.Code:     {http.StatusBadRequest, 15},
+	api.ErrInvalidClock.Code:           {http.StatusBadRequest, 16},
+	api.ErrInvalidVariant.Code:         {http.StatusBadRequest, 17},
+	api.ErrInvalidSquare.Code:          {http.StatusBadRequest, 20},
+	api.ErrInvalidPieceType.Code:       {http.StatusBadRequest, 21},
+	api.ErrIllegalMove.Code:            {http.StatusUnprocessableEntity, 22},
+	api.ErrAmbiguousAction.Code:        {http.StatusUnprocessableEntity, 23},
+	api.ErrInvalidElapsedTime.Code:     {http.StatusBadRequest, 24},
+	api.ErrInvalidNotation.Code:        {http.StatusUnprocessableEntity, 30},
+	api.ErrUnknownNotation.Code:        {http.StatusBadRequest, 31},
+	api.ErrInvalidEPD.Code:             {http.StatusUnprocessableEntity, 32},
+	api.ErrUnknownAIMode.Code:          {http.StatusBadRequest, 40},
+	api.ErrMissingAILimits.Code:        {http.StatusBadRequest, 41},
+	api.ErrInvalidAILimits.Code:        {http.StatusBadRequest, 42},
+	api.ErrInvalidAnalyzeLines.Code:    {http.StatusBadRequest, 43},
+	api.ErrNoBook.Code:                 {http.StatusNotImplemented, 50},
+	api.ErrNoSessionStore.Code:         {http.StatusNotImplemented, 51},
+	api.ErrSessionNotFound.Code:        {http.StatusNotFound, 60},
+	api.ErrSessionConflict.Code:        {http.StatusConflict, 61},
+	api.ErrInvalidLastEventID.Code:     {http.StatusBadRequest, 62},
+}
+
+func errorOutcome(err error) (status, exitCode int) {
+	outcome, ok := errorCodeOutcomes[api.ErrorOf(err).Code]
+	if !ok {
+		return http.StatusInternalServerError, 1
+	}
+	return outcome.status, outcome.exitCode
+}
+
+// writeError writes the error, with the HTTP status of its code.
+func writeError(w http.ResponseWriter, err error) {
+	status, _ := errorOutcome(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintln(w, formatError(err))
+}
+
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, &api.Error{Code: errMethodNotAllowed, Message: fmt.Sprintf("method %v not allowed: please use %v", r.Method, allowed)})
+}
+
+func handleCliMakeBook(flagMakeBook *string) {
+	f, err := os.Open(*flagMakeBook)
+	if err != nil {
+		mustCliFatal(err)
+	}
+	defer f.Close()
+	builder := book.NewBuilder()
+	if _, err := builder.AddPGN(f); err != nil {
+		mustCliFatal(err)
+	}
+	if _, err := builder.Book().WriteTo(os.Stdout); err != nil {
+		mustCliFatal(err)
+	}
+}
+
+// perftTableSizeMB is the size of the hash table of the -perft and -perftSuite modes.
+const perftTableSizeMB = 64
+
+func handleCliPerft(flagPerft *int, flagFEN *string) {
+	g := core.NewDefaultGame()
+	if *flagFEN != "" {
+		var err error
+		if g, err = core.NewGameFromFEN(*flagFEN); err != nil {
+			mustCliFatal(err)
+		}
+	}
+	total := 0
+	for _, d := range core.PerftDivide(g, *flagPerft, core.NewPerftTable(perftTableSizeMB)) {
+		fmt.Printf("%v: %v\n", d.Move, d.Nodes)
+		total += d.Nodes
+	}
+	fmt.Printf("\nNodes searched: %v\n", total)
+}
+
+func handleCliPerftSuite(flagPerftSuite *string, flagPerft *int) {
+	f, err := os.Open(*flagPerftSuite)
+	if err != nil {
+		mustCliFatal(err)
+	}
+	defer f.Close()
+	failures, err := core.RunPerftSuite(f, *flagPerft, core.NewPerftTable(perftTableSizeMB), func(r core.PerftSuiteResult) {
+		status := "ok"
+		if !r.Passed() {
+			status = fmt.Sprintf("MISMATCH (expected %v)", r.Expected)
+		}
+		fmt.Printf("line %v depth %v: %v %v\t%v\n", r.Line, r.Depth, r.Nodes, status, r.FEN)
+	})
+	if err != nil {
+		mustCliFatal(err)
+	}
+	if len(failures) > 0 {
+		fmt.Printf("\n%v mismatches\n", len(failures))
+		os.Exit(1)
 	}
-	json.NewEncoder(w).Encode(out{outputGame, outputAction, moveAvailable})
 }
 
+// mustCliFatal prints the error and exits with the exit code of its code.
 func mustCliFatal(err error) {
 	fmt.Println(formatError(err))
-	os.Exit(1)
+	_, exitCode := errorOutcome(err)
+	os.Exit(exitCode)
 }
 
 func formatError(err error) string {
-	errByts, _ := json.Marshal(err.Error())
-	return fmt.Sprintf(`{"error": %v}`, string(errByts))
+	errByts, _ := json.Marshal(newErrOut(err))
+	return string(errByts)
 }
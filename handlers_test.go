@@ -0,0 +1,244 @@
+// +build !tinygo
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/marianogappa/cheesse/api"
+	"github.com/marianogappa/cheesse/openapi"
+	"github.com/marianogappa/cheesse/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// operaGame is Morphy's Opera Game (Paris, 1858), which ends in checkmate.
+var operaGame = strings.Fields("e4 e5 Nf3 d6 d4 Bg4 dxe5 Bxf3 Qxf3 dxe5 Bc4 Nf6 Qb3 Qe7 Nc3 c6 Bg5 b5 Nxb5 cxb5 Bxb5+ Nbd7 O-O-O Rd8 Rxd7 Rxd7 Rd1 Qe6 Bxd7+ Nxd7 Qb8+ Nxb8 Rd8#")
+
+func newGamesServer(t *testing.T) *httptest.Server {
+	a = api.New().WithSessionStore(api.NewMemorySessionStore())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/games", handleServerGames)
+	mux.HandleFunc("/games/", handleServerGame)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func createGame(t *testing.T, server *httptest.Server) string {
+	resp, err := http.Post(server.URL+"/games", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var session api.OutputSession
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&session))
+	return session.ID
+}
+
+func readWebSocketEvent(t *testing.T, conn *websocket.Conn) api.OutputSessionEvent {
+	t.Helper()
+	message, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var event api.OutputSessionEvent
+	require.NoError(t, json.Unmarshal(message, &event))
+	return event
+}
+
+// readServerSentEvents reads the SSE stream's events until it ends or there are
+// max of them, returning them and the response's status.
+func readServerSentEvents(t *testing.T, url string, lastEventID, max int) ([]api.OutputSessionEvent, int) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.Itoa(lastEventID))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var (
+		events  []api.OutputSessionEvent
+		scanner = bufio.NewScanner(resp.Body)
+		id      string
+	)
+	for len(events) < max && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			var event api.OutputSessionEvent
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+			assert.Equal(t, id, strconv.Itoa(event.ID))
+			events = append(events, event)
+		}
+	}
+	return events, resp.StatusCode
+}
+
+func TestServerGameStreaming(t *testing.T) {
+	server := newGamesServer(t)
+	id := createGame(t, server)
+	wsURL := fmt.Sprintf("ws%s/games/%s/ws", strings.TrimPrefix(server.URL, "http"), id)
+	eventsURL := fmt.Sprintf("%s/games/%s/events", server.URL, id)
+
+	// A spectator follows the game over SSE, and drops the connection midway.
+	spectated := make(chan []api.OutputSessionEvent)
+	go func() {
+		events, status := readServerSentEvents(t, eventsURL, 0, 10)
+		assert.Equal(t, http.StatusOK, status)
+		rest, _ := readServerSentEvents(t, eventsURL, len(events), len(operaGame))
+		spectated <- append(events, rest...)
+	}()
+
+	var players [2]*websocket.Conn
+	for i := range players {
+		conn, err := websocket.Dial(context.Background(), wsURL)
+		require.NoError(t, err)
+		defer conn.Close()
+		players[i] = conn
+	}
+
+	t.Run("errors are sent back to the player", func(t *testing.T) {
+		require.NoError(t, players[1].WriteMessage([]byte(`{"action":{"actionString":"e5"}}`)))
+		message, err := players[1].ReadMessage()
+		require.NoError(t, err)
+		assert.Contains(t, string(message), "error")
+	})
+
+	for i, move := range operaGame {
+		message, _ := json.Marshal(map[string]interface{}{"action": api.InputAction{ActionString: move}, "actionCount": i})
+		require.NoError(t, players[i%2].WriteMessage(message))
+		for _, player := range players {
+			event := readWebSocketEvent(t, player)
+			assert.Equal(t, i+1, event.ID)
+			assert.Equal(t, move, event.Action.ActionString)
+		}
+	}
+
+	// The game is over: the server closes the connections.
+	for _, player := range players {
+		_, err := player.ReadMessage()
+		assert.Equal(t, io.EOF, err)
+	}
+
+	events := <-spectated
+	require.Len(t, events, len(operaGame))
+	for i, event := range events {
+		assert.Equal(t, i+1, event.ID)
+		assert.Equal(t, operaGame[i], event.Action.ActionString)
+	}
+	last := events[len(events)-1]
+	assert.True(t, last.IsCheckmate)
+	assert.True(t, last.IsGameOver)
+	assert.Equal(t, "White", last.GameOverWinner)
+	assert.Equal(t, "1n1Rkb1r/p4ppp/4q3/4p1B1/4P3/8/PPP2PPP/2K5 b k - 1 17", last.FENString)
+
+	t.Run("reconnecting after the end of the game", func(t *testing.T) {
+		events, status := readServerSentEvents(t, eventsURL, len(operaGame), 1)
+		assert.Empty(t, events)
+		assert.Equal(t, http.StatusNoContent, status)
+
+		// A late WebSocket client gets the events it missed, and then the connection is closed.
+		conn, err := websocket.Dial(context.Background(), wsURL+"?lastEventId=31")
+		require.NoError(t, err)
+		defer conn.Close()
+		assert.Equal(t, 32, readWebSocketEvent(t, conn).ID)
+		assert.Equal(t, 33, readWebSocketEvent(t, conn).ID)
+		_, err = conn.ReadMessage()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("unknown games and invalid event ids", func(t *testing.T) {
+		_, err := websocket.Dial(context.Background(), strings.Replace(wsURL, id, "unknown", 1))
+		assert.ErrorContains(t, err, "404")
+		_, status := readServerSentEvents(t, eventsURL+"?lastEventId=34", 0, 1)
+		assert.Equal(t, http.StatusBadRequest, status)
+	})
+}
+
+func TestServerErrors(t *testing.T) {
+	a = api.New()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/doAction", handleServerDoAction)
+	mux.HandleFunc("/bookMoves", handleServerBookMoves)
+	mux.HandleFunc("/games/", handleServerGame)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ts := []struct {
+		path, body string
+		status     int
+		errOut     errOut
+	}{
+		{"/doAction", `{"action":{"fromSquare":"e2","toSquare":"e5"}}`, http.StatusUnprocessableEntity, errOut{Code: "ILLEGAL_MOVE"}},
+		{"/doAction", `{"game":{"fenString":"8/8/8 w - - 0 1"}}`, http.StatusBadRequest, errOut{Code: "INVALID_FEN", Field: "fenString", Detail: "piecePlacement"}},
+		{"/doAction", `{"action":{"fromSquare":"e2","toSquare":"e9"}}`, http.StatusBadRequest, errOut{Code: "INVALID_SQUARE", Field: "toSquare", Detail: "e9"}},
+		{"/doAction", `{"game":`, http.StatusBadRequest, errOut{Code: "INVALID_REQUEST", Detail: "invalid JSON: unexpected EOF"}},
+		{"/doAction", `{"game":{"fen":"8/8/8/8/8/8/8/8 w - - 0 1"}}`, http.StatusBadRequest, errOut{Code: "INVALID_REQUEST", Field: "game.fen", Detail: "unknown field"}},
+		{"/doAction", `{"action":{"elapsedMs":"3s"}}`, http.StatusBadRequest, errOut{Code: "INVALID_REQUEST", Field: "action.elapsedMs", Detail: "must be an integer"}},
+		{"/bookMoves", `{}`, http.StatusNotImplemented, errOut{Code: "NO_BOOK"}},
+		{"/games/unknown/actions", `{}`, http.StatusNotImplemented, errOut{Code: "NO_SESSION_STORE"}},
+	}
+	for _, tc := range ts {
+		t.Run(tc.path+" "+tc.body, func(t *testing.T) {
+			resp, err := http.Post(server.URL+tc.path, "application/json", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tc.status, resp.StatusCode)
+			var actual errOut
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&actual))
+			assert.NotEmpty(t, actual.Error)
+			actual.Error = ""
+			assert.Equal(t, tc.errOut, actual)
+		})
+	}
+
+	_, exitCode := errorOutcome(api.ErrIllegalMove)
+	assert.Equal(t, 22, exitCode)
+	_, exitCode = errorOutcome(io.ErrUnexpectedEOF)
+	assert.Equal(t, 1, exitCode, "errors that aren't API errors are internal")
+}
+
+func TestServerOpenAPI(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openapi.json", handleServerOpenAPI)
+	mux.HandleFunc("/docs/", handleServerDocs)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var document openapi.Document
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&document))
+	assert.Equal(t, openapi.Version, document.OpenAPI)
+	for _, path := range []string{"/parseGame", "/doAction", "/parseNotation", "/convertNotation", "/aiMove", "/games/{id}/actions"} {
+		assert.Contains(t, document.Paths, path)
+	}
+	doAction := document.Paths["/doAction"]["post"]
+	require.NotNil(t, doAction)
+	assert.Equal(t, "#/components/schemas/DoActionRequest", doAction.RequestBody.Content["application/json"].Schema.Ref)
+	assert.Equal(t, "#/components/schemas/DoActionResponse", doAction.Responses["200"].Content["application/json"].Schema.Ref)
+	assert.Contains(t, document.Components.Schemas["InputAction"].Properties, "actionString")
+
+	resp, err = http.Get(server.URL + "/docs/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+
+	resp, err = http.Get(server.URL + "/docs/cheesse.wasm")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "only the docs' assets are served")
+}
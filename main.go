@@ -6,32 +6,82 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
+
+	"github.com/marianogappa/cheesse/api"
+	"github.com/marianogappa/cheesse/book"
+	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/uci"
 )
 
 var (
 	flagServe         = flag.Int("serve", 0, "Start a server on the specified port.")
+	flagUCI           = flag.Bool("uci", false, "Run as a UCI (Universal Chess Interface) engine over stdin/stdout.")
 	flagDefaultGame   = flag.Bool("defaultGame", false, "Default API call. Returns a default game.")
+	flagDefaultChess960Game = flag.String("defaultChess960Game", "", "DefaultChess960Game API call. Requires a JSON string with arguments. Please review spec.")
 	flagParseGame     = flag.String("parseGame", "", "ParseGame API call. Requires a JSON string with arguments. Please review spec.")
 	flagDoAction      = flag.String("doAction", "", "DoAction API call. Requires a JSON string with arguments. Please review spec.")
 	flagParseNotation   = flag.String("parseNotation", "", "ParseNotation API call. Requires a JSON string with arguments. Please review spec.")
 	flagConvertNotation = flag.String("convertNotation", "", "ConvertNotation API call. Requires a JSON string with arguments. Please review spec.")
+	flagAnalyze         = flag.String("analyze", "", "Analyze API call. Requires a JSON string with arguments. Please review spec.")
+	flagBookMoves       = flag.String("bookMoves", "", "BookMoves API call. Requires a JSON string with arguments and the -book flag. Please review spec.")
+	flagParseEPD        = flag.String("parseEPD", "", "ParseEPD API call. Requires a JSON string with arguments. Please review spec.")
+	flagBook            = flag.String("book", "", "Path to a Polyglot opening book (.bin) for the aiMove and bookMoves API calls.")
+	flagMakeBook        = flag.String("makeBook", "", "Builds a Polyglot opening book out of the games of the specified PGN file, and writes it to stdout.")
+	flagSyzygy          = flag.String("syzygy", "", "Directories with Syzygy endgame tablebases (.rtbw and .rtbz files), separated like in PATH, for the AI and to annotate games.")
+	flagPerft           = flag.Int("perft", 0, "Counts the leaf nodes of the move tree at the specified depth, divided by root move, for the -fen position. Used to debug move generation.")
+	flagFEN             = flag.String("fen", "", "FEN string of the position for -perft. Defaults to the starting position.")
+	flagOpenAPI         = flag.Bool("openapi", false, "Prints the OpenAPI spec of the server's API, which the API calls' JSON arguments are the requests of.")
+	flagPerftSuite      = flag.String("perftSuite", "", "Runs the perft suite of the specified EPD file (e.g. \"<fen> ;D1 20 ;D2 400\") up to the -perft depth, or to every depth if not set, and reports mismatches.")
 )
 
 func main() {
 	flag.Parse()
 
+	if *flagBook != "" {
+		b, err := book.Open(*flagBook)
+		if err != nil {
+			mustCliFatal(err)
+		}
+		a = a.WithBook(b)
+	}
+	if *flagSyzygy != "" {
+		tb, err := core.OpenTablebase(*flagSyzygy)
+		if err != nil {
+			mustCliFatal(err)
+		}
+		a = a.WithTablebase(tb)
+	}
+	a = a.WithSessionStore(api.NewMemorySessionStore())
+
 	http.HandleFunc("/parseGame", handleServerParseGame)
 	http.HandleFunc("/defaultGame", handleServerDefaultGame)
+	http.HandleFunc("/defaultChess960Game", handleServerDefaultChess960Game)
 	http.HandleFunc("/doAction", handleServerDoAction)
 	http.HandleFunc("/parseNotation", handleServerParseNotation)
 	http.HandleFunc("/convertNotation", handleServerConvertNotation)
 	http.HandleFunc("/aiMove", handleServerAIMove)
+	http.HandleFunc("/aiMoveWithLimits", handleServerAIMoveWithLimits)
+	http.HandleFunc("/analyze", handleServerAnalyze)
+	http.HandleFunc("/bookMoves", handleServerBookMoves)
+	http.HandleFunc("/parseEPD", handleServerParseEPD)
+	http.HandleFunc("/games", handleServerGames)
+	http.HandleFunc("/games/", handleServerGame)
+	http.HandleFunc("/openapi.json", handleServerOpenAPI)
+	http.HandleFunc("/docs", handleServerDocs)
+	http.HandleFunc("/docs/", handleServerDocs)
 
 	switch {
 	case *flagServe != 0:
 		http.ListenAndServe(fmt.Sprintf(":%v", *flagServe), nil)
+	case *flagUCI:
+		if err := uci.New(os.Stdout).Run(os.Stdin); err != nil {
+			mustCliFatal(err)
+		}
 	case *flagDefaultGame:
 		handleCliDefaultGame()
+	case *flagDefaultChess960Game != "":
+		handleCliDefaultChess960Game(flagDefaultChess960Game)
 	case *flagParseGame != "":
 		handleCliParseGame(flagParseGame)
 	case *flagDoAction != "":
@@ -40,5 +90,19 @@ func main() {
 		handleCliParseNotation(flagParseNotation)
 	case *flagConvertNotation != "":
 		handleCliConvertNotation(flagConvertNotation)
+	case *flagAnalyze != "":
+		handleCliAnalyze(flagAnalyze)
+	case *flagBookMoves != "":
+		handleCliBookMoves(flagBookMoves)
+	case *flagParseEPD != "":
+		handleCliParseEPD(flagParseEPD)
+	case *flagOpenAPI:
+		handleCliOpenAPI()
+	case *flagMakeBook != "":
+		handleCliMakeBook(flagMakeBook)
+	case *flagPerftSuite != "":
+		handleCliPerftSuite(flagPerftSuite, flagPerft)
+	case *flagPerft > 0:
+		handleCliPerft(flagPerft, flagFEN)
 	}
 }
@@ -4,10 +4,13 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"syscall/js"
 
 	"github.com/marianogappa/cheesse/api"
+	"github.com/marianogappa/cheesse/book"
 )
 
 // The WASM bindings expose the full cheesse API as synchronous JS globals.
@@ -17,15 +20,22 @@ import (
 // tags of the api package entities (camelCase), so the JS shapes are exactly the
 // same as the HTTP server's.
 //
-// Response envelope: {"error": "..."} on failure, otherwise the same shape as the
-// corresponding HTTP endpoint's response.
+// Response envelope: {"error": "...", "code": "...", "field": "...", "detail": "..."}
+// on failure (see api.Error; field and detail are optional), otherwise the same
+// shape as the corresponding HTTP endpoint's response.
 func main() {
 	js.Global().Set("cheesseDefaultGame", js.FuncOf(jsDefaultGame))
+	js.Global().Set("cheesseDefaultChess960Game", js.FuncOf(jsDefaultChess960Game))
 	js.Global().Set("cheesseParseGame", js.FuncOf(jsParseGame))
 	js.Global().Set("cheesseDoAction", js.FuncOf(jsDoAction))
 	js.Global().Set("cheesseParseNotation", js.FuncOf(jsParseNotation))
 	js.Global().Set("cheesseConvertNotation", js.FuncOf(jsConvertNotation))
 	js.Global().Set("cheesseAIMove", js.FuncOf(jsAIMove))
+	js.Global().Set("cheesseAIMoveWithLimits", js.FuncOf(jsAIMoveWithLimits))
+	js.Global().Set("cheesseAnalyze", js.FuncOf(jsAnalyze))
+	js.Global().Set("cheesseLoadBook", js.FuncOf(jsLoadBook))
+	js.Global().Set("cheesseBookMoves", js.FuncOf(jsBookMoves))
+	js.Global().Set("cheesseParseEPD", js.FuncOf(jsParseEPD))
 	select {}
 }
 
@@ -38,6 +48,24 @@ func jsDefaultGame(this js.Value, p []js.Value) interface{} {
 	return toJS(out{a.DefaultGame()}, nil)
 }
 
+func jsDefaultChess960Game(this js.Value, p []js.Value) interface{} {
+	type args struct {
+		ID int `json:"id"`
+	}
+	var input args
+	if err := fromJS(p[0], &input); err != nil {
+		return toJS(nil, err)
+	}
+	outputGame, err := a.DefaultChess960Game(input.ID)
+	if err != nil {
+		return toJS(nil, err)
+	}
+	type out struct {
+		Game api.OutputGame `json:"game"`
+	}
+	return toJS(out{outputGame}, nil)
+}
+
 func jsParseGame(this js.Value, p []js.Value) interface{} {
 	type args struct {
 		Game api.InputGame `json:"game"`
@@ -138,15 +166,113 @@ func jsAIMove(this js.Value, p []js.Value) interface{} {
 	return toJS(out{outputGame, outputAction, moveAvailable}, nil)
 }
 
+func jsAIMoveWithLimits(this js.Value, p []js.Value) interface{} {
+	type args struct {
+		Game   api.InputGame     `json:"game"`
+		Limits api.InputAILimits `json:"limits"`
+	}
+	var input args
+	if err := fromJS(p[0], &input); err != nil {
+		return toJS(nil, err)
+	}
+	outputGame, outputAction, searchResult, moveAvailable, err := a.AIMoveWithLimits(context.Background(), input.Game, input.Limits)
+	if err != nil {
+		return toJS(nil, err)
+	}
+	type out struct {
+		Game          api.OutputGame         `json:"game"`
+		Action        api.OutputAction       `json:"action"`
+		SearchResult  api.OutputSearchResult `json:"searchResult"`
+		MoveAvailable bool                   `json:"moveAvailable"`
+	}
+	return toJS(out{outputGame, outputAction, searchResult, moveAvailable}, nil)
+}
+
+func jsAnalyze(this js.Value, p []js.Value) interface{} {
+	type args struct {
+		Game    api.InputGame           `json:"game"`
+		Options api.InputAnalyzeOptions `json:"options"`
+	}
+	var input args
+	if err := fromJS(p[0], &input); err != nil {
+		return toJS(nil, err)
+	}
+	results, err := a.Analyze(context.Background(), input.Game, input.Options)
+	if err != nil {
+		return toJS(nil, err)
+	}
+	type out struct {
+		Lines []api.OutputSearchResult `json:"lines"`
+	}
+	return toJS(out{results}, nil)
+}
+
+// jsLoadBook loads the Polyglot opening book used by cheesseAIMove and
+// cheesseBookMoves. The .bin file's bytes are base64-encoded in the `book` field.
+func jsLoadBook(this js.Value, p []js.Value) interface{} {
+	type args struct {
+		Book []byte `json:"book"`
+	}
+	var input args
+	if err := fromJS(p[0], &input); err != nil {
+		return toJS(nil, err)
+	}
+	b, err := book.Read(bytes.NewReader(input.Book))
+	if err != nil {
+		return toJS(nil, invalidRequest(err))
+	}
+	a = a.WithBook(b)
+	type out struct {
+		Entries int `json:"entries"`
+	}
+	return toJS(out{b.Len()}, nil)
+}
+
+func jsBookMoves(this js.Value, p []js.Value) interface{} {
+	type args struct {
+		Game api.InputGame `json:"game"`
+	}
+	var input args
+	if err := fromJS(p[0], &input); err != nil {
+		return toJS(nil, err)
+	}
+	moves, err := a.BookMoves(input.Game)
+	if err != nil {
+		return toJS(nil, err)
+	}
+	type out struct {
+		Moves []api.OutputBookMove `json:"moves"`
+	}
+	return toJS(out{moves}, nil)
+}
+
+func jsParseEPD(this js.Value, p []js.Value) interface{} {
+	type args struct {
+		EPDString string `json:"epdString"`
+	}
+	var input args
+	if err := fromJS(p[0], &input); err != nil {
+		return toJS(nil, err)
+	}
+	outputGame, outputEPD, err := a.ParseEPD(input.EPDString)
+	if err != nil {
+		return toJS(nil, err)
+	}
+	type out struct {
+		Game api.OutputGame `json:"game"`
+		EPD  api.OutputEPD  `json:"epd"`
+	}
+	return toJS(out{outputGame, outputEPD}, nil)
+}
+
 // fromJS reads a Uint8Array JS value containing JSON into dst.
 func fromJS(v js.Value, dst interface{}) error {
 	jsonBytes := make([]byte, v.Length())
 	js.CopyBytesToGo(jsonBytes, v)
-	return json.Unmarshal(jsonBytes, dst)
-}
-
-type errOut struct {
-	Error string `json:"error"`
+	if err := json.Unmarshal(jsonBytes, dst); err != nil {
+		return invalidRequest(err)
+	}
+	return nil
 }
 
 // toJS marshals a response (or an error envelope) to JSON and returns it as a
@@ -154,11 +280,11 @@ type errOut struct {
 func toJS(response interface{}, err error) js.Value {
 	var bs []byte
 	if err != nil {
-		bs, _ = json.Marshal(errOut{err.Error()})
+		bs, _ = json.Marshal(newErrOut(err))
 	} else {
 		bs, err = json.Marshal(response)
 		if err != nil {
-			bs, _ = json.Marshal(errOut{err.Error()})
+			bs, _ = json.Marshal(newErrOut(err))
 		}
 	}
 	buffer := js.Global().Get("Uint8Array").New(len(bs))
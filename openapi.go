@@ -0,0 +1,161 @@
+// +build !tinygo
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/marianogappa/cheesse/api"
+	"github.com/marianogappa/cheesse/openapi"
+)
+
+// serverOperation is an operation of the server, as its OpenAPI spec describes it.
+// Its request and response are the types of their JSON bodies, if any.
+type serverOperation struct {
+	method, path, id, summary string
+	request, response         interface{}
+	status                    int
+	parameters                []openapi.Parameter
+	content                   map[string]openapi.MediaType // of responses that aren't JSON
+}
+
+var gameIDParameter = openapi.Parameter{Name: "id", In: "path", Required: true, Description: "The id of the game session.", Schema: &openapi.Schema{Type: "string"}}
+
+var serverOperations = []serverOperation{
+	{method: http.MethodPost, path: "/defaultGame", id: "defaultGame", summary: "Returns the initial game of chess.", response: api.OutputGame{}},
+	{method: http.MethodPost, path: "/defaultChess960Game", id: "defaultChess960Game", summary: "Returns the initial game of Chess960 for a start position id between 0 and 959.", request: defaultChess960GameRequest{}, response: api.OutputGame{}},
+	{method: http.MethodPost, path: "/parseGame", id: "parseGame", summary: "Parses a game, returning its state, its legal actions and whether it's over.", request: api.InputGame{}, response: api.OutputGame{}},
+	{method: http.MethodPost, path: "/doAction", id: "doAction", summary: "Does an action on a game, returning the resulting game.", request: doActionRequest{}, response: doActionResponse{}},
+	{method: http.MethodPost, path: "/parseNotation", id: "parseNotation", summary: "Plays a match in any supported notation, which is auto-detected, from a game.", request: parseNotationRequest{}, response: parseNotationResponse{}},
+	{method: http.MethodPost, path: "/convertNotation", id: "convertNotation", summary: "Converts a match in any supported notation to the target notation.", request: convertNotationRequest{}, response: convertNotationResponse{}},
+	{method: http.MethodPost, path: "/aiMove", id: "aiMove", summary: "Selects and does a move for the side to move, with one of the AI modes.", request: aiMoveRequest{}, response: aiMoveResponse{}},
+	{method: http.MethodPost, path: "/aiMoveWithLimits", id: "aiMoveWithLimits", summary: "Selects and does a move for the side to move, searching until the limits are reached.", request: aiMoveWithLimitsRequest{}, response: aiMoveWithLimitsResponse{}},
+	{method: http.MethodPost, path: "/analyze", id: "analyze", summary: "Finds the best moves for the side to move, each with its principal variation.", request: analyzeRequest{}, response: analyzeResponse{}},
+	{method: http.MethodPost, path: "/bookMoves", id: "bookMoves", summary: "Returns the opening book's moves for a game.", request: bookMovesRequest{}, response: bookMovesResponse{}},
+	{method: http.MethodPost, path: "/parseEPD", id: "parseEPD", summary: "Parses a position in Extended Position Description, returning its game and its operations.", request: parseEPDRequest{}, response: parseEPDResponse{}},
+	{method: http.MethodPost, path: "/games", id: "createSession", summary: "Creates a game session, from a game and a match in any supported notation.", request: createSessionRequest{}, response: api.OutputSession{}, status: http.StatusCreated},
+	{method: http.MethodGet, path: "/games/{id}", id: "session", summary: "Returns a game session.", response: api.OutputSession{}, parameters: []openapi.Parameter{gameIDParameter}},
+	{method: http.MethodPost, path: "/games/{id}/actions", id: "doSessionAction", summary: "Does an action on a game session, if it has `actionCount` actions (if supplied).", request: sessionActionRequest{}, response: sessionActionResponse{}, parameters: []openapi.Parameter{gameIDParameter}},
+	{method: http.MethodGet, path: "/games/{id}/pgn", id: "sessionPGN", summary: "Returns the PGN document of a game session.", parameters: []openapi.Parameter{gameIDParameter},
+		content: map[string]openapi.MediaType{"application/x-chess-pgn": {Schema: &openapi.Schema{Type: "string"}}}},
+	{method: http.MethodGet, path: "/games/{id}/events", id: "sessionEvents", summary: "Streams the events of a game session as Server-Sent Events, whose data are OutputSessionEvent objects.", parameters: []openapi.Parameter{gameIDParameter, lastEventIDParameter("header", "Last-Event-ID"), lastEventIDParameter("query", "lastEventId")},
+		content: map[string]openapi.MediaType{"text/event-stream": {Schema: &openapi.Schema{Type: "string"}}}},
+	{method: http.MethodGet, path: "/games/{id}/ws", id: "sessionWebSocket", summary: "Streams the events of a game session over a WebSocket, which takes the requests of doSessionAction as messages.", parameters: []openapi.Parameter{gameIDParameter, lastEventIDParameter("query", "lastEventId")}, status: http.StatusSwitchingProtocols},
+}
+
+func lastEventIDParameter(in, name string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: in, Description: "The id of the last event the client got, to resume after it.", Schema: &openapi.Schema{Type: "integer"}}
+}
+
+// spec is the server's OpenAPI spec, and requestSchemas the schemas its requests
+// are validated against, by type.
+var spec, requestSchemas = newOpenAPISpec()
+
+func newOpenAPISpec() (*openapi.Document, map[reflect.Type]*openapi.Schema) {
+	d := openapi.NewDocument(openapi.Info{
+		Title:       "cheesse",
+		Description: "A chess API: games in FEN or as boards, their legal actions, notations, AI moves and game sessions. Errors are ErrOut objects with the code's HTTP status.",
+		Version:     "1",
+	})
+	requestSchemas := map[reflect.Type]*openapi.Schema{}
+	for _, op := range serverOperations {
+		operation := &openapi.Operation{
+			OperationID: op.id,
+			Summary:     op.summary,
+			Parameters:  op.parameters,
+			Responses: map[string]openapi.Response{
+				"default": {Description: "An error.", Content: d.JSON(reflect.TypeOf(errOut{}))},
+			},
+		}
+		if op.request != nil {
+			t := reflect.TypeOf(op.request)
+			operation.RequestBody = &openapi.RequestBody{Content: d.JSON(t)}
+			requestSchemas[t] = d.Schema(t)
+		}
+		status, response := op.status, openapi.Response{Description: "Success.", Content: op.content}
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if op.response != nil {
+			response.Content = d.JSON(reflect.TypeOf(op.response))
+		}
+		operation.Responses[fmt.Sprint(status)] = response
+		d.AddOperation(op.path, op.method, operation)
+	}
+	d.Schema(reflect.TypeOf(api.OutputSessionEvent{}))
+	return d, requestSchemas
+}
+
+// decodeRequest decodes the JSON body of the request (see decodeJSON). An empty
+// body is an empty object.
+func decodeRequest(r *http.Request, dst interface{}) error {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return invalidRequest(err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		body = []byte("{}")
+	}
+	return decodeJSON(body, dst)
+}
+
+// decodeJSON decodes the JSON value into dst, after validating it against the
+// schema of dst's type in the server's OpenAPI spec: so, unlike encoding/json, it
+// doesn't allow unknown fields.
+func decodeJSON(data []byte, dst interface{}) error {
+	if schema, ok := requestSchemas[reflect.TypeOf(dst).Elem()]; ok {
+		if err := spec.Validate(schema, data); err != nil {
+			validationErr := err.(*openapi.ValidationError)
+			return &api.Error{
+				Code:    api.ErrInvalidRequest.Code,
+				Message: fmt.Sprintf("%v: %v", api.ErrInvalidRequest, err),
+				Field:   validationErr.Path,
+				Detail:  validationErr.Reason,
+			}
+		}
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return invalidRequest(err)
+	}
+	return nil
+}
+
+func handleServerOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(spec)
+}
+
+func handleCliOpenAPI() {
+	byts, _ := json.MarshalIndent(spec, "", "  ")
+	fmt.Println(string(byts))
+}
+
+//go:embed site/docs.html site/style.css site/logo.svg site/common.js
+var docsFS embed.FS
+
+// handleServerDocs serves the docs page of the OpenAPI spec at /docs/, with its
+// assets.
+func handleServerDocs(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/docs" {
+		http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/docs/")
+	if name == "" {
+		name = "docs.html"
+	}
+	byts, err := docsFS.ReadFile("site/" + name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(byts))
+}
@@ -0,0 +1,225 @@
+// Package openapi describes HTTP APIs as OpenAPI 3 documents, with the schemas of
+// their requests and responses generated from the Go types that encoding/json
+// decodes and encodes them as, and validates JSON values against those schemas.
+//
+// It only supports as much of OpenAPI as cheesse's server needs.
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Version is the version of OpenAPI of the documents.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document. Please use NewDocument to construct it.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info is the metadata of the API that a Document describes.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem are the operations of a path, by lowercase HTTP method (e.g. "post").
+type PathItem map[string]*Operation
+
+// Operation is an API operation: a path and an HTTP method.
+type Operation struct {
+	OperationID string              `json:"operationId,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter is a parameter of an Operation, e.g. in its path or its query string.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body of an Operation's requests, by media type.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an Operation, whose content is by media type.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a request's or response's body of a media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components are the schemas that other schemas of a Document reference, by name.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the schema of a JSON value. Objects' AdditionalProperties is either
+// the *Schema of their values (of maps), or false (of structs). Values of a
+// schema with AllOf are also values of each of its schemas.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties interface{}        `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+// NewDocument constructs a Document without paths.
+func NewDocument(info Info) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+}
+
+// AddOperation adds the operation of the given path and HTTP method.
+func (d *Document) AddOperation(path, method string, operation *Operation) {
+	if d.Paths[path] == nil {
+		d.Paths[path] = PathItem{}
+	}
+	d.Paths[path][strings.ToLower(method)] = operation
+}
+
+// JSON returns the content of a JSON request or response with the schema of the
+// given type (see Schema).
+func (d *Document) JSON(t reflect.Type) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: d.Schema(t)}}
+}
+
+// Schema returns the schema of the JSON values that encoding/json encodes values
+// of the given type as. Named struct types are added to the document's components,
+// by their name with an uppercase first letter, and referenced.
+func (d *Document) Schema(t reflect.Type) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Ptr:
+		s := d.Schema(t.Elem())
+		if s.Ref != "" {
+			// $ref siblings are ignored, so the nullable reference needs a wrapper.
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte", Nullable: true}
+		}
+		return &Schema{Type: "array", Items: d.Schema(t.Elem()), Nullable: true}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: d.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.Schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := componentName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			d.Components.Schemas[name] = &Schema{} // Placeholder for recursive types
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + name}
+	}
+	return &Schema{} // Any value
+}
+
+// structSchema returns the schema of the objects of the struct type's fields.
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: false}
+	d.addFields(s, t)
+	return s
+}
+
+// addFields adds the properties of the struct type's fields to the schema, with
+// those of embedded structs in it, as encoding/json does.
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = d.Schema(field.Type)
+	}
+	// The fields of embedded structs are shadowed by the struct's own.
+	for _, ft := range embedded {
+		embeddedSchema := &Schema{Properties: map[string]*Schema{}}
+		d.addFields(embeddedSchema, ft)
+		for name, property := range embeddedSchema.Properties {
+			if _, ok := s.Properties[name]; !ok {
+				s.Properties[name] = property
+			}
+		}
+	}
+}
+
+func componentName(t reflect.Type) string {
+	r, size := utf8.DecodeRuneInString(t.Name())
+	return string(unicode.ToUpper(r)) + t.Name()[size:]
+}
+
+// resolve returns the schema that the schema references, if it's a reference.
+func (d *Document) resolve(s *Schema) *Schema {
+	for s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+	}
+	return s
+}
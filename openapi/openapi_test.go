@@ -0,0 +1,102 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLimits struct {
+	MaxDepth int `json:"maxDepth"`
+}
+
+type testNode struct {
+	Name       string       `json:"name"`
+	Variations [][]testNode `json:"variations,omitempty"`
+}
+
+type testRequest struct {
+	testLimits
+	Game struct {
+		FENString string   `json:"fenString"`
+		History   []string `json:"history"`
+	} `json:"game"`
+	Clock    *testLimits       `json:"clock"`
+	Metadata map[string]string `json:"metadata"`
+	Tree     []testNode        `json:"tree"`
+	Ratio    float64           `json:"ratio"`
+	Small    int32             `json:"small"`
+	Ignored  string            `json:"-"`
+	internal string
+}
+
+func TestSchema(t *testing.T) {
+	d := NewDocument(Info{Title: "test", Version: "1"})
+	s := d.Schema(reflect.TypeOf(testRequest{}))
+	assert.Equal(t, "#/components/schemas/TestRequest", s.Ref)
+
+	byts, err := json.Marshal(d.Components.Schemas)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"TestLimits": {"type": "object", "properties": {"maxDepth": {"type": "integer", "format": "int64"}}, "additionalProperties": false},
+		"TestNode": {"type": "object", "properties": {
+			"name": {"type": "string"},
+			"variations": {"type": "array", "nullable": true, "items": {"type": "array", "nullable": true, "items": {"$ref": "#/components/schemas/TestNode"}}}
+		}, "additionalProperties": false},
+		"TestRequest": {"type": "object", "properties": {
+			"maxDepth": {"type": "integer", "format": "int64"},
+			"game": {"type": "object", "properties": {
+				"fenString": {"type": "string"},
+				"history": {"type": "array", "nullable": true, "items": {"type": "string"}}
+			}, "additionalProperties": false},
+			"clock": {"nullable": true, "allOf": [{"$ref": "#/components/schemas/TestLimits"}]},
+			"metadata": {"type": "object", "nullable": true, "additionalProperties": {"type": "string"}},
+			"tree": {"type": "array", "nullable": true, "items": {"$ref": "#/components/schemas/TestNode"}},
+			"ratio": {"type": "number", "format": "double"},
+			"small": {"type": "integer", "format": "int32"}
+		}, "additionalProperties": false}
+	}`, string(byts))
+}
+
+func TestValidate(t *testing.T) {
+	d := NewDocument(Info{Title: "test", Version: "1"})
+	s := d.Schema(reflect.TypeOf(testRequest{}))
+
+	ts := []struct {
+		json string
+		err  *ValidationError
+	}{
+		{`{}`, nil},
+		{`{"maxDepth": 3, "game": {"fenString": "8/8/8/8/8/8/8/8 w - - 0 1", "history": null}, "clock": {"maxDepth": 1}, "metadata": {"Event": "?"}, "tree": [{"name": "e4", "variations": [[{"name": "d4"}]]}], "ratio": 0.5, "small": -2}`, nil},
+		{`{"clock": null, "metadata": null, "tree": null}`, nil},
+		{`{"game": {"fenstring": ""}}`, &ValidationError{Path: "game.fenstring", Reason: "unknown field"}},
+		{`{"game": {"fenString": 3}}`, &ValidationError{Path: "game.fenString", Reason: "must be a string"}},
+		{`{"game": {"history": ["a", 1]}}`, &ValidationError{Path: "game.history[1]", Reason: "must be a string"}},
+		{`{"game": null}`, &ValidationError{Path: "game", Reason: "must not be null"}},
+		{`{"maxDepth": 1.5}`, &ValidationError{Path: "maxDepth", Reason: "must be an integer (int64)"}},
+		{`{"small": 4294967296}`, &ValidationError{Path: "small", Reason: "must be an integer (int32)"}},
+		{`{"clock": {"maxDepth": "1"}}`, &ValidationError{Path: "clock.maxDepth", Reason: "must be an integer"}},
+		{`{"metadata": {"Event": false}}`, &ValidationError{Path: "metadata.Event", Reason: "must be a string"}},
+		{`{"tree": [{"variations": [[{"name": "e4", "nag": 1}]]}]}`, &ValidationError{Path: "tree[0].variations[0][0].nag", Reason: "unknown field"}},
+		{`[]`, &ValidationError{Reason: "must be an object"}},
+		{`{} {}`, &ValidationError{Reason: "invalid JSON: unexpected data after the value"}},
+	}
+	for _, tc := range ts {
+		t.Run(tc.json, func(t *testing.T) {
+			err := d.Validate(s, []byte(tc.json))
+			if tc.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tc.err, err)
+		})
+	}
+
+	err := d.Validate(s, []byte(`{"game": `))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Contains(t, validationErr.Reason, "invalid JSON")
+}
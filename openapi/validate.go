@@ -0,0 +1,121 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// ValidationError is the error of a JSON value that isn't valid for a schema.
+type ValidationError struct {
+	// Path is the path to the invalid value, e.g. `game.positionHistory[2]`, or
+	// empty for the whole value.
+	Path string
+	// Reason is why the value is invalid, e.g. "unknown field".
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Reason)
+}
+
+// Validate returns a *ValidationError if the JSON document isn't a valid value of
+// the schema, which is one of the document's (see Schema). Unlike encoding/json,
+// it doesn't allow unknown fields, nor null values but of nullable schemas.
+func (d *Document) Validate(s *Schema, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Reason: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return &ValidationError{Reason: "invalid JSON: unexpected data after the value"}
+	}
+	return d.validate(s, value, "")
+}
+
+func (d *Document) validate(s *Schema, value interface{}, path string) error {
+	s = d.resolve(s)
+	if value == nil {
+		if s.Nullable || (s.Type == "" && len(s.AllOf) == 0) {
+			return nil
+		}
+		return &ValidationError{Path: path, Reason: "must not be null"}
+	}
+	for _, sub := range s.AllOf {
+		if err := d.validate(sub, value, path); err != nil {
+			return err
+		}
+	}
+	switch s.Type {
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return &ValidationError{Path: path, Reason: "must be a boolean"}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return &ValidationError{Path: path, Reason: "must be a string"}
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return &ValidationError{Path: path, Reason: "must be a number"}
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return &ValidationError{Path: path, Reason: "must be an integer"}
+		}
+		n, err := strconv.ParseInt(string(number), 10, 64)
+		if err != nil || (s.Format == "int32" && (n < math.MinInt32 || n > math.MaxInt32)) {
+			return &ValidationError{Path: path, Reason: fmt.Sprintf("must be an integer (%s)", s.Format)}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return &ValidationError{Path: path, Reason: "must be an array"}
+		}
+		for i, item := range items {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return &ValidationError{Path: path, Reason: "must be an object"}
+		}
+		// Sorted, so that the first invalid field is always the same one.
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if property, ok = s.AdditionalProperties.(*Schema); !ok {
+					return &ValidationError{Path: joinPath(path, name), Reason: "unknown field"}
+				}
+			}
+			if err := d.validate(property, object[name], joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/marianogappa/cheesse/core"
 )
@@ -24,6 +25,19 @@ type Token struct {
 	// Comment holds the text of any comment(s) attached to this token (e.g. PGN
 	// {...} or ; comments following a move), without delimiters.
 	Comment string
+	// PreComment holds the text of any comment(s) preceding this token, e.g. at
+	// the start of a PGN variation.
+	PreComment string
+	// NAGs holds the Numeric Annotation Glyphs attached to this token (e.g. PGN
+	// $1, or the "!" suffix).
+	NAGs []int
+	// Commands holds the commands embedded in Comment (e.g. PGN [%clk 0:03:00]),
+	// which are removed from Comment's text.
+	Commands []core.CommentCommand
+	// Variations holds the unparsed movetext of each variation that follows this
+	// token, without delimiters (e.g. the contents of a PGN RAV). Each one is an
+	// alternative to this token's half move.
+	Variations []string
 }
 
 // ParserVariant defines the interface for notation-specific parsing logic.
@@ -40,7 +54,8 @@ type ParserVariant interface {
 	//   - error: any error that occurred during token extraction
 	PopHalfMove(pg *ParsingGame) (*Token, bool, error)
 
-	// Finalize completes the parsing process (does nothing for now, but reserved for future use).
+	// Finalize completes the parsing process once all half moves are parsed, e.g. with
+	// information that spans the whole game, such as PGN's clocks.
 	Finalize(pg *ParsingGame) error
 
 	// ActionToStringVariants converts an action to all possible string representations in this notation.
@@ -58,20 +73,29 @@ type ParsingGame struct {
 }
 
 // ParsedGame represents the final parsed result.
+//
+// GameSteps is the mainline. MoveTree has one MoveNode per mainline step, which
+// additionally carries its annotations and variations.
 type ParsedGame struct {
 	GameSteps []core.GameStep
+	MoveTree  []core.MoveNode
 	Metadata  map[string]string
 }
 
 // Build constructs the final ParsedGame from the ParsingGame.
 // If multiple branches remain, it picks the first one.
 func (pg *ParsingGame) Build() *ParsedGame {
-	var gameSteps []core.GameStep
+	var (
+		gameSteps []core.GameStep
+		moveTree  []core.MoveNode
+	)
 	if len(pg.Alternatives) > 0 {
 		gameSteps = pg.Alternatives[0].GameSteps
+		moveTree = pg.Alternatives[0].MoveTree
 	}
 	return &ParsedGame{
 		GameSteps: gameSteps,
+		MoveTree:  moveTree,
 		Metadata:  pg.Metadata,
 	}
 }
@@ -103,31 +127,55 @@ func (p *GenericNotationParser) Parse(initialGame core.Game, s string) (*ParsedG
 	// The variant pops tokens, and we handle game state management.
 	// On a mid-game failure, parsing stops but the valid prefix of steps is
 	// returned along with the error, matching the other parsers' behavior.
+	if err := p.parseHalfMoves(parsingGame); err != nil {
+		return parsingGame.Build(), err
+	}
+
+	// Step 3: Finalize
+	if err := p.variant.Finalize(parsingGame); err != nil {
+		return nil, err
+	}
+
+	return parsingGame.Build(), nil
+}
+
+// parseHalfMoves pops and processes half moves until the variant has no more.
+func (p *GenericNotationParser) parseHalfMoves(pg *ParsingGame) error {
 	for {
-		token, hasMore, err := p.variant.PopHalfMove(parsingGame)
+		token, hasMore, err := p.variant.PopHalfMove(pg)
 		if err != nil {
-			return parsingGame.Build(), err
+			return err
 		}
 		if token == nil {
-			break
+			return nil
 		}
 
 		// Process the token based on its type
-		if err := p.ProcessToken(parsingGame, token); err != nil {
-			return parsingGame.Build(), err
+		if err := p.ProcessToken(pg, token); err != nil {
+			return err
 		}
 
 		if !hasMore {
-			break
+			return nil
 		}
 	}
+}
 
-	// Step 3: Finalize
-	if err := p.variant.Finalize(parsingGame); err != nil {
+// parseVariation parses a variation's movetext as a line starting from the given
+// game (the position the variation branches from). Unlike a full parse, the whole
+// variation must be valid.
+func (p *GenericNotationParser) parseVariation(g core.Game, s string) ([]core.MoveNode, error) {
+	pg := &ParsingGame{
+		Alternatives: []GameAlternative{{InitialGame: g}},
+		Remaining:    s,
+	}
+	if err := p.parseHalfMoves(pg); err != nil {
 		return nil, err
 	}
-
-	return parsingGame.Build(), nil
+	if rest := strings.TrimSpace(pg.Remaining[pg.Pos:]); rest != "" {
+		return nil, fmt.Errorf("unexpected %q", rest)
+	}
+	return pg.Build().MoveTree, nil
 }
 
 // MatchHalfMove attempts to match a half move string against all possible actions in the current game.
@@ -194,16 +242,33 @@ func (p *GenericNotationParser) ProcessToken(pg *ParsingGame, token *Token) erro
 				continue
 			}
 
+			// Variations are alternatives to this half move, so they branch from
+			// the position before it
+			variations := make([][]core.MoveNode, len(token.Variations))
+			for i, variation := range token.Variations {
+				if variations[i], err = p.parseVariation(currentGame, variation); err != nil {
+					return fmt.Errorf("invalid variation (%v) for half move %q: %w", strings.TrimSpace(variation), token.Value, err)
+				}
+			}
+
 			// Create a new alternative for each matching action
 			for _, action := range matches {
 				newGame := currentGame.DoAction(action)
 				newAlternative := alt.Clone()
-				newAlternative.GameSteps = append(newAlternative.GameSteps, core.GameStep{
+				gameStep := core.GameStep{
 					StepString:      token.Value,
 					StepComment:     token.Comment,
 					StepAction:      action,
 					StepGame:        newGame,
 					StepPreMoveGame: currentGame,
+				}
+				newAlternative.GameSteps = append(newAlternative.GameSteps, gameStep)
+				newAlternative.MoveTree = append(newAlternative.MoveTree, core.MoveNode{
+					GameStep:   gameStep,
+					NAGs:       token.NAGs,
+					PreComment: token.PreComment,
+					Commands:   token.Commands,
+					Variations: variations,
 				})
 
 				newAlternatives = append(newAlternatives, newAlternative)
@@ -222,12 +287,19 @@ func (p *GenericNotationParser) ProcessToken(pg *ParsingGame, token *Token) erro
 		newAlternatives := []GameAlternative{}
 		for _, alt := range pg.Alternatives {
 			newAlternative := alt.Clone()
-			newAlternative.GameSteps = append(newAlternative.GameSteps, core.GameStep{
+			gameStep := core.GameStep{
 				StepString:      token.Value,
 				StepComment:     token.Comment,
 				StepAction:      core.Action{},     // Empty action for result markers
 				StepGame:        alt.CurrentGame(), // Game state doesn't change
 				StepPreMoveGame: alt.CurrentGame(),
+			}
+			newAlternative.GameSteps = append(newAlternative.GameSteps, gameStep)
+			newAlternative.MoveTree = append(newAlternative.MoveTree, core.MoveNode{
+				GameStep:   gameStep,
+				NAGs:       token.NAGs,
+				PreComment: token.PreComment,
+				Commands:   token.Commands,
 			})
 			newAlternatives = append(newAlternatives, newAlternative)
 		}
@@ -132,7 +132,7 @@ func NewNotationParserAlgebraic(initialCharacteristics Characteristics) *Notatio
 				},
 
 				// Capture and promotion with pawn, potentially without rank
-				`([a-h])(x|:)?([a-h])([1-8]?)([=\(])([QBNR♕♗♘♖♛♝♞♜])\)?(\+\+|dbl\.? ?ch|dis\.? ?ch|\+|†|ch|#|mate|‡|≠|X|x|×)?(!!|\?\?|!\?|\?!|!|\?)?`: func(ms []string, g core.Game) []tokenMatch {
+				`([a-h])(x|:)?([a-h])([1-8]?)([=\(])([QBNRK♕♗♘♖♔♛♝♞♜♚])\)?(\+\+|dbl\.? ?ch|dis\.? ?ch|\+|†|ch|#|mate|‡|≠|X|x|×)?(!!|\?\?|!\?|\?!|!|\?)?`: func(ms []string, g core.Game) []tokenMatch {
 					fromSquareFile, _, toSquareFile, toSquareRank, promotionSymbol, sPromotionPieceType, threatenSymbol, _ := ms[1], ms[2], ms[3], ms[4], ms[5], ms[6], ms[7], ms[8]
 					isCheck, isCheckmate, usesCheckSymbol, usesCheckmateSymbol := processThreatenSymbol(threatenSymbol)
 					ap := actionPattern{
@@ -158,7 +158,7 @@ func NewNotationParserAlgebraic(initialCharacteristics Characteristics) *Notatio
 				},
 
 				// Promotion
-				`([a-h])([1-8])([=\(])([QBNR♕♗♘♖♛♝♞♜])\)?(\+\+|dbl\.? ?ch|dis\.? ?ch|\+|†|ch|#|mate|‡|≠|X|x|×)?(!!|\?\?|!\?|\?!|!|\?)?`: func(ms []string, g core.Game) []tokenMatch {
+				`([a-h])([1-8])([=\(])([QBNRK♕♗♘♖♔♛♝♞♜♚])\)?(\+\+|dbl\.? ?ch|dis\.? ?ch|\+|†|ch|#|mate|‡|≠|X|x|×)?(!!|\?\?|!\?|\?!|!|\?)?`: func(ms []string, g core.Game) []tokenMatch {
 					toSquareFile, toSquareRank, promotionSymbol, sPromotionPieceType, threatenSymbol, _ := ms[1], ms[2], ms[3], ms[4], ms[5], ms[6]
 					isCheck, isCheckmate, usesCheckSymbol, usesCheckmateSymbol := processThreatenSymbol(threatenSymbol)
 					ap := actionPattern{
@@ -182,6 +182,22 @@ func NewNotationParserAlgebraic(initialCharacteristics Characteristics) *Notatio
 					return []tokenMatch{{ms[0], &ap, ch}}
 				},
 
+				// Drop, in variants such as Crazyhouse
+				`([QBNRP♕♗♘♖♙♛♝♞♜♟]?)@([a-h])([1-8])(\+\+|dbl\.? ?ch|dis\.? ?ch|\+|†|ch|#|mate|‡|≠|X|x|×)?(!!|\?\?|!\?|\?!|!|\?)?`: func(ms []string, g core.Game) []tokenMatch {
+					sFromPieceType, toSquareFile, toSquareRank, threatenSymbol, _ := ms[1], ms[2], ms[3], ms[4], ms[5]
+					isCheck, isCheckmate, usesCheckSymbol, usesCheckmateSymbol := processThreatenSymbol(threatenSymbol)
+					ap := actionPattern{
+						fromPieceType: stringToPieceType(sFromPieceType),
+						toX:           fileToPInt(toSquareFile),
+						toY:           rankToPInt(toSquareRank),
+						isDrop:        pBool(true),
+						isCheck:       isCheck,
+						isCheckmate:   isCheckmate,
+					}
+					ch := Characteristics{usesCheckSymbol: usesCheckSymbol, usesCheckmateSymbol: usesCheckmateSymbol}
+					return []tokenMatch{{ms[0], &ap, ch}}
+				},
+
 				// Castling
 				`(0-0|0-0-0|O-O|O-O-O)(\+\+|dbl\.? ?ch|dis\.? ?ch|\+|†|ch|#|mate|‡|≠|X|x|×)?(!!|\?\?|!\?|\?!|!|\?)?`: func(ms []string, g core.Game) []tokenMatch {
 					castlingSymbol, threatenSymbol, _ := ms[1], ms[2], ms[3]
@@ -327,6 +343,7 @@ func stringToPieceType(s string) core.PieceType {
 		"B": core.PieceBishop,
 		"N": core.PieceKnight,
 		"R": core.PieceRook,
+		"P": core.PiecePawn,
 		"":  core.PiecePawn,
 		// Figurine symbols, both colors
 		"♕": core.PieceQueen,
@@ -4101,3 +4101,33 @@ func TestNotationParserAlgebraic(t *testing.T) {
 		})
 	}
 }
+
+func TestNotationParserAlgebraic_Drops(t *testing.T) {
+	g, err := core.NewVariantGameFromFEN("r1bqkbnr/pppp1ppp/8/4n3/4P3/8/PPPP1PPP/RNBQKB1R[Pn] w KQkq - 0 4", core.VariantCrazyhouse)
+	require.NoError(t, err)
+	gameSteps, err := NewNotationParserAlgebraic(Characteristics{}).Parse(g, "4. P@d4 N@f3+ 5. gxf3")
+	require.NoError(t, err)
+	require.Len(t, gameSteps, 3)
+	assert.True(t, gameSteps[0].StepAction.IsDrop)
+	assert.Equal(t, "N@f3+", gameSteps[1].StepString)
+	assert.Equal(t, "r1bqkbnr/pppp1ppp/8/4n3/3PP3/5P2/PPPP1P1P/RNBQKB1R[N] b KQkq - 0 5", gameSteps[2].StepGame.ToFEN())
+
+	_, err = NewNotationParserAlgebraic(Characteristics{}).Parse(g, "4. d5")
+	assert.Error(t, err, "a move to a square isn't a drop on it")
+	gameSteps, err = NewNotationParserAlgebraic(Characteristics{}).Parse(g, "4. @d4")
+	require.NoError(t, err)
+	assert.True(t, gameSteps[0].StepAction.IsDrop, "pawn drops may omit the P")
+}
+
+func TestNotationParserAlgebraic_KingPromotion(t *testing.T) {
+	g, err := core.NewVariantGameFromFEN("8/4P3/8/8/8/8/8/k7 w - - 0 1", core.VariantAntichess)
+	require.NoError(t, err)
+	gameSteps, err := NewNotationParserAlgebraic(Characteristics{}).Parse(g, "1. e8=K")
+	require.NoError(t, err)
+	require.Len(t, gameSteps, 1)
+	assert.Equal(t, core.PieceType(core.PieceKing), gameSteps[0].StepAction.PromotionPieceType)
+	assert.Equal(t, "4K3/8/8/8/8/8/8/k7 b - - 0 1", gameSteps[0].StepGame.ToFEN())
+
+	_, err = NewNotationParserAlgebraic(Characteristics{}).Parse(core.NewDefaultGame(), "1. e8=K")
+	assert.Error(t, err, "pawns only promote to kings in Antichess")
+}
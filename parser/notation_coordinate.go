@@ -48,7 +48,7 @@ func NewNotationParserCoordinate(initialCharacteristics Characteristics) *Notati
 			},
 			"move": {
 				// Move or capture: e2-e4, b5xd7, e2e4; optional promotion (Q, =Q, (Q), /Q) and check suffix (+, ch, ++, mate)
-				`([a-h])([1-8])(-|x|:)?([a-h])([1-8])(?:([=\(/])?([QBNR])\)?)?(\+\+|mate|#|\+|ch)?`: func(ms []string, g core.Game) []tokenMatch {
+				`([a-h])([1-8])(-|x|:)?([a-h])([1-8])(?:([=\(/])?([QBNRK])\)?)?(\+\+|mate|#|\+|ch)?`: func(ms []string, g core.Game) []tokenMatch {
 					fromFile, fromRank, delimiter, toFile, toRank, promotionSymbol, promotionPiece, threatenSymbol := ms[1], ms[2], ms[3], ms[4], ms[5], ms[6], ms[7], ms[8]
 
 					var isCheck, isCheckmate *bool
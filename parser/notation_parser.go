@@ -35,6 +35,7 @@ type actionPattern struct {
 	capturedPieceY     *int
 	isCheck            *bool
 	isCheckmate        *bool
+	isDrop             *bool
 }
 
 func (p actionPattern) Clone() actionPattern {
@@ -58,6 +59,7 @@ func (p actionPattern) Clone() actionPattern {
 		capturedPieceY:     cloneInt(p.capturedPieceY),
 		isCheck:            cloneBool(p.isCheck),
 		isCheckmate:        cloneBool(p.isCheckmate),
+		isDrop:             cloneBool(p.isDrop),
 	}
 }
 
@@ -128,15 +130,27 @@ func (p actionPattern) String() string {
 	if p.capturedPieceY != nil {
 		sb.WriteString(fmt.Sprintf("{a.capturedPiece.xy.y}:%v\n", *p.capturedPieceY))
 	}
+	if p.isDrop != nil {
+		sb.WriteString(fmt.Sprintf("{a.isDrop}:%v\n", *p.isDrop))
+	}
 	return sb.String()
 }
 
 func (p *actionPattern) isMatch(a core.Action) bool {
 	// A draw action can never be inferred from a move string: only a pattern that
 	// explicitly targets it may match (no notation pattern does so at present).
+	// Neither can draw offers, their answers or claims.
 	if a.IsDraw && (p.isDraw == nil || !*p.isDraw) {
 		return false
 	}
+	if a.IsDrawOffer || a.IsDrawAccept || a.IsDrawDecline || a.IsDrawClaim {
+		return false
+	}
+	// Likewise, a drop (e.g. "N@f3") only matches a pattern that targets drops,
+	// rather than any move to its square.
+	if a.IsDrop != (p.isDrop != nil && *p.isDrop) {
+		return false
+	}
 	if !pieceTypeMatcher(p.fromPieceType)(a.FromPiece.PieceType) ||
 		!intMatcher(p.fromX)(a.FromPiece.XY.X) ||
 		!intMatcher(p.fromY)(a.FromPiece.XY.Y) ||
@@ -186,9 +200,13 @@ func pieceTypeMatcher(v core.PieceType) func(interface{}) bool {
 }
 
 // GameAlternative represents a single branch in the parsing game tree.
+//
+// MoveTree is only built by parsers that support annotations and variations
+// (see GenericNotationParser); when built, it has one MoveNode per GameStep.
 type GameAlternative struct {
 	InitialGame core.Game
 	GameSteps   []core.GameStep
+	MoveTree    []core.MoveNode
 }
 
 // Clone creates a deep copy of the GameAlternative.
@@ -197,9 +215,15 @@ func (a GameAlternative) Clone() GameAlternative {
 	for i := range a.GameSteps {
 		clonedGameSteps[i] = a.GameSteps[i].Clone()
 	}
+	var clonedMoveTree []core.MoveNode
+	if a.MoveTree != nil {
+		clonedMoveTree = make([]core.MoveNode, len(a.MoveTree))
+		copy(clonedMoveTree, a.MoveTree)
+	}
 	return GameAlternative{
 		InitialGame: a.InitialGame.Clone(),
 		GameSteps:   clonedGameSteps,
+		MoveTree:    clonedMoveTree,
 	}
 }
 
@@ -233,7 +257,7 @@ func (p *gameStepParser) matchingActions(ap actionPattern) []core.Action {
 		actionSet = map[core.Action]struct{}{}
 	)
 	for _, alternative := range p.alternatives {
-		for _, action := range alternative.CurrentGame().Actions {
+		for _, action := range candidateActions(alternative.CurrentGame()) {
 			if !ap.isMatch(action) {
 				continue
 			}
@@ -256,10 +280,19 @@ func (p *gameStepParser) matchingActions(ap actionPattern) []core.Action {
 	return matched
 }
 
+// candidateActions returns the actions a pattern may match on the given game: its
+// legal actions, plus the draw agreement that a "1/2-1/2" result records.
+func candidateActions(g core.Game) []core.Action {
+	if g.IsGameOver {
+		return g.Actions
+	}
+	return append(g.Actions[:len(g.Actions):len(g.Actions)], g.DrawAgreement())
+}
+
 func (p *gameStepParser) next(ap actionPattern, actionString string) bool {
 	newAlternatives := []GameAlternative{}
 	for _, alternative := range p.alternatives {
-		for _, action := range alternative.CurrentGame().Actions {
+		for _, action := range candidateActions(alternative.CurrentGame()) {
 			if ap.isMatch(action) {
 				newGame := alternative.CurrentGame().DoAction(action)
 				if ap.isCheck != nil && newGame.IsCheck != *ap.isCheck {
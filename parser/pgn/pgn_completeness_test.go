@@ -2,6 +2,7 @@ package pgn
 
 import (
 	"testing"
+	"time"
 
 	"github.com/marianogappa/cheesse/core"
 	"github.com/marianogappa/cheesse/parser"
@@ -17,7 +18,7 @@ func parsePGN(t *testing.T, pgn string) (*parser.ParsedGame, error) {
 }
 
 func TestPGNRAVVariations(t *testing.T) {
-	t.Run("skips a simple variation", func(t *testing.T) {
+	t.Run("mainline skips a simple variation", func(t *testing.T) {
 		parsed, err := parsePGN(t, "1. e4 e5 (1... c5 2. Nf3) 2. Nf3 Nc6")
 		require.NoError(t, err)
 		require.Len(t, parsed.GameSteps, 4)
@@ -27,14 +28,14 @@ func TestPGNRAVVariations(t *testing.T) {
 		assert.Equal(t, "Nc6", parsed.GameSteps[3].StepString)
 	})
 
-	t.Run("skips nested variations", func(t *testing.T) {
+	t.Run("mainline skips nested variations", func(t *testing.T) {
 		parsed, err := parsePGN(t, "1. e4 e5 (1... c5 (1... e6 2. d4) 2. Nf3) 2. Nf3")
 		require.NoError(t, err)
 		require.Len(t, parsed.GameSteps, 3)
 		assert.Equal(t, "Nf3", parsed.GameSteps[2].StepString)
 	})
 
-	t.Run("skips variation containing comments with parens", func(t *testing.T) {
+	t.Run("mainline skips variation containing comments with parens", func(t *testing.T) {
 		parsed, err := parsePGN(t, "1. e4 e5 (1... c5 {sicilian (sharp)} 2. Nf3) 2. Nf3")
 		require.NoError(t, err)
 		require.Len(t, parsed.GameSteps, 3)
@@ -48,6 +49,64 @@ func TestPGNRAVVariations(t *testing.T) {
 	})
 }
 
+func TestPGNMoveTree(t *testing.T) {
+	t.Run("variation branches from the position before its move", func(t *testing.T) {
+		parsed, err := parsePGN(t, "1. e4 e5 (1... c5 2. Nf3) 2. Nf3 Nc6")
+		require.NoError(t, err)
+		require.Len(t, parsed.MoveTree, 4)
+		assert.Empty(t, parsed.MoveTree[0].Variations)
+		require.Len(t, parsed.MoveTree[1].Variations, 1)
+		variation := parsed.MoveTree[1].Variations[0]
+		require.Len(t, variation, 2)
+		assert.Equal(t, "c5", variation[0].StepString)
+		assert.Equal(t, parsed.MoveTree[1].StepPreMoveGame.ToFEN(), variation[0].StepPreMoveGame.ToFEN())
+		assert.Equal(t, "rnbqkbnr/pp1ppppp/8/2p5/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2", variation[1].StepGame.ToFEN())
+	})
+
+	t.Run("nested and sibling variations", func(t *testing.T) {
+		parsed, err := parsePGN(t, "1. e4 e5 (1... c5 (1... e6 2. d4) 2. Nf3) (1... d5) 2. Nf3")
+		require.NoError(t, err)
+		require.Len(t, parsed.MoveTree[1].Variations, 2)
+		sicilian, scandinavian := parsed.MoveTree[1].Variations[0], parsed.MoveTree[1].Variations[1]
+		require.Len(t, sicilian[0].Variations, 1)
+		assert.Equal(t, "e6", sicilian[0].Variations[0][0].StepString)
+		assert.Equal(t, "d4", sicilian[0].Variations[0][1].StepString)
+		require.Len(t, scandinavian, 1)
+		assert.Equal(t, "d5", scandinavian[0].StepString)
+	})
+
+	t.Run("variations carry their own comments and NAGs", func(t *testing.T) {
+		parsed, err := parsePGN(t, "{Game start} 1. e4! $14 e5 ({Instead} 1... c5?! {Sicilian} $2) 2. Nf3")
+		require.NoError(t, err)
+		assert.Equal(t, "Game start", parsed.MoveTree[0].PreComment)
+		assert.Equal(t, []int{1, 14}, parsed.MoveTree[0].NAGs)
+		variation := parsed.MoveTree[1].Variations[0]
+		assert.Equal(t, "Instead", variation[0].PreComment)
+		assert.Equal(t, "Sicilian", variation[0].StepComment)
+		assert.Equal(t, []int{6, 2}, variation[0].NAGs)
+		assert.Equal(t, "c5", variation[0].StepString)
+	})
+
+	t.Run("mainline result marker is part of the tree", func(t *testing.T) {
+		parsed, err := parsePGN(t, "1. e4 e5 1-0")
+		require.NoError(t, err)
+		require.Len(t, parsed.MoveTree, 3)
+		assert.Equal(t, "1-0", parsed.MoveTree[2].StepString)
+	})
+
+	t.Run("invalid variation fails the parse at the move it follows", func(t *testing.T) {
+		parsed, err := parsePGN(t, "1. e4 e5 (1... Ke6) 2. Nf3")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Ke6")
+		assert.Len(t, parsed.GameSteps, 1)
+	})
+
+	t.Run("variation with trailing garbage is invalid", func(t *testing.T) {
+		_, err := parsePGN(t, "1. e4 e5 (1... c5 ???) 2. Nf3")
+		require.Error(t, err)
+	})
+}
+
 func TestPGNBareNAGs(t *testing.T) {
 	t.Run("bare NAG after move", func(t *testing.T) {
 		parsed, err := parsePGN(t, "1. e4 $1 e5 $14 2. Nf3")
@@ -89,6 +148,54 @@ func TestPGNCommentsAttached(t *testing.T) {
 	})
 }
 
+func TestPGNCommentCommands(t *testing.T) {
+	t.Run("commands are extracted from the comment", func(t *testing.T) {
+		parsed, err := parsePGN(t, "[Event \"E\"]\n\n1. e4 { [%eval 0.17] [%clk 0:03:00] } e5 {Solid [%clk 0:02:59]} *")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"Event": "E"}, parsed.Metadata, "brackets in comments aren't tag pairs")
+		assert.Equal(t, []core.CommentCommand{{Name: "eval", Value: "0.17"}, {Name: "clk", Value: "0:03:00"}}, parsed.MoveTree[0].Commands)
+		assert.Equal(t, "", parsed.MoveTree[0].StepComment)
+		assert.Equal(t, []core.CommentCommand{{Name: "clk", Value: "0:02:59"}}, parsed.MoveTree[1].Commands)
+		assert.Equal(t, "Solid", parsed.MoveTree[1].StepComment)
+	})
+
+	t.Run("brackets in semicolon comments aren't tag pairs", func(t *testing.T) {
+		parsed, err := parsePGN(t, "1. e4 ; see [ref]\ne5")
+		require.NoError(t, err)
+		assert.Empty(t, parsed.Metadata)
+		assert.Equal(t, "see [ref]", parsed.GameSteps[0].StepComment)
+	})
+}
+
+func TestPGNClocks(t *testing.T) {
+	t.Run("clk commands set the clocks of the TimeControl tag", func(t *testing.T) {
+		parsed, err := parsePGN(t, "[TimeControl \"180+2\"]\n\n1. e4 {[%clk 0:03:01]} e5 {[%clk 0:02:58.5]} 2. Nf3 Nc6 {[%clk 0:02:50]} *")
+		require.NoError(t, err)
+		require.Len(t, parsed.GameSteps, 5)
+
+		assert.Equal(t, "180+2", parsed.GameSteps[0].StepPreMoveGame.Clock.TimeControl.String())
+		assert.Equal(t, [2]time.Duration{3 * time.Minute, 3 * time.Minute}, parsed.GameSteps[0].StepPreMoveGame.Clock.Remaining)
+		assert.Equal(t, [2]time.Duration{3 * time.Minute, 181 * time.Second}, parsed.GameSteps[0].StepGame.Clock.Remaining)
+		assert.Equal(t, [2]time.Duration{178500 * time.Millisecond, 181 * time.Second}, parsed.GameSteps[1].StepGame.Clock.Remaining)
+		assert.Equal(t, [2]time.Duration{178500 * time.Millisecond, 181 * time.Second}, parsed.GameSteps[2].StepGame.Clock.Remaining, "a move without clk keeps the last known time")
+		assert.Equal(t, [2]int{2, 2}, parsed.GameSteps[3].StepGame.Clock.Moves)
+		assert.Equal(t, parsed.GameSteps[3].StepGame.Clock, parsed.GameSteps[4].StepGame.Clock, "the result marker doesn't move")
+		assert.Equal(t, parsed.GameSteps[1].StepGame, parsed.MoveTree[1].StepGame)
+	})
+
+	t.Run("no clocks without clk commands or a time control", func(t *testing.T) {
+		for _, pgn := range []string{
+			"[TimeControl \"180+2\"]\n\n1. e4 e5 *",
+			"[TimeControl \"-\"]\n\n1. e4 {[%clk 0:03:01]} e5 *",
+			"1. e4 {[%clk 0:03:01]} e5 *",
+		} {
+			parsed, err := parsePGN(t, pgn)
+			require.NoError(t, err)
+			assert.False(t, parsed.GameSteps[1].StepGame.Clock.IsTimed(), pgn)
+		}
+	})
+}
+
 func TestPGNPartialParse(t *testing.T) {
 	t.Run("invalid move mid-game returns valid prefix and error", func(t *testing.T) {
 		parsed, err := parsePGN(t, "1. e4 e5 2. Qxf7 Nc6")
@@ -0,0 +1,254 @@
+package pgn
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/marianogappa/cheesse/parser"
+)
+
+// DefaultMaxGameSize is the default limit, in bytes, of a single game's text in a
+// Reader.
+const DefaultMaxGameSize = 1 << 20
+
+// Game is a game read from a PGN database by a Reader.
+type Game struct {
+	parser.ParsedGame
+
+	// Number is the 1-based position of the game in the database.
+	Number int
+	// Line is the 1-based line where the game starts.
+	Line int
+	// Result is the game's termination marker (1-0, 0-1, 1/2-1/2 or *), or its
+	// Result tag if the movetext doesn't end with one.
+	Result string
+}
+
+// GameError is returned by Reader.Read when a game can't be read or parsed. The
+// game is skipped, and the next call to Read continues with the following one.
+type GameError struct {
+	Number int
+	Line   int
+	Err    error
+}
+
+// Error implements error.
+func (e *GameError) Error() string {
+	return fmt.Sprintf("game %d (line %d): %v", e.Number, e.Line, e.Err)
+}
+
+// Unwrap returns the reason the game couldn't be read.
+func (e *GameError) Unwrap() error {
+	return e.Err
+}
+
+// ErrGameTooLarge is wrapped by a GameError when a game's text exceeds the Reader's
+// MaxGameSize.
+var ErrGameTooLarge = errors.New("game exceeds the maximum game size")
+
+// Reader reads games one by one from a PGN database (e.g. a multi-game .pgn
+// file). Only the text of the game being read is kept in memory, so databases of
+// any size can be read.
+//
+// Games are delimited by the first tag pair that follows some movetext, so a game
+// missing its termination marker doesn't swallow the next one.
+type Reader struct {
+	// MaxGameSize is the limit, in bytes, of a single game's text. Larger games
+	// are skipped with an ErrGameTooLarge GameError.
+	MaxGameSize int
+
+	r        *bufio.Reader
+	buf      bytes.Buffer
+	pending  []byte // text read past the end of the previous game
+	line     int    // lines read so far
+	gameLine int
+	number   int
+	err      error
+}
+
+// NewReader creates a Reader of the PGN database in r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{
+		MaxGameSize: DefaultMaxGameSize,
+		r:           bufio.NewReader(r),
+	}
+}
+
+// Read reads and parses the next game. It returns io.EOF when there are no more
+// games.
+//
+// When a game fails to parse, it returns the game with the valid prefix of its
+// moves along with a *GameError; reading can continue with the next call. Any
+// other error comes from the underlying reader, and ends the reading.
+func (r *Reader) Read() (*Game, error) {
+	text, err := r.readGameText()
+	if err != nil {
+		return nil, err
+	}
+
+	gameError := func(err error) error {
+		return &GameError{Number: r.number, Line: r.gameLine, Err: err}
+	}
+	initialGame, err := initialGameFromTags(text)
+	if err != nil {
+		return nil, gameError(err)
+	}
+	parsed, err := parser.NewGenericNotationParser(NewVariantPGN()).Parse(initialGame, text)
+	if parsed == nil {
+		return nil, gameError(err)
+	}
+	game := &Game{ParsedGame: *parsed, Number: r.number, Line: r.gameLine, Result: gameResult(parsed)}
+	if err != nil {
+		return game, gameError(err)
+	}
+	return game, nil
+}
+
+// readGameText returns the text of the next game, skipping games that exceed
+// MaxGameSize.
+func (r *Reader) readGameText() (string, error) {
+	for {
+		if r.err != nil && len(r.pending) == 0 {
+			return "", r.err
+		}
+		tooLarge, err := r.scanGame()
+		if err != nil && !errors.Is(err, io.EOF) {
+			r.err = err
+			return "", err
+		}
+		r.err = err
+		text := strings.TrimSpace(r.buf.String())
+		r.buf.Reset()
+		if text == "" && !tooLarge {
+			continue
+		}
+		r.number++
+		if tooLarge {
+			return "", &GameError{Number: r.number, Line: r.gameLine, Err: ErrGameTooLarge}
+		}
+		return text, nil
+	}
+}
+
+// scanGame reads the next game's text into buf, leaving any text that belongs to
+// the following game in pending. It returns whether the game exceeded
+// MaxGameSize, in which case its text is discarded.
+func (r *Reader) scanGame() (bool, error) {
+	var (
+		inTag, inQuotes, inCurly, inSemicolon bool
+		started, sawMovetext, tooLarge        bool
+		atLineStart                           = true
+		size                                  int
+	)
+	for {
+		chunk := r.pending
+		r.pending = nil
+		var err error
+		if chunk == nil {
+			if chunk, err = r.r.ReadSlice('\n'); err != nil && err != bufio.ErrBufferFull && len(chunk) == 0 {
+				return tooLarge, err
+			}
+		}
+		for i, c := range chunk {
+			switch {
+			case inCurly:
+				inCurly = c != '}'
+			case inSemicolon:
+				inSemicolon = c != '\n'
+			case inTag:
+				if c == '"' {
+					inQuotes = !inQuotes
+				}
+				inTag = inQuotes || c != ']'
+			case c == '[' && atLineStart:
+				if sawMovetext {
+					// A tag pair after movetext starts the next game
+					r.pending = append([]byte(nil), chunk[i:]...)
+					return tooLarge, nil
+				}
+				inTag = true
+			case c == '{':
+				inCurly, sawMovetext = true, true
+			case c == ';':
+				inSemicolon, sawMovetext = true, true
+			case c != ' ' && c != '\t' && c != '\r' && c != '\n':
+				sawMovetext = true
+			}
+			if !started && c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+				started, r.gameLine = true, r.line+1
+			}
+			if c == '\n' {
+				r.line++
+				atLineStart = true
+			} else if c != ' ' && c != '\t' && c != '\r' {
+				atLineStart = false
+			}
+		}
+		size += len(chunk)
+		if size > r.MaxGameSize {
+			tooLarge = true
+			r.buf.Reset()
+		} else {
+			r.buf.Write(chunk)
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return tooLarge, err
+		}
+	}
+}
+
+// initialGameFromTags returns the game's starting position, which is the FEN tag's
+// if present, of the variant of the Variant tag.
+func initialGameFromTags(text string) (core.Game, error) {
+	tagPairs, _, err := extractTagPairs(text)
+	if err != nil {
+		return core.Game{}, err
+	}
+	fen, ok := tagPairs["FEN"]
+	variant := strings.ToLower(tagPairs["Variant"])
+	if rulesVariant, isVariant := variantFromTag(variant); isVariant {
+		if !ok {
+			return core.NewVariantGame(rulesVariant), nil
+		}
+		return core.NewVariantGameFromFEN(fen, rulesVariant)
+	}
+	if !ok {
+		return core.NewDefaultGame(), nil
+	}
+	if strings.Contains(variant, "960") || strings.Contains(variant, "fischer") {
+		return core.NewChess960GameFromFEN(fen)
+	}
+	return core.NewGameFromFEN(fen)
+}
+
+// variantFromTag returns the rules variant (other than standard) of a lowercase
+// Variant tag, e.g. "king of the hill", which is its name but for case and
+// punctuation.
+func variantFromTag(tag string) (core.Variant, bool) {
+	name := strings.Map(func(r rune) rune {
+		if r < 'a' || r > 'z' {
+			return -1
+		}
+		return r
+	}, tag)
+	for _, v := range core.Variants {
+		if v != core.VariantStandard && strings.ToLower(v.Name()) == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// gameResult returns the game's termination marker, or its Result tag if the
+// movetext doesn't end with one.
+func gameResult(parsed *parser.ParsedGame) string {
+	if n := len(parsed.GameSteps); n > 0 && parsed.GameSteps[n-1].StepAction == (core.Action{}) {
+		return parsed.GameSteps[n-1].StepString
+	}
+	return parsed.Metadata["Result"]
+}
@@ -0,0 +1,227 @@
+package pgn
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll reads every game, collecting the GameErrors separately.
+func readAll(t *testing.T, r *Reader) ([]*Game, []*GameError) {
+	t.Helper()
+	var (
+		games      []*Game
+		gameErrors []*GameError
+	)
+	for {
+		game, err := r.Read()
+		if err == io.EOF {
+			return games, gameErrors
+		}
+		var gameError *GameError
+		if errors.As(err, &gameError) {
+			gameErrors = append(gameErrors, gameError)
+			continue
+		}
+		require.NoError(t, err)
+		games = append(games, game)
+	}
+}
+
+func TestReader(t *testing.T) {
+	t.Run("reads games one by one", func(t *testing.T) {
+		database := `[Event "One"]
+[Result "1-0"]
+
+1. e4 e5 2. Qh5 Nc6 3. Bc4 Nf6 4. Qxf7# 1-0
+
+[Event "Two"]
+[Result "1/2-1/2"]
+
+1. d4 {a comment
+[spanning lines]} d5 (1... Nf6
+2. c4) 1/2-1/2
+`
+		games, gameErrors := readAll(t, NewReader(strings.NewReader(database)))
+		require.Empty(t, gameErrors)
+		require.Len(t, games, 2)
+
+		assert.Equal(t, 1, games[0].Number)
+		assert.Equal(t, 1, games[0].Line)
+		assert.Equal(t, "One", games[0].Metadata["Event"])
+		assert.Equal(t, "1-0", games[0].Result)
+		assert.Len(t, games[0].MoveTree, 8)
+
+		assert.Equal(t, 2, games[1].Number)
+		assert.Equal(t, 6, games[1].Line)
+		assert.Equal(t, "Two", games[1].Metadata["Event"])
+		assert.Equal(t, "1/2-1/2", games[1].Result)
+		require.Len(t, games[1].MoveTree, 3)
+		assert.Equal(t, "a comment\n[spanning lines]", games[1].MoveTree[0].StepComment)
+		require.Len(t, games[1].MoveTree[1].Variations, 1)
+	})
+
+	t.Run("a game without termination marker ends at the next tag pair", func(t *testing.T) {
+		database := "[Event \"One\"]\n[Result \"0-1\"]\n1. e4 e5\n[Event \"Two\"]\n1. d4 *"
+		games, gameErrors := readAll(t, NewReader(strings.NewReader(database)))
+		require.Empty(t, gameErrors)
+		require.Len(t, games, 2)
+		assert.Len(t, games[0].GameSteps, 2)
+		assert.Equal(t, "0-1", games[0].Result, "falls back to the Result tag")
+		assert.Equal(t, "*", games[1].Result)
+	})
+
+	t.Run("tag values may contain comment delimiters", func(t *testing.T) {
+		database := "[Event \"{Odd}; name\"]\n\n1. e4 *\n\n[Event \"Two\"]\n\n1. d4 *\n"
+		games, gameErrors := readAll(t, NewReader(strings.NewReader(database)))
+		require.Empty(t, gameErrors)
+		require.Len(t, games, 2)
+		assert.Equal(t, "Two", games[1].Metadata["Event"])
+	})
+
+	t.Run("a broken game is reported and skipped", func(t *testing.T) {
+		database := `[Event "One"]
+
+1. e4 e5 *
+
+[Event "Broken"]
+
+1. e4 e5 2. Qxf7 Nc6 *
+
+[Event "Three"]
+
+1. d4 *
+`
+		r := NewReader(strings.NewReader(database))
+		_, err := r.Read()
+		require.NoError(t, err)
+
+		game, err := r.Read()
+		var gameError *GameError
+		require.ErrorAs(t, err, &gameError)
+		assert.Equal(t, 2, gameError.Number)
+		assert.Equal(t, 5, gameError.Line)
+		assert.Contains(t, err.Error(), "Qxf7")
+		require.NotNil(t, game)
+		assert.Len(t, game.GameSteps, 2, "the valid prefix is returned")
+
+		game, err = r.Read()
+		require.NoError(t, err)
+		assert.Equal(t, "Three", game.Metadata["Event"])
+		assert.Equal(t, 3, game.Number)
+
+		_, err = r.Read()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("FEN tag sets the initial position", func(t *testing.T) {
+		database := `[Event "Endgame"]
+[SetUp "1"]
+[FEN "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"]
+
+1. e4 Kd7 *
+
+[Variant "Chess960"]
+[FEN "4k3/8/8/8/8/8/8/1R3KR1 w KQ - 0 1"]
+
+1. O-O-O *
+`
+		games, gameErrors := readAll(t, NewReader(strings.NewReader(database)))
+		require.Empty(t, gameErrors)
+		require.Len(t, games, 2)
+		assert.Equal(t, "8/3k4/8/8/4P3/8/8/4K3 w - - 1 2", games[0].GameSteps[1].StepGame.ToFEN())
+		assert.Equal(t, "4k3/8/8/8/8/8/8/2KR2R1 b - - 1 1", games[1].GameSteps[0].StepGame.ToFEN())
+	})
+
+	t.Run("Variant tag sets the rules variant", func(t *testing.T) {
+		database := `[Variant "Three-check"]
+[FEN "4k3/8/8/8/8/8/8/R3K3 w - - 0 1 +2+0"]
+
+1. Ra8+ *
+
+[Variant "Racing Kings"]
+
+1. Kh3 *
+
+[Variant "Crazyhouse"]
+
+1. e4 d5 2. exd5 Qxd5 3. Nc3 Qa5 4. P@d5 *
+`
+		games, gameErrors := readAll(t, NewReader(strings.NewReader(database)))
+		require.Empty(t, gameErrors)
+		require.Len(t, games, 3)
+		assert.True(t, games[0].GameSteps[0].StepGame.IsVariantEnd)
+		assert.Equal(t, "racingKings", games[1].GameSteps[0].StepGame.Variant().Name())
+		assert.Equal(t, "rnb1kbnr/ppp1pppp/8/q2P4/8/2N5/PPPP1PPP/R1BQKBNR[p] b KQkq - 0 4", games[2].GameSteps[6].StepGame.ToFEN())
+	})
+
+	t.Run("a game larger than MaxGameSize is reported and skipped", func(t *testing.T) {
+		database := "[Event \"Huge\"]\n\n1. e4 {" + strings.Repeat("long comment ", 1000) + "} e5 *\n\n[Event \"Small\"]\n\n1. d4 *\n"
+		r := NewReader(strings.NewReader(database))
+		r.MaxGameSize = 1000
+		games, gameErrors := readAll(t, r)
+		require.Len(t, gameErrors, 1)
+		assert.ErrorIs(t, gameErrors[0], ErrGameTooLarge)
+		assert.Equal(t, 1, gameErrors[0].Number)
+		require.Len(t, games, 1)
+		assert.Equal(t, "Small", games[0].Metadata["Event"])
+		assert.Equal(t, 2, games[0].Number)
+	})
+
+	t.Run("lines longer than the read buffer", func(t *testing.T) {
+		comment := strings.Repeat("x", 10000)
+		database := "[Event \"One\"]\n\n1. e4 {" + comment + "} e5 *\n[Event \"Two\"]\n\n1. d4 *"
+		games, gameErrors := readAll(t, NewReader(strings.NewReader(database)))
+		require.Empty(t, gameErrors)
+		require.Len(t, games, 2)
+		assert.Equal(t, comment, games[0].GameSteps[0].StepComment)
+	})
+
+	t.Run("empty database", func(t *testing.T) {
+		_, err := NewReader(strings.NewReader("\n\n")).Read()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func TestReaderCorpus(t *testing.T) {
+	files, err := filepath.Glob("../testdata/games/*.pgn")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	sort.Strings(files)
+	limit := 200
+	if testing.Short() {
+		limit = 20
+	}
+	if len(files) > limit {
+		files = files[:limit]
+	}
+
+	// Concatenate the games into one database, as they were before being split
+	readers := make([]io.Reader, 0, 2*len(files))
+	for _, file := range files {
+		f, err := os.Open(file)
+		require.NoError(t, err)
+		defer f.Close()
+		readers = append(readers, f, strings.NewReader("\n\n"))
+	}
+
+	games, gameErrors := readAll(t, NewReader(io.MultiReader(readers...)))
+	require.Empty(t, gameErrors)
+	require.Len(t, games, len(files))
+	for i, file := range files {
+		b, err := os.ReadFile(file)
+		require.NoError(t, err)
+		parsed, err := parsePGN(t, string(b))
+		require.NoError(t, err, file)
+		assert.Equal(t, parsed.Metadata, games[i].Metadata, file)
+		require.Equal(t, len(parsed.GameSteps), len(games[i].GameSteps), file)
+		assert.Equal(t, games[i].Metadata["Result"], games[i].Result, file)
+	}
+}
@@ -2,7 +2,10 @@ package pgn
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/marianogappa/cheesse/core"
 	"github.com/marianogappa/cheesse/parser"
@@ -42,8 +45,10 @@ func (p *VariantPGN) Initialize(initialGame core.Game, s string) (*parser.Parsin
 // It handles all token peeking and position management internally.
 // Only the token extraction logic is PGN-specific; game state management is handled generically.
 func (p *VariantPGN) PopHalfMove(pg *parser.ParsingGame) (*parser.Token, bool, error) {
-	// First, skip any move numbers, comments, annotations and variations that
-	// precede the next half move.
+	// First, skip any move numbers, annotations and variations that precede the
+	// next half move. Comments are kept as the half move's pre-move comment (e.g.
+	// at the start of the game or of a variation).
+	preComment := ""
 	for {
 		tokenValue, tokenType, newPos, err := peekToken(pg.Remaining, pg.Pos)
 		if err != nil {
@@ -62,6 +67,9 @@ func (p *VariantPGN) PopHalfMove(pg *parser.ParsingGame) (*parser.Token, bool, e
 				// Position didn't advance, break to avoid infinite loop
 				break
 			}
+			if tokenType == tokenTypeComment || tokenType == tokenTypeCurlyComment {
+				preComment = joinComments(preComment, commentText(tokenValue, tokenType))
+			}
 			pg.Pos = newPos
 			continue
 		}
@@ -84,18 +92,25 @@ func (p *VariantPGN) PopHalfMove(pg *parser.ParsingGame) (*parser.Token, bool, e
 
 	// Convert internal token type to generic token type
 	var genericType parser.TokenType
+	var nags []int
 	if tokenType == tokenTypeHalfMove {
 		genericType = parser.TokenTypeHalfMove
-		// Strip move annotations from the end of the token
+		// Strip move annotations from the end of the token, keeping them as NAGs
 		// Valid annotations: ?, !, ??, !!, ?!, !?
-		tokenValue = stripMoveAnnotations(tokenValue)
+		stripped := stripMoveAnnotations(tokenValue)
+		if nag, ok := moveAnnotationNAGs[tokenValue[len(stripped):]]; ok {
+			nags = append(nags, nag)
+		}
+		tokenValue = stripped
 	} else if tokenType == tokenTypeResult {
 		genericType = parser.TokenTypeResult
 	}
 
 	token := &parser.Token{
-		Value: tokenValue,
-		Type:  genericType,
+		Value:      tokenValue,
+		Type:       genericType,
+		NAGs:       nags,
+		PreComment: preComment,
 	}
 
 	// Now process any comments, annotations or variations that follow the half move
@@ -116,23 +131,28 @@ func (p *VariantPGN) PopHalfMove(pg *parser.ParsingGame) (*parser.Token, bool, e
 			if nextPos <= pg.Pos {
 				return token, false, nil
 			}
-			commentText := commentText(nextTokenValue, nextType)
-			if commentText != "" {
-				if token.Comment != "" {
-					token.Comment += " "
-				}
-				token.Comment += commentText
-			}
+			text, commands := extractCommentCommands(commentText(nextTokenValue, nextType))
+			token.Comment = joinComments(token.Comment, text)
+			token.Commands = append(token.Commands, commands...)
 			pg.Pos = nextPos
 			continue
 		}
 
-		// If it's an annotation, move number or variation, skip it
+		// If it's an annotation or a variation, attach it to the token; move
+		// numbers are skipped
 		if nextType == tokenTypeAnnotation || nextType == tokenTypeMoveNumber || nextType == tokenTypeRAV {
 			// Check if position actually advanced to avoid infinite loop
 			if nextPos <= pg.Pos {
 				return token, false, nil
 			}
+			switch nextType {
+			case tokenTypeAnnotation:
+				if nag, err := strconv.Atoi(strings.Trim(nextTokenValue, "($)")); err == nil {
+					token.NAGs = append(token.NAGs, nag)
+				}
+			case tokenTypeRAV:
+				token.Variations = append(token.Variations, strings.TrimSuffix(strings.TrimPrefix(nextTokenValue, "("), ")"))
+			}
 			pg.Pos = nextPos
 			continue
 		}
@@ -160,16 +180,97 @@ func commentText(tokenValue string, t tokenType) string {
 	return ""
 }
 
-// Finalize implements ParserVariant.Finalize for PGN.
+// commentCommandRegexp matches a command embedded in a comment, e.g. [%clk 0:03:00].
+var commentCommandRegexp = regexp.MustCompile(`\[%(\w+)\s*([^\]]*)\]`)
+
+// extractCommentCommands removes the embedded commands from a comment's text and
+// returns them separately, in order.
+func extractCommentCommands(text string) (string, []core.CommentCommand) {
+	matches := commentCommandRegexp.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return text, nil
+	}
+	commands := make([]core.CommentCommand, len(matches))
+	for i, match := range matches {
+		commands[i] = core.CommentCommand{Name: match[1], Value: strings.TrimSpace(match[2])}
+	}
+	return strings.Join(strings.Fields(commentCommandRegexp.ReplaceAllString(text, " ")), " "), commands
+}
+
+// joinComments appends a comment's text to the comments already collected,
+// separated by a space.
+func joinComments(comments, text string) string {
+	if text == "" {
+		return comments
+	}
+	if comments == "" {
+		return text
+	}
+	return comments + " " + text
+}
+
+// moveAnnotationNAGs maps move suffix annotations to their equivalent NAGs.
+var moveAnnotationNAGs = map[string]int{"!": 1, "?": 2, "!!": 3, "??": 4, "!?": 5, "?!": 6}
+
+// Finalize implements ParserVariant.Finalize for PGN. If the TimeControl tag has
+// a time control and the mainline has [%clk] commands, the mainline's games get
+// clocks (see core.Clock), whose times are the commands' times after each move.
+// The tag is kept as metadata either way.
 func (p *VariantPGN) Finalize(pg *parser.ParsingGame) error {
-	// Does nothing for now
+	tc, err := core.ParseTimeControl(pg.Metadata["TimeControl"])
+	if err != nil || len(tc.Periods) == 0 {
+		return nil
+	}
+	for i := range pg.Alternatives {
+		addClocks(&pg.Alternatives[i], tc)
+	}
 	return nil
 }
 
+// addClocks adds the clocks of the given time control to the alternative's
+// mainline, as its [%clk] commands say, if it has any.
+func addClocks(alt *parser.GameAlternative, tc core.TimeControl) {
+	if len(alt.MoveTree) != len(alt.GameSteps) {
+		return
+	}
+	times := make([]time.Duration, len(alt.MoveTree))
+	hasClocks := false
+	for i, node := range alt.MoveTree {
+		times[i] = -1
+		for _, command := range node.Commands {
+			if command.Name != "clk" {
+				continue
+			}
+			if t, err := core.ParseClockTime(command.Value); err == nil {
+				times[i], hasClocks = t, true
+			}
+		}
+	}
+	if !hasClocks {
+		return
+	}
+
+	clock := core.NewClock(tc)
+	alt.InitialGame = alt.InitialGame.WithClock(clock)
+	for i := range alt.MoveTree {
+		step := &alt.MoveTree[i].GameStep
+		step.StepPreMoveGame = step.StepPreMoveGame.WithClock(clock)
+		if step.StepAction.IsMove() {
+			player := step.StepPreMoveGame.Turn()
+			clock.Moves[player]++
+			if times[i] >= 0 {
+				clock.Remaining[player] = times[i]
+			}
+		}
+		step.StepGame = step.StepGame.WithClock(clock)
+		alt.GameSteps[i] = *step
+	}
+}
+
 // ActionToStringVariants implements ParserVariant.ActionToStringVariants for PGN.
 func (p *VariantPGN) ActionToStringVariants(a core.Action, g core.Game) []string {
-	// Terminal actions have no move notation: results are handled as result tokens.
-	if a.IsResign || a.IsDraw {
+	// Actions that aren't moves have no move notation: results are handled as result tokens.
+	if !a.IsMove() {
 		return nil
 	}
 	if a.IsKingsideCastle {
@@ -194,6 +295,14 @@ func (p *VariantPGN) ActionToStringVariants(a core.Action, g core.Game) []string
 		checkMate = "#"
 	}
 
+	if a.IsDrop {
+		variants := []string{a.DropString() + check + checkMate}
+		if a.FromPiece.PieceType == core.PiecePawn {
+			variants = append(variants, a.DropString()[1:]+check+checkMate)
+		}
+		return variants
+	}
+
 	if a.IsPromotion {
 		promotion := "=" + a.PromotionPieceType.ToAlgebraic()
 		suffix := promotion + check + checkMate
@@ -251,16 +360,34 @@ func extractTagPairs(pgn string) (map[string]string, string, error) {
 
 		switch state {
 		case stateNormal:
-			if char == '[' {
+			switch char {
+			case '[':
 				state = stateTagPair
 				tagName.Reset()
 				tagValue.Reset()
 				inTagValue = false
 				pos++
-			} else {
-				result.WriteByte(char)
-				pos++
+				continue
+			case '{':
+				// Comments may contain brackets, e.g. [%clk 0:03:00]; they're not tag pairs
+				state = stateCurlyComment
+			case ';':
+				state = stateSemicolonComment
+			}
+			result.WriteByte(char)
+			pos++
+		case stateCurlyComment:
+			if char == '}' {
+				state = stateNormal
 			}
+			result.WriteByte(char)
+			pos++
+		case stateSemicolonComment:
+			if char == '\n' {
+				state = stateNormal
+			}
+			result.WriteByte(char)
+			pos++
 		case stateTagPair:
 			if char == ']' {
 				// End of tag pair
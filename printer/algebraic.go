@@ -60,7 +60,7 @@ func algDisambiguate(gameStep core.GameStep) string {
 }
 
 func algEnPassant(gameStep core.GameStep, gameCharacteristics GameCharacteristics) string {
-	if !gameStep.StepAction.IsEnPassantCapture || gameCharacteristics.usesEnPassantSymbol == nil {
+	if !gameStep.StepAction.IsEnPassantCapture || gameCharacteristics.usesEnPassantSymbol == nil || *gameCharacteristics.usesEnPassantSymbol == "" {
 		return ""
 	}
 	return fmt.Sprintf(" %v", *gameCharacteristics.usesEnPassantSymbol)
@@ -163,8 +163,28 @@ func algMove(gameStep core.GameStep, gameCharacteristics GameCharacteristics) st
 	)
 }
 
+// algDrop prints a drop, e.g. "N@f3", with pawns as "P@e4".
+func algDrop(gameStep core.GameStep, gameCharacteristics GameCharacteristics) string {
+	a := gameStep.StepAction
+	piece := a.DropString()[:1]
+	if gameCharacteristics.isFigurine {
+		piece = a.FromPiece.PieceType.ToColorFigurine(a.FromPiece.Owner)
+	}
+	return fmt.Sprintf(
+		"%v@%v%v%v",
+		piece,
+		a.ToXY.ToAlgebraic(),
+		algCheck(gameStep, gameCharacteristics),
+		algCheckmate(gameStep, gameCharacteristics),
+	)
+}
+
 func algResign(gameStep core.GameStep, gameCharacteristics GameCharacteristics) string {
-	if (!gameStep.StepAction.IsResign && !gameStep.StepAction.IsDraw) || gameCharacteristics.usesEndGameSymbol == nil {
+	if gameStep.StepAction.IsDrawOffer {
+		return drawOfferSymbol
+	}
+	a := gameStep.StepAction
+	if (!a.IsResign && !a.IsDraw && !a.IsDrawAccept && !a.IsDrawClaim) || gameCharacteristics.usesEndGameSymbol == nil {
 		return ""
 	}
 	return fmt.Sprintf("%v", *gameCharacteristics.usesEndGameSymbol)
@@ -175,9 +195,12 @@ func (p AlgebraicPrinter) PrintAction(gameStep core.GameStep, gameCharacteristic
 	if gameStep.StepAction.IsCastle {
 		return algCastle(gameStep, gameCharacteristics), nil
 	}
-	if gameStep.StepAction.IsResign || gameStep.StepAction.IsDraw {
+	if !gameStep.StepAction.IsMove() {
 		return algResign(gameStep, gameCharacteristics), nil
 	}
+	if gameStep.StepAction.IsDrop {
+		return algDrop(gameStep, gameCharacteristics), nil
+	}
 	if gameStep.StepAction.IsCapture {
 		return algCapture(gameStep, gameCharacteristics), nil
 	}
@@ -242,6 +242,26 @@ func TestAlgebraicPrinter_Promotion(t *testing.T) {
 	})
 }
 
+func TestAlgebraicPrinter_Drop(t *testing.T) {
+	g, err := core.NewVariantGameFromFEN("r1bqkbnr/pppp1ppp/8/4n3/3PP3/8/PPPP1PPP/RNBQKB1R[n] b KQkq - 0 4", core.VariantCrazyhouse)
+	require.NoError(t, err)
+	for _, action := range g.Actions {
+		if !action.IsDrop || action.ToXY != (core.XY{X: 5, Y: 5}) {
+			continue
+		}
+		gs := core.GameStep{StepAction: action, StepGame: g.DoAction(action), StepPreMoveGame: g}
+		result, err := AlgebraicPrinter{}.PrintAction(gs, GameCharacteristics{})
+		require.NoError(t, err)
+		assert.Equal(t, "N@f3+", result)
+
+		result, err = AlgebraicPrinter{}.PrintAction(gs, FigurineCharacteristics())
+		require.NoError(t, err)
+		assert.Equal(t, "♞@f3+", result)
+		return
+	}
+	t.Fatal("no drop on f3")
+}
+
 func TestAlgebraicPrinter_DoubleCheck(t *testing.T) {
 	p := AlgebraicPrinter{}
 	gc := GameCharacteristics{}
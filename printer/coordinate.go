@@ -17,9 +17,12 @@ func (p CoordinatePrinter) PrintAction(gameStep core.GameStep, gameCharacteristi
 	if gameStep.StepAction.IsCastle {
 		return algCastle(gameStep, gameCharacteristics) + coordCheck(gameStep, gameCharacteristics), nil
 	}
-	if gameStep.StepAction.IsResign || gameStep.StepAction.IsDraw {
+	if !gameStep.StepAction.IsMove() {
 		return algResign(gameStep, gameCharacteristics), nil
 	}
+	if gameStep.StepAction.IsDrop {
+		return algDrop(gameStep, gameCharacteristics), nil
+	}
 	delimiter := "-"
 	if gameStep.StepAction.IsCapture {
 		delimiter = "x"
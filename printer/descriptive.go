@@ -120,7 +120,11 @@ func move(gameStep core.GameStep, gameCharacteristics GameCharacteristics) strin
 }
 
 func resign(gameStep core.GameStep, gameCharacteristics GameCharacteristics) string {
-	if (!gameStep.StepAction.IsResign && !gameStep.StepAction.IsDraw) || gameCharacteristics.usesEndGameSymbol == nil {
+	if gameStep.StepAction.IsDrawOffer {
+		return drawOfferSymbol
+	}
+	a := gameStep.StepAction
+	if (!a.IsResign && !a.IsDraw && !a.IsDrawAccept && !a.IsDrawClaim) || gameCharacteristics.usesEndGameSymbol == nil {
 		return ""
 	}
 	return fmt.Sprintf("%v", *gameCharacteristics.usesEndGameSymbol)
@@ -132,7 +136,7 @@ func (p DescriptivePrinter) PrintAction(gameStep core.GameStep, gameCharacterist
 	if gameStep.StepAction.IsCastle {
 		return castle(gameStep, gameCharacteristics), nil
 	}
-	if gameStep.StepAction.IsResign || gameStep.StepAction.IsDraw {
+	if !gameStep.StepAction.IsMove() {
 		return resign(gameStep, gameCharacteristics), nil
 	}
 	if gameStep.StepAction.IsCapture {
@@ -32,6 +32,9 @@ type GameCharacteristics struct {
 	descriptiveUseKt               *bool
 }
 
+// drawOfferSymbol is how scoresheets record a draw offer, per FIDE's Laws of Chess.
+const drawOfferSymbol = "(=)"
+
 func pstr(s string) *string {
 	return &s
 }
@@ -44,6 +47,15 @@ func SANCharacteristics() GameCharacteristics {
 	}
 }
 
+// PGNCharacteristics returns GameCharacteristics for PGN's movetext: SAN, which
+// (unlike the printer defaults) doesn't mark en passant captures or double checks.
+func PGNCharacteristics() GameCharacteristics {
+	gc := SANCharacteristics()
+	gc.usesEnPassantSymbol = pstr("")
+	gc.usesDoubleCheckSymbol = pstr("+")
+	return gc
+}
+
 // FigurineCharacteristics returns GameCharacteristics for Figurine Algebraic Notation:
 // SAN with unicode chess symbols instead of piece letters.
 func FigurineCharacteristics() GameCharacteristics {
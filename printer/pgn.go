@@ -10,7 +10,7 @@ import (
 
 // PGNPrinter renders a game as a PGN document: a tag-pair section (the Seven Tag
 // Roster plus any extra metadata) followed by the movetext with move numbers,
-// comments, the result marker, and lines wrapped at 80 columns.
+// comments, NAGs, variations, the result marker, and lines wrapped at 80 columns.
 type PGNPrinter struct {
 	// Metadata holds tag pairs to render in the tag section (e.g. from a parsed
 	// PGN's headers). Seven Tag Roster keys missing from it get "?" placeholders.
@@ -22,7 +22,35 @@ var pgnSevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Bla
 // PrintGame renders the full PGN document as lines: the tag section, a blank
 // line, then the movetext wrapped at 80 columns.
 func (p PGNPrinter) PrintGame(gameSteps []core.GameStep, gameCharacteristics GameCharacteristics) ([]string, error) {
+	moveTree := make([]core.MoveNode, len(gameSteps))
+	for i, gameStep := range gameSteps {
+		moveTree[i] = core.MoveNode{GameStep: gameStep}
+	}
+	return p.PrintMoveTree(moveTree, gameCharacteristics)
+}
+
+// PrintMoveTree is like PrintGame, but renders a move tree (e.g. a parsed PGN's
+// MoveTree): besides the mainline, the movetext has each move's NAGs, comments
+// (including embedded commands such as [%clk ...]) and nested variations.
+//
+// Timed games (see core.Clock) get a TimeControl tag, unless Metadata has one,
+// and a [%clk] command with the player's remaining time after each of their moves
+// that doesn't have one.
+func (p PGNPrinter) PrintMoveTree(moveTree []core.MoveNode, gameCharacteristics GameCharacteristics) ([]string, error) {
+	gameSteps := make([]core.GameStep, len(moveTree))
+	for i, node := range moveTree {
+		gameSteps[i] = node.GameStep
+	}
 	result := p.resultMarker(gameSteps)
+	if len(moveTree) > 0 && moveTree[0].StepPreMoveGame.Clock.IsTimed() {
+		if _, ok := p.Metadata["TimeControl"]; !ok {
+			metadata := map[string]string{"TimeControl": moveTree[0].StepPreMoveGame.Clock.TimeControl.String()}
+			for k, v := range p.Metadata {
+				metadata[k] = v
+			}
+			p.Metadata = metadata
+		}
+	}
 
 	lines := []string{}
 	for _, tag := range pgnSevenTagRoster {
@@ -59,10 +87,15 @@ func (p PGNPrinter) PrintGame(gameSteps []core.GameStep, gameCharacteristics Gam
 	}
 	lines = append(lines, "")
 
-	movetext, err := p.movetext(gameSteps, result)
-	if err != nil {
+	var sb strings.Builder
+	if err := p.writeLine(&sb, moveTree); err != nil {
 		return nil, err
 	}
+	if sb.Len() > 0 {
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(result)
+	movetext := sb.String()
 	lines = append(lines, wrapText(movetext, 80)...)
 	return lines, nil
 }
@@ -86,10 +119,12 @@ func (p PGNPrinter) resultMarker(gameSteps []core.GameStep) string {
 	finalGame := last.StepGame
 	switch {
 	case finalGame.IsCheckmate && finalGame.GameOverWinner.String() == "White",
-		last.StepAction.IsResign && last.StepAction.FromPiece.Owner.String() == "Black":
+		last.StepAction.IsResign && last.StepAction.FromPiece.Owner.String() == "Black",
+		finalGame.IsTimeout && finalGame.GameOverWinner.String() == "White":
 		return "1-0"
 	case finalGame.IsCheckmate && finalGame.GameOverWinner.String() == "Black",
-		last.StepAction.IsResign && last.StepAction.FromPiece.Owner.String() == "White":
+		last.StepAction.IsResign && last.StepAction.FromPiece.Owner.String() == "White",
+		finalGame.IsTimeout && finalGame.GameOverWinner.String() == "Black":
 		return "0-1"
 	case finalGame.IsDraw, finalGame.IsStalemate, last.StepAction.IsDraw:
 		return "1/2-1/2"
@@ -97,40 +132,79 @@ func (p PGNPrinter) resultMarker(gameSteps []core.GameStep) string {
 	return "*"
 }
 
-func (p PGNPrinter) movetext(gameSteps []core.GameStep, result string) (string, error) {
-	sanCharacteristics := SANCharacteristics()
-	var sb strings.Builder
+// writeLine writes a line of moves (the mainline or a variation) as movetext,
+// recursing into variations.
+func (p PGNPrinter) writeLine(sb *strings.Builder, line []core.MoveNode) error {
+	pgnCharacteristics := PGNCharacteristics()
 	needsMoveNumber := true
-	for _, gameStep := range gameSteps {
-		// Result markers and terminal actions are rendered via the result marker at the end.
-		if gameStep.StepAction == (core.Action{}) || gameStep.StepAction.IsResign || gameStep.StepAction.IsDraw {
+	for _, node := range line {
+		// Result markers and actions that aren't moves (e.g. resignations) are rendered via the result marker at the end.
+		if !node.StepAction.IsMove() {
 			continue
 		}
-		san, err := AlgebraicPrinter{}.PrintAction(gameStep, sanCharacteristics)
+		san, err := AlgebraicPrinter{}.PrintAction(node.GameStep, pgnCharacteristics)
 		if err != nil {
-			return "", err
+			return err
 		}
-		if sb.Len() > 0 {
-			sb.WriteByte(' ')
+		if node.PreComment != "" {
+			writeSeparated(sb, fmt.Sprintf("{%s}", node.PreComment))
+			needsMoveNumber = true
 		}
-		preMoveGame := gameStep.StepPreMoveGame
+		preMoveGame := node.StepPreMoveGame
 		if preMoveGame.Turn() == core.ColorWhite {
-			fmt.Fprintf(&sb, "%d. ", preMoveGame.FullMoveNumber)
+			writeSeparated(sb, fmt.Sprintf("%d. %s", preMoveGame.FullMoveNumber, san))
 		} else if needsMoveNumber {
-			fmt.Fprintf(&sb, "%d... ", preMoveGame.FullMoveNumber)
+			writeSeparated(sb, fmt.Sprintf("%d... %s", preMoveGame.FullMoveNumber, san))
+		} else {
+			writeSeparated(sb, san)
 		}
-		sb.WriteString(san)
 		needsMoveNumber = false
-		if gameStep.StepComment != "" {
-			fmt.Fprintf(&sb, " {%s}", gameStep.StepComment)
+		for _, nag := range node.NAGs {
+			fmt.Fprintf(sb, " $%d", nag)
+		}
+		if comment := pgnComment(node); comment != "" {
+			fmt.Fprintf(sb, " {%s}", comment)
 			needsMoveNumber = true // Conventionally re-state the move number after a comment
 		}
+		for _, variation := range node.Variations {
+			sb.WriteString(" (")
+			if err := p.writeLine(sb, variation); err != nil {
+				return err
+			}
+			sb.WriteByte(')')
+			needsMoveNumber = true
+		}
 	}
-	if sb.Len() > 0 {
+	return nil
+}
+
+// pgnComment renders the comment following a move: its embedded commands (e.g.
+// [%eval 0.17]), including the player's clock in timed games, followed by its
+// text.
+func pgnComment(node core.MoveNode) string {
+	parts := make([]string, 0, len(node.Commands)+2)
+	hasClock := false
+	for _, command := range node.Commands {
+		parts = append(parts, fmt.Sprintf("[%%%s %s]", command.Name, command.Value))
+		hasClock = hasClock || command.Name == "clk"
+	}
+	if clock := node.StepGame.Clock; clock.IsTimed() && !hasClock {
+		remaining := clock.Remaining[node.StepPreMoveGame.Turn()]
+		parts = append(parts, fmt.Sprintf("[%%clk %s]", core.FormatClockTime(remaining)))
+	}
+	if node.StepComment != "" {
+		parts = append(parts, node.StepComment)
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeSeparated writes s, preceded by a space unless it starts the builder or
+// follows an opening parenthesis.
+func writeSeparated(sb *strings.Builder, s string) {
+	if current := sb.String(); current != "" && !strings.HasSuffix(current, "(") {
 		sb.WriteByte(' ')
 	}
-	sb.WriteString(result)
-	return sb.String(), nil
+	sb.WriteString(s)
 }
 
 // wrapText greedily wraps the text at the given column, breaking on spaces.
@@ -1,8 +1,12 @@
 package printer
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/marianogappa/cheesse/core"
 	"github.com/marianogappa/cheesse/parser"
@@ -95,3 +99,137 @@ func TestPGNPrinterRoundTrip(t *testing.T) {
 	}
 	assert.Equal(t, "RT", reparsed.Metadata["Event"])
 }
+
+func TestPGNPrinterMoveTree(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pgn      string
+		expected string
+	}{
+		{
+			name:     "variation re-states move numbers",
+			pgn:      "1. e4 e5 (1... c5 2. Nf3) 2. Nf3 Nc6 *",
+			expected: "1. e4 e5 (1... c5 2. Nf3) 2. Nf3 Nc6 *",
+		},
+		{
+			name:     "variation on a white move",
+			pgn:      "1. e4 (1. d4 d5) 1... e5 *",
+			expected: "1. e4 (1. d4 d5) 1... e5 *",
+		},
+		{
+			name:     "nested and sibling variations",
+			pgn:      "1. e4 e5 (1... c5 (1... e6 2. d4) 2. Nf3) (1... d5) 2. Nf3 *",
+			expected: "1. e4 e5 (1... c5 (1... e6 2. d4) 2. Nf3) (1... d5) 2. Nf3 *",
+		},
+		{
+			name:     "NAGs, including move suffixes",
+			pgn:      "1. e4! $14 e5?! 2. Nf3 *",
+			expected: "1. e4 $1 $14 e5 $6 2. Nf3 *",
+		},
+		{
+			name:     "pre-move comments",
+			pgn:      "{Start} 1. e4 e5 ({Or} 1... c5) 2. Nf3 *",
+			expected: "{Start} 1. e4 e5 ({Or} 1... c5) 2. Nf3 *",
+		},
+		{
+			name:     "embedded commands precede the comment text",
+			pgn:      "1. e4 { [%eval 0.17] [%clk 0:03:00] } e5 {Solid [%clk 0:02:59]} *",
+			expected: "1. e4 {[%eval 0.17] [%clk 0:03:00]} 1... e5 {[%clk 0:02:59] Solid} *",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsed := parsePGNForPrinting(t, tc.pgn)
+			lines, err := PGNPrinter{}.PrintMoveTree(parsed.MoveTree, SANCharacteristics())
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, strings.Join(lines[len(pgnSevenTagRoster)+1:], " "))
+		})
+	}
+}
+
+func TestPGNPrinterClocks(t *testing.T) {
+	tc, err := core.ParseTimeControl("180+2")
+	require.NoError(t, err)
+	g := core.NewDefaultGame().WithClock(core.NewClock(tc))
+	var steps []core.GameStep
+	for _, move := range []struct {
+		san     string
+		elapsed time.Duration
+	}{{"e4", time.Second}, {"e5", 4500 * time.Millisecond}, {"Nf3", 10 * time.Second}} {
+		matches, err := parser.NewGenericNotationParser(pgn.NewVariantPGN()).MatchHalfMove(move.san, g)
+		require.NoError(t, err)
+		newGame := g.DoTimedAction(matches[0], move.elapsed)
+		steps = append(steps, core.GameStep{StepAction: matches[0], StepGame: newGame, StepPreMoveGame: g})
+		g = newGame
+	}
+
+	lines, err := PGNPrinter{}.PrintGame(steps, SANCharacteristics())
+	require.NoError(t, err)
+	assert.Contains(t, lines, `[TimeControl "180+2"]`)
+	assert.Equal(t, "1. e4 {[%clk 0:03:01]} 1... e5 {[%clk 0:02:57.5]} 2. Nf3 {[%clk 0:02:53]} *", lines[len(lines)-1])
+
+	// The clocks survive a round trip
+	parsed := parsePGNForPrinting(t, strings.Join(lines, "\n"))
+	assert.Equal(t, g.Clock, parsed.GameSteps[2].StepGame.Clock)
+	reprinted, err := PGNPrinter{Metadata: parsed.Metadata}.PrintMoveTree(parsed.MoveTree, SANCharacteristics())
+	require.NoError(t, err)
+	assert.Equal(t, lines, reprinted)
+}
+
+func TestPGNPrinterMoveTreeRoundTrip(t *testing.T) {
+	initialGame, err := core.NewGameFromFEN("rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1")
+	require.NoError(t, err)
+	genericParser := parser.NewGenericNotationParser(pgn.NewVariantPGN())
+
+	files, err := filepath.Glob(filepath.Join("../parser/testdata/games", "*.pgn"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	limit := len(files)
+	if testing.Short() {
+		limit = 100
+	}
+
+	// Parsing is slow enough that round-tripping the whole corpus twice takes too
+	// long; every annotated game is covered, but only a sample of the plain ones.
+	for i, file := range files[:limit] {
+		pgnContent, err := os.ReadFile(file)
+		require.NoError(t, err)
+		if !testing.Short() && i%10 != 0 && !strings.ContainsAny(string(pgnContent), "{($;") {
+			continue
+		}
+		parsed, err := genericParser.Parse(initialGame, string(pgnContent))
+		require.NoError(t, err, file)
+
+		lines, err := PGNPrinter{Metadata: parsed.Metadata}.PrintMoveTree(parsed.MoveTree, SANCharacteristics())
+		require.NoError(t, err, file)
+		reparsed, err := genericParser.Parse(initialGame, strings.Join(lines, "\n"))
+		require.NoError(t, err, file)
+
+		for k, v := range parsed.Metadata {
+			assert.Equal(t, v, reparsed.Metadata[k], "%v: tag %v", file, k)
+		}
+		assertMoveTreesEqual(t, parsed.MoveTree, reparsed.MoveTree, filepath.Base(file))
+	}
+}
+
+// assertMoveTreesEqual asserts that two move trees are semantically identical:
+// same actions, annotations and variations, regardless of how they were written.
+func assertMoveTreesEqual(t *testing.T, expected, actual []core.MoveNode, path string) {
+	t.Helper()
+	if !assert.Len(t, actual, len(expected), path) {
+		return
+	}
+	for i := range expected {
+		nodePath := fmt.Sprintf("%s/%d", path, i)
+		assert.Equal(t, expected[i].StepAction, actual[i].StepAction, nodePath)
+		assert.Equal(t, expected[i].StepComment, actual[i].StepComment, nodePath)
+		assert.Equal(t, expected[i].PreComment, actual[i].PreComment, nodePath)
+		assert.Equal(t, expected[i].NAGs, actual[i].NAGs, nodePath)
+		assert.Equal(t, expected[i].Commands, actual[i].Commands, nodePath)
+		if assert.Len(t, actual[i].Variations, len(expected[i].Variations), nodePath) {
+			for j := range expected[i].Variations {
+				assertMoveTreesEqual(t, expected[i].Variations[j], actual[i].Variations[j], fmt.Sprintf("%s(%d)", nodePath, j))
+			}
+		}
+	}
+}
@@ -8,7 +8,10 @@ LABELS = {
     "BenchmarkDoAction_Start": "Apply a move (opening)",
     "BenchmarkDoAction_Kiwipete": "Apply a move (complex position)",
     "BenchmarkNewGameFromFEN": "Parse a FEN string",
+    "BenchmarkLegalActions_Kiwipete": "Generate legal moves in place (complex position)",
+    "BenchmarkMakeUnmakeMove_Kiwipete": "Make and unmake a move (complex position)",
     "BenchmarkPerft3_Start": "Perft(3) from starting position",
+    "BenchmarkPerft3_Start_DoAction": "Perft(3) from starting position, with DoAction",
     "BenchmarkAIDepth0_Start": "AI move, Easy (depth 0, opening)",
     "BenchmarkAIDepth1_Start": "AI move, Medium (depth 1, opening)",
     "BenchmarkAIDepth2_Endgame": "AI move, Hard (depth 2, endgame)",
@@ -0,0 +1,413 @@
+// Package uci implements the Universal Chess Interface protocol on top of the
+// core and ai packages, so that cheesse can be used as an engine by chess GUIs
+// and tournament managers.
+//
+// Reference: https://www.wbec-ridderkerk.nl/html/UCIProtocol.html
+package uci
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marianogappa/cheesse/ai"
+	"github.com/marianogappa/cheesse/core"
+)
+
+const (
+	engineName   = "cheesse"
+	engineAuthor = "Mariano Gappa"
+
+	// defaultMovesToGo is the number of moves the remaining time is assumed to be
+	// for, when "go" has clock times but no "movestogo".
+	defaultMovesToGo = 30
+
+	// maxHashMB is the maximum size of the transposition table, in megabytes.
+	maxHashMB = 1024
+)
+
+// Engine is a UCI engine. Commands are read with Run; responses are written to
+// the Engine's output.
+type Engine struct {
+	out io.Writer
+	mu  sync.Mutex // serializes writes to out, which searches do concurrently
+
+	game     core.Game
+	chess960 bool
+	// tt is kept across searches, so that what's learned on a move helps on the
+	// next ones.
+	tt *ai.TranspositionTable
+	// tablebase is set with the SyzygyPath option.
+	tablebase *core.Tablebase
+
+	search *search // the running search, if any
+}
+
+// search is a "go" command being run in the background.
+type search struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	// unbounded is whether the search only ends on "stop", as it has no depth or
+	// time limit.
+	unbounded bool
+}
+
+// limits are the arguments of a "go" command.
+type limits struct {
+	depth     int
+	nodes     int64
+	moveTime  time.Duration
+	wTime     time.Duration
+	bTime     time.Duration
+	wInc      time.Duration
+	bInc      time.Duration
+	movesToGo int
+	infinite  bool
+}
+
+// New creates a UCI engine that writes its responses to out, starting from the
+// default game.
+func New(out io.Writer) *Engine {
+	return &Engine{
+		out:  out,
+		game: core.NewDefaultGame(),
+		tt:   ai.NewTranspositionTable(ai.DefaultTranspositionTableSizeMB),
+	}
+}
+
+// Run reads commands from in until "quit" or the end of the input. At the end of
+// the input, a running search is let finish (or stopped, if unbounded) first, so
+// that scripted sessions get their "bestmove".
+func (e *Engine) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if quit := e.Execute(scanner.Text()); quit {
+			return nil
+		}
+	}
+	if e.search != nil && e.search.unbounded {
+		e.stopSearch()
+	}
+	e.waitSearch()
+	return scanner.Err()
+}
+
+// Execute runs a single command, and returns whether it was "quit". Unknown
+// commands are reported with an "info string" and otherwise ignored, as the
+// protocol requires.
+func (e *Engine) Execute(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	switch cmd, args := fields[0], fields[1:]; cmd {
+	case "uci":
+		e.println("id name " + engineName)
+		e.println("id author " + engineAuthor)
+		e.println(fmt.Sprintf("option name Hash type spin default %d min 1 max %d", ai.DefaultTranspositionTableSizeMB, maxHashMB))
+		e.println("option name UCI_Chess960 type check default false")
+		e.println("option name SyzygyPath type string default <empty>")
+		e.println("uciok")
+	case "isready":
+		e.println("readyok")
+	case "debug", "register", "ponderhit":
+		// Not supported, and safe to ignore
+	case "setoption":
+		e.setOption(args)
+	case "ucinewgame":
+		e.stopSearch()
+		e.game = core.NewDefaultGame()
+		e.tt.Clear()
+	case "position":
+		e.stopSearch()
+		if err := e.position(args); err != nil {
+			e.println("info string " + err.Error())
+		}
+	case "go":
+		e.stopSearch()
+		e.goSearch(parseLimits(args))
+	case "stop":
+		e.stopSearch()
+	case "quit":
+		e.stopSearch()
+		return true
+	default:
+		e.println(fmt.Sprintf("info string unknown command %q", cmd))
+	}
+	return false
+}
+
+// setOption handles "setoption name <id> [value <x>]".
+func (e *Engine) setOption(args []string) {
+	var name, value []string
+	target := &name
+	for _, arg := range args {
+		switch arg {
+		case "name":
+			target = &name
+		case "value":
+			target = &value
+		default:
+			*target = append(*target, arg)
+		}
+	}
+	switch strings.Join(name, " ") {
+	case "Hash":
+		e.stopSearch()
+		sizeMB, err := strconv.Atoi(strings.Join(value, " "))
+		if err != nil || sizeMB < 1 || sizeMB > maxHashMB {
+			e.println(fmt.Sprintf("info string invalid Hash value %q", strings.Join(value, " ")))
+			return
+		}
+		e.tt = ai.NewTranspositionTable(sizeMB)
+	case "UCI_Chess960":
+		e.stopSearch() // The search renders its moves according to it
+		e.chess960 = strings.Join(value, " ") == "true"
+	case "SyzygyPath":
+		e.stopSearch()
+		path := strings.Join(value, " ")
+		if path == "" || path == "<empty>" {
+			e.tablebase = nil
+			return
+		}
+		tb, err := core.OpenTablebase(path)
+		if err != nil {
+			e.println("info string " + err.Error())
+			return
+		}
+		e.tablebase = tb
+		e.println(fmt.Sprintf("info string found Syzygy tables of up to %d pieces", tb.MaxPieces()))
+	default:
+		e.println(fmt.Sprintf("info string unknown option %q", strings.Join(name, " ")))
+	}
+}
+
+// position handles "position startpos|fen <fen> [moves <move>...]".
+func (e *Engine) position(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("position requires startpos or fen")
+	}
+	var (
+		g    core.Game
+		rest []string
+	)
+	switch args[0] {
+	case "startpos":
+		g, rest = core.NewDefaultGame(), args[1:]
+	case "fen":
+		fen := args[1:]
+		for i, arg := range fen {
+			if arg == "moves" {
+				fen, rest = fen[:i], fen[i:]
+				break
+			}
+		}
+		var err error
+		if e.chess960 {
+			g, err = core.NewChess960GameFromFEN(strings.Join(fen, " "))
+		} else {
+			g, err = core.NewGameFromFEN(strings.Join(fen, " "))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid fen: %w", err)
+		}
+	default:
+		return fmt.Errorf("position requires startpos or fen, got %q", args[0])
+	}
+
+	if len(rest) > 0 && rest[0] == "moves" {
+		for _, move := range rest[1:] {
+			action, ok := e.findAction(g, move)
+			if !ok {
+				return fmt.Errorf("illegal move %q in %v", move, g.ToFEN())
+			}
+			g = g.DoAction(action)
+		}
+	}
+	e.game = g
+	return nil
+}
+
+// parseLimits parses the arguments of "go". Unsupported arguments (e.g.
+// "searchmoves") are ignored.
+func parseLimits(args []string) limits {
+	var l limits
+	for i := 0; i < len(args); i++ {
+		value := func() int {
+			if i+1 >= len(args) {
+				return 0
+			}
+			i++
+			n, _ := strconv.Atoi(args[i])
+			return n
+		}
+		ms := func() time.Duration { return time.Duration(value()) * time.Millisecond }
+		switch args[i] {
+		case "depth":
+			l.depth = value()
+		case "nodes":
+			l.nodes = int64(value())
+		case "movetime":
+			l.moveTime = ms()
+		case "wtime":
+			l.wTime = ms()
+		case "btime":
+			l.bTime = ms()
+		case "winc":
+			l.wInc = ms()
+		case "binc":
+			l.bInc = ms()
+		case "movestogo":
+			l.movesToGo = value()
+		case "infinite":
+			l.infinite = true
+		}
+	}
+	return l
+}
+
+// budget returns how long the side to move may search for, or 0 for no time
+// limit.
+func (l limits) budget(turn core.Color) time.Duration {
+	if l.moveTime > 0 {
+		return l.moveTime
+	}
+	remaining, inc := l.bTime, l.bInc
+	if turn == core.ColorWhite {
+		remaining, inc = l.wTime, l.wInc
+	}
+	if remaining <= 0 {
+		return 0
+	}
+	movesToGo := l.movesToGo
+	if movesToGo <= 0 {
+		movesToGo = defaultMovesToGo
+	}
+	budget := remaining/time.Duration(movesToGo) + inc
+	if budget > remaining/2 {
+		budget = remaining / 2
+	}
+	return budget
+}
+
+// goSearch starts a search of the current game in the background. It reports an
+// "info" line for every completed depth and finishes with "bestmove": when a
+// limit is reached, or on "stop".
+func (e *Engine) goSearch(l limits) {
+	g := e.game
+	searchLimits := ai.Limits{Depth: l.depth, Time: l.budget(g.Turn()), Nodes: l.nodes}
+	if l.infinite {
+		searchLimits = ai.Limits{}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &search{
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		unbounded: searchLimits == ai.Limits{},
+	}
+	e.search = s
+	if e.tablebase != nil {
+		searchLimits.Tablebase = e.tablebase
+	}
+
+	go func() {
+		defer close(s.done)
+		result, ok := ai.SearchWithTable(ctx, e.tt, g, searchLimits, func(r ai.SearchResult) {
+			e.println(e.infoString(g, r))
+		})
+		if l.infinite {
+			// The protocol requires waiting for "stop" before answering
+			<-ctx.Done()
+		}
+		if !ok {
+			e.println("bestmove (none)")
+			return
+		}
+		e.println("bestmove " + e.moveString(g, result.Action))
+	}()
+}
+
+// infoString renders a search iteration's result as an "info" line.
+func (e *Engine) infoString(g core.Game, r ai.SearchResult) string {
+	score := fmt.Sprintf("cp %d", r.Score)
+	if r.Mate != 0 {
+		score = fmt.Sprintf("mate %d", r.Mate)
+	}
+	pv := make([]string, len(r.PV))
+	for i, action := range r.PV {
+		pv[i] = e.moveString(g, action)
+		g = g.DoAction(action)
+	}
+	nps := int64(0)
+	if r.Time > 0 {
+		nps = r.Nodes * int64(time.Second) / int64(r.Time)
+	}
+	return fmt.Sprintf("info depth %d score %s nodes %d nps %d time %d pv %s", r.Depth, score, r.Nodes, nps, r.Time.Milliseconds(), strings.Join(pv, " "))
+}
+
+// stopSearch stops the running search, if any, and waits for its "bestmove".
+func (e *Engine) stopSearch() {
+	if e.search == nil {
+		return
+	}
+	e.search.cancel()
+	e.waitSearch()
+}
+
+// waitSearch waits for the running search, if any, to finish.
+func (e *Engine) waitSearch() {
+	if e.search == nil {
+		return
+	}
+	<-e.search.done
+	e.search.cancel()
+	e.search = nil
+}
+
+// moveString renders an action in UCI's long algebraic notation, e.g. e2e4 or
+// e7e8q. Castling is rendered as the king's move, or as king takes rook when
+// UCI_Chess960 is set. Drops are rendered as in SAN, e.g. N@f3.
+func (e *Engine) moveString(g core.Game, a core.Action) string {
+	if a.IsDrop {
+		return a.DropString()
+	}
+	to := a.ToXY
+	if a.IsCastle && e.chess960 {
+		to = g.CastlingRookXY(a.FromPiece.Owner, a.IsKingsideCastle)
+	}
+	s := a.FromPiece.XY.ToAlgebraic() + to.ToAlgebraic()
+	if a.IsPromotion {
+		s += strings.ToLower(a.PromotionPieceType.ToAlgebraic())
+	}
+	return s
+}
+
+// findAction returns the legal action of the given UCI move. Castling is accepted
+// both as the king's move and as king takes rook, but only as the latter when
+// UCI_Chess960 is set: in Chess960, the king's move may be a move of its own.
+func (e *Engine) findAction(g core.Game, move string) (core.Action, bool) {
+	for _, a := range g.Actions {
+		if !a.IsMove() {
+			continue
+		}
+		if move == e.moveString(g, a) {
+			return a, true
+		}
+		if a.IsCastle && move == a.FromPiece.XY.ToAlgebraic()+g.CastlingRookXY(a.FromPiece.Owner, a.IsKingsideCastle).ToAlgebraic() {
+			return a, true
+		}
+	}
+	return core.Action{}, false
+}
+
+// println writes a line to the output.
+func (e *Engine) println(s string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	fmt.Fprintln(e.out, s)
+}
@@ -0,0 +1,242 @@
+package uci
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marianogappa/cheesse/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runSession pipes a scripted session through a new engine, and returns it along
+// with its output lines.
+func runSession(t *testing.T, script ...string) (*Engine, []string) {
+	t.Helper()
+	var out bytes.Buffer
+	e := New(&out)
+	require.NoError(t, e.Run(strings.NewReader(strings.Join(script, "\n"))))
+	return e, strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+func lastLine(lines []string) string {
+	return lines[len(lines)-1]
+}
+
+func TestHandshake(t *testing.T) {
+	_, lines := runSession(t, "uci", "isready", "quit")
+	assert.Equal(t, []string{
+		"id name cheesse",
+		"id author Mariano Gappa",
+		"option name Hash type spin default 16 min 1 max 1024",
+		"option name UCI_Chess960 type check default false",
+		"option name SyzygyPath type string default <empty>",
+		"uciok",
+		"readyok",
+	}, lines)
+}
+
+func TestPosition(t *testing.T) {
+	ts := []struct {
+		name        string
+		script      []string
+		expectedFEN string
+	}{
+		{
+			name:        "startpos with moves",
+			script:      []string{"position startpos moves e2e4 e7e5 g1f3"},
+			expectedFEN: "rnbqkbnr/pppp1ppp/8/4p3/4P3/5N2/PPPP1PPP/RNBQKB1R b KQkq - 1 2",
+		},
+		{
+			name:        "fen with moves",
+			script:      []string{"position fen 7k/5P2/8/8/8/8/1K6/8 w - - 0 1 moves f7f8n"},
+			expectedFEN: "5N1k/8/8/8/8/8/1K6/8 b - - 0 1",
+		},
+		{
+			name:        "castling as the king's move",
+			script:      []string{"position fen r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1 moves e1g1 e8c8"},
+			expectedFEN: "2kr3r/8/8/8/8/8/8/R4RK1 w - - 2 2",
+		},
+		{
+			name:        "Chess960 castling as king takes rook",
+			script:      []string{"setoption name UCI_Chess960 value true", "position fen 4k3/8/8/8/8/8/8/1R3KR1 w KQ - 0 1 moves f1g1"},
+			expectedFEN: "4k3/8/8/8/8/8/8/1R3RK1 b - - 1 1",
+		},
+		{
+			name:        "Chess960 king move to the castled king's square",
+			script:      []string{"setoption name UCI_Chess960 value true", "position fen 4k3/8/8/8/8/8/8/R2K4 w A - 0 1 moves d1c1"},
+			expectedFEN: "4k3/8/8/8/8/8/8/R1K5 b - - 1 1",
+		},
+		{
+			name:        "Chess960 castling next to the king",
+			script:      []string{"setoption name UCI_Chess960 value true", "position fen 4k3/8/8/8/8/8/8/R2K4 w A - 0 1 moves d1a1"},
+			expectedFEN: "4k3/8/8/8/8/8/8/2KR4 b - - 1 1",
+		},
+		{
+			name:        "Chess960 castling isn't the king's move",
+			script:      []string{"setoption name UCI_Chess960 value true", "position fen 4k3/8/8/8/8/8/8/4K2R w H - 0 1", "position fen 4k3/8/8/8/8/8/8/4K2R w H - 0 1 moves e1g1"},
+			expectedFEN: "4k3/8/8/8/8/8/8/4K2R w K - 0 1",
+		},
+		{
+			name:        "ucinewgame resets the position",
+			script:      []string{"position startpos moves e2e4", "ucinewgame"},
+			expectedFEN: "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+		},
+		{
+			name:        "an illegal move keeps the previous position",
+			script:      []string{"position startpos moves e2e4", "position startpos moves e2e5"},
+			expectedFEN: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1",
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			e, _ := runSession(t, tc.script...)
+			assert.Equal(t, tc.expectedFEN, e.game.ToFEN())
+		})
+	}
+
+	t.Run("errors are reported as info strings", func(t *testing.T) {
+		_, lines := runSession(t, "position startpos moves e2e5", "position fen nonsense", "foo")
+		require.Len(t, lines, 3)
+		assert.Contains(t, lines[0], `info string illegal move "e2e5"`)
+		assert.Contains(t, lines[1], "info string invalid fen")
+		assert.Equal(t, `info string unknown command "foo"`, lines[2])
+	})
+}
+
+func TestGo(t *testing.T) {
+	t.Run("depth reports every iteration", func(t *testing.T) {
+		_, lines := runSession(t, "position startpos moves e2e4", "go depth 2")
+		require.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[0], "info depth 1 score cp "), lines[0])
+		assert.True(t, strings.HasPrefix(lines[1], "info depth 2 score cp "), lines[1])
+		assert.True(t, strings.HasPrefix(lines[2], "bestmove "), lines[2])
+	})
+
+	t.Run("finds mate in one", func(t *testing.T) {
+		_, lines := runSession(t, "position fen r1bqkb1r/pppp1ppp/2n2n2/4p2Q/2B1P3/8/PPPP1PPP/RNB1K1NR w KQkq - 4 3", "go depth 3")
+		assert.Contains(t, lines[0], "score mate 1 ")
+		assert.Equal(t, "bestmove h5f7", lastLine(lines))
+	})
+
+	t.Run("promotions have the piece suffix", func(t *testing.T) {
+		_, lines := runSession(t, "position fen 7k/5P2/8/8/8/8/1K6/8 w - - 0 1", "go depth 1")
+		assert.Equal(t, "bestmove f7f8q", lastLine(lines))
+	})
+
+	t.Run("Chess960 castling is printed as king takes rook", func(t *testing.T) {
+		g, err := core.NewGameFromFEN("4k3/8/8/8/8/8/8/1R3KR1 w GB - 0 1")
+		require.NoError(t, err)
+		e := New(io.Discard)
+		e.chess960 = true
+		for _, a := range g.Actions {
+			if a.IsCastle && a.IsKingsideCastle {
+				assert.Equal(t, "f1g1", e.moveString(g, a))
+			}
+			if a.IsCastle && a.IsQueensideCastle {
+				assert.Equal(t, "f1b1", e.moveString(g, a))
+			}
+		}
+	})
+
+	t.Run("no legal moves", func(t *testing.T) {
+		_, lines := runSession(t, "position fen r1bqkb1r/pppp1Qpp/2n2n2/4p3/2B1P3/8/PPPP1PPP/RNB1K1NR b KQkq - 0 4", "go depth 2")
+		assert.Equal(t, []string{"bestmove (none)"}, lines)
+	})
+
+	t.Run("the pv is the whole line", func(t *testing.T) {
+		_, lines := runSession(t, "position fen 3r2k1/5ppp/8/8/8/8/4RPPP/4R1K1 w - - 0 1", "go depth 5")
+		assert.Contains(t, lines[len(lines)-2], "score mate 2 ")
+		assert.True(t, strings.HasSuffix(lines[len(lines)-2], " pv e2e8 d8e8 e1e8"), lines[len(lines)-2])
+		assert.Equal(t, "bestmove e2e8", lastLine(lines))
+	})
+
+	t.Run("nodes", func(t *testing.T) {
+		_, lines := runSession(t, "go nodes 300")
+		assert.True(t, strings.HasPrefix(lastLine(lines), "bestmove "), lastLine(lines))
+	})
+
+	t.Run("movetime", func(t *testing.T) {
+		start := time.Now()
+		_, lines := runSession(t, "go movetime 100")
+		assert.Less(t, time.Since(start), 2*time.Second)
+		assert.True(t, strings.HasPrefix(lastLine(lines), "bestmove "), lastLine(lines))
+	})
+
+	t.Run("infinite waits for stop", func(t *testing.T) {
+		var out bytes.Buffer
+		e := New(&out)
+		e.Execute("go infinite")
+		time.Sleep(50 * time.Millisecond)
+		e.mu.Lock()
+		assert.NotContains(t, out.String(), "bestmove")
+		e.mu.Unlock()
+		e.Execute("stop")
+		assert.Contains(t, out.String(), "bestmove ")
+	})
+
+	t.Run("quit stops the search", func(t *testing.T) {
+		_, lines := runSession(t, "go infinite", "quit", "isready")
+		assert.True(t, strings.HasPrefix(lastLine(lines), "bestmove "), lastLine(lines))
+	})
+}
+
+func TestSetOption(t *testing.T) {
+	e, lines := runSession(t, "setoption name Hash value 1", "setoption name Hash value 0", "setoption name Foo value 1")
+	assert.Equal(t, []string{`info string invalid Hash value "0"`, `info string unknown option "Foo"`}, lines)
+	assert.NotNil(t, e.tt)
+}
+
+func TestSetOption_Chess960(t *testing.T) {
+	// Setting it stops the search, which renders its moves according to it
+	start := time.Now()
+	e, lines := runSession(t, "position startpos", "go movetime 60000", "setoption name UCI_Chess960 value true")
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.True(t, strings.HasPrefix(lastLine(lines), "bestmove "), lastLine(lines))
+	assert.True(t, e.chess960)
+}
+
+func TestSetOption_SyzygyPath(t *testing.T) {
+	// A hand-built KQvK WDL table, where the side with the queen always wins
+	dir := t.TempDir()
+	table := []byte{0x71, 0xE8, 0x23, 0x5D, 0x01, 0x00, 0x55, 0x66, 0xEE, 0x00, 0x80, 4, 0x80, 0}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "KQvK.rtbw"), table, 0o644))
+
+	e, lines := runSession(t, "setoption name SyzygyPath value "+dir, "position fen 4k3/8/8/8/8/8/8/3QK3 w - - 0 1", "go depth 2")
+	assert.Equal(t, "info string found Syzygy tables of up to 3 pieces", lines[0])
+	assert.True(t, strings.HasPrefix(lastLine(lines), "bestmove "), lastLine(lines))
+	assert.NotNil(t, e.tablebase)
+
+	e, lines = runSession(t, "setoption name SyzygyPath value "+filepath.Join(dir, "missing"))
+	assert.True(t, strings.HasPrefix(lines[0], "info string "), lines[0])
+	assert.Nil(t, e.tablebase)
+
+	e, _ = runSession(t, "setoption name SyzygyPath value "+dir, "setoption name SyzygyPath value <empty>")
+	assert.Nil(t, e.tablebase)
+}
+
+func TestBudget(t *testing.T) {
+	ts := []struct {
+		name     string
+		args     string
+		turn     core.Color
+		expected time.Duration
+	}{
+		{"movetime", "movetime 1500 wtime 60000", core.ColorWhite, 1500 * time.Millisecond},
+		{"clock", "wtime 60000 btime 30000", core.ColorWhite, 2 * time.Second},
+		{"clock for black with increment", "wtime 60000 btime 30000 binc 1000", core.ColorBlack, 2 * time.Second},
+		{"moves to go", "wtime 60000 movestogo 10", core.ColorWhite, 6 * time.Second},
+		{"at most half of the remaining time", "wtime 1000 winc 2000", core.ColorWhite, 500 * time.Millisecond},
+		{"no time limit", "depth 3 nodes 1000", core.ColorWhite, 0},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseLimits(strings.Fields(tc.args)).budget(tc.turn))
+		})
+	}
+}
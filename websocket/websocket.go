@@ -0,0 +1,318 @@
+// Package websocket implements as much of the WebSocket protocol (RFC 6455) as
+// cheesse's server needs: upgrading HTTP requests to WebSocket connections,
+// dialing them (e.g. from test clients), and exchanging messages over them.
+//
+// It doesn't support extensions (e.g. compression), subprotocols or TLS (wss://).
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// MaxMessageSize is the size of the largest message a Conn reads, in bytes.
+const MaxMessageSize = 1 << 20
+
+// acceptGUID is appended to a handshake's key to compute its accept key.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+var (
+	// ErrNotWebSocket is returned by Upgrade for requests that aren't WebSocket
+	// handshakes.
+	ErrNotWebSocket = errors.New("websocket: not a WebSocket handshake: please send a GET request with Upgrade: websocket and Sec-WebSocket-Version: 13")
+
+	errMessageTooLarge = errors.New("websocket: message too large")
+	errProtocol        = errors.New("websocket: protocol error")
+)
+
+// Conn is a WebSocket connection. It's safe to write to it while reading from it,
+// but not to read from it concurrently.
+type Conn struct {
+	conn     net.Conn
+	br       *bufio.Reader
+	isClient bool
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+}
+
+// Upgrade upgrades the HTTP request to a WebSocket connection, which then belongs
+// to the caller. If it returns an error, the caller may still write the response.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		!headerHasToken(r.Header, "Connection", "upgrade") || !headerHasToken(r.Header, "Upgrade", "websocket") {
+		return nil, ErrNotWebSocket
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("websocket: the response doesn't support hijacking its connection")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, br: brw.Reader}, nil
+}
+
+// Dial opens a WebSocket connection to the given ws:// URL. If the server doesn't
+// upgrade the connection, the error has its response's status and body.
+func Dial(ctx context.Context, rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ws" {
+		return nil, fmt.Errorf("websocket: unsupported URL scheme %q: please use ws://", u.Scheme)
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "80")
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+	httpURL := *u
+	httpURL.Scheme = "http"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, httpURL.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, fmt.Errorf("%w: invalid Sec-WebSocket-Accept", errProtocol)
+	}
+	return &Conn{conn: conn, br: br, isClient: true}, nil
+}
+
+// ReadMessage reads the next text or binary message, answering pings along the
+// way. It returns io.EOF once the peer closes the connection.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var (
+		message   []byte
+		inMessage bool
+	)
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			// Echo the status code, as the closing handshake requires.
+			c.closeWith(payload[:min(len(payload), 2)])
+			return nil, io.EOF
+		case opText, opBinary:
+			if inMessage {
+				return nil, fmt.Errorf("%w: new message before the previous one's end", errProtocol)
+			}
+			inMessage = true
+		case opContinuation:
+			if !inMessage {
+				return nil, fmt.Errorf("%w: continuation frame without a message", errProtocol)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown opcode %#x", errProtocol, opcode)
+		}
+		if len(message)+len(payload) > MaxMessageSize {
+			return nil, errMessageTooLarge
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// WriteMessage writes a text message.
+func (c *Conn) WriteMessage(message []byte) error {
+	return c.writeFrame(opText, message)
+}
+
+// Close closes the connection with a normal closure, without waiting for the
+// peer to answer it.
+func (c *Conn) Close() error {
+	return c.closeWith([]byte{0x03, 0xe8}) // 1000: normal closure
+}
+
+func (c *Conn) closeWith(status []byte) error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		c.writeFrame(opClose, status)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *Conn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode := header[0]&0x80 != 0, header[0]&0x0f
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: reserved bits set without an extension", errProtocol)
+	}
+	// Clients mask their frames, and servers don't.
+	if isMasked := header[1]&0x80 != 0; isMasked == c.isClient {
+		return false, 0, nil, fmt.Errorf("%w: invalid masking", errProtocol)
+	}
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err := io.ReadFull(c.br, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err := io.ReadFull(c.br, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if opcode >= opClose && (!fin || length > 125) {
+		return false, 0, nil, fmt.Errorf("%w: invalid control frame", errProtocol)
+	}
+	if length > MaxMessageSize {
+		return false, 0, nil, errMessageTooLarge
+	}
+	var mask [4]byte
+	if !c.isClient {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if !c.isClient {
+		maskPayload(payload, mask)
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|opcode)
+	var maskBit byte
+	if c.isClient {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	if c.isClient {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		maskPayload(frame[start:], mask)
+	} else {
+		frame = append(frame, payload...)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+func maskPayload(payload []byte, mask [4]byte) {
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+}
+
+func acceptKey(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// headerHasToken returns whether the comma-separated header has the given token,
+// case-insensitively.
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
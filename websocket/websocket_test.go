@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEchoServer starts a server that echoes the messages of its WebSocket
+// connections, and returns its ws:// URL.
+func newEchoServer(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(message) == "close" {
+				return
+			}
+			if err := conn.WriteMessage(message); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestConn(t *testing.T) {
+	conn, err := Dial(context.Background(), newEchoServer(t))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Payload lengths of each of the three length encodings
+	for _, message := range []string{"", "e4", strings.Repeat("e", 126), strings.Repeat("e", 70000)} {
+		require.NoError(t, conn.WriteMessage([]byte(message)))
+		echoed, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, message, string(echoed))
+	}
+
+	t.Run("pings are answered, and fragmented messages put back together", func(t *testing.T) {
+		require.NoError(t, conn.writeFrame(opPing, []byte("ping")))
+		frame := func(fin bool, opcode byte, payload string) {
+			first := opcode
+			if fin {
+				first |= 0x80
+			}
+			mask := [4]byte{1, 2, 3, 4}
+			masked := []byte(payload)
+			maskPayload(masked, mask)
+			_, err := conn.conn.Write(append(append([]byte{first, 0x80 | byte(len(payload))}, mask[:]...), masked...))
+			require.NoError(t, err)
+		}
+		frame(false, opText, "1. e4 ")
+		frame(true, opPing, "")
+		frame(true, opContinuation, "e5")
+		echoed, err := conn.ReadMessage()
+		require.NoError(t, err)
+		assert.Equal(t, "1. e4 e5", string(echoed), "the pongs were skipped")
+	})
+
+	t.Run("the peer closing the connection ends reading", func(t *testing.T) {
+		require.NoError(t, conn.WriteMessage([]byte("close")))
+		_, err := conn.ReadMessage()
+		assert.Equal(t, io.EOF, err)
+	})
+}
+
+func TestUpgradeErrors(t *testing.T) {
+	url := newEchoServer(t)
+	resp, err := http.Get("http" + strings.TrimPrefix(url, "ws"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	_, err = Dial(context.Background(), "http"+strings.TrimPrefix(url, "ws"))
+	assert.Error(t, err)
+}
+
+func TestMessageTooLarge(t *testing.T) {
+	conn, err := Dial(context.Background(), newEchoServer(t))
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.WriteMessage(make([]byte, MaxMessageSize+1)) // The server may hang up before reading it all
+	_, err = conn.ReadMessage()
+	assert.Error(t, err, "the server hung up")
+}